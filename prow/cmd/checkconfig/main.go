@@ -0,0 +1,285 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Checkconfig loads the prow config and plugin config together and prints
+// every problem it finds as a JSON list, so that config changes can be gated
+// in presubmit. It exits non-zero if there are any errors.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/kube"
+	"k8s.io/test-infra/prow/plugins"
+
+	_ "k8s.io/test-infra/prow/plugins/assign"
+	_ "k8s.io/test-infra/prow/plugins/cla"
+	_ "k8s.io/test-infra/prow/plugins/close"
+	_ "k8s.io/test-infra/prow/plugins/heart"
+	_ "k8s.io/test-infra/prow/plugins/help"
+	_ "k8s.io/test-infra/prow/plugins/label"
+	_ "k8s.io/test-infra/prow/plugins/lgtm"
+	_ "k8s.io/test-infra/prow/plugins/releasenote"
+	_ "k8s.io/test-infra/prow/plugins/reopen"
+	_ "k8s.io/test-infra/prow/plugins/trigger"
+	_ "k8s.io/test-infra/prow/plugins/yuks"
+)
+
+var (
+	configPath    = flag.String("config-path", "/etc/config/config", "Path to config.yaml.")
+	jobConfigPath = flag.String("job-config-path", "", "Path to a directory or glob of job config files.")
+	pluginConfig  = flag.String("plugin-config", "/etc/plugins/plugins", "Path to plugin config file.")
+	strict        = flag.Bool("strict", false, "Exit non-zero on warnings as well as errors.")
+	testAll       = flag.String("test-all-comment", "@k8s-bot test this", "Comment that the merge bot leaves to rerun every always_run job. Empty skips the check.")
+)
+
+type severity string
+
+const (
+	// Errors break jobs, so checkconfig fails on them.
+	sevError severity = "error"
+	// Warnings are probably mistakes, but prow copes with them.
+	sevWarning severity = "warning"
+)
+
+// problem is one thing wrong with the config.
+type problem struct {
+	Rule     string   `json:"rule"`
+	Severity severity `json:"severity"`
+	Repo     string   `json:"repo,omitempty"`
+	Job      string   `json:"job,omitempty"`
+	Message  string   `json:"message"`
+}
+
+func main() {
+	flag.Parse()
+
+	problems := check(*configPath, *jobConfigPath, *pluginConfig, *testAll)
+	b, err := json.MarshalIndent(problems, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error marshaling problems: %v\n", err)
+		os.Exit(2)
+	}
+	fmt.Println(string(b))
+	for _, p := range problems {
+		if p.Severity == sevError || *strict {
+			os.Exit(1)
+		}
+	}
+}
+
+// check loads both configs and runs every rule against them. Rules that need
+// a config that failed to load are skipped. Every always_run job must match
+// testAll unless it is empty.
+func check(configPath, jobConfigPath, pluginConfig, testAll string) []problem {
+	problems := []problem{}
+	c, err := config.Load(configPath, jobConfigPath)
+	if err != nil {
+		problems = append(problems, problem{
+			Rule:     "load-config",
+			Severity: sevError,
+			Message:  err.Error(),
+		})
+	}
+	pa := &plugins.PluginAgent{}
+	if err := pa.Load(pluginConfig); err != nil {
+		problems = append(problems, problem{
+			Rule:     "load-plugins",
+			Severity: sevError,
+			Message:  fmt.Sprintf("error loading %s: %v", pluginConfig, err),
+		})
+		pa = nil
+	}
+	if c != nil {
+		problems = append(problems, checkPresubmits(c, pa, testAll)...)
+		problems = append(problems, checkPostsubmits(c)...)
+		problems = append(problems, checkPeriodics(c)...)
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Repo != problems[j].Repo {
+			return problems[i].Repo < problems[j].Repo
+		}
+		return problems[i].Job < problems[j].Job
+	})
+	return problems
+}
+
+// presubmit is a presubmit along with whether it runs after another job.
+type presubmit struct {
+	config.Presubmit
+	child bool
+}
+
+func flattenPresubmits(js []config.Presubmit, child bool) []presubmit {
+	var res []presubmit
+	for _, j := range js {
+		res = append(res, presubmit{j, child})
+		res = append(res, flattenPresubmits(j.RunAfterSuccess, true)...)
+	}
+	return res
+}
+
+func checkPresubmits(c *config.Config, pa *plugins.PluginAgent, testAll string) []problem {
+	var problems []problem
+	add := func(rule string, sev severity, repo, job, format string, args ...interface{}) {
+		problems = append(problems, problem{
+			Rule:     rule,
+			Severity: sev,
+			Repo:     repo,
+			Job:      job,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+	for repo, rootJobs := range c.Presubmits {
+		if pa != nil && len(rootJobs) > 0 {
+			parts := strings.SplitN(repo, "/", 2)
+			if len(parts) != 2 {
+				add("repo-name", sevError, repo, "", "presubmits must be keyed by org/repo")
+			} else if _, ok := pa.PullRequestHandlers(parts[0], parts[1])["trigger"]; !ok {
+				add("no-trigger-plugin", sevError, repo, "", "the trigger plugin is not enabled, so these presubmits never run")
+			}
+		}
+		jobs := flattenPresubmits(rootJobs, false)
+		for i, job := range jobs {
+			if job.Name == "" {
+				add("missing-name", sevError, repo, "", "a presubmit has no name")
+			}
+			if job.Context == "" && !job.SkipReport {
+				add("missing-context", sevError, repo, job.Name, "no context to report status with")
+			}
+			if job.child && (job.AlwaysRun || job.RunIfChanged != "") {
+				add("child-always-run", sevError, repo, job.Name, "run_after_success jobs run when their parent passes and cannot set always_run or run_if_changed")
+			}
+			if job.AlwaysRun && job.RunIfChanged != "" {
+				add("always-run-if-changed", sevWarning, repo, job.Name, "run_if_changed is ignored because always_run is set")
+			}
+			if job.AlwaysRun && testAll != "" && !job.TriggerMatches(testAll) {
+				add("always-run-trigger", sevError, repo, job.Name, "trigger %q does not match %q, so the merge bot cannot rerun it", job.Trigger, testAll)
+			}
+			if job.Trigger == "" {
+				add("missing-trigger", sevError, repo, job.Name, "an empty trigger matches every comment")
+			}
+			if job.RerunCommand == "" {
+				add("missing-rerun-command", sevError, repo, job.Name, "no rerun_command to tell users about")
+			} else {
+				if !job.TriggerMatches(job.RerunCommand) {
+					add("rerun-command", sevError, repo, job.Name, "rerun_command %q does not match trigger %q", job.RerunCommand, job.Trigger)
+				}
+				for j, other := range jobs {
+					if i != j && other.Trigger != "" && other.TriggerMatches(job.RerunCommand) {
+						add("rerun-command-conflict", sevError, repo, job.Name, "rerun_command %q also triggers %s", job.RerunCommand, other.Name)
+					}
+				}
+			}
+			problems = append(problems, checkBranches(repo, job.Name, job.Brancher)...)
+			problems = append(problems, checkSpec(repo, job.Name, job.Spec)...)
+		}
+	}
+	return problems
+}
+
+func checkPostsubmits(c *config.Config) []problem {
+	var problems []problem
+	var walk func(repo string, js []config.Postsubmit)
+	walk = func(repo string, js []config.Postsubmit) {
+		for _, job := range js {
+			if job.Name == "" {
+				problems = append(problems, problem{
+					Rule:     "missing-name",
+					Severity: sevError,
+					Repo:     repo,
+					Message:  "a postsubmit has no name",
+				})
+			}
+			problems = append(problems, checkBranches(repo, job.Name, job.Brancher)...)
+			problems = append(problems, checkSpec(repo, job.Name, job.Spec)...)
+			walk(repo, job.RunAfterSuccess)
+		}
+	}
+	for repo, js := range c.Postsubmits {
+		walk(repo, js)
+	}
+	return problems
+}
+
+func checkPeriodics(c *config.Config) []problem {
+	var problems []problem
+	var walk func(js []config.Periodic)
+	walk = func(js []config.Periodic) {
+		for _, job := range js {
+			if job.Name == "" {
+				problems = append(problems, problem{
+					Rule:     "missing-name",
+					Severity: sevError,
+					Message:  "a periodic has no name",
+				})
+			}
+			problems = append(problems, checkSpec("", job.Name, job.Spec)...)
+			walk(job.RunAfterSuccess)
+		}
+	}
+	walk(c.Periodics)
+	return problems
+}
+
+func checkBranches(repo, job string, br config.Brancher) []problem {
+	if len(br.Branches) > 0 && len(br.SkipBranches) > 0 {
+		return []problem{{
+			Rule:     "branches",
+			Severity: sevWarning,
+			Repo:     repo,
+			Job:      job,
+			Message:  "both branches and skip_branches are set, so only branches in branches and not in skip_branches run",
+		}}
+	}
+	return nil
+}
+
+// checkSpec checks that a pod spec can actually start. Jobs without a spec
+// run on Jenkins.
+func checkSpec(repo, job string, spec *kube.PodSpec) []problem {
+	if spec == nil {
+		return nil
+	}
+	var problems []problem
+	if len(spec.Containers) == 0 {
+		problems = append(problems, problem{
+			Rule:     "pod-spec",
+			Severity: sevError,
+			Repo:     repo,
+			Job:      job,
+			Message:  "spec has no containers",
+		})
+	}
+	for i, c := range spec.Containers {
+		if c.Image == "" {
+			problems = append(problems, problem{
+				Rule:     "pod-spec",
+				Severity: sevError,
+				Repo:     repo,
+				Job:      job,
+				Message:  fmt.Sprintf("container %d has no image", i),
+			})
+		}
+	}
+	return problems
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+const goodPlugins = `plugins:
+  org/repo:
+  - trigger
+`
+
+const goodJob = `  - name: unit
+    context: unit
+    always_run: true
+    trigger: "@k8s-bot (unit )?test this"
+    rerun_command: "@k8s-bot unit test this"
+`
+
+func TestCheck(t *testing.T) {
+	var testcases = []struct {
+		name    string
+		config  string
+		plugins string
+		// testAll defaults to @k8s-bot test this.
+		testAll     string
+		skipTestAll bool
+		expected    []string
+	}{
+		{
+			name:    "good config",
+			config:  "presubmits:\n  org/repo:\n" + goodJob,
+			plugins: goodPlugins,
+		},
+		{
+			name:     "unparseable config",
+			config:   "presubmits: [",
+			plugins:  goodPlugins,
+			expected: []string{"load-config"},
+		},
+		{
+			name:     "unknown plugin",
+			config:   "presubmits:\n  org/repo:\n" + goodJob,
+			plugins:  "plugins:\n  org/repo:\n  - nope\n",
+			expected: []string{"load-plugins"},
+		},
+		{
+			name:     "invalid plugin settings",
+			config:   "presubmits:\n  org/repo:\n" + goodJob,
+			plugins:  goodPlugins + "triggers:\n- repos: [org]\n- repos: [org]\n",
+			expected: []string{"load-plugins"},
+		},
+		{
+			name:     "trigger plugin disabled",
+			config:   "presubmits:\n  org/repo:\n" + goodJob,
+			plugins:  "plugins:\n  org/repo:\n  - lgtm\n",
+			expected: []string{"no-trigger-plugin"},
+		},
+		{
+			name: "rerun command does not match",
+			config: `presubmits:
+  org/repo:
+  - name: e2e
+    context: e2e
+    trigger: "@k8s-bot e2e test this"
+    rerun_command: "@k8s-bot e2e retest this"
+`,
+			plugins:  goodPlugins,
+			expected: []string{"rerun-command"},
+		},
+		{
+			name: "rerun command runs another job",
+			config: "presubmits:\n  org/repo:\n" + goodJob + `  - name: unit-race
+    context: unit-race
+    trigger: "@k8s-bot unit (race )?test this"
+    rerun_command: "@k8s-bot unit race test this"
+`,
+			plugins:  goodPlugins,
+			expected: []string{"rerun-command-conflict"},
+		},
+		{
+			name: "child with always_run",
+			config: `presubmits:
+  org/repo:
+  - name: build
+    context: build
+    trigger: "@k8s-bot build test this"
+    rerun_command: "@k8s-bot build test this"
+    run_after_success:
+    - name: e2e
+      context: e2e
+      always_run: true
+      trigger: "@k8s-bot (e2e )?test this"
+      rerun_command: "@k8s-bot e2e test this"
+`,
+			plugins:  goodPlugins,
+			expected: []string{"child-always-run"},
+		},
+		{
+			name: "always_run job the merge bot cannot rerun",
+			config: `presubmits:
+  org/repo:
+  - name: e2e
+    context: e2e
+    always_run: true
+    run_if_changed: "^e2e/"
+    trigger: "@k8s-bot e2e test this"
+    rerun_command: "@k8s-bot e2e test this"
+    branches:
+    - master
+    skip_branches:
+    - release
+`,
+			plugins:  goodPlugins,
+			expected: []string{"always-run-if-changed", "always-run-trigger", "branches"},
+		},
+		{
+			name: "always_run job for another bot",
+			config: `presubmits:
+  org/repo:
+  - name: unit
+    context: unit
+    always_run: true
+    trigger: "@my-bot (unit )?test this"
+    rerun_command: "@my-bot unit test this"
+`,
+			plugins: goodPlugins,
+			testAll: "@my-bot test this",
+		},
+		{
+			name:     "always_run job for another bot checked against ours",
+			config:   "presubmits:\n  org/repo:\n" + goodJob,
+			plugins:  goodPlugins,
+			testAll:  "@my-bot test this",
+			expected: []string{"always-run-trigger"},
+		},
+		{
+			name: "always_run trigger not checked",
+			config: `presubmits:
+  org/repo:
+  - name: unit
+    context: unit
+    always_run: true
+    trigger: "/test unit"
+    rerun_command: "/test unit"
+`,
+			plugins:     goodPlugins,
+			skipTestAll: true,
+		},
+		{
+			name: "missing fields",
+			config: `presubmits:
+  org/repo:
+  - name: e2e
+`,
+			plugins:  goodPlugins,
+			expected: []string{"missing-context", "missing-rerun-command", "missing-trigger"},
+		},
+		{
+			name: "bad pod specs",
+			config: `postsubmits:
+  org/repo:
+  - name: deploy
+    spec:
+      containers:
+      - args: ["deploy"]
+periodics:
+- name: nightly
+  interval: 24h
+  spec:
+    containers: []
+`,
+			plugins:  goodPlugins,
+			expected: []string{"pod-spec", "pod-spec"},
+		},
+	}
+	for _, tc := range testcases {
+		if tc.testAll == "" && !tc.skipTestAll {
+			tc.testAll = "@k8s-bot test this"
+		}
+		dir, err := ioutil.TempDir("", "checkconfig")
+		if err != nil {
+			t.Fatalf("Error making temp dir: %v", err)
+		}
+		configPath := filepath.Join(dir, "config.yaml")
+		pluginPath := filepath.Join(dir, "plugins.yaml")
+		if err := ioutil.WriteFile(configPath, []byte(tc.config), 0644); err != nil {
+			t.Fatalf("Error writing config: %v", err)
+		}
+		if err := ioutil.WriteFile(pluginPath, []byte(tc.plugins), 0644); err != nil {
+			t.Fatalf("Error writing plugins: %v", err)
+		}
+		problems := check(configPath, "", pluginPath, tc.testAll)
+		os.RemoveAll(dir)
+
+		var rules []string
+		for _, p := range problems {
+			rules = append(rules, p.Rule)
+		}
+		sort.Strings(rules)
+		if len(rules) != len(tc.expected) {
+			t.Errorf("For case %s, expected rules %v, got %+v.", tc.name, tc.expected, problems)
+			continue
+		}
+		for i := range rules {
+			if rules[i] != tc.expected[i] {
+				t.Errorf("For case %s, expected rules %v, got %+v.", tc.name, tc.expected, problems)
+				break
+			}
+		}
+	}
+}
+
+func TestCheckProwConfig(t *testing.T) {
+	if problems := check("../../config.yaml", "", "../../plugins.yaml", "@k8s-bot test this"); len(problems) > 0 {
+		t.Errorf("Expected the prow config to be clean, got %+v.", problems)
+	}
+}
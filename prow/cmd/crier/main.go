@@ -24,37 +24,97 @@ import (
 	"strconv"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/crier"
 	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/kube"
 )
 
 var (
-	port            = flag.Int("port", 8888, "port to listen on")
-	githubBotName   = flag.String("github-bot-name", "", "Name of the GitHub bot.")
-	githubTokenFile = flag.String("github-token-file", "/etc/github/oauth", "Path to the file containing the GitHub OAuth token.")
-	dryRun          = flag.Bool("dry-run", true, "Whether or not to make mutating API calls to GitHub.")
+	port             = flag.Int("port", 8888, "port to listen on")
+	githubBotName    = flag.String("github-bot-name", "", "Name of the GitHub bot.")
+	githubTokenFile  = flag.String("github-token-file", "/etc/github/oauth", "Path to the file containing the GitHub OAuth token.")
+	githubEndpoint   = flag.String("github-endpoint", github.DefaultEndpoint, "Base URL of the GitHub API.")
+	githubAppID      = flag.String("github-app-id", "", "ID of the GitHub App to authenticate as. If set, --github-app-key-file is used instead of --github-token-file.")
+	githubAppKeyFile = flag.String("github-app-key-file", "/etc/github/app-key", "Path to the file containing the GitHub App's private key.")
+	githubTokens     = flag.Int("github-hourly-tokens", 0, "How many GitHub API calls to make per hour at most. If 0, only GitHub's rate limit applies.")
+	githubBurst      = flag.Int("github-burst", 100, "How many GitHub API calls to allow in a burst when --github-hourly-tokens is set.")
+	smtpUsername     = flag.String("smtp-username", "", "Username to log in to the mail servers of email reporters with. If empty, mail is sent without logging in.")
+	smtpPasswordFile = flag.String("smtp-password-file", "/etc/smtp/password", "Path to the file containing the SMTP password.")
+	dryRun           = flag.Bool("dry-run", true, "Whether or not to make mutating API calls to GitHub.")
+	configPath       = flag.String("config-path", "/etc/config/config", "Path to config.yaml.")
+	jobConfigPath    = flag.String("job-config-path", "", "Path to a directory or glob of job config files.")
 )
 
 func main() {
 	flag.Parse()
 	logrus.SetFormatter(&logrus.JSONFormatter{})
 
-	oauthSecretRaw, err := ioutil.ReadFile(*githubTokenFile)
-	if err != nil {
-		logrus.WithError(err).Fatalf("Could not read oauth secret file.")
+	ca := &config.ConfigAgent{}
+	if err := ca.Start(*configPath, *jobConfigPath); err != nil {
+		logrus.WithError(err).Fatal("Error starting config agent.")
 	}
-	oauthSecret := string(bytes.TrimSpace(oauthSecretRaw))
 
 	var ghc *github.Client
-	if *dryRun {
-		ghc = github.NewDryRunClient(*githubBotName, oauthSecret)
+	if *githubAppID != "" {
+		appKey, err := ioutil.ReadFile(*githubAppKeyFile)
+		if err != nil {
+			logrus.WithError(err).Fatal("Could not read GitHub App private key file.")
+		}
+		if *dryRun {
+			ghc, err = github.NewDryRunAppClient(*githubBotName, *githubAppID, appKey, *githubEndpoint)
+		} else {
+			ghc, err = github.NewAppClient(*githubBotName, *githubAppID, appKey, *githubEndpoint)
+		}
+		if err != nil {
+			logrus.WithError(err).Fatal("Error creating GitHub App client.")
+		}
 	} else {
-		ghc = github.NewClient(*githubBotName, oauthSecret)
+		oauthSecretRaw, err := ioutil.ReadFile(*githubTokenFile)
+		if err != nil {
+			logrus.WithError(err).Fatalf("Could not read oauth secret file.")
+		}
+		oauthSecret := string(bytes.TrimSpace(oauthSecretRaw))
+
+		if *dryRun {
+			ghc = github.NewDryRunClient(*githubBotName, oauthSecret, *githubEndpoint)
+		} else {
+			ghc = github.NewClient(*githubBotName, oauthSecret, *githubEndpoint)
+		}
+	}
+	ghc.Throttle(*githubTokens, *githubBurst)
+
+	kc, err := kube.NewClientInCluster("default")
+	if err != nil {
+		logrus.WithError(err).Fatal("Error getting kube client.")
+	}
+	stop := make(chan struct{})
+	kca := kube.NewCache(kc)
+	if err := kca.Start(stop); err != nil {
+		logrus.WithError(err).Fatal("Error starting kube cache.")
 	}
 
-	cs := crier.NewServer(ghc)
-	cs.Run()
+	mux := http.NewServeMux()
+	mux.Handle("/config", ca)
+	mux.Handle("/metrics", promhttp.Handler())
+	go func() {
+		logrus.Fatal(http.ListenAndServe(":"+strconv.Itoa(*port), mux))
+	}()
+
+	var creds crier.SMTPCredentials
+	if *smtpUsername != "" {
+		password, err := ioutil.ReadFile(*smtpPasswordFile)
+		if err != nil {
+			logrus.WithError(err).Fatal("Could not read SMTP password file.")
+		}
+		creds = crier.SMTPCredentials{
+			Username: *smtpUsername,
+			Password: string(bytes.TrimSpace(password)),
+		}
+	}
 
-	logrus.Fatal(http.ListenAndServe(":"+strconv.Itoa(*port), cs))
+	c := crier.NewController(kca, ghc, ca, creds)
+	c.Run(stop)
 }
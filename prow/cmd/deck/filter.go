@@ -0,0 +1,243 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPerPage = 100
+	maxPerPage     = 1000
+)
+
+// jobFilter selects jobs by the query parameters of a request. Empty fields
+// match every job.
+type jobFilter struct {
+	Type   string
+	Repo   string
+	Job    string
+	State  string
+	Author string
+	Pull   int
+	// Since and Until bound when the job started.
+	Since time.Time
+	Until time.Time
+}
+
+// parseFilter reads type, repo, job, state, author, pull, since and until from
+// the query. Since and until are either RFC 3339 times or durations before
+// now, such as "24h".
+func parseFilter(q url.Values, now time.Time) (jobFilter, error) {
+	f := jobFilter{
+		Type:   q.Get("type"),
+		Repo:   q.Get("repo"),
+		Job:    q.Get("job"),
+		State:  q.Get("state"),
+		Author: q.Get("author"),
+	}
+	if p := q.Get("pull"); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil || n <= 0 {
+			return jobFilter{}, fmt.Errorf("invalid pull %q", p)
+		}
+		f.Pull = n
+	}
+	var err error
+	if f.Since, err = parseTime(q.Get("since"), now); err != nil {
+		return jobFilter{}, fmt.Errorf("invalid since: %v", err)
+	}
+	if f.Until, err = parseTime(q.Get("until"), now); err != nil {
+		return jobFilter{}, fmt.Errorf("invalid until: %v", err)
+	}
+	return f, nil
+}
+
+func parseTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func (f jobFilter) matches(j Job) bool {
+	if f.Type != "" && j.Type != f.Type {
+		return false
+	}
+	if f.Repo != "" && j.Repo != f.Repo {
+		return false
+	}
+	if f.Job != "" && j.Job != f.Job {
+		return false
+	}
+	if f.State != "" && j.State != f.State {
+		return false
+	}
+	if f.Author != "" && !containsString(j.authors, f.Author) {
+		return false
+	}
+	if f.Pull != 0 && !containsInt(j.pulls, f.Pull) {
+		return false
+	}
+	if !f.Since.IsZero() && j.st.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && j.st.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(ns []int, n int) bool {
+	for _, v := range ns {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
+
+// filterJobs returns the jobs that match, in the same order.
+func filterJobs(jobs []Job, f jobFilter) []Job {
+	var res []Job
+	for _, j := range jobs {
+		if f.matches(j) {
+			res = append(res, j)
+		}
+	}
+	return res
+}
+
+// parsePage reads the 1-based page and per_page from the query.
+func parsePage(q url.Values) (int, int, error) {
+	page, perPage := 1, defaultPerPage
+	if p := q.Get("page"); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 1 {
+			return 0, 0, fmt.Errorf("invalid page %q", p)
+		}
+		page = n
+	}
+	if p := q.Get("per_page"); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 1 || n > maxPerPage {
+			return 0, 0, fmt.Errorf("invalid per_page %q, must be between 1 and %d", p, maxPerPage)
+		}
+		perPage = n
+	}
+	return page, perPage, nil
+}
+
+// paginate returns the jobs on the page. Pages past the end are empty.
+func paginate(jobs []Job, page, perPage int) []Job {
+	// Compare before multiplying, since page can be huge.
+	if page-1 >= (len(jobs)+perPage-1)/perPage {
+		return []Job{}
+	}
+	start := (page - 1) * perPage
+	end := start + perPage
+	if end > len(jobs) {
+		end = len(jobs)
+	}
+	return jobs[start:end]
+}
+
+// JobPage is a page of jobs along with what the filters can be set to.
+type JobPage struct {
+	Jobs    []Job `json:"jobs"`
+	Total   int   `json:"total"`
+	Page    int   `json:"page"`
+	PerPage int   `json:"per_page"`
+
+	// The values of each field among jobs of the requested type, for
+	// filling in the filters.
+	Repos    []string `json:"repos"`
+	JobNames []string `json:"job_names"`
+	Authors  []string `json:"authors"`
+	Pulls    []int    `json:"pulls"`
+	States   []string `json:"states"`
+}
+
+func newJobPage(jobs []Job, f jobFilter, page, perPage int) JobPage {
+	repos := map[string]bool{}
+	names := map[string]bool{}
+	authors := map[string]bool{}
+	pulls := map[int]bool{}
+	states := map[string]bool{}
+	for _, j := range jobs {
+		if f.Type != "" && j.Type != f.Type {
+			continue
+		}
+		if j.Repo != "/" {
+			repos[j.Repo] = true
+		}
+		names[j.Job] = true
+		states[j.State] = true
+		for _, a := range j.authors {
+			authors[a] = true
+		}
+		for _, p := range j.pulls {
+			pulls[p] = true
+		}
+	}
+	matched := filterJobs(jobs, f)
+	jp := JobPage{
+		Jobs:     paginate(matched, page, perPage),
+		Total:    len(matched),
+		Page:     page,
+		PerPage:  perPage,
+		Repos:    sortedKeys(repos),
+		JobNames: sortedKeys(names),
+		Authors:  sortedKeys(authors),
+		States:   sortedKeys(states),
+		Pulls:    []int{},
+	}
+	for p := range pulls {
+		jp.Pulls = append(jp.Pulls, p)
+	}
+	sort.Ints(jp.Pulls)
+	sort.Slice(jp.Authors, func(i, j int) bool {
+		return strings.ToLower(jp.Authors[i]) < strings.ToLower(jp.Authors[j])
+	})
+	return jp
+}
+
+func sortedKeys(m map[string]bool) []string {
+	ks := []string{}
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
@@ -0,0 +1,246 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"math"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseFilter(t *testing.T) {
+	now := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	var testcases = []struct {
+		name     string
+		query    string
+		expected jobFilter
+		err      bool
+	}{
+		{
+			name:  "empty",
+			query: "",
+		},
+		{
+			name:     "fields",
+			query:    "type=presubmit&repo=o/r&job=j&state=success&author=a&pull=5",
+			expected: jobFilter{Type: "presubmit", Repo: "o/r", Job: "j", State: "success", Author: "a", Pull: 5},
+		},
+		{
+			name:     "since duration",
+			query:    "since=24h",
+			expected: jobFilter{Since: now.Add(-24 * time.Hour)},
+		},
+		{
+			name:     "since and until times",
+			query:    "since=2017-05-01T00:00:00Z&until=2017-05-02T00:00:00Z",
+			expected: jobFilter{Since: time.Date(2017, 5, 1, 0, 0, 0, 0, time.UTC), Until: time.Date(2017, 5, 2, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:  "bad pull",
+			query: "pull=abc",
+			err:   true,
+		},
+		{
+			name:  "negative pull",
+			query: "pull=-1",
+			err:   true,
+		},
+		{
+			name:  "bad since",
+			query: "since=yesterday",
+			err:   true,
+		},
+	}
+	for _, tc := range testcases {
+		q, err := url.ParseQuery(tc.query)
+		if err != nil {
+			t.Fatalf("For case %s, bad query: %v", tc.name, err)
+		}
+		f, err := parseFilter(q, now)
+		if tc.err {
+			if err == nil {
+				t.Errorf("For case %s, expected an error.", tc.name)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("For case %s, didn't expect error: %v", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(f, tc.expected) {
+			t.Errorf("For case %s, expected %+v, got %+v", tc.name, tc.expected, f)
+		}
+	}
+}
+
+func testJobs() []Job {
+	now := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	return []Job{
+		{ProwJob: "1", Type: "presubmit", Repo: "o/r", Job: "unit", State: "success", st: now, pulls: []int{1}, authors: []string{"Alice"}},
+		{ProwJob: "2", Type: "presubmit", Repo: "o/r", Job: "e2e", State: "failure", st: now.Add(-time.Hour), pulls: []int{2}, authors: []string{"bob"}},
+		{ProwJob: "3", Type: "batch", Repo: "o/r", Job: "unit", State: "pending", st: now.Add(-2 * time.Hour), pulls: []int{1, 2}, authors: []string{"Alice", "bob"}},
+		{ProwJob: "4", Type: "periodic", Repo: "/", Job: "cleanup", State: "success", st: now.Add(-48 * time.Hour)},
+		{ProwJob: "5", Type: "postsubmit", Repo: "o/other", Job: "unit", State: "error", st: now.Add(-3 * time.Hour)},
+	}
+}
+
+func names(jobs []Job) []string {
+	ns := []string{}
+	for _, j := range jobs {
+		ns = append(ns, j.ProwJob)
+	}
+	return ns
+}
+
+func TestFilterJobs(t *testing.T) {
+	now := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	var testcases = []struct {
+		name     string
+		filter   jobFilter
+		expected []string
+	}{
+		{
+			name:     "everything",
+			expected: []string{"1", "2", "3", "4", "5"},
+		},
+		{
+			name:     "type",
+			filter:   jobFilter{Type: "presubmit"},
+			expected: []string{"1", "2"},
+		},
+		{
+			name:     "repo and job",
+			filter:   jobFilter{Repo: "o/r", Job: "unit"},
+			expected: []string{"1", "3"},
+		},
+		{
+			name:     "state",
+			filter:   jobFilter{State: "success"},
+			expected: []string{"1", "4"},
+		},
+		{
+			name:     "author ignores case",
+			filter:   jobFilter{Author: "alice"},
+			expected: []string{"1", "3"},
+		},
+		{
+			name:     "pull includes batches",
+			filter:   jobFilter{Pull: 2},
+			expected: []string{"2", "3"},
+		},
+		{
+			name:     "since",
+			filter:   jobFilter{Since: now.Add(-90 * time.Minute)},
+			expected: []string{"1", "2"},
+		},
+		{
+			name:     "until",
+			filter:   jobFilter{Until: now.Add(-90 * time.Minute)},
+			expected: []string{"3", "4", "5"},
+		},
+	}
+	for _, tc := range testcases {
+		if got := names(filterJobs(testJobs(), tc.filter)); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("For case %s, expected %v, got %v", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestParsePage(t *testing.T) {
+	var testcases = []struct {
+		query   string
+		page    int
+		perPage int
+		err     bool
+	}{
+		{query: "", page: 1, perPage: defaultPerPage},
+		{query: "page=3&per_page=10", page: 3, perPage: 10},
+		{query: "page=0", err: true},
+		{query: "page=x", err: true},
+		{query: "per_page=0", err: true},
+		{query: "per_page=1001", err: true},
+	}
+	for _, tc := range testcases {
+		q, err := url.ParseQuery(tc.query)
+		if err != nil {
+			t.Fatalf("For query %q, bad query: %v", tc.query, err)
+		}
+		page, perPage, err := parsePage(q)
+		if tc.err {
+			if err == nil {
+				t.Errorf("For query %q, expected an error.", tc.query)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("For query %q, didn't expect error: %v", tc.query, err)
+			continue
+		}
+		if page != tc.page || perPage != tc.perPage {
+			t.Errorf("For query %q, expected page %d of %d, got page %d of %d", tc.query, tc.page, tc.perPage, page, perPage)
+		}
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	var testcases = []struct {
+		page     int
+		perPage  int
+		expected []string
+	}{
+		{page: 1, perPage: 2, expected: []string{"1", "2"}},
+		{page: 3, perPage: 2, expected: []string{"5"}},
+		{page: 4, perPage: 2, expected: []string{}},
+		{page: 1, perPage: 10, expected: []string{"1", "2", "3", "4", "5"}},
+		{page: math.MaxInt64, perPage: 2, expected: []string{}},
+		{page: math.MaxInt64 / 2, perPage: 1000, expected: []string{}},
+	}
+	for _, tc := range testcases {
+		if got := names(paginate(testJobs(), tc.page, tc.perPage)); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("For page %d of %d, expected %v, got %v", tc.page, tc.perPage, tc.expected, got)
+		}
+	}
+}
+
+func TestNewJobPage(t *testing.T) {
+	jp := newJobPage(testJobs(), jobFilter{Type: "batch", State: "success"}, 1, 10)
+	if jp.Total != 0 || len(jp.Jobs) != 0 {
+		t.Errorf("Expected no jobs, got %d: %v", jp.Total, names(jp.Jobs))
+	}
+	// The options only come from jobs of the requested type, but don't
+	// depend on the other filters.
+	if !reflect.DeepEqual(jp.States, []string{"pending"}) {
+		t.Errorf("Expected states [pending], got %v", jp.States)
+	}
+	if !reflect.DeepEqual(jp.Pulls, []int{1, 2}) {
+		t.Errorf("Expected pulls [1 2], got %v", jp.Pulls)
+	}
+	if !reflect.DeepEqual(jp.Authors, []string{"Alice", "bob"}) {
+		t.Errorf("Expected authors [Alice bob], got %v", jp.Authors)
+	}
+
+	jp = newJobPage(testJobs(), jobFilter{}, 2, 2)
+	if jp.Total != 5 || !reflect.DeepEqual(names(jp.Jobs), []string{"3", "4"}) {
+		t.Errorf("Expected jobs [3 4] of 5, got %v of %d", names(jp.Jobs), jp.Total)
+	}
+	if !reflect.DeepEqual(jp.Repos, []string{"o/other", "o/r"}) {
+		t.Errorf("Expected repos [o/other o/r], got %v", jp.Repos)
+	}
+	if !reflect.DeepEqual(jp.JobNames, []string{"cleanup", "e2e", "unit"}) {
+		t.Errorf("Expected job names [cleanup e2e unit], got %v", jp.JobNames)
+	}
+}
@@ -18,6 +18,7 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"regexp"
 	"sort"
 	"strconv"
@@ -26,8 +27,10 @@ import (
 
 	"github.com/Sirupsen/logrus"
 
+	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/jenkins"
 	"k8s.io/test-infra/prow/kube"
+	"k8s.io/test-infra/prow/plank"
 )
 
 const (
@@ -54,14 +57,33 @@ type Job struct {
 	PodName     string `json:"pod_name"`
 	Agent       string `json:"agent"`
 	ProwJob     string `json:"prow_job"`
+	// QueuePosition is the place in line of a job that is waiting to start.
+	QueuePosition int `json:"queue_position,omitempty"`
+	// PRHistoryURL links to all results for the PR, for presubmits.
+	PRHistoryURL string `json:"pr_history_url,omitempty"`
 
-	st time.Time
-	ft time.Time
+	st      time.Time
+	ft      time.Time
+	pulls   []int
+	authors []string
+}
+
+type kubeCache interface {
+	ListProwJobs(map[string]string) ([]kube.ProwJob, error)
+	GetProwJob(string) (kube.ProwJob, error)
+	GetPod(string) (kube.Pod, error)
+}
+
+type configAgent interface {
+	Config() *config.Config
 }
 
 type JobAgent struct {
 	kc      *kube.Client
+	cache   kubeCache
 	jc      *jenkins.Client
+	ca      configAgent
+	logs    *logCache
 	jobs    []Job
 	jobsMap map[string]Job // pod name -> Job
 	mut     sync.Mutex
@@ -87,31 +109,170 @@ func (ja *JobAgent) Jobs() []Job {
 
 var jobNameRE = regexp.MustCompile(`^([\w-]+)-(\d+)$`)
 
-func (ja *JobAgent) GetLog(name string) ([]byte, error) {
+// logPollPeriod is how often we ask Jenkins for more of a running build's log.
+const logPollPeriod = 2 * time.Second
+
+func (ja *JobAgent) job(name string) (Job, bool) {
 	ja.mut.Lock()
+	defer ja.mut.Unlock()
 	job, ok := ja.jobsMap[name]
-	ja.mut.Unlock() // unlock now-- getting the log takes a while!
+	return job, ok
+}
+
+// finished says whether the job's log is complete.
+func finished(job Job) bool {
+	return job.State != string(kube.TriggeredState) && job.State != string(kube.PendingState)
+}
+
+// jenkinsBuild splits the pod name of a Jenkins job into its job and build
+// number.
+func jenkinsBuild(name string) (string, int, error) {
+	m := jobNameRE.FindStringSubmatch(name)
+	if m == nil {
+		return "", 0, fmt.Errorf("invalid job name %s", name)
+	}
+	number, err := strconv.Atoi(m[2])
+	if err != nil {
+		return "", 0, err
+	}
+	return m[1], number, nil
+}
+
+// GetLog returns the whole log of the job. The logs of finished jobs are
+// cached.
+func (ja *JobAgent) GetLog(name string) ([]byte, error) {
+	job, ok := ja.job(name)
 	if !ok {
 		return nil, fmt.Errorf("GetLog found no such job %s", name)
 	}
+	if log, ok := ja.logs.get(name); ok {
+		return log, nil
+	}
+	log, err := ja.fetchLog(job, name)
+	if err != nil {
+		return nil, err
+	}
+	if finished(job) {
+		ja.logs.set(name, log)
+	}
+	return log, nil
+}
+
+func (ja *JobAgent) fetchLog(job Job, name string) ([]byte, error) {
 	if job.Agent == "" || job.Agent == "kubernetes" {
 		// running on Kubernetes
 		return ja.kc.GetLog(name)
 	} else if ja.jc != nil && job.Agent == "jenkins" {
 		// running on Jenkins
-		m := jobNameRE.FindStringSubmatch(name)
-		if m == nil {
-			return nil, fmt.Errorf("GetLog invalid job name %s", name)
-		}
-		number, err := strconv.Atoi(m[2])
+		jenkinsJob, number, err := jenkinsBuild(name)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("GetLog %v", err)
 		}
-		return ja.jc.GetLog(m[1], number)
+		return ja.jc.GetLog(jenkinsJob, number)
 	}
 	return nil, fmt.Errorf("cannot get log for %s", name)
 }
 
+// FollowLog writes the log of the job to w as it is written, starting tail
+// lines from the end if tail is positive. It returns once the job's log ends
+// or stop is closed.
+func (ja *JobAgent) FollowLog(name string, tail int, w io.Writer, stop <-chan struct{}) error {
+	job, ok := ja.job(name)
+	if !ok {
+		return fmt.Errorf("FollowLog found no such job %s", name)
+	}
+	if finished(job) {
+		log, err := ja.GetLog(name)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(tailLines(log, tail))
+		return err
+	}
+	if job.Agent == "" || job.Agent == "kubernetes" {
+		rc, err := ja.kc.StreamLog(name, kube.LogOptions{Follow: true, TailLines: tail}, stop)
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		if _, err := io.Copy(w, rc); err != nil {
+			select {
+			case <-stop:
+				return nil
+			default:
+				return err
+			}
+		}
+		return nil
+	} else if ja.jc != nil && job.Agent == "jenkins" {
+		jenkinsJob, number, err := jenkinsBuild(name)
+		if err != nil {
+			return fmt.Errorf("FollowLog %v", err)
+		}
+		var start int64
+		for {
+			text, next, more, err := ja.jc.GetProgressiveLog(jenkinsJob, number, start)
+			if err != nil {
+				return err
+			}
+			if start == 0 {
+				text = tailLines(text, tail)
+			}
+			if _, err := w.Write(text); err != nil {
+				return err
+			}
+			if !more {
+				return nil
+			}
+			start = next
+			select {
+			case <-stop:
+				return nil
+			case <-time.After(logPollPeriod):
+			}
+		}
+	}
+	return fmt.Errorf("cannot follow log for %s", name)
+}
+
+// ProwJobDetail is what deck shows on the page of a single ProwJob.
+type ProwJobDetail struct {
+	Job    Job                `json:"job"`
+	Spec   kube.ProwJobSpec   `json:"spec"`
+	Status kube.ProwJobStatus `json:"status"`
+	// Pod is the status of the pod that runs the job, if it still exists.
+	Pod *kube.PodStatus `json:"pod,omitempty"`
+}
+
+// ProwJob returns the details of the named ProwJob or a kube.NotFoundError.
+func (ja *JobAgent) ProwJob(name string) (ProwJobDetail, error) {
+	pj, err := ja.cache.GetProwJob(name)
+	if err != nil {
+		return ProwJobDetail{}, err
+	}
+	d := ProwJobDetail{
+		Job:    newJob(pj, 0, ja.ca.Config()),
+		Spec:   pj.Spec,
+		Status: pj.Status,
+	}
+	// The job list knows the queue position.
+	for _, j := range ja.Jobs() {
+		if j.ProwJob == name {
+			d.Job.QueuePosition = j.QueuePosition
+			break
+		}
+	}
+	if pj.Spec.Agent == kube.KubernetesAgent && pj.Status.PodName != "" {
+		pod, err := ja.cache.GetPod(pj.Status.PodName)
+		if err == nil {
+			d.Pod = &pod.Status
+		} else if !kube.IsNotFound(err) {
+			return ProwJobDetail{}, err
+		}
+	}
+	return d, nil
+}
+
 func (ja *JobAgent) tryUpdate() {
 	if err := ja.update(); err != nil {
 		logrus.WithError(err).Warning("Error updating job list.")
@@ -125,44 +286,16 @@ func (a byStartTime) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a byStartTime) Less(i, j int) bool { return a[i].st.After(a[j].st) }
 
 func (ja *JobAgent) update() error {
-	pjs, err := ja.kc.ListProwJobs(nil)
+	pjs, err := ja.cache.ListProwJobs(nil)
 	if err != nil {
 		return err
 	}
+	cfg := ja.ca.Config()
+	positions := plank.QueuePositions(pjs, cfg.Plank.MaxConcurrency)
 	var njs []Job
 	njsMap := map[string]Job{}
 	for _, j := range pjs {
-		nj := Job{
-			Type:    string(j.Spec.Type),
-			Repo:    fmt.Sprintf("%s/%s", j.Spec.Refs.Org, j.Spec.Refs.Repo),
-			Refs:    j.Spec.Refs.String(),
-			BaseRef: j.Spec.Refs.BaseRef,
-			BaseSHA: j.Spec.Refs.BaseSHA,
-			Job:     j.Spec.Job,
-			Context: j.Spec.Context,
-			Agent:   string(j.Spec.Agent),
-			ProwJob: j.Metadata.Name,
-
-			Started:     j.Status.StartTime.Format(time.Stamp),
-			State:       string(j.Status.State),
-			Description: j.Status.Description,
-			PodName:     j.Status.PodName,
-			URL:         j.Status.URL,
-
-			st: j.Status.StartTime,
-			ft: j.Status.CompletionTime,
-		}
-		if !nj.ft.IsZero() {
-			nj.Finished = nj.ft.Format("15:04:05")
-			duration := nj.ft.Sub(nj.st)
-			duration -= duration % time.Second // strip fractional seconds
-			nj.Duration = duration.String()
-		}
-		if len(j.Spec.Refs.Pulls) == 1 {
-			nj.Number = j.Spec.Refs.Pulls[0].Number
-			nj.Author = j.Spec.Refs.Pulls[0].Author
-			nj.PullSHA = j.Spec.Refs.Pulls[0].SHA
-		}
+		nj := newJob(j, positions[j.Metadata.Name], cfg)
 		njs = append(njs, nj)
 		if nj.PodName != "" {
 			njsMap[nj.PodName] = nj
@@ -176,3 +309,50 @@ func (ja *JobAgent) update() error {
 	ja.jobsMap = njsMap
 	return nil
 }
+
+// newJob summarizes the ProwJob for the job list.
+func newJob(j kube.ProwJob, position int, cfg *config.Config) Job {
+	nj := Job{
+		Type:    string(j.Spec.Type),
+		Repo:    fmt.Sprintf("%s/%s", j.Spec.Refs.Org, j.Spec.Refs.Repo),
+		Refs:    j.Spec.Refs.String(),
+		BaseRef: j.Spec.Refs.BaseRef,
+		BaseSHA: j.Spec.Refs.BaseSHA,
+		Job:     j.Spec.Job,
+		Context: j.Spec.Context,
+		Agent:   string(j.Spec.Agent),
+		ProwJob: j.Metadata.Name,
+
+		QueuePosition: position,
+
+		Started:     j.Status.StartTime.Format(time.Stamp),
+		State:       string(j.Status.State),
+		Description: j.Status.Description,
+		PodName:     j.Status.PodName,
+		URL:         j.Status.URL,
+
+		st: j.Status.StartTime,
+		ft: j.Status.CompletionTime,
+	}
+	if !nj.ft.IsZero() {
+		nj.Finished = nj.ft.Format("15:04:05")
+		duration := nj.ft.Sub(nj.st)
+		duration -= duration % time.Second // strip fractional seconds
+		nj.Duration = duration.String()
+	}
+	if len(j.Spec.Refs.Pulls) == 1 {
+		nj.Number = j.Spec.Refs.Pulls[0].Number
+		nj.Author = j.Spec.Refs.Pulls[0].Author
+		nj.PullSHA = j.Spec.Refs.Pulls[0].SHA
+		if u, err := cfg.URLTemplates.PRHistoryURL(j); err == nil {
+			nj.PRHistoryURL = u
+		} else {
+			logrus.WithField("prowjob", j.Metadata.Name).WithError(err).Warning("Error building PR history URL.")
+		}
+	}
+	for _, pull := range j.Spec.Refs.Pulls {
+		nj.pulls = append(nj.pulls, pull.Number)
+		nj.authors = append(nj.authors, pull.Author)
+	}
+	return nj
+}
@@ -0,0 +1,239 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logCache holds the logs of finished jobs, which don't change, up to
+// maxBytes in total. It evicts the least recently used logs first.
+type logCache struct {
+	mut      sync.Mutex
+	maxBytes int
+	bytes    int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type logEntry struct {
+	name string
+	log  []byte
+}
+
+func newLogCache(maxBytes int) *logCache {
+	return &logCache{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    map[string]*list.Element{},
+	}
+}
+
+func (c *logCache) get(name string) ([]byte, bool) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	e, ok := c.items[name]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*logEntry).log, true
+}
+
+// set stores the log unless it is larger than the whole cache.
+func (c *logCache) set(name string, log []byte) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if len(log) > c.maxBytes {
+		return
+	}
+	if e, ok := c.items[name]; ok {
+		c.bytes += len(log) - len(e.Value.(*logEntry).log)
+		e.Value.(*logEntry).log = log
+		c.ll.MoveToFront(e)
+	} else {
+		c.items[name] = c.ll.PushFront(&logEntry{name: name, log: log})
+		c.bytes += len(log)
+	}
+	for c.bytes > c.maxBytes {
+		e := c.ll.Back()
+		c.ll.Remove(e)
+		delete(c.items, e.Value.(*logEntry).name)
+		c.bytes -= len(e.Value.(*logEntry).log)
+	}
+}
+
+// maxClients is how many clients the rate limiter tracks before it forgets
+// the ones that have a full bucket.
+const maxClients = 10000
+
+// rateLimiter gives each client a token bucket of log requests.
+type rateLimiter struct {
+	mut     sync.Mutex
+	rate    float64 // tokens per second
+	burst   float64
+	clients map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter allows each client perMinute requests a minute in bursts of
+// up to burst. It allows everything if either is not positive.
+func newRateLimiter(perMinute, burst int) *rateLimiter {
+	rl := &rateLimiter{clients: map[string]*bucket{}}
+	if perMinute > 0 && burst > 0 {
+		rl.rate = float64(perMinute) / time.Minute.Seconds()
+		rl.burst = float64(burst)
+	}
+	return rl
+}
+
+// allow takes a token for the client at now. If there are none left, it
+// returns false and how long until the next one.
+func (rl *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	rl.mut.Lock()
+	defer rl.mut.Unlock()
+	if rl.rate == 0 {
+		return true, 0
+	}
+	b, ok := rl.clients[client]
+	if !ok {
+		if len(rl.clients) >= maxClients {
+			rl.forget(now)
+		}
+		b = &bucket{tokens: rl.burst, last: now}
+		rl.clients[client] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * rl.rate
+	if b.tokens > rl.burst {
+		b.tokens = rl.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / rl.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// forget drops the clients whose buckets would be full by now, since a new
+// bucket is the same. The caller must hold the lock.
+func (rl *rateLimiter) forget(now time.Time) {
+	for c, b := range rl.clients {
+		if b.tokens+now.Sub(b.last).Seconds()*rl.rate >= rl.burst {
+			delete(rl.clients, c)
+		}
+	}
+}
+
+// clientAddress identifies the client for rate limiting. Behind the ingress
+// every request comes from the load balancer, which appends the client's
+// address and then its own to X-Forwarded-For. Anything before those came
+// from the client, so it can't be trusted.
+func clientAddress(r *http.Request) string {
+	if f := r.Header.Get("X-Forwarded-For"); f != "" {
+		parts := strings.Split(f, ",")
+		if len(parts) >= 2 {
+			return strings.TrimSpace(parts[len(parts)-2])
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// followLimiter caps how many logs each client may follow at once.
+type followLimiter struct {
+	mut  sync.Mutex
+	max  int
+	open map[string]int
+}
+
+// newFollowLimiter allows each client max streams at once. It allows
+// everything if max is not positive.
+func newFollowLimiter(max int) *followLimiter {
+	return &followLimiter{max: max, open: map[string]int{}}
+}
+
+// acquire takes a stream for the client, returning false if it has too many
+// open. Every successful acquire must be followed by a release.
+func (fl *followLimiter) acquire(client string) bool {
+	fl.mut.Lock()
+	defer fl.mut.Unlock()
+	if fl.max > 0 && fl.open[client] >= fl.max {
+		return false
+	}
+	fl.open[client]++
+	return true
+}
+
+func (fl *followLimiter) release(client string) {
+	fl.mut.Lock()
+	defer fl.mut.Unlock()
+	fl.open[client]--
+	if fl.open[client] <= 0 {
+		delete(fl.open, client)
+	}
+}
+
+// tailLines returns the last n lines of the log, or all of it if n is not
+// positive.
+func tailLines(log []byte, n int) []byte {
+	if n <= 0 {
+		return log
+	}
+	end := len(log)
+	// A trailing newline ends the last line rather than starting another.
+	if end > 0 && log[end-1] == '\n' {
+		end--
+	}
+	i := end
+	for ; n > 0; n-- {
+		i = bytes.LastIndexByte(log[:i], '\n')
+		if i < 0 {
+			return log
+		}
+	}
+	return log[i+1:]
+}
+
+// flushWriter flushes after every write so that followers see each line as
+// soon as it is written. It notes whether anything was written, since after
+// that it is too late to reply with an error.
+type flushWriter struct {
+	w     http.ResponseWriter
+	wrote bool
+}
+
+func (fw *flushWriter) Write(b []byte) (int, error) {
+	fw.wrote = true
+	n, err := fw.w.Write(b)
+	if f, ok := fw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return n, err
+}
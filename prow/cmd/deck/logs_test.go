@@ -0,0 +1,144 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLogCache(t *testing.T) {
+	c := newLogCache(10)
+	c.set("a", []byte("aaaa"))
+	c.set("b", []byte("bbbb"))
+	// Using a makes b the least recently used.
+	if _, ok := c.get("a"); !ok {
+		t.Fatal("Expected a to be cached.")
+	}
+	c.set("c", []byte("cccc"))
+	if _, ok := c.get("b"); ok {
+		t.Error("Expected b to be evicted.")
+	}
+	if l, ok := c.get("a"); !ok || string(l) != "aaaa" {
+		t.Errorf("Expected a to be cached, got %q, %t", string(l), ok)
+	}
+	if l, ok := c.get("c"); !ok || string(l) != "cccc" {
+		t.Errorf("Expected c to be cached, got %q, %t", string(l), ok)
+	}
+	c.set("big", []byte("more than ten bytes"))
+	if _, ok := c.get("big"); ok {
+		t.Error("Expected a log larger than the cache not to be cached.")
+	}
+	if c.bytes != 8 {
+		t.Errorf("Expected 8 bytes in the cache, got %d", c.bytes)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	rl := newRateLimiter(60, 2)
+	for i := 0; i < 2; i++ {
+		if ok, _ := rl.allow("a", now); !ok {
+			t.Errorf("Expected request %d to be allowed.", i)
+		}
+	}
+	if ok, wait := rl.allow("a", now); ok || wait != time.Second {
+		t.Errorf("Expected to wait a second, got %t, %v", ok, wait)
+	}
+	if ok, _ := rl.allow("b", now); !ok {
+		t.Error("Expected another client to be allowed.")
+	}
+	if ok, _ := rl.allow("a", now.Add(time.Second)); !ok {
+		t.Error("Expected a request to be allowed after a second.")
+	}
+	// Full buckets are forgotten, and a is the only one that isn't full.
+	rl.forget(now.Add(time.Second))
+	if _, ok := rl.clients["b"]; ok {
+		t.Error("Expected b to be forgotten.")
+	}
+	if _, ok := rl.clients["a"]; !ok {
+		t.Error("Expected a to be remembered.")
+	}
+	if ok, _ := newRateLimiter(0, 0).allow("a", now); !ok {
+		t.Error("Expected a disabled limiter to allow everything.")
+	}
+}
+
+func TestTailLines(t *testing.T) {
+	var testcases = []struct {
+		log      string
+		n        int
+		expected string
+	}{
+		{log: "a\nb\nc\n", n: 0, expected: "a\nb\nc\n"},
+		{log: "a\nb\nc\n", n: 1, expected: "c\n"},
+		{log: "a\nb\nc\n", n: 2, expected: "b\nc\n"},
+		{log: "a\nb\nc\n", n: 5, expected: "a\nb\nc\n"},
+		{log: "a\nb\nc", n: 1, expected: "c"},
+		{log: "", n: 1, expected: ""},
+	}
+	for _, tc := range testcases {
+		if got := string(tailLines([]byte(tc.log), tc.n)); got != tc.expected {
+			t.Errorf("For %q with %d lines, expected %q, got %q", tc.log, tc.n, tc.expected, got)
+		}
+	}
+}
+
+func TestClientAddress(t *testing.T) {
+	req := httptest.NewRequest("GET", "/log", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	if a := clientAddress(req); a != "10.0.0.1" {
+		t.Errorf("Expected 10.0.0.1, got %s", a)
+	}
+	// The load balancer appends the client and then itself. Anything before
+	// that came from the client.
+	req.Header.Set("X-Forwarded-For", "1.2.3.4, 5.6.7.8, 130.211.0.1")
+	if a := clientAddress(req); a != "5.6.7.8" {
+		t.Errorf("Expected 5.6.7.8, got %s", a)
+	}
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+	if a := clientAddress(req); a != "10.0.0.1" {
+		t.Errorf("Expected 10.0.0.1, got %s", a)
+	}
+}
+
+func TestFollowLimiter(t *testing.T) {
+	fl := newFollowLimiter(2)
+	if !fl.acquire("a") || !fl.acquire("a") {
+		t.Fatal("Expected two streams to be allowed.")
+	}
+	if fl.acquire("a") {
+		t.Error("Expected a third stream not to be allowed.")
+	}
+	if !fl.acquire("b") {
+		t.Error("Expected another client to be allowed.")
+	}
+	fl.release("a")
+	if !fl.acquire("a") {
+		t.Error("Expected a stream to be allowed after one was released.")
+	}
+	fl.release("a")
+	fl.release("a")
+	fl.release("b")
+	if len(fl.open) != 0 {
+		t.Errorf("Expected no open streams, got %v", fl.open)
+	}
+	if !newFollowLimiter(0).acquire("a") {
+		t.Error("Expected a disabled limiter to allow everything.")
+	}
+}
@@ -18,17 +18,25 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
 	"net/http"
+	"net/url"
 	"regexp"
+	"strconv"
+	"time"
 
 	"github.com/NYTimes/gziphandler"
 	"github.com/Sirupsen/logrus"
 	"github.com/ghodss/yaml"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/jenkins"
 	"k8s.io/test-infra/prow/kube"
 	"k8s.io/test-infra/prow/plank"
@@ -39,9 +47,20 @@ const (
 )
 
 var (
+	configPath    = flag.String("config-path", "/etc/config/config", "Path to config.yaml.")
+	jobConfigPath = flag.String("job-config-path", "", "Path to a directory or glob of job config files.")
+
 	jenkinsURL       = flag.String("jenkins-url", "", "Jenkins URL")
 	jenkinsUserName  = flag.String("jenkins-user", "jenkins-trigger", "Jenkins username")
 	jenkinsTokenFile = flag.String("jenkins-token-file", "/etc/jenkins/jenkins", "Path to the file containing the Jenkins API token.")
+
+	hookURL = flag.String("hook-url", "http://hook:8888/plugin-help", "URL of hook's plugin help endpoint.")
+
+	logCacheBytes        = flag.Int("log-cache-bytes", 256<<20, "How many bytes of finished jobs' logs to keep in memory.")
+	logRequestsPerMinute = flag.Int("log-requests-per-minute", 60, "How many log requests each client may make a minute. Zero disables the limit.")
+	logBurst             = flag.Int("log-burst", 20, "How many log requests each client may make at once.")
+	logFollowsPerClient  = flag.Int("log-follows-per-client", 5, "How many logs each client may follow at once. Zero disables the limit.")
+	logFollowTimeout     = flag.Duration("log-follow-timeout", time.Hour, "How long a followed log is streamed before it is closed.")
 )
 
 // Matches letters, numbers, hyphens, and underscores.
@@ -52,6 +71,11 @@ func main() {
 
 	logrus.SetFormatter(&logrus.JSONFormatter{})
 
+	ca := &config.ConfigAgent{}
+	if err := ca.Start(*configPath, *jobConfigPath); err != nil {
+		logrus.WithError(err).Fatal("Error starting config agent.")
+	}
+
 	kc, err := kube.NewClientInCluster(namespace)
 	if err != nil {
 		logrus.WithError(err).Fatal("Error getting client.")
@@ -67,62 +91,233 @@ func main() {
 		jc = jenkins.NewClient(*jenkinsURL, *jenkinsUserName, jenkinsToken)
 	}
 
+	kca := kube.NewCache(kc)
+	if err := kca.Start(make(chan struct{})); err != nil {
+		logrus.WithError(err).Fatal("Error starting kube cache.")
+	}
+
 	ja := &JobAgent{
-		kc: kc,
-		jc: jc,
+		kc:    kc,
+		cache: kca,
+		jc:    jc,
+		ca:    ca,
+		logs:  newLogCache(*logCacheBytes),
 	}
 	ja.Start()
 
 	http.Handle("/", gziphandler.GzipHandler(http.FileServer(http.Dir("/static"))))
 	http.Handle("/data.js", gziphandler.GzipHandler(handleData(ja)))
-	http.Handle("/log", gziphandler.GzipHandler(handleLog(ja)))
+	http.Handle("/jobs.js", gziphandler.GzipHandler(handleJobs(ja)))
+	http.Handle("/prowjob-detail.js", gziphandler.GzipHandler(handleProwJob(ja)))
+	http.Handle("/log", handleLogRanges(handleLog(ja, newRateLimiter(*logRequestsPerMinute, *logBurst), newFollowLimiter(*logFollowsPerClient), *logFollowTimeout)))
 	http.Handle("/rerun", gziphandler.GzipHandler(handleRerun(kc)))
+	http.Handle("/plugin-help.js", gziphandler.GzipHandler(handlePluginHelp(&http.Client{Timeout: 10 * time.Second}, *hookURL)))
+	http.Handle("/config", ca)
+	http.Handle("/metrics", promhttp.Handler())
 
 	logrus.WithError(http.ListenAndServe(":http", nil)).Fatal("ListenAndServe returned.")
 }
 
+// handleData writes out the jobs that match the filters in the query. If the
+// query has page or per_page then only that page of jobs is written.
 func handleData(ja *JobAgent) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Cache-Control", "no-cache")
-		jobs := ja.Jobs()
-		jd, err := json.Marshal(jobs)
+		q := r.URL.Query()
+		f, err := parseFilter(q, time.Now())
 		if err != nil {
-			logrus.WithError(err).Error("Error marshaling jobs.")
-			jd = []byte("[]")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		jobs := filterJobs(ja.Jobs(), f)
+		if q.Get("page") != "" || q.Get("per_page") != "" {
+			page, perPage, err := parsePage(q)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			jobs = paginate(jobs, page, perPage)
+		}
+		if jobs == nil {
+			jobs = []Job{}
+		}
+		writeJSON(w, r, jobs)
+	}
+}
+
+// handleJobs writes out a JobPage for the filters and page in the query.
+func handleJobs(ja *JobAgent) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		q := r.URL.Query()
+		f, err := parseFilter(q, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		page, perPage, err := parsePage(q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, r, newJobPage(ja.Jobs(), f, page, perPage))
+	}
+}
+
+type prowJobGetter interface {
+	ProwJob(name string) (ProwJobDetail, error)
+}
+
+// handleProwJob writes out the ProwJobDetail for ?prowjob=name.
+func handleProwJob(pg prowJobGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		name := r.URL.Query().Get("prowjob")
+		if !objReg.MatchString(name) {
+			http.Error(w, "Invalid ProwJob query", http.StatusBadRequest)
+			return
+		}
+		d, err := pg.ProwJob(name)
+		if kube.IsNotFound(err) {
+			http.Error(w, fmt.Sprintf("ProwJob %s not found.", name), http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, fmt.Sprintf("Error getting ProwJob: %v", err), http.StatusInternalServerError)
+			logrus.WithError(err).Warning("Error getting ProwJob.")
+			return
+		}
+		writeJSON(w, r, d)
+	}
+}
+
+// writeJSON writes v as JSON. If the query has a "var" then it writes out
+// "var value = {...};" instead so that pages can load it as a script.
+func writeJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		logrus.WithError(err).Error("Error marshaling JSON.")
+		http.Error(w, fmt.Sprintf("Error marshaling JSON: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if name := r.URL.Query().Get("var"); name != "" {
+		fmt.Fprintf(w, "var %s = %s;", name, string(b))
+	} else {
+		fmt.Fprint(w, string(b))
+	}
+}
+
+// handlePluginHelp fetches the plugin help from hook, optionally for a single
+// ?repo=org/repo, and writes it out like handleData.
+func handlePluginHelp(c *http.Client, hookURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		u, err := url.Parse(hookURL)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid hook URL: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if repo := r.URL.Query().Get("repo"); repo != "" {
+			q := u.Query()
+			q.Set("repo", repo)
+			u.RawQuery = q.Encode()
+		}
+		resp, err := c.Get(u.String())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error getting plugin help: %v", err), http.StatusBadGateway)
+			logrus.WithError(err).Warning("Error getting plugin help.")
+			return
+		}
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error reading plugin help: %v", err), http.StatusBadGateway)
+			return
+		}
+		if resp.StatusCode != http.StatusOK {
+			http.Error(w, fmt.Sprintf("Hook returned %d: %s", resp.StatusCode, string(b)), http.StatusBadGateway)
+			return
 		}
-		// If we have a "var" query, then write out "var value = {...};".
-		// Otherwise, just write out the JSON.
 		if v := r.URL.Query().Get("var"); v != "" {
-			fmt.Fprintf(w, "var %s = %s;", v, string(jd))
+			fmt.Fprintf(w, "var %s = %s;", v, string(b))
 		} else {
-			fmt.Fprintf(w, string(jd))
+			fmt.Fprint(w, string(b))
 		}
 	}
 }
 
 type logClient interface {
 	GetLog(name string) ([]byte, error)
+	FollowLog(name string, tail int, w io.Writer, stop <-chan struct{}) error
 }
 
-// TODO(spxtr): Cache, rate limit, and limit which pods can be logged.
-func handleLog(lc logClient) http.HandlerFunc {
+// handleLog writes out the log of ?pod=name. With tail=n it starts n lines
+// from the end. With follow=true it streams the log until the job finishes,
+// for up to maxFollow, and otherwise it honors Range headers.
+// TODO(spxtr): Limit which pods can be logged.
+func handleLog(lc logClient, rl *rateLimiter, fl *followLimiter, maxFollow time.Duration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Cache-Control", "no-cache")
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		pod := r.URL.Query().Get("pod")
+		q := r.URL.Query()
+		pod := q.Get("pod")
 		if !objReg.MatchString(pod) {
 			http.Error(w, "Invalid pod query", http.StatusBadRequest)
 			return
 		}
+		tail := 0
+		if t := q.Get("tail"); t != "" {
+			n, err := strconv.Atoi(t)
+			if err != nil || n < 0 {
+				http.Error(w, fmt.Sprintf("Invalid tail %q", t), http.StatusBadRequest)
+				return
+			}
+			tail = n
+		}
+		client := clientAddress(r)
+		if ok, wait := rl.allow(client, time.Now()); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "Too many log requests, try again later.", http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if q.Get("follow") == "true" {
+			if !fl.acquire(client) {
+				http.Error(w, "Too many logs followed at once, close some first.", http.StatusTooManyRequests)
+				return
+			}
+			defer fl.release(client)
+			// Streams end after maxFollow so that they can't pile up.
+			ctx, cancel := context.WithTimeout(r.Context(), maxFollow)
+			defer cancel()
+			fw := &flushWriter{w: w}
+			if err := lc.FollowLog(pod, tail, fw, ctx.Done()); err != nil {
+				if !fw.wrote {
+					http.Error(w, fmt.Sprintf("Log not found: %v", err), http.StatusNotFound)
+				}
+				logrus.WithError(err).Warning("Error following log.")
+			}
+			return
+		}
 		log, err := lc.GetLog(pod)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Log not found: %v", err), http.StatusNotFound)
 			logrus.WithError(err).Warning("Error returned.")
 			return
 		}
-		if _, err = w.Write(log); err != nil {
-			logrus.WithError(err).Warning("Error writing log.")
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(tailLines(log, tail)))
+	}
+}
+
+// handleLogRanges serves ranges without gzip, since Content-Range counts the
+// uncompressed bytes.
+func handleLogRanges(h http.HandlerFunc) http.HandlerFunc {
+	gz := gziphandler.GzipHandler(h)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			h(w, r)
+			return
 		}
+		gz.ServeHTTP(w, r)
 	}
 }
 
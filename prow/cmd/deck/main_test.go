@@ -17,12 +17,17 @@ limitations under the License.
 package main
 
 import (
+	"encoding/json"
 	"errors"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ghodss/yaml"
 
@@ -34,11 +39,30 @@ type flc int
 func (f flc) GetLog(name string) ([]byte, error) {
 	if name == "pn" {
 		return []byte("hello"), nil
+	} else if name == "lines" {
+		return []byte("one\ntwo\nthree\n"), nil
 	} else {
 		return nil, errors.New("muahaha")
 	}
 }
 
+func (f flc) FollowLog(name string, tail int, w io.Writer, stop <-chan struct{}) error {
+	if name == "forever" {
+		io.WriteString(w, "started\n")
+		<-stop
+		return nil
+	}
+	if name != "lines" {
+		return errors.New("muahaha")
+	}
+	for _, l := range []string{"one\n", "two\n", "three\n"} {
+		if _, err := io.WriteString(w, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func TestHandleLog(t *testing.T) {
 	var testcases = []struct {
 		name string
@@ -76,7 +100,7 @@ func TestHandleLog(t *testing.T) {
 			code: http.StatusOK,
 		},
 	}
-	handler := handleLog(flc(0))
+	handler := handleLog(flc(0), newRateLimiter(0, 0), newFollowLimiter(0), time.Minute)
 	for _, tc := range testcases {
 		req, err := http.NewRequest(http.MethodGet, "", nil)
 		if err != nil {
@@ -103,6 +127,124 @@ func TestHandleLog(t *testing.T) {
 	}
 }
 
+func TestHandleLogOptions(t *testing.T) {
+	var testcases = []struct {
+		name     string
+		path     string
+		header   http.Header
+		code     int
+		expected string
+	}{
+		{
+			name:     "tail",
+			path:     "/log?pod=lines&tail=2",
+			code:     http.StatusOK,
+			expected: "two\nthree\n",
+		},
+		{
+			name: "bad tail",
+			path: "/log?pod=lines&tail=-1",
+			code: http.StatusBadRequest,
+		},
+		{
+			name:     "range",
+			path:     "/log?pod=lines",
+			header:   http.Header{"Range": []string{"bytes=4-6"}},
+			code:     http.StatusPartialContent,
+			expected: "two",
+		},
+		{
+			name:     "open range",
+			path:     "/log?pod=lines",
+			header:   http.Header{"Range": []string{"bytes=8-"}},
+			code:     http.StatusPartialContent,
+			expected: "three\n",
+		},
+		{
+			name:     "follow",
+			path:     "/log?pod=lines&follow=true",
+			code:     http.StatusOK,
+			expected: "one\ntwo\nthree\n",
+		},
+		{
+			name: "follow a pod that doesn't exist",
+			path: "/log?pod=doesnotexist&follow=true",
+			code: http.StatusNotFound,
+		},
+	}
+	handler := handleLog(flc(0), newRateLimiter(0, 0), newFollowLimiter(0), time.Minute)
+	for _, tc := range testcases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		for k, v := range tc.header {
+			req.Header[k] = v
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tc.code {
+			t.Errorf("For case %s, expected code %d, got %d", tc.name, tc.code, rr.Code)
+			continue
+		}
+		if tc.expected != "" && rr.Body.String() != tc.expected {
+			t.Errorf("For case %s, expected %q, got %q", tc.name, tc.expected, rr.Body.String())
+		}
+	}
+}
+
+func TestHandleLogRateLimit(t *testing.T) {
+	handler := handleLog(flc(0), newRateLimiter(1, 2), newFollowLimiter(0), time.Minute)
+	for i, code := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		req := httptest.NewRequest(http.MethodGet, "/log?pod=pn", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != code {
+			t.Errorf("For request %d, expected code %d, got %d", i, code, rr.Code)
+		}
+		if code == http.StatusTooManyRequests && rr.Header().Get("Retry-After") == "" {
+			t.Errorf("For request %d, expected a Retry-After header.", i)
+		}
+	}
+	// Other clients have their own limit.
+	req := httptest.NewRequest(http.MethodGet, "/log?pod=pn", nil)
+	req.Header.Set("X-Forwarded-For", "10.0.0.1, 130.211.0.1")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected another client to be allowed, got code %d", rr.Code)
+	}
+}
+
+func TestHandleLogFollowLimits(t *testing.T) {
+	fl := newFollowLimiter(1)
+	handler := handleLog(flc(0), newRateLimiter(0, 0), fl, 10*time.Millisecond)
+	// The stream is closed once it has been open too long.
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/log?pod=forever&follow=true", nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "started\n" {
+		t.Errorf("Expected the stream to start, got %d: %q", rr.Code, rr.Body.String())
+	}
+	if len(fl.open) != 0 {
+		t.Errorf("Expected the stream to be released, got %v", fl.open)
+	}
+
+	// A client that already follows as many logs as it may can't follow more.
+	req := httptest.NewRequest(http.MethodGet, "/log?pod=lines&follow=true", nil)
+	client := clientAddress(req)
+	if !fl.acquire(client) {
+		t.Fatal("Expected to acquire a stream.")
+	}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected code %d, got %d", http.StatusTooManyRequests, rr.Code)
+	}
+	fl.release(client)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected code %d once released, got %d", http.StatusOK, rr.Code)
+	}
+}
+
 type fpjc kube.ProwJob
 
 func (fc *fpjc) GetProwJob(name string) (kube.ProwJob, error) {
@@ -147,3 +289,182 @@ func TestRerun(t *testing.T) {
 		t.Errorf("Wrong state, expected \"%v\", got \"%v\"", kube.TriggeredState, res.Status.State)
 	}
 }
+
+func TestHandlePluginHelp(t *testing.T) {
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("repo") {
+		case "":
+			w.Write([]byte(`{"org":{}}`))
+		case "org/repo":
+			w.Write([]byte(`{"lgtm":{}}`))
+		default:
+			http.Error(w, "bad repo", http.StatusBadRequest)
+		}
+	}))
+	defer hook.Close()
+	var testcases = []struct {
+		name     string
+		path     string
+		code     int
+		expected string
+	}{
+		{
+			name:     "all",
+			path:     "/plugin-help.js",
+			code:     http.StatusOK,
+			expected: `{"org":{}}`,
+		},
+		{
+			name:     "one repo as a var",
+			path:     "/plugin-help.js?repo=org/repo&var=help",
+			code:     http.StatusOK,
+			expected: `var help = {"lgtm":{}};`,
+		},
+		{
+			name: "hook error",
+			path: "/plugin-help.js?repo=org",
+			code: http.StatusBadGateway,
+		},
+	}
+	handler := handlePluginHelp(http.DefaultClient, hook.URL+"/plugin-help")
+	for _, tc := range testcases {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if rr.Code != tc.code {
+			t.Errorf("For case %s, expected code %d, got %d", tc.name, tc.code, rr.Code)
+			continue
+		}
+		if tc.code == http.StatusOK && strings.TrimSpace(rr.Body.String()) != tc.expected {
+			t.Errorf("For case %s, expected %s, got %s", tc.name, tc.expected, rr.Body.String())
+		}
+	}
+}
+
+func TestHandleJobs(t *testing.T) {
+	ja := &JobAgent{jobs: testJobs()}
+	var testcases = []struct {
+		name     string
+		path     string
+		code     int
+		expected []string
+		total    int
+	}{
+		{
+			name:     "filtered",
+			path:     "/jobs.js?type=presubmit&author=bob",
+			code:     http.StatusOK,
+			expected: []string{"2"},
+			total:    1,
+		},
+		{
+			name:     "second page",
+			path:     "/jobs.js?page=2&per_page=3",
+			code:     http.StatusOK,
+			expected: []string{"4", "5"},
+			total:    5,
+		},
+		{
+			name:     "page past the end",
+			path:     "/jobs.js?page=9223372036854775807&per_page=2",
+			code:     http.StatusOK,
+			expected: []string{},
+			total:    5,
+		},
+		{
+			name: "bad filter",
+			path: "/jobs.js?pull=abc",
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "bad page",
+			path: "/jobs.js?page=0",
+			code: http.StatusBadRequest,
+		},
+	}
+	handler := handleJobs(ja)
+	for _, tc := range testcases {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if rr.Code != tc.code {
+			t.Errorf("For case %s, expected code %d, got %d", tc.name, tc.code, rr.Code)
+			continue
+		}
+		if tc.code != http.StatusOK {
+			continue
+		}
+		var jp JobPage
+		if err := json.Unmarshal(rr.Body.Bytes(), &jp); err != nil {
+			t.Errorf("For case %s, error unmarshaling: %v", tc.name, err)
+			continue
+		}
+		if got := names(jp.Jobs); !reflect.DeepEqual(got, tc.expected) || jp.Total != tc.total {
+			t.Errorf("For case %s, expected %v of %d, got %v of %d", tc.name, tc.expected, tc.total, got, jp.Total)
+		}
+	}
+}
+
+type fpg map[string]ProwJobDetail
+
+func (f fpg) ProwJob(name string) (ProwJobDetail, error) {
+	d, ok := f[name]
+	if !ok {
+		return ProwJobDetail{}, kube.NotFoundError{}
+	}
+	return d, nil
+}
+
+func TestHandleProwJob(t *testing.T) {
+	pg := fpg{
+		"pj": ProwJobDetail{
+			Job: Job{ProwJob: "pj", Job: "unit"},
+			Status: kube.ProwJobStatus{
+				State: kube.SuccessState,
+				History: []kube.StatusChange{
+					{State: kube.TriggeredState},
+					{State: kube.SuccessState, Description: "Job succeeded."},
+				},
+			},
+		},
+	}
+	var testcases = []struct {
+		name string
+		path string
+		code int
+	}{
+		{
+			name: "found",
+			path: "/prowjob-detail.js?prowjob=pj",
+			code: http.StatusOK,
+		},
+		{
+			name: "not found",
+			path: "/prowjob-detail.js?prowjob=other",
+			code: http.StatusNotFound,
+		},
+		{
+			name: "invalid name",
+			path: "/prowjob-detail.js?prowjob=a/b",
+			code: http.StatusBadRequest,
+		},
+	}
+	handler := handleProwJob(pg)
+	for _, tc := range testcases {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if rr.Code != tc.code {
+			t.Errorf("For case %s, expected code %d, got %d", tc.name, tc.code, rr.Code)
+			continue
+		}
+		if tc.code != http.StatusOK {
+			continue
+		}
+		var d ProwJobDetail
+		if err := json.Unmarshal(rr.Body.Bytes(), &d); err != nil {
+			t.Errorf("For case %s, error unmarshaling: %v", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(d, pg["pj"]) {
+			t.Errorf("For case %s, expected %+v, got %+v", tc.name, pg["pj"], d)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+"use strict";
+
+function getParameterByName(name) {  // http://stackoverflow.com/a/5158301/3694
+    var match = RegExp('[?&]' + name + '=([^&/]*)').exec(window.location.search);
+    return match && decodeURIComponent(match[1].replace(/\+/g, ' '));
+}
+
+window.onload = function() {
+    var sel = document.getElementById("repo");
+    var param = getParameterByName("repo");
+    var keys = Object.keys(allHelp).sort();
+    for (var i = 0; i < keys.length; i++) {
+        var o = document.createElement("option");
+        o.text = keys[i];
+        if (param && keys[i] === param) {
+            o.selected = true;
+        }
+        sel.appendChild(o);
+    }
+    redraw();
+};
+
+function redraw() {
+    var sel = document.getElementById("repo");
+    var commands = document.getElementById("commands").getElementsByTagName("tbody")[0];
+    var plugins = document.getElementById("plugins").getElementsByTagName("tbody")[0];
+    while (commands.firstChild)
+        commands.removeChild(commands.firstChild);
+    while (plugins.firstChild)
+        plugins.removeChild(plugins.firstChild);
+    if (sel.selectedIndex < 0) return;
+
+    var key = sel.options[sel.selectedIndex].text;
+    if (window.history && window.history.replaceState !== undefined) {
+        history.replaceState(null, "", "?repo=" + encodeURIComponent(key));
+    }
+
+    var help = allHelp[key];
+    var names = Object.keys(help).sort();
+    for (var i = 0; i < names.length; i++) {
+        var p = help[names[i]];
+        var r = document.createElement("tr");
+        r.appendChild(createTextCell(names[i]));
+        r.appendChild(createTextCell(p.description));
+        plugins.appendChild(r);
+
+        var cmds = p.commands || [];
+        for (var j = 0; j < cmds.length; j++) {
+            var c = document.createElement("tr");
+            var usage = createTextCell(cmds[j].usage);
+            usage.title = cmds[j].regex;
+            c.appendChild(usage);
+            c.appendChild(createTextCell(names[i]));
+            c.appendChild(createTextCell(cmds[j].who_can_use));
+            c.appendChild(createTextCell(cmds[j].description));
+            commands.appendChild(c);
+        }
+    }
+}
+
+function createTextCell(text) {
+    var c = document.createElement("td");
+    c.appendChild(document.createTextNode(text));
+    return c;
+}
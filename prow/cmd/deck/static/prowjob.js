@@ -0,0 +1,156 @@
+"use strict";
+
+function getParameterByName(name) {  // http://stackoverflow.com/a/5158301/3694
+    var match = RegExp('[?&]' + name + '=([^&/]*)').exec(window.location.search);
+    return match && decodeURIComponent(match[1].replace(/\+/g, ' '));
+}
+
+window.onload = function() {
+    var name = getParameterByName("prowjob");
+    if (!name) {
+        showError("No ProwJob given.");
+        return;
+    }
+    var req = new XMLHttpRequest();
+    req.onload = function() {
+        if (req.status !== 200) {
+            showError(req.responseText);
+            return;
+        }
+        draw(JSON.parse(req.responseText));
+    };
+    req.onerror = function() {
+        showError("Error loading ProwJob " + name + ".");
+    };
+    req.open("GET", "prowjob-detail.js?prowjob=" + encodeURIComponent(name));
+    req.send();
+};
+
+function showError(text) {
+    document.getElementById("title").textContent = text;
+    addLink("Jobs", "/");
+}
+
+function draw(detail) {
+    var job = detail.job;
+    var title = document.getElementById("title");
+    title.textContent = job.job + " ";
+    var state = document.createElement("span");
+    state.className = job.state;
+    state.textContent = job.state;
+    title.appendChild(state);
+    document.title = job.job + " " + job.state;
+
+    if (job.url) {
+        addLink("Results", job.url);
+    }
+    if (job.pod_name) {
+        addLink("Log", "log?pod=" + job.pod_name);
+        if (job.state === "pending") {
+            addLink("Follow log", "log?pod=" + job.pod_name + "&follow=true");
+        }
+    }
+    addLink("Rerun", "rerun?prowjob=" + job.prow_job);
+    addLink("Jobs", "/?type=" + job.type + "&job=" + encodeURIComponent(job.job));
+
+    drawSummary(detail);
+    drawHistory(detail.status.history || []);
+    drawPod(detail.pod);
+    document.getElementById("spec").textContent = JSON.stringify(detail.spec, null, 2);
+}
+
+function addLink(text, url) {
+    var li = document.createElement("li");
+    var a = document.createElement("a");
+    a.href = url;
+    a.textContent = text;
+    li.appendChild(a);
+    document.getElementById("links").appendChild(li);
+}
+
+function drawSummary(detail) {
+    var job = detail.job;
+    var rows = [
+        ["ProwJob", job.prow_job],
+        ["Type", job.type],
+        ["Agent", job.agent],
+        ["Context", job.context],
+        ["Started", job.started],
+        ["Duration", job.duration],
+        ["Description", job.description]
+    ];
+    if (job.repo && job.repo !== "/") {
+        rows.push(["Repository", job.repo]);
+        rows.push(["Base", job.base_ref + " (" + job.base_sha.slice(0, 7) + ")"]);
+    }
+    if (job.type === "presubmit") {
+        rows.push(["Pull request", "#" + job.number + " (" + job.pull_sha.slice(0, 7) + ") by " + job.author]);
+    } else if (job.type === "batch") {
+        rows.push(["Refs", job.refs]);
+    }
+    rows.push(["Rerun", "kubectl create -f \"https://" + window.location.hostname + "/rerun?prowjob=" + job.prow_job + "\""]);
+    var tbody = document.getElementById("summary").getElementsByTagName("tbody")[0];
+    for (var i = 0; i < rows.length; i++) {
+        if (!rows[i][1]) continue;
+        var r = document.createElement("tr");
+        var th = document.createElement("th");
+        th.textContent = rows[i][0];
+        r.appendChild(th);
+        r.appendChild(createTextCell(rows[i][1]));
+        tbody.appendChild(r);
+    }
+}
+
+function drawHistory(history) {
+    var tbody = document.getElementById("history").getElementsByTagName("tbody")[0];
+    for (var i = 0; i < history.length; i++) {
+        var r = document.createElement("tr");
+        r.appendChild(createTextCell(history[i].time));
+        var s = createTextCell(history[i].state);
+        s.className = history[i].state;
+        r.appendChild(s);
+        r.appendChild(createTextCell(history[i].description || ""));
+        tbody.appendChild(r);
+    }
+}
+
+function drawPod(pod) {
+    var table = document.getElementById("pod");
+    if (!pod) {
+        table.style.display = "none";
+        return;
+    }
+    var tbody = table.getElementsByTagName("tbody")[0];
+    var r = document.createElement("tr");
+    r.appendChild(createTextCell("pod"));
+    r.appendChild(createTextCell(pod.phase + (pod.reason ? ": " + pod.reason : "")));
+    r.appendChild(createTextCell(""));
+    tbody.appendChild(r);
+    var containers = (pod.initContainerStatuses || []).concat(pod.containerStatuses || []);
+    for (var i = 0; i < containers.length; i++) {
+        var cs = containers[i];
+        r = document.createElement("tr");
+        r.appendChild(createTextCell(cs.name));
+        r.appendChild(createTextCell(containerState(cs.state)));
+        r.appendChild(createTextCell(String(cs.restartCount)));
+        tbody.appendChild(r);
+    }
+}
+
+function containerState(state) {
+    if (state.waiting) {
+        return "waiting" + (state.waiting.reason ? ": " + state.waiting.reason : "");
+    } else if (state.running) {
+        return "running since " + state.running.startedAt;
+    } else if (state.terminated) {
+        var t = state.terminated;
+        return "terminated with exit code " + t.exitCode + (t.reason ? ": " + t.reason : "");
+    }
+    return "unknown";
+}
+
+function createTextCell(text) {
+    var c = document.createElement("td");
+    c.appendChild(document.createTextNode(text));
+    return c;
+}
@@ -1,141 +1,159 @@
 "use strict";
 
 var types = ["presubmit", "postsubmit", "periodic", "batch"];
-var repos = {};
-var jobs = {};
-var authors = {};
-var pulls = {};
-var states = {};
 
 function getParameterByName(name) {  // http://stackoverflow.com/a/5158301/3694
     var match = RegExp('[?&]' + name + '=([^&/]*)').exec(window.location.search);
     return match && decodeURIComponent(match[1].replace(/\+/g, ' '));
 }
 
-function updateQueryStringParameter(uri, key, value) {
-    var re = new RegExp("([?&])" + key + "=.*?(&|$)", "i");
-    var separator = uri.indexOf('?') !== -1 ? "&" : "?";
-    if (uri.match(re)) {
-            return uri.replace(re, '$1' + key + "=" + value + '$2');
-    } else {
-        return uri + separator + key + "=" + value;
-    }
-}
+// The filters that the job list can be narrowed down by. Each has a select
+// with the same ID and a query parameter with the same name.
+var filters = ["type", "repo", "pull", "author", "job", "state", "since"];
+var filterLabels = {
+    repo: "all repositories",
+    pull: "all pull requests",
+    author: "all authors",
+    job: "all jobs",
+    state: "all states"
+};
+// The current filters and page, which are kept in the URL so that it can be
+// shared.
+var params = {};
 
 window.onload = function() {
-    for (var i = 0; i < allBuilds.length; i++) {
-        repos[allBuilds[i].repo] = true;
-        jobs[allBuilds[i].job] = true;
-        if (allBuilds[i].type === "presubmit") {
-            authors[allBuilds[i].author] = true;
-            pulls[allBuilds[i].number] = true;
-            states[allBuilds[i].state] = true;
+    var modal = document.getElementById('rerun');
+    window.onclick = function(event) {
+        if (event.target == modal) {
+            modal.style.display = "none";
         }
+    };
+    for (var i = 0; i < filters.length; i++) {
+        params[filters[i]] = getParameterByName(filters[i]) || "";
     }
-
-    addOptions(types, "type");
-    var rs = Array.from(Object.keys(repos)).filter(function(r) { return r !== "/"; }).sort();
-    addOptions(rs, "repo");
-    var js = Array.from(Object.keys(jobs)).sort();
-    addOptions(js, "job");
-    var as = Array.from(Object.keys(authors)).sort(function (a, b) {
-        return a.toLowerCase().localeCompare(b.toLowerCase());
-    });
-    addOptions(as, "author");
-    var ps = Array.from(Object.keys(pulls)).sort(function (a, b) {
-        return parseInt(a) - parseInt(b);
-    });
-    addOptions(ps, "pull");
-    var ss = Array.from(Object.keys(states)).sort();
-    addOptions(ss, "state");
-
-    redraw();
+    if (params.type === "") {
+        params.type = types[0];
+    }
+    params.page = parseInt(getParameterByName("page")) || 1;
+    load();
 };
 
-function addOptions(s, p) {
-    var sel = document.getElementById(p);
-    var param = getParameterByName(p);
-    for (var i = 0; i < s.length; i++) {
-        var o = document.createElement("option");
-        o.text = s[i];
-        if (param && s[i] === param) {
-            o.selected = true;
+function query() {
+    var args = [];
+    for (var i = 0; i < filters.length; i++) {
+        if (params[filters[i]] !== "") {
+            args.push(filters[i] + "=" + encodeURIComponent(params[filters[i]]));
+        }
+    }
+    if (params.page > 1) {
+        args.push("page=" + params.page);
+    }
+    return args.join("&");
+}
+
+// load fetches the page of jobs that matches the filters and draws it.
+function load() {
+    var q = query();
+    if (window.history && window.history.replaceState !== undefined) {
+        history.replaceState(null, "", "/?" + q);
+    }
+    var req = new XMLHttpRequest();
+    req.onload = function() {
+        if (req.status !== 200) {
+            showMessage("Error loading jobs: " + req.responseText);
+            return;
         }
-        sel.appendChild(o);
+        redraw(JSON.parse(req.responseText));
+    };
+    req.onerror = function() {
+        showMessage("Error loading jobs.");
+    };
+    req.open("GET", "jobs.js?" + q);
+    req.send();
+}
+
+function filterChanged() {
+    for (var i = 0; i < filters.length; i++) {
+        var sel = document.getElementById(filters[i]);
+        params[filters[i]] = sel.options[sel.selectedIndex].value;
     }
+    params.page = 1;
+    load();
 }
 
-function equalSelected(sel, t) {
-    return sel.selectedIndex == 0 || sel.options[sel.selectedIndex].text == t;
+function goToPage(page) {
+    params.page = page;
+    load();
 }
 
-function encodedText(sel) {
-    if (sel.selectedIndex == 0) {
-        return "";
+// setOptions fills in the select with the values, keeping the current
+// filter selected even if no job has it anymore.
+function setOptions(id, values) {
+    var sel = document.getElementById(id);
+    while (sel.firstChild)
+        sel.removeChild(sel.firstChild);
+    if (filterLabels[id]) {
+        sel.appendChild(createOption("", filterLabels[id]));
     }
-    return encodeURIComponent(sel.options[sel.selectedIndex].text);
+    var found = params[id] === "";
+    for (var i = 0; i < values.length; i++) {
+        var v = String(values[i]);
+        sel.appendChild(createOption(v, v));
+        if (v === params[id]) found = true;
+    }
+    if (!found) {
+        sel.appendChild(createOption(params[id], params[id]));
+    }
+    selectValue(sel, params[id]);
+}
+
+function createOption(value, text) {
+    var o = document.createElement("option");
+    o.value = value;
+    o.text = text;
+    return o;
+}
+
+function selectValue(sel, value) {
+    for (var i = 0; i < sel.options.length; i++) {
+        if (sel.options[i].value === value) {
+            sel.selectedIndex = i;
+            return;
+        }
+    }
+}
+
+function showMessage(text) {
+    var pages = document.getElementById("pages");
+    while (pages.firstChild)
+        pages.removeChild(pages.firstChild);
+    pages.appendChild(document.createTextNode(text));
 }
 
 function groupKey(build) {
     return build.repo + " " + build.number + " " + build.refs;
 }
 
-function redraw() {
+function redraw(page) {
+    setOptions("type", types);
+    setOptions("repo", page.repos);
+    setOptions("pull", page.pulls);
+    setOptions("author", page.authors);
+    setOptions("job", page.job_names);
+    setOptions("state", page.states);
+    selectValue(document.getElementById("since"), params.since);
+
     var modal = document.getElementById('rerun');
     var rerun_command = document.getElementById('rerun-content');
-    window.onclick = function(event) {
-        if (event.target == modal) {
-            modal.style.display = "none";
-        }
-    };
     var builds = document.getElementById("builds").getElementsByTagName("tbody")[0];
     while (builds.firstChild)
         builds.removeChild(builds.firstChild);
 
-    var typeSel = document.getElementById("type")
-    var selectedType = typeSel.options[typeSel.selectedIndex].text;
-    var repoSel = document.getElementById("repo")
-    var pullSel = document.getElementById("pull")
-    var authorSel = document.getElementById("author")
-    var jobSel = document.getElementById("job")
-    var stateSel = document.getElementById("state")
-
-    if (window.history && window.history.replaceState !== undefined) {
-        var args = [];
-        args.push("type=" + selectedType);
-        var rt = encodedText(repoSel);
-        if (rt !== "") args.push("repo=" + rt);
-        var pt = encodedText(pullSel);
-        if (pt !== "") args.push("pull=" + pt);
-        var at = encodedText(authorSel);
-        if (at !== "") args.push("author=" + at);
-        var jt = encodedText(jobSel);
-        if (jt !== "") args.push("job=" + jt);
-        var st = encodedText(stateSel);
-        if (st !== "") args.push("state=" + st);
-        if (args.length > 0) {
-            history.replaceState(null, "", "/?" + args.join('&'));
-        } else {
-            history.replaceState(null, "", "/")
-        }
-    }
-
     var lastKey = '';
-    for (var i = 0, emitted = 0; i < allBuilds.length && emitted < 500; i++) {
-        var build = allBuilds[i];
-        if (build.type !== selectedType) continue;
-
-        if (build.type !== "periodic" && !equalSelected(repoSel, build.repo)) continue;
-        if (!equalSelected(stateSel, build.state)) continue;
-        if (!equalSelected(jobSel, build.job)) continue;
-        if (build.type === "presubmit") {
-            if (!equalSelected(pullSel, build.number)) continue;
-            if (!equalSelected(authorSel, build.author)) continue;
-        }
-        emitted++;
-
+    for (var i = 0; i < page.jobs.length; i++) {
+        var build = page.jobs[i];
         var r = document.createElement("tr");
-        r.appendChild(stateCell(build.state));
+        r.appendChild(stateCell(build));
         if (build.pod_name) {
             r.appendChild(createLinkCell("\u2261", "log?pod=" + build.pod_name));
         } else {
@@ -173,9 +191,42 @@ function redraw() {
             r.appendChild(createLinkCell(build.job, build.url));
         }
         r.appendChild(createTextCell(build.started));
-        r.appendChild(createTextCell(build.duration));
+        if (build.queue_position) {
+            r.appendChild(createTextCell("queued (#" + build.queue_position + ")"));
+        } else {
+            r.appendChild(createTextCell(build.duration));
+        }
         builds.appendChild(r);
     }
+    drawPages(page);
+}
+
+// drawPages shows where we are in the list and links to the pages around us.
+function drawPages(page) {
+    var pages = document.getElementById("pages");
+    while (pages.firstChild)
+        pages.removeChild(pages.firstChild);
+    var last = Math.max(1, Math.ceil(page.total / page.per_page));
+    if (page.page > 1) {
+        pages.appendChild(createPageLink("\u2039 newer", page.page - 1));
+        pages.appendChild(document.createTextNode(" "));
+    }
+    pages.appendChild(document.createTextNode("Page " + page.page + " of " + last + " (" + page.total + " jobs)"));
+    if (page.page < last) {
+        pages.appendChild(document.createTextNode(" "));
+        pages.appendChild(createPageLink("older \u203a", page.page + 1));
+    }
+}
+
+function createPageLink(text, page) {
+    var a = document.createElement("a");
+    a.href = "#";
+    a.onclick = function() {
+        goToPage(page);
+        return false;
+    };
+    a.appendChild(document.createTextNode(text));
+    return a;
 }
 
 function createTextCell(text) {
@@ -207,17 +258,28 @@ function createRerunCell(modal, rerun_command, prowjob) {
     return c;
 }
 
-function stateCell(state) {
+// stateCell links to the page of the build.
+function stateCell(build) {
     var c = document.createElement("td");
-    c.className = state;
+    c.className = build.state;
+    var a = document.createElement("a");
+    a.href = "prowjob.html?prowjob=" + encodeURIComponent(build.prow_job);
+    a.title = build.state + (build.description ? ": " + build.description : "");
+    a.className = build.state;
+    a.appendChild(document.createTextNode(stateSymbol(build.state)));
+    c.appendChild(a);
+    return c;
+}
+
+function stateSymbol(state) {
     if (state === "triggered" || state === "pending") {
-        c.appendChild(document.createTextNode("\u2022"));
+        return "\u2022";
     } else if (state === "success") {
-        c.appendChild(document.createTextNode("\u2713"));
+        return "\u2713";
     } else if (state === "failure" || state === "error" || state === "aborted") {
-        c.appendChild(document.createTextNode("\u2717"));
+        return "\u2717";
     }
-    return c;
+    return "";
 }
 
 function batchRevisionCell(build) {
@@ -261,5 +323,13 @@ function prRevisionCell(build) {
     al.href = "https://github.com/" + build.author;
     al.text = build.author;
     c.appendChild(al);
+    if (build.pr_history_url) {
+        c.appendChild(document.createTextNode(" ("));
+        var hl = document.createElement("a");
+        hl.href = build.pr_history_url;
+        hl.text = "history";
+        c.appendChild(hl);
+        c.appendChild(document.createTextNode(")"));
+    }
     return c;
 }
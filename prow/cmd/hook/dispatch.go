@@ -0,0 +1,199 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/plugins"
+)
+
+var (
+	// How many times to retry a plugin that returns an error.
+	maxRetries = 3
+	// How long to wait before the first retry. Each later retry waits twice
+	// as long as the one before.
+	retryBackoff = 2 * time.Second
+)
+
+// Start recovers the events that were still queued when hook last stopped
+// and starts the workers that handle events.
+func (s *Server) Start() error {
+	s.cond = sync.NewCond(&s.mut)
+	events, err := s.Queue.List()
+	if err != nil {
+		return fmt.Errorf("error listing queued events: %v", err)
+	}
+	for _, e := range events {
+		// Dead letters wait for someone to replay them.
+		if len(e.Failed) == 0 {
+			s.enqueue(e)
+		}
+	}
+	if len(s.pending) > 0 {
+		logrus.Infof("Recovered %d queued events.", len(s.pending))
+	}
+	workers := s.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go s.work()
+	}
+	return nil
+}
+
+// enqueue hands the event to the workers.
+func (s *Server) enqueue(e Event) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.pending = append(s.pending, e)
+	s.cond.Signal()
+}
+
+func (s *Server) work() {
+	for {
+		s.mut.Lock()
+		for len(s.pending) == 0 {
+			s.cond.Wait()
+		}
+		e := s.pending[0]
+		s.pending = s.pending[1:]
+		s.mut.Unlock()
+		s.handle(e)
+	}
+}
+
+// handle runs every plugin that hasn't handled the event yet, recording
+// progress in the queue after each one. The event is removed from the queue
+// once all of them succeed, and otherwise kept as a dead letter.
+func (s *Server) handle(e Event) {
+	l, calls, err := s.demuxEvent(e)
+	if err != nil {
+		// Retrying won't make the payload parse.
+		logrus.WithField("event", e.ID).WithError(err).Error("Error parsing event.")
+		if err := s.Queue.Delete(e.ID); err != nil {
+			logrus.WithField("event", e.ID).WithError(err).Error("Error removing event from queue.")
+		}
+		return
+	}
+	l = l.WithField("event", e.ID)
+	for _, c := range calls {
+		if e.done(c.plugin) {
+			continue
+		}
+		pc := s.Plugins.PluginClient
+		pc.Logger = l.WithField("plugin", c.plugin)
+		pc.Config = s.ConfigAgent.Config()
+		pc.PluginConfig = s.Plugins.Config()
+		if err := callWithRetries(pc, c); err != nil {
+			pc.Logger.WithError(err).Errorf("Error handling %s event, giving up.", e.Type)
+			if e.Failed == nil {
+				e.Failed = map[string]string{}
+			}
+			e.Failed[c.plugin] = err.Error()
+		} else {
+			e.Done = append(e.Done, c.plugin)
+		}
+		if err := s.Queue.Put(e); err != nil {
+			l.WithError(err).Error("Error saving event progress.")
+		}
+	}
+	if len(e.Failed) > 0 {
+		return
+	}
+	if err := s.Queue.Delete(e.ID); err != nil {
+		l.WithError(err).Error("Error removing event from queue.")
+	}
+}
+
+func callWithRetries(pc plugins.PluginClient, c pluginCall) error {
+	backoff := retryBackoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = c.handle(pc); err == nil || attempt == maxRetries {
+			return err
+		}
+		pc.Logger.WithError(err).Warningf("Error handling event, retrying in %s.", backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// ServeDeadLetters lists dead letters on GET. On POST it replays the dead
+// letter given by the id parameter, rerunning only the plugins that failed,
+// and on DELETE it discards it.
+func (s *Server) ServeDeadLetters(w http.ResponseWriter, r *http.Request) {
+	events, err := s.Queue.List()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error listing events: %v", err), http.StatusInternalServerError)
+		return
+	}
+	dead := []Event{}
+	for _, e := range events {
+		if len(e.Failed) > 0 {
+			dead = append(dead, e)
+		}
+	}
+	if r.Method == http.MethodGet {
+		b, err := json.MarshalIndent(dead, "", "  ")
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error marshaling events: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	var e *Event
+	for i := range dead {
+		if dead[i].ID == id {
+			e = &dead[i]
+		}
+	}
+	if e == nil {
+		http.Error(w, fmt.Sprintf("No dead letter with id %q.", id), http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		e.Failed = nil
+		if err := s.Queue.Put(*e); err != nil {
+			http.Error(w, fmt.Sprintf("Error queueing event: %v", err), http.StatusInternalServerError)
+			return
+		}
+		s.enqueue(*e)
+		logrus.WithField("event", id).Info("Replaying dead letter.")
+		fmt.Fprintf(w, "Replaying %s.", id)
+	case http.MethodDelete:
+		if err := s.Queue.Delete(id); err != nil {
+			http.Error(w, fmt.Sprintf("Error deleting event: %v", err), http.StatusInternalServerError)
+			return
+		}
+		logrus.WithField("event", id).Info("Discarded dead letter.")
+		fmt.Fprintf(w, "Discarded %s.", id)
+	default:
+		http.Error(w, "405 Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/plugins"
+	"k8s.io/test-infra/prow/record"
+)
+
+// Test plugins that count their calls. Flaky fails on its first call and
+// broken fails until it is fixed.
+var (
+	calls       = map[string]int{}
+	brokenFixed bool
+)
+
+func init() {
+	plugins.RegisterIssueHandler("hook-test-ok", func(plugins.PluginClient, github.IssueEvent) error {
+		calls["ok"]++
+		return nil
+	})
+	plugins.RegisterIssueHandler("hook-test-flaky", func(plugins.PluginClient, github.IssueEvent) error {
+		calls["flaky"]++
+		if calls["flaky"] == 1 {
+			return errors.New("flake")
+		}
+		return nil
+	})
+	plugins.RegisterIssueHandler("hook-test-broken", func(plugins.PluginClient, github.IssueEvent) error {
+		calls["broken"]++
+		if !brokenFixed {
+			return errors.New("broken")
+		}
+		return nil
+	})
+}
+
+func TestHandleRetriesAndReplays(t *testing.T) {
+	calls, brokenFixed = map[string]int{}, false
+	oldRetries, oldBackoff := maxRetries, retryBackoff
+	maxRetries, retryBackoff = 1, time.Millisecond
+	defer func() { maxRetries, retryBackoff = oldRetries, oldBackoff }()
+
+	dir, err := ioutil.TempDir("", "hook")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	pluginPath := filepath.Join(dir, "plugins.yaml")
+	if err := ioutil.WriteFile(pluginPath, []byte("plugins:\n  org/repo:\n  - hook-test-ok\n  - hook-test-flaky\n  - hook-test-broken\n"), 0644); err != nil {
+		t.Fatalf("Error writing plugins: %v", err)
+	}
+	pa := &plugins.PluginAgent{}
+	if err := pa.Load(pluginPath); err != nil {
+		t.Fatalf("Error loading plugins: %v", err)
+	}
+	q, err := NewQueue(filepath.Join(dir, "queue.log"))
+	if err != nil {
+		t.Fatalf("Error opening queue: %v", err)
+	}
+	s := &Server{
+		Plugins:     pa,
+		ConfigAgent: &config.ConfigAgent{},
+		Queue:       q,
+	}
+	s.cond = sync.NewCond(&s.mut)
+
+	payload, _ := json.Marshal(github.IssueEvent{
+		Action: "opened",
+		Repo: github.Repo{
+			Owner: github.User{Login: "org"},
+			Name:  "repo",
+		},
+	})
+	e := Event{ID: "1", Type: "issues", Payload: payload}
+	if err := q.Put(e); err != nil {
+		t.Fatalf("Error queueing event: %v", err)
+	}
+	s.handle(e)
+
+	if !reflect.DeepEqual(calls, map[string]int{"ok": 1, "flaky": 2, "broken": 2}) {
+		t.Errorf("Expected ok once, flaky and broken twice, got %v.", calls)
+	}
+	events, _ := q.List()
+	if len(events) != 1 {
+		t.Fatalf("Expected the event to be kept as a dead letter, got %+v.", events)
+	}
+	done := append([]string(nil), events[0].Done...)
+	sort.Strings(done)
+	if !reflect.DeepEqual(done, []string{"hook-test-flaky", "hook-test-ok"}) {
+		t.Errorf("Expected ok and flaky to be done, got %v.", done)
+	}
+	if events[0].Failed["hook-test-broken"] != "broken" {
+		t.Errorf("Expected broken to have failed, got %v.", events[0].Failed)
+	}
+
+	rr := httptest.NewRecorder()
+	s.ServeDeadLetters(rr, httptest.NewRequest(http.MethodGet, "/dead-letters", nil))
+	var dead []Event
+	if err := json.Unmarshal(rr.Body.Bytes(), &dead); err != nil {
+		t.Fatalf("Error unmarshaling dead letters: %v", err)
+	}
+	if len(dead) != 1 || dead[0].ID != "1" {
+		t.Errorf("Expected dead letter 1, got %+v.", dead)
+	}
+
+	rr = httptest.NewRecorder()
+	s.ServeDeadLetters(rr, httptest.NewRequest(http.MethodPost, "/dead-letters?id=nope", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 replaying an unknown event, got %d.", rr.Code)
+	}
+
+	brokenFixed = true
+	rr = httptest.NewRecorder()
+	s.ServeDeadLetters(rr, httptest.NewRequest(http.MethodPost, "/dead-letters?id=1", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200 replaying the event, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(s.pending) != 1 {
+		t.Fatalf("Expected the replayed event to be pending, got %+v.", s.pending)
+	}
+	s.handle(s.pending[0])
+	if !reflect.DeepEqual(calls, map[string]int{"ok": 1, "flaky": 2, "broken": 3}) {
+		t.Errorf("Expected only broken to run again, got %v.", calls)
+	}
+	if events, _ := q.List(); len(events) != 0 {
+		t.Errorf("Expected the event to be removed once handled, got %+v.", events)
+	}
+}
+
+func TestServeHTTPQueuesEvents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hook")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	q, _ := NewQueue("")
+	s := &Server{
+		HMACSecret: []byte("abc"),
+		Queue:      q,
+		Recorder:   &record.Recorder{Dir: dir},
+	}
+	s.cond = sync.NewCond(&s.mut)
+	// echo -n '{}' | openssl dgst -sha1 -hmac abc
+	const hmac string = "sha1=db5c76f4264d0ad96cf21baec394964b4b8ce580"
+	for _, eventType := range []string{"ping", "issues"} {
+		r := httptest.NewRequest(http.MethodPost, "/hook", strings.NewReader("{}"))
+		r.Header.Set("X-GitHub-Event", eventType)
+		r.Header.Set("X-GitHub-Delivery", "delivery-"+eventType)
+		r.Header.Set("X-Hub-Signature", hmac)
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, r)
+		if rr.Code != http.StatusOK {
+			t.Errorf("For %s, expected 200, got %d.", eventType, rr.Code)
+		}
+	}
+	if ids := eventIDs(t, q); !reflect.DeepEqual(ids, []string{"delivery-issues"}) {
+		t.Errorf("Expected only the issues event to be queued, got %v.", ids)
+	}
+	if len(s.pending) != 1 || s.pending[0].ID != "delivery-issues" {
+		t.Errorf("Expected the issues event to be pending, got %+v.", s.pending)
+	}
+	s.Recorder.Close()
+	recs, err := record.Load(dir)
+	if err != nil {
+		t.Fatalf("Error loading recorded webhooks: %v", err)
+	}
+	if len(recs) != 2 || recs[0].Event != "ping" || recs[1].Event != "issues" {
+		t.Errorf("Expected the ping and issues webhooks to be recorded, got %+v.", recs)
+	} else if recs[1].Headers["X-GitHub-Delivery"] != "delivery-issues" {
+		t.Errorf("Expected the delivery ID to be recorded, got %v.", recs[1].Headers)
+	}
+}
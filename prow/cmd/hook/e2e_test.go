@@ -0,0 +1,256 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/crier"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+	"k8s.io/test-infra/prow/kube"
+	"k8s.io/test-infra/prow/kube/fakekube"
+	"k8s.io/test-infra/prow/plank"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+// TestEndToEnd runs hook and the lgtm plugin against a fake GitHub, which
+// sends hook a webhook for everything that happens.
+func TestEndToEnd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hook")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	pluginPath := filepath.Join(dir, "plugins.yaml")
+	if err := ioutil.WriteFile(pluginPath, []byte("plugins:\n  org/repo:\n  - lgtm\n"), 0644); err != nil {
+		t.Fatalf("Error writing plugins: %v", err)
+	}
+
+	fgh := fakegithub.NewServer("k8s-ci-robot")
+	fgh.HMACSecret = []byte("secret")
+	fgh.AddRepo("org", "repo", "lgtm")
+	fgh.AddMember("org", "alice")
+	gh := httptest.NewServer(fgh)
+	defer gh.Close()
+
+	pa := &plugins.PluginAgent{
+		PluginClient: plugins.PluginClient{
+			GitHubClient: github.NewClient("k8s-ci-robot", "", gh.URL),
+		},
+	}
+	if err := pa.Load(pluginPath); err != nil {
+		t.Fatalf("Error loading plugins: %v", err)
+	}
+	ca := &config.ConfigAgent{}
+	ca.Set(&config.Config{})
+	queue, err := NewQueue("")
+	if err != nil {
+		t.Fatalf("Error making queue: %v", err)
+	}
+	s := &Server{
+		Plugins:     pa,
+		ConfigAgent: ca,
+		HMACSecret:  fgh.HMACSecret,
+		Queue:       queue,
+		Workers:     1,
+	}
+	if err := s.Start(); err != nil {
+		t.Fatalf("Error starting hook: %v", err)
+	}
+	hook := httptest.NewServer(s)
+	defer hook.Close()
+	fgh.HookURL = hook.URL
+
+	number, err := fgh.OpenPullRequest("org", "repo", "bob", "Fix the thing", "abcdef", nil)
+	if err != nil {
+		t.Fatalf("Error opening PR: %v", err)
+	}
+	if err := fgh.Comment("org", "repo", number, "bob", "/lgtm"); err != nil {
+		t.Fatalf("Error commenting: %v", err)
+	}
+	if err := fgh.Comment("org", "repo", number, "alice", "/lgtm"); err != nil {
+		t.Fatalf("Error commenting: %v", err)
+	}
+
+	// Hook handles events in the background.
+	var i github.Issue
+	for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(10 * time.Millisecond) {
+		if i, err = fgh.Issue("org", "repo", number); err != nil {
+			t.Fatalf("Error getting PR: %v", err)
+		}
+		if i.HasLabel("lgtm") {
+			break
+		}
+	}
+	if !i.HasLabel("lgtm") || !i.IsAssignee("alice") {
+		t.Errorf("Expected the PR to be LGTMd by and assigned to alice, got %+v.", i)
+	}
+	refused := false
+	for _, ic := range fgh.Comments("org", "repo", number) {
+		if ic.User.Login == "k8s-ci-robot" && strings.Contains(ic.Body, "you cannot LGTM your own PR") {
+			refused = true
+		}
+	}
+	if !refused {
+		t.Errorf("Expected the bot to refuse bob's /lgtm.")
+	}
+	if errs := fgh.WebhookErrors(); len(errs) > 0 {
+		t.Errorf("Unexpected webhook errors: %v", errs)
+	}
+}
+
+// TestPresubmit runs a presubmit from start to finish: the fake GitHub tells
+// hook about a new PR, the trigger plugin creates a ProwJob in the fake
+// apiserver, plank runs it and crier reports its status back to GitHub.
+func TestPresubmit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hook")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	pluginPath := filepath.Join(dir, "plugins.yaml")
+	if err := ioutil.WriteFile(pluginPath, []byte("plugins:\n  org/repo:\n  - trigger\n"), 0644); err != nil {
+		t.Fatalf("Error writing plugins: %v", err)
+	}
+
+	fgh := fakegithub.NewServer("k8s-ci-robot")
+	fgh.HMACSecret = []byte("secret")
+	fgh.AddRepo("org", "repo")
+	fgh.AddMember("org", "bob")
+	if err := fgh.SetRef("org", "repo", "heads/master", "123456"); err != nil {
+		t.Fatalf("Error setting ref: %v", err)
+	}
+	gh := httptest.NewServer(fgh)
+	defer gh.Close()
+	apiserver := fakekube.NewServer()
+	kubeServ := httptest.NewServer(apiserver)
+	defer kubeServ.Close()
+	defer apiserver.Close()
+	totServ := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "42")
+	}))
+	defer totServ.Close()
+
+	cfg := &config.Config{}
+	if err := cfg.SetPresubmits(map[string][]config.Presubmit{
+		"org/repo": {{
+			Name:      "pull-some-job",
+			Context:   "Some Job Context",
+			AlwaysRun: true,
+			Spec:      &kube.PodSpec{Containers: []kube.Container{{Image: "test"}}},
+		}},
+	}); err != nil {
+		t.Fatalf("Error setting presubmits: %v", err)
+	}
+	ca := &config.ConfigAgent{}
+	ca.Set(cfg)
+
+	kca := kube.NewCache(kube.NewClient(kubeServ.URL, "default"))
+	stop := make(chan struct{})
+	defer close(stop)
+	if err := kca.Start(stop); err != nil {
+		t.Fatalf("Error starting cache: %v", err)
+	}
+	go plank.NewController(kca, ca, nil, totServ.URL).Run(stop)
+	go crier.NewController(kca, github.NewClient("k8s-ci-robot", "", gh.URL), ca, crier.SMTPCredentials{}).Run(stop)
+
+	pa := &plugins.PluginAgent{
+		PluginClient: plugins.PluginClient{
+			GitHubClient: github.NewClient("k8s-ci-robot", "", gh.URL),
+			KubeClient:   kube.NewClient(kubeServ.URL, "default"),
+		},
+	}
+	if err := pa.Load(pluginPath); err != nil {
+		t.Fatalf("Error loading plugins: %v", err)
+	}
+	queue, err := NewQueue("")
+	if err != nil {
+		t.Fatalf("Error making queue: %v", err)
+	}
+	s := &Server{
+		Plugins:     pa,
+		ConfigAgent: ca,
+		HMACSecret:  fgh.HMACSecret,
+		Queue:       queue,
+		Workers:     1,
+	}
+	if err := s.Start(); err != nil {
+		t.Fatalf("Error starting hook: %v", err)
+	}
+	hook := httptest.NewServer(s)
+	defer hook.Close()
+	fgh.HookURL = hook.URL
+
+	if _, err := fgh.OpenPullRequest("org", "repo", "bob", "Fix the thing", "abcdef", nil); err != nil {
+		t.Fatalf("Error opening PR: %v", err)
+	}
+
+	waitFor := func(what string, done func() bool) {
+		for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(10 * time.Millisecond) {
+			if done() {
+				return
+			}
+		}
+		t.Fatalf("Timed out waiting for %s.", what)
+	}
+	var pj kube.ProwJob
+	waitFor("the pod to start", func() bool {
+		pjs := apiserver.ProwJobs("default")
+		if len(pjs) == 0 {
+			return false
+		}
+		pj = pjs[0]
+		return pj.Status.PodName != ""
+	})
+	if pj.Spec.Refs.BaseSHA != "123456" || pj.Spec.Refs.Pulls[0].SHA != "abcdef" {
+		t.Errorf("Expected the prow job to test abcdef on 123456, got %+v.", pj.Spec.Refs)
+	}
+	statuses := func() []string {
+		var states []string
+		for _, st := range fgh.Statuses("org", "repo", "abcdef") {
+			if st.Context == "Some Job Context" {
+				states = append(states, st.State)
+			}
+		}
+		return states
+	}
+	waitFor("crier to report pending", func() bool { return len(statuses()) == 1 })
+	if err := apiserver.SetPodPhase("default", pj.Status.PodName, kube.PodSucceeded); err != nil {
+		t.Fatalf("Error finishing pod: %v", err)
+	}
+	waitFor("crier to report success", func() bool { return len(statuses()) == 2 })
+	if expected, got := []string{"pending", "success"}, statuses(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected statuses %v, got %v.", expected, got)
+	}
+	if n := len(apiserver.ProwJobs("default")); n != 1 {
+		t.Errorf("Expected one prow job, got %d.", n)
+	}
+	if errs := fgh.WebhookErrors(); len(errs) > 0 {
+		t.Errorf("Unexpected webhook errors: %v", errs)
+	}
+}
@@ -20,9 +20,16 @@ import (
 	"github.com/Sirupsen/logrus"
 
 	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/plugins"
 )
 
-func (s *Server) handlePullRequestEvent(pr github.PullRequestEvent) {
+// pluginCall is one plugin's handler bound to an event.
+type pluginCall struct {
+	plugin string
+	handle func(plugins.PluginClient) error
+}
+
+func (s *Server) pullRequestCalls(pr github.PullRequestEvent) (*logrus.Entry, []pluginCall) {
 	l := logrus.WithFields(logrus.Fields{
 		"org":    pr.PullRequest.Base.Repo.Owner.Login,
 		"repo":   pr.PullRequest.Base.Repo.Name,
@@ -31,17 +38,15 @@ func (s *Server) handlePullRequestEvent(pr github.PullRequestEvent) {
 		"url":    pr.PullRequest.HTMLURL,
 	})
 	l.Infof("Pull request %s.", pr.Action)
+	var calls []pluginCall
 	for p, h := range s.Plugins.PullRequestHandlers(pr.PullRequest.Base.Repo.Owner.Login, pr.PullRequest.Base.Repo.Name) {
-		pc := s.Plugins.PluginClient
-		pc.Logger = l.WithField("plugin", p)
-		pc.Config = s.ConfigAgent.Config()
-		if err := h(pc, pr); err != nil {
-			pc.Logger.WithError(err).Error("Error handling PullRequestEvent.")
-		}
+		h := h
+		calls = append(calls, pluginCall{p, func(pc plugins.PluginClient) error { return h(pc, pr) }})
 	}
+	return l, calls
 }
 
-func (s *Server) handlePushEvent(pe github.PushEvent) {
+func (s *Server) pushCalls(pe github.PushEvent) (*logrus.Entry, []pluginCall) {
 	l := logrus.WithFields(logrus.Fields{
 		"org":  pe.Repo.Owner.Name,
 		"repo": pe.Repo.Name,
@@ -49,17 +54,15 @@ func (s *Server) handlePushEvent(pe github.PushEvent) {
 		"head": pe.After,
 	})
 	l.Info("Push event.")
+	var calls []pluginCall
 	for p, h := range s.Plugins.PushEventHandlers(pe.Repo.Owner.Name, pe.Repo.Name) {
-		pc := s.Plugins.PluginClient
-		pc.Logger = l.WithField("plugin", p)
-		pc.Config = s.ConfigAgent.Config()
-		if err := h(pc, pe); err != nil {
-			pc.Logger.WithError(err).Error("Error handling PushEvent.")
-		}
+		h := h
+		calls = append(calls, pluginCall{p, func(pc plugins.PluginClient) error { return h(pc, pe) }})
 	}
+	return l, calls
 }
 
-func (s *Server) handleIssueEvent(i github.IssueEvent) {
+func (s *Server) issueCalls(i github.IssueEvent) (*logrus.Entry, []pluginCall) {
 	l := logrus.WithFields(logrus.Fields{
 		"org":    i.Repo.Owner.Login,
 		"repo":   i.Repo.Name,
@@ -68,17 +71,15 @@ func (s *Server) handleIssueEvent(i github.IssueEvent) {
 		"url":    i.Issue.HTMLURL,
 	})
 	l.Infof("Issue %s.", i.Action)
+	var calls []pluginCall
 	for p, h := range s.Plugins.IssueHandlers(i.Repo.Owner.Login, i.Repo.Name) {
-		pc := s.Plugins.PluginClient
-		pc.Logger = l.WithField("plugin", p)
-		pc.Config = s.ConfigAgent.Config()
-		if err := h(pc, i); err != nil {
-			pc.Logger.WithError(err).Error("Error handleing IssueEvent.")
-		}
+		h := h
+		calls = append(calls, pluginCall{p, func(pc plugins.PluginClient) error { return h(pc, i) }})
 	}
+	return l, calls
 }
 
-func (s *Server) handleIssueCommentEvent(ic github.IssueCommentEvent) {
+func (s *Server) issueCommentCalls(ic github.IssueCommentEvent) (*logrus.Entry, []pluginCall) {
 	l := logrus.WithFields(logrus.Fields{
 		"org":    ic.Repo.Owner.Login,
 		"repo":   ic.Repo.Name,
@@ -87,17 +88,15 @@ func (s *Server) handleIssueCommentEvent(ic github.IssueCommentEvent) {
 		"url":    ic.Comment.HTMLURL,
 	})
 	l.Infof("Issue comment %s.", ic.Action)
+	var calls []pluginCall
 	for p, h := range s.Plugins.IssueCommentHandlers(ic.Repo.Owner.Login, ic.Repo.Name) {
-		pc := s.Plugins.PluginClient
-		pc.Logger = l.WithField("plugin", p)
-		pc.Config = s.ConfigAgent.Config()
-		if err := h(pc, ic); err != nil {
-			pc.Logger.WithError(err).Error("Error handling IssueCommentEvent.")
-		}
+		h := h
+		calls = append(calls, pluginCall{p, func(pc plugins.PluginClient) error { return h(pc, ic) }})
 	}
+	return l, calls
 }
 
-func (s *Server) handleStatusEvent(se github.StatusEvent) {
+func (s *Server) statusCalls(se github.StatusEvent) (*logrus.Entry, []pluginCall) {
 	l := logrus.WithFields(logrus.Fields{
 		"org":     se.Repo.Owner.Login,
 		"repo":    se.Repo.Name,
@@ -107,12 +106,110 @@ func (s *Server) handleStatusEvent(se github.StatusEvent) {
 		"id":      se.ID,
 	})
 	l.Infof("Status description %s.", se.Description)
+	var calls []pluginCall
 	for p, h := range s.Plugins.StatusEventHandlers(se.Repo.Owner.Login, se.Repo.Name) {
-		pc := s.Plugins.PluginClient
-		pc.Logger = l.WithField("plugin", p)
-		pc.Config = s.ConfigAgent.Config()
-		if err := h(pc, se); err != nil {
-			pc.Logger.WithError(err).Error("Error handling StatusEvent.")
-		}
+		h := h
+		calls = append(calls, pluginCall{p, func(pc plugins.PluginClient) error { return h(pc, se) }})
+	}
+	return l, calls
+}
+
+func (s *Server) reviewCalls(re github.ReviewEvent) (*logrus.Entry, []pluginCall) {
+	l := logrus.WithFields(logrus.Fields{
+		"org":      re.Repo.Owner.Login,
+		"repo":     re.Repo.Name,
+		"pr":       re.PullRequest.Number,
+		"reviewer": re.Review.User.Login,
+		"url":      re.Review.HTMLURL,
+	})
+	l.Infof("Review %s (%s).", re.Action, re.Review.State)
+	var calls []pluginCall
+	for p, h := range s.Plugins.ReviewEventHandlers(re.Repo.Owner.Login, re.Repo.Name) {
+		h := h
+		calls = append(calls, pluginCall{p, func(pc plugins.PluginClient) error { return h(pc, re) }})
+	}
+	return l, calls
+}
+
+func (s *Server) reviewCommentCalls(rce github.ReviewCommentEvent) (*logrus.Entry, []pluginCall) {
+	l := logrus.WithFields(logrus.Fields{
+		"org":    rce.Repo.Owner.Login,
+		"repo":   rce.Repo.Name,
+		"pr":     rce.PullRequest.Number,
+		"author": rce.Comment.User.Login,
+		"url":    rce.Comment.HTMLURL,
+	})
+	l.Infof("Review comment %s.", rce.Action)
+	var calls []pluginCall
+	for p, h := range s.Plugins.ReviewCommentEventHandlers(rce.Repo.Owner.Login, rce.Repo.Name) {
+		h := h
+		calls = append(calls, pluginCall{p, func(pc plugins.PluginClient) error { return h(pc, rce) }})
+	}
+	return l, calls
+}
+
+func (s *Server) createCalls(ce github.CreateEvent) (*logrus.Entry, []pluginCall) {
+	l := logrus.WithFields(logrus.Fields{
+		"org":    ce.Repo.Owner.Login,
+		"repo":   ce.Repo.Name,
+		"ref":    ce.Ref,
+		"sender": ce.Sender.Login,
+	})
+	l.Infof("Created %s.", ce.RefType)
+	var calls []pluginCall
+	for p, h := range s.Plugins.CreateEventHandlers(ce.Repo.Owner.Login, ce.Repo.Name) {
+		h := h
+		calls = append(calls, pluginCall{p, func(pc plugins.PluginClient) error { return h(pc, ce) }})
+	}
+	return l, calls
+}
+
+func (s *Server) deleteCalls(de github.DeleteEvent) (*logrus.Entry, []pluginCall) {
+	l := logrus.WithFields(logrus.Fields{
+		"org":    de.Repo.Owner.Login,
+		"repo":   de.Repo.Name,
+		"ref":    de.Ref,
+		"sender": de.Sender.Login,
+	})
+	l.Infof("Deleted %s.", de.RefType)
+	var calls []pluginCall
+	for p, h := range s.Plugins.DeleteEventHandlers(de.Repo.Owner.Login, de.Repo.Name) {
+		h := h
+		calls = append(calls, pluginCall{p, func(pc plugins.PluginClient) error { return h(pc, de) }})
+	}
+	return l, calls
+}
+
+func (s *Server) releaseCalls(re github.ReleaseEvent) (*logrus.Entry, []pluginCall) {
+	l := logrus.WithFields(logrus.Fields{
+		"org":  re.Repo.Owner.Login,
+		"repo": re.Repo.Name,
+		"tag":  re.Release.TagName,
+		"url":  re.Release.HTMLURL,
+	})
+	l.Infof("Release %s.", re.Action)
+	var calls []pluginCall
+	for p, h := range s.Plugins.ReleaseEventHandlers(re.Repo.Owner.Login, re.Repo.Name) {
+		h := h
+		calls = append(calls, pluginCall{p, func(pc plugins.PluginClient) error { return h(pc, re) }})
+	}
+	return l, calls
+}
+
+func (s *Server) checkRunCalls(cre github.CheckRunEvent) (*logrus.Entry, []pluginCall) {
+	l := logrus.WithFields(logrus.Fields{
+		"org":        cre.Repo.Owner.Login,
+		"repo":       cre.Repo.Name,
+		"name":       cre.CheckRun.Name,
+		"sha":        cre.CheckRun.HeadSHA,
+		"status":     cre.CheckRun.Status,
+		"conclusion": cre.CheckRun.Conclusion,
+	})
+	l.Infof("Check run %s.", cre.Action)
+	var calls []pluginCall
+	for p, h := range s.Plugins.CheckRunEventHandlers(cre.Repo.Owner.Login, cre.Repo.Name) {
+		h := h
+		calls = append(calls, pluginCall{p, func(pc plugins.PluginClient) error { return h(pc, cre) }})
 	}
+	return l, calls
 }
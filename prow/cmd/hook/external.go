@@ -0,0 +1,74 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+var externalClient = &http.Client{Timeout: 30 * time.Second}
+
+// externalCalls returns calls that forward the event to the external plugins
+// for org/repo that want it.
+func (s *Server) externalCalls(org, repo string, e Event) []pluginCall {
+	var calls []pluginCall
+	for _, ep := range s.Plugins.Config().ExternalPluginsFor(org, repo, e.Type) {
+		ep := ep
+		calls = append(calls, pluginCall{ep.Name, func(plugins.PluginClient) error { return s.forward(ep, e) }})
+	}
+	return calls
+}
+
+// forward sends the event to the external plugin the way GitHub sent it to
+// us, signed with the plugin's HMAC secret. The secret is read every time so
+// that it can be rotated without restarting hook.
+func (s *Server) forward(ep plugins.ExternalPlugin, e Event) error {
+	secret, err := ioutil.ReadFile(ep.HMACSecretFile)
+	if err != nil {
+		return fmt.Errorf("error reading hmac secret: %v", err)
+	}
+	secret = bytes.TrimSpace(secret)
+	if len(secret) == 0 {
+		return fmt.Errorf("hmac secret file %s is empty", ep.HMACSecretFile)
+	}
+	req, err := http.NewRequest(http.MethodPost, ep.Endpoint, bytes.NewReader(e.Payload))
+	if err != nil {
+		return fmt.Errorf("error making request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", e.Type)
+	req.Header.Set("X-GitHub-Delivery", e.ID)
+	req.Header.Set("X-Hub-Signature", github.PayloadSignature(e.Payload, secret))
+	resp, err := externalClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error forwarding to %s: %v", ep.Endpoint, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s responded with %d: %s", ep.Endpoint, resp.StatusCode, bytes.TrimSpace(b))
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+func TestExternalPlugins(t *testing.T) {
+	// Each plugin has its own secret, and neither is hook's.
+	secrets := map[string][]byte{
+		"/everything": []byte("abc"),
+		"/comments":   []byte("def"),
+	}
+	var received []string
+	fail := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if !github.ValidatePayload(body, r.Header.Get("X-Hub-Signature"), secrets[r.URL.Path]) {
+			t.Errorf("Bad signature on %s event forwarded to %s.", r.Header.Get("X-GitHub-Event"), r.URL.Path)
+		}
+		received = append(received, r.URL.Path+" "+r.Header.Get("X-GitHub-Event")+" "+r.Header.Get("X-GitHub-Delivery"))
+		if fail {
+			http.Error(w, "nope", http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "hook")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	for path, secret := range secrets {
+		if err := ioutil.WriteFile(filepath.Join(dir, path), append(secret, '\n'), 0600); err != nil {
+			t.Fatalf("Error writing secret: %v", err)
+		}
+	}
+	pluginPath := filepath.Join(dir, "plugins.yaml")
+	config := fmt.Sprintf(`external_plugins:
+  org:
+  - name: everything
+    endpoint: %s/everything
+    hmac_secret_file: %s/everything
+  org/repo:
+  - name: comments
+    endpoint: %s/comments
+    hmac_secret_file: %s/comments
+    events: [issue_comment]
+`, ts.URL, dir, ts.URL, dir)
+	if err := ioutil.WriteFile(pluginPath, []byte(config), 0644); err != nil {
+		t.Fatalf("Error writing plugins: %v", err)
+	}
+	pa := &plugins.PluginAgent{}
+	if err := pa.Load(pluginPath); err != nil {
+		t.Fatalf("Error loading plugins: %v", err)
+	}
+	s := &Server{Plugins: pa, HMACSecret: []byte("hook")}
+
+	const payload = `{"repository":{"name":"repo","owner":{"login":"org"}}}`
+	for _, e := range []Event{
+		{ID: "1", Type: "issue_comment", Payload: []byte(payload)},
+		{ID: "2", Type: "issues", Payload: []byte(payload)},
+	} {
+		_, calls, err := s.demuxEvent(e)
+		if err != nil {
+			t.Fatalf("Error demuxing %s: %v", e.Type, err)
+		}
+		for _, c := range calls {
+			if err := c.handle(plugins.PluginClient{}); err != nil {
+				t.Errorf("Error calling %s: %v", c.plugin, err)
+			}
+		}
+	}
+	expected := []string{
+		"/everything issue_comment 1",
+		"/comments issue_comment 1",
+		"/everything issues 2",
+	}
+	if fmt.Sprint(received) != fmt.Sprint(expected) {
+		t.Errorf("Expected forwards %v, got %v.", expected, received)
+	}
+
+	fail = true
+	_, calls, _ := s.demuxEvent(Event{ID: "3", Type: "issues", Payload: []byte(payload)})
+	if len(calls) != 1 || calls[0].handle(plugins.PluginClient{}) == nil {
+		t.Errorf("Expected a failing external plugin to return an error.")
+	}
+
+	fail = false
+	if err := os.Remove(filepath.Join(dir, "everything")); err != nil {
+		t.Fatalf("Error removing secret: %v", err)
+	}
+	_, calls, _ = s.demuxEvent(Event{ID: "4", Type: "issues", Payload: []byte(payload)})
+	if len(calls) != 1 || calls[0].handle(plugins.PluginClient{}) == nil {
+		t.Errorf("Expected forwarding without a secret to fail.")
+	}
+	if len(received) != 4 {
+		t.Errorf("Expected nothing to be forwarded without a secret, got %v.", received[4:])
+	}
+}
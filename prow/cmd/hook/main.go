@@ -26,16 +26,19 @@ import (
 	"syscall"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/github"
 	"k8s.io/test-infra/prow/kube"
 	"k8s.io/test-infra/prow/plugins"
+	"k8s.io/test-infra/prow/record"
 
 	_ "k8s.io/test-infra/prow/plugins/assign"
 	_ "k8s.io/test-infra/prow/plugins/cla"
 	_ "k8s.io/test-infra/prow/plugins/close"
 	_ "k8s.io/test-infra/prow/plugins/heart"
+	_ "k8s.io/test-infra/prow/plugins/help"
 	_ "k8s.io/test-infra/prow/plugins/label"
 	_ "k8s.io/test-infra/prow/plugins/lgtm"
 	_ "k8s.io/test-infra/prow/plugins/releasenote"
@@ -45,10 +48,19 @@ import (
 )
 
 var (
-	port = flag.Int("port", 8888, "Port to listen on.")
+	port      = flag.Int("port", 8888, "Port to listen on.")
+	adminPort = flag.Int("admin-port", 8889, "Port to serve dead letters on. Keep it private.")
 
-	configPath   = flag.String("config-path", "/etc/config/config", "Path to config.yaml.")
-	pluginConfig = flag.String("plugin-config", "/etc/plugins/plugins", "Path to plugin config file.")
+	queuePath = flag.String("queue-path", "", "Path to the log of queued events. If empty, queued events are lost on restart.")
+	workers   = flag.Int("workers", 20, "How many events to handle at once.")
+
+	recordDir      = flag.String("record-dir", "", "Directory to record every validated webhook in, for replaying with phony. If empty, webhooks are not recorded.")
+	recordMaxBytes = flag.Int64("record-max-bytes", 100<<20, "Size at which to start a new recording file.")
+	recordMaxFiles = flag.Int("record-max-files", 10, "How many recording files to keep. Older ones are deleted.")
+
+	configPath    = flag.String("config-path", "/etc/config/config", "Path to config.yaml.")
+	jobConfigPath = flag.String("job-config-path", "", "Path to a directory or glob of job config files.")
+	pluginConfig  = flag.String("plugin-config", "/etc/plugins/plugins", "Path to plugin config file.")
 
 	local = flag.Bool("local", false, "Run locally for testing purposes only. Does not require secret files.")
 	dry   = flag.Bool("dry", false, "Dry run for testing. Uses API tokens but does not mutate.")
@@ -56,6 +68,11 @@ var (
 	githubBotName     = flag.String("github-bot-name", "", "Name of the GitHub bot.")
 	webhookSecretFile = flag.String("hmac-secret-file", "/etc/webhook/hmac", "Path to the file containing the GitHub HMAC secret.")
 	githubTokenFile   = flag.String("github-token-file", "/etc/github/oauth", "Path to the file containing the GitHub OAuth secret.")
+	githubEndpoint    = flag.String("github-endpoint", github.DefaultEndpoint, "Base URL of the GitHub API.")
+	githubAppID       = flag.String("github-app-id", "", "ID of the GitHub App to authenticate as. If set, --github-app-key-file is used instead of --github-token-file.")
+	githubAppKeyFile  = flag.String("github-app-key-file", "/etc/github/app-key", "Path to the file containing the GitHub App's private key.")
+	githubTokens      = flag.Int("github-hourly-tokens", 0, "How many GitHub API calls to make per hour at most. If 0, only GitHub's rate limit applies.")
+	githubBurst       = flag.Int("github-burst", 100, "How many GitHub API calls to allow in a burst when --github-hourly-tokens is set.")
 )
 
 func main() {
@@ -92,20 +109,36 @@ func main() {
 		}
 		webhookSecret = bytes.TrimSpace(webhookSecretRaw)
 
-		oauthSecretRaw, err := ioutil.ReadFile(*githubTokenFile)
-		if err != nil {
-			logrus.WithError(err).Fatal("Could not read oauth secret file.")
-		}
-		oauthSecret := string(bytes.TrimSpace(oauthSecretRaw))
-
 		if *githubBotName == "" {
 			logrus.Fatal("Must specify --github-bot-name.")
 		}
-		if *dry {
-			githubClient = github.NewDryRunClient(*githubBotName, oauthSecret)
+		if *githubAppID != "" {
+			appKey, err := ioutil.ReadFile(*githubAppKeyFile)
+			if err != nil {
+				logrus.WithError(err).Fatal("Could not read GitHub App private key file.")
+			}
+			if *dry {
+				githubClient, err = github.NewDryRunAppClient(*githubBotName, *githubAppID, appKey, *githubEndpoint)
+			} else {
+				githubClient, err = github.NewAppClient(*githubBotName, *githubAppID, appKey, *githubEndpoint)
+			}
+			if err != nil {
+				logrus.WithError(err).Fatal("Error creating GitHub App client.")
+			}
 		} else {
-			githubClient = github.NewClient(*githubBotName, oauthSecret)
+			oauthSecretRaw, err := ioutil.ReadFile(*githubTokenFile)
+			if err != nil {
+				logrus.WithError(err).Fatal("Could not read oauth secret file.")
+			}
+			oauthSecret := string(bytes.TrimSpace(oauthSecretRaw))
+
+			if *dry {
+				githubClient = github.NewDryRunClient(*githubBotName, oauthSecret, *githubEndpoint)
+			} else {
+				githubClient = github.NewClient(*githubBotName, oauthSecret, *githubEndpoint)
+			}
 		}
+		githubClient.Throttle(*githubTokens, *githubBurst)
 
 		kubeClient, err = kube.NewClientInCluster("default")
 		if err != nil {
@@ -114,9 +147,16 @@ func main() {
 	}
 
 	configAgent := &config.ConfigAgent{}
-	if err := configAgent.Start(*configPath); err != nil {
+	if err := configAgent.Start(*configPath, *jobConfigPath); err != nil {
 		logrus.WithError(err).Fatal("Error starting config agent.")
 	}
+	// Jobs are looked up per event, so new jobs take effect immediately.
+	configAgent.Subscribe(func(_, c *config.Config) {
+		logrus.WithFields(logrus.Fields{
+			"presubmits":  len(c.AllPresubmits()),
+			"postsubmits": len(c.AllPostsubmits()),
+		}).Info("Job config changed.")
+	})
 
 	pluginAgent := &plugins.PluginAgent{
 		PluginClient: plugins.PluginClient{
@@ -129,15 +169,44 @@ func main() {
 		logrus.WithError(err).Fatal("Error starting plugins.")
 	}
 
+	queue, err := NewQueue(*queuePath)
+	if err != nil {
+		logrus.WithError(err).Fatal("Error opening event queue.")
+	}
 	server := &Server{
 		HMACSecret:  webhookSecret,
 		ConfigAgent: configAgent,
 		Plugins:     pluginAgent,
+		Queue:       queue,
+		Workers:     *workers,
+	}
+	if *recordDir != "" {
+		server.Recorder = &record.Recorder{
+			Dir:      *recordDir,
+			MaxBytes: *recordMaxBytes,
+			MaxFiles: *recordMaxFiles,
+		}
 	}
+	if err := server.Start(); err != nil {
+		logrus.WithError(err).Fatal("Error starting event workers.")
+	}
+
+	// Dead letters hold webhook payloads, so they get their own port that
+	// isn't exposed outside the cluster.
+	adminMux := http.NewServeMux()
+	adminMux.HandleFunc("/dead-letters", server.ServeDeadLetters)
+	go func() {
+		logrus.Fatal(http.ListenAndServe(":"+strconv.Itoa(*adminPort), adminMux))
+	}()
 
 	// Return 200 on / for health checks.
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
 	// For /hook, handle a webhook normally.
 	http.Handle("/hook", server)
+	// Debugging and monitoring.
+	http.Handle("/config", configAgent)
+	http.Handle("/plugin-config", pluginAgent)
+	http.HandleFunc("/plugin-help", pluginAgent.ServeHelp)
+	http.Handle("/metrics", promhttp.Handler())
 	logrus.Fatal(http.ListenAndServe(":"+strconv.Itoa(*port), nil))
 }
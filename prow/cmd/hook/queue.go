@@ -0,0 +1,228 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// Event is a webhook that hook accepted, along with how far the plugins got
+// in handling it.
+type Event struct {
+	// ID is GitHub's delivery ID for the webhook.
+	ID       string          `json:"id"`
+	Type     string          `json:"type"`
+	Payload  json.RawMessage `json:"payload"`
+	Received time.Time       `json:"received"`
+	// Done lists the plugins that handled the event. They are skipped if
+	// the event is handled again after a restart or a replay.
+	Done []string `json:"done,omitempty"`
+	// Failed maps plugins that ran out of retries to their last error. An
+	// event with failures is a dead letter.
+	Failed map[string]string `json:"failed,omitempty"`
+}
+
+func (e Event) done(plugin string) bool {
+	for _, p := range e.Done {
+		if p == plugin {
+			return true
+		}
+	}
+	return false
+}
+
+func (e Event) copy() Event {
+	c := e
+	c.Done = append([]string(nil), e.Done...)
+	if e.Failed != nil {
+		c.Failed = map[string]string{}
+		for k, v := range e.Failed {
+			c.Failed[k] = v
+		}
+	}
+	return c
+}
+
+// Queue durably stores events until every plugin has handled them.
+type Queue interface {
+	// Put stores the event, replacing any earlier version with the same ID.
+	// Once it returns, the event survives a restart.
+	Put(e Event) error
+	// Delete forgets the event.
+	Delete(id string) error
+	// List returns every stored event in the order they were first put.
+	List() ([]Event, error)
+}
+
+// logQueue is a Queue kept in an append-only log of JSON records. The log is
+// compacted when it is opened and whenever it grows much bigger than the
+// events it holds.
+type logQueue struct {
+	mut  sync.Mutex
+	path string
+	// f is nil if the queue is only in memory.
+	f *os.File
+	// records is how many records are in the log.
+	records int
+
+	events map[string]Event
+	order  []string
+}
+
+type logRecord struct {
+	Put    *Event `json:"put,omitempty"`
+	Delete string `json:"delete,omitempty"`
+}
+
+// NewQueue opens the queue logged at path, creating it if needed. If path is
+// empty, events are only kept in memory and are lost when hook restarts.
+func NewQueue(path string) (Queue, error) {
+	q := &logQueue{
+		path:   path,
+		events: map[string]Event{},
+	}
+	if path == "" {
+		return q, nil
+	}
+	f, err := os.Open(path)
+	if err == nil {
+		dec := json.NewDecoder(f)
+		for {
+			var r logRecord
+			if err := dec.Decode(&r); err == io.EOF {
+				break
+			} else if err != nil {
+				// Most likely we died halfway through a write.
+				logrus.WithError(err).Warningf("Ignoring the rest of %s.", path)
+				break
+			}
+			q.apply(r)
+		}
+		f.Close()
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error opening %s: %v", path, err)
+	}
+	if err := q.compact(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *logQueue) apply(r logRecord) {
+	if r.Put != nil {
+		if _, ok := q.events[r.Put.ID]; !ok {
+			q.order = append(q.order, r.Put.ID)
+		}
+		q.events[r.Put.ID] = r.Put.copy()
+	} else if r.Delete != "" {
+		delete(q.events, r.Delete)
+	}
+}
+
+// compact rewrites the log with only the events that are still stored.
+func (q *logQueue) compact() error {
+	var order []string
+	for _, id := range q.order {
+		if _, ok := q.events[id]; ok {
+			order = append(order, id)
+		}
+	}
+	q.order = order
+	if q.path == "" {
+		return nil
+	}
+	tmp := q.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %v", tmp, err)
+	}
+	enc := json.NewEncoder(f)
+	for _, id := range q.order {
+		e := q.events[id]
+		if err := enc.Encode(logRecord{Put: &e}); err != nil {
+			f.Close()
+			return fmt.Errorf("error writing %s: %v", tmp, err)
+		}
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("error syncing %s: %v", tmp, err)
+	}
+	f.Close()
+	if err := os.Rename(tmp, q.path); err != nil {
+		return fmt.Errorf("error replacing %s: %v", q.path, err)
+	}
+	if q.f != nil {
+		q.f.Close()
+	}
+	q.f, err = os.OpenFile(q.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %v", q.path, err)
+	}
+	q.records = len(q.order)
+	return nil
+}
+
+func (q *logQueue) append(r logRecord) error {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+	if q.f != nil {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		if _, err := q.f.Write(append(b, '\n')); err != nil {
+			return fmt.Errorf("error writing %s: %v", q.path, err)
+		}
+		if err := q.f.Sync(); err != nil {
+			return fmt.Errorf("error syncing %s: %v", q.path, err)
+		}
+		q.records++
+	}
+	q.apply(r)
+	if q.records > 2*len(q.events)+100 || len(q.order) > 2*len(q.events)+100 {
+		return q.compact()
+	}
+	return nil
+}
+
+func (q *logQueue) Put(e Event) error {
+	return q.append(logRecord{Put: &e})
+}
+
+func (q *logQueue) Delete(id string) error {
+	return q.append(logRecord{Delete: id})
+}
+
+func (q *logQueue) List() ([]Event, error) {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+	var events []Event
+	for _, id := range q.order {
+		if e, ok := q.events[id]; ok {
+			events = append(events, e.copy())
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func eventIDs(t *testing.T, q Queue) []string {
+	events, err := q.List()
+	if err != nil {
+		t.Fatalf("Error listing events: %v", err)
+	}
+	var ids []string
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+func TestQueueSurvivesRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "queue.log")
+
+	q, err := NewQueue(path)
+	if err != nil {
+		t.Fatalf("Error opening queue: %v", err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if err := q.Put(Event{ID: id, Type: "issues", Payload: []byte(`{}`)}); err != nil {
+			t.Fatalf("Error putting %s: %v", id, err)
+		}
+	}
+	if err := q.Put(Event{ID: "a", Type: "issues", Payload: []byte(`{}`), Done: []string{"lgtm"}}); err != nil {
+		t.Fatalf("Error updating a: %v", err)
+	}
+	if err := q.Delete("b"); err != nil {
+		t.Fatalf("Error deleting b: %v", err)
+	}
+
+	// Simulate dying halfway through a write.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("Error opening log: %v", err)
+	}
+	f.WriteString(`{"put":{"id":"d","ty`)
+	f.Close()
+
+	q, err = NewQueue(path)
+	if err != nil {
+		t.Fatalf("Error reopening queue: %v", err)
+	}
+	if ids := eventIDs(t, q); !reflect.DeepEqual(ids, []string{"a", "c"}) {
+		t.Errorf("Expected events a and c, got %v.", ids)
+	}
+	events, _ := q.List()
+	if len(events) > 0 && !events[0].done("lgtm") {
+		t.Errorf("Expected a to remember that lgtm is done, got %+v.", events[0])
+	}
+	// The queue still works after recovering.
+	if err := q.Put(Event{ID: "e", Type: "push", Payload: []byte(`{}`)}); err != nil {
+		t.Fatalf("Error putting e: %v", err)
+	}
+	q, err = NewQueue(path)
+	if err != nil {
+		t.Fatalf("Error reopening queue: %v", err)
+	}
+	if ids := eventIDs(t, q); !reflect.DeepEqual(ids, []string{"a", "c", "e"}) {
+		t.Errorf("Expected events a, c and e, got %v.", ids)
+	}
+}
+
+func TestQueueCompacts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "queue.log")
+
+	q, err := NewQueue(path)
+	if err != nil {
+		t.Fatalf("Error opening queue: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		id := fmt.Sprintf("event-%d", i)
+		if err := q.Put(Event{ID: id, Type: "issues", Payload: []byte(`{}`)}); err != nil {
+			t.Fatalf("Error putting %s: %v", id, err)
+		}
+		if err := q.Delete(id); err != nil {
+			t.Fatalf("Error deleting %s: %v", id, err)
+		}
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Error checking log: %v", err)
+	}
+	// 2000 uncompacted records would be well over 50KB.
+	if fi.Size() > 10000 {
+		t.Errorf("Expected the log to be compacted, but it is %d bytes.", fi.Size())
+	}
+	if ids := eventIDs(t, q); len(ids) != 0 {
+		t.Errorf("Expected no events, got %v.", ids)
+	}
+}
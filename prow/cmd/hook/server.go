@@ -19,24 +19,40 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"github.com/Sirupsen/logrus"
 	"io/ioutil"
 	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/satori/go.uuid"
 
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/github"
 	"k8s.io/test-infra/prow/plugins"
+	"k8s.io/test-infra/prow/record"
 )
 
-// Server implements http.Handler. It validates incoming GitHub webhooks and
-// then dispatches them to the appropriate plugins.
+// Server implements http.Handler. It validates incoming GitHub webhooks,
+// stores them in the queue and then dispatches them to the appropriate
+// plugins. Call Start before serving.
 type Server struct {
 	Plugins     *plugins.PluginAgent
 	ConfigAgent *config.ConfigAgent
 	HMACSecret  []byte
+	// Queue keeps events until every plugin has handled them.
+	Queue Queue
+	// Workers is how many events are handled at once.
+	Workers int
+	// Recorder archives every validated webhook if it is set.
+	Recorder *record.Recorder
+
+	mut     sync.Mutex
+	cond    *sync.Cond
+	pending []Event
 }
 
-// ServeHTTP validates an incoming webhook and puts it into the event channel.
+// ServeHTTP validates an incoming webhook and puts it into the queue.
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
@@ -67,45 +83,133 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "403 Forbidden: Invalid X-Hub-Signature", http.StatusForbidden)
 		return
 	}
+
+	if s.Recorder != nil {
+		if err := s.Recorder.Record(eventType, r.Header, payload); err != nil {
+			logrus.WithError(err).Error("Error recording webhook.")
+		}
+	}
+
+	if handledEvents[eventType] {
+		id := r.Header.Get("X-GitHub-Delivery")
+		if id == "" {
+			id = uuid.NewV1().String()
+		}
+		e := Event{
+			ID:       id,
+			Type:     eventType,
+			Payload:  payload,
+			Received: time.Now(),
+		}
+		// Only acknowledge the webhook once we're sure not to lose it.
+		if err := s.Queue.Put(e); err != nil {
+			logrus.WithError(err).Error("Error queueing event.")
+			http.Error(w, "500 Internal Server Error: Failed to queue event", http.StatusInternalServerError)
+			return
+		}
+		s.enqueue(e)
+	}
 	fmt.Fprint(w, "Event received. Have a nice day.")
+}
 
-	if err := s.demuxEvent(eventType, payload); err != nil {
-		logrus.WithError(err).Error("Error parsing event.")
+// The event types that plugins can handle. We acknowledge and drop others.
+var handledEvents = map[string]bool{}
+
+func init() {
+	for _, e := range plugins.EventTypes {
+		handledEvents[e] = true
 	}
 }
 
-func (s *Server) demuxEvent(eventType string, payload []byte) error {
-	switch eventType {
+// demuxEvent parses the payload and returns the plugin handlers to call,
+// including forwarding it to any external plugins.
+func (s *Server) demuxEvent(e Event) (*logrus.Entry, []pluginCall, error) {
+	var (
+		l         *logrus.Entry
+		calls     []pluginCall
+		org, repo string
+	)
+	switch e.Type {
 	case "issues":
 		var i github.IssueEvent
-		if err := json.Unmarshal(payload, &i); err != nil {
-			return err
+		if err := json.Unmarshal(e.Payload, &i); err != nil {
+			return nil, nil, err
 		}
-		go s.handleIssueEvent(i)
+		org, repo = i.Repo.Owner.Login, i.Repo.Name
+		l, calls = s.issueCalls(i)
 	case "issue_comment":
 		var ic github.IssueCommentEvent
-		if err := json.Unmarshal(payload, &ic); err != nil {
-			return err
+		if err := json.Unmarshal(e.Payload, &ic); err != nil {
+			return nil, nil, err
 		}
-		go s.handleIssueCommentEvent(ic)
+		org, repo = ic.Repo.Owner.Login, ic.Repo.Name
+		l, calls = s.issueCommentCalls(ic)
 	case "pull_request":
 		var pr github.PullRequestEvent
-		if err := json.Unmarshal(payload, &pr); err != nil {
-			return err
+		if err := json.Unmarshal(e.Payload, &pr); err != nil {
+			return nil, nil, err
 		}
-		go s.handlePullRequestEvent(pr)
+		org, repo = pr.PullRequest.Base.Repo.Owner.Login, pr.PullRequest.Base.Repo.Name
+		l, calls = s.pullRequestCalls(pr)
 	case "push":
 		var pe github.PushEvent
-		if err := json.Unmarshal(payload, &pe); err != nil {
-			return err
+		if err := json.Unmarshal(e.Payload, &pe); err != nil {
+			return nil, nil, err
 		}
-		go s.handlePushEvent(pe)
+		org, repo = pe.Repo.Owner.Name, pe.Repo.Name
+		l, calls = s.pushCalls(pe)
 	case "status":
 		var se github.StatusEvent
-		if err := json.Unmarshal(payload, &se); err != nil {
-			return err
+		if err := json.Unmarshal(e.Payload, &se); err != nil {
+			return nil, nil, err
+		}
+		org, repo = se.Repo.Owner.Login, se.Repo.Name
+		l, calls = s.statusCalls(se)
+	case "pull_request_review":
+		var re github.ReviewEvent
+		if err := json.Unmarshal(e.Payload, &re); err != nil {
+			return nil, nil, err
+		}
+		org, repo = re.Repo.Owner.Login, re.Repo.Name
+		l, calls = s.reviewCalls(re)
+	case "pull_request_review_comment":
+		var rce github.ReviewCommentEvent
+		if err := json.Unmarshal(e.Payload, &rce); err != nil {
+			return nil, nil, err
+		}
+		org, repo = rce.Repo.Owner.Login, rce.Repo.Name
+		l, calls = s.reviewCommentCalls(rce)
+	case "create":
+		var ce github.CreateEvent
+		if err := json.Unmarshal(e.Payload, &ce); err != nil {
+			return nil, nil, err
+		}
+		org, repo = ce.Repo.Owner.Login, ce.Repo.Name
+		l, calls = s.createCalls(ce)
+	case "delete":
+		var de github.DeleteEvent
+		if err := json.Unmarshal(e.Payload, &de); err != nil {
+			return nil, nil, err
+		}
+		org, repo = de.Repo.Owner.Login, de.Repo.Name
+		l, calls = s.deleteCalls(de)
+	case "release":
+		var re github.ReleaseEvent
+		if err := json.Unmarshal(e.Payload, &re); err != nil {
+			return nil, nil, err
+		}
+		org, repo = re.Repo.Owner.Login, re.Repo.Name
+		l, calls = s.releaseCalls(re)
+	case "check_run":
+		var cre github.CheckRunEvent
+		if err := json.Unmarshal(e.Payload, &cre); err != nil {
+			return nil, nil, err
 		}
-		go s.handleStatusEvent(se)
+		org, repo = cre.Repo.Owner.Login, cre.Repo.Name
+		l, calls = s.checkRunCalls(cre)
+	default:
+		return nil, nil, fmt.Errorf("unhandled event type %s", e.Type)
 	}
-	return nil
+	calls = append(calls, s.externalCalls(org, repo, e)...)
+	return l, calls, nil
 }
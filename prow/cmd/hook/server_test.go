@@ -17,10 +17,17 @@ limitations under the License.
 package main
 
 import (
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/plugins"
 )
 
 func TestServeHTTPErrors(t *testing.T) {
@@ -102,3 +109,56 @@ func TestServeHTTPErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestDemuxNewEventTypes(t *testing.T) {
+	var handled []string
+	seen := func(eventType string) error {
+		handled = append(handled, eventType)
+		return nil
+	}
+	const name = "hook-test-events"
+	plugins.RegisterReviewEventHandler(name, func(plugins.PluginClient, github.ReviewEvent) error { return seen("pull_request_review") })
+	plugins.RegisterReviewCommentEventHandler(name, func(plugins.PluginClient, github.ReviewCommentEvent) error {
+		return seen("pull_request_review_comment")
+	})
+	plugins.RegisterCreateEventHandler(name, func(plugins.PluginClient, github.CreateEvent) error { return seen("create") })
+	plugins.RegisterDeleteEventHandler(name, func(plugins.PluginClient, github.DeleteEvent) error { return seen("delete") })
+	plugins.RegisterReleaseEventHandler(name, func(plugins.PluginClient, github.ReleaseEvent) error { return seen("release") })
+	plugins.RegisterCheckRunEventHandler(name, func(plugins.PluginClient, github.CheckRunEvent) error { return seen("check_run") })
+
+	dir, err := ioutil.TempDir("", "hook")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	pluginPath := filepath.Join(dir, "plugins.yaml")
+	if err := ioutil.WriteFile(pluginPath, []byte("plugins:\n  org/repo:\n  - "+name+"\n"), 0644); err != nil {
+		t.Fatalf("Error writing plugins: %v", err)
+	}
+	pa := &plugins.PluginAgent{}
+	if err := pa.Load(pluginPath); err != nil {
+		t.Fatalf("Error loading plugins: %v", err)
+	}
+	s := &Server{Plugins: pa}
+
+	const payload = `{"repository":{"name":"repo","owner":{"login":"org"}}}`
+	eventTypes := []string{"pull_request_review", "pull_request_review_comment", "create", "delete", "release", "check_run"}
+	for _, eventType := range eventTypes {
+		if !handledEvents[eventType] {
+			t.Errorf("Expected hook to handle %s events.", eventType)
+		}
+		_, calls, err := s.demuxEvent(Event{Type: eventType, Payload: []byte(payload)})
+		if err != nil {
+			t.Errorf("For %s, error demuxing: %v", eventType, err)
+			continue
+		}
+		if len(calls) != 1 {
+			t.Errorf("For %s, expected one plugin call, got %d.", eventType, len(calls))
+			continue
+		}
+		calls[0].handle(plugins.PluginClient{})
+	}
+	if !reflect.DeepEqual(handled, eventTypes) {
+		t.Errorf("Expected handlers for %v to run, got %v.", eventTypes, handled)
+	}
+}
@@ -29,13 +29,18 @@ import (
 )
 
 var configPath = flag.String("config-path", "/etc/config/config", "Path to config.yaml.")
+var jobConfigPath = flag.String("job-config-path", "", "Path to a directory or glob of job config files.")
+
+// A cron run that was due less than this long ago is still on time. Anything
+// older was missed, and is handled according to the job's catch-up policy.
+const missedAfter = 5 * time.Minute
 
 func main() {
 	flag.Parse()
 	logrus.SetFormatter(&logrus.JSONFormatter{})
 
 	ca := config.ConfigAgent{}
-	if err := ca.Start(*configPath); err != nil {
+	if err := ca.Start(*configPath, *jobConfigPath); err != nil {
 		logrus.WithError(err).Fatal("Error starting config agent.")
 	}
 
@@ -44,8 +49,13 @@ func main() {
 		logrus.WithError(err).Fatal("Error getting kube client.")
 	}
 
+	kca := kube.NewCache(kc)
+	if err := kca.Start(make(chan struct{})); err != nil {
+		logrus.WithError(err).Fatal("Error starting kube cache.")
+	}
+
 	for now := range time.Tick(1 * time.Minute) {
-		if err := sync(kc, ca.Config(), now); err != nil {
+		if err := sync(kca, ca.Config(), now); err != nil {
 			logrus.WithError(err).Error("Error syncing periodic jobs.")
 		}
 	}
@@ -73,7 +83,7 @@ func sync(kc kubeClient, cfg *config.Config, now time.Time) error {
 	}
 	for _, p := range cfg.Periodics {
 		j, ok := latestJobs[p.Name]
-		if !ok || (j.Complete() && now.Sub(j.Status.StartTime) > p.GetInterval()) {
+		if shouldStart(p, j, ok, now) {
 			if _, err := kc.CreateProwJob(plank.NewProwJob(plank.PeriodicSpec(p))); err != nil {
 				return fmt.Errorf("error creating prow job: %v", err)
 			}
@@ -81,3 +91,22 @@ func sync(kc kubeClient, cfg *config.Config, now time.Time) error {
 	}
 	return nil
 }
+
+// shouldStart returns whether to start the periodic given its latest run, if
+// there is one.
+func shouldStart(p config.Periodic, latest kube.ProwJob, ok bool, now time.Time) bool {
+	if ok && !latest.Complete() {
+		return false
+	}
+	s := p.GetCron()
+	if s == nil {
+		return !ok || now.Sub(latest.Status.StartTime) > p.GetInterval()
+	}
+	// Start if there is a scheduled time that we haven't missed yet.
+	if !s.Next(now.Add(-missedAfter)).After(now) {
+		return !ok || !s.Next(latest.Status.StartTime).After(now)
+	}
+	// Otherwise only make up for a missed run if the job asks for it. With no
+	// previous run there is nothing to make up for.
+	return ok && p.CatchUp == config.CatchUpOnce && !s.Next(latest.Status.StartTime).After(now)
+}
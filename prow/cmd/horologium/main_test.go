@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/cron"
 	"k8s.io/test-infra/prow/kube"
 )
 
@@ -120,3 +121,115 @@ func TestSync(t *testing.T) {
 		}
 	}
 }
+
+// Assumes there is one periodic job called "j" that runs daily at 02:00.
+func TestCronSync(t *testing.T) {
+	day := func(d, h, m int) time.Time {
+		return time.Date(2017, time.May, d, h, m, 0, 0, time.UTC)
+	}
+	testcases := []struct {
+		testName string
+
+		catchUp      config.CatchUpPolicy
+		now          time.Time
+		lastStart    time.Time
+		lastComplete bool
+
+		shouldStart bool
+	}{
+		{
+			testName:    "no job, on time",
+			now:         day(17, 2, 1),
+			shouldStart: true,
+		},
+		{
+			testName:    "no job, not time yet",
+			now:         day(17, 1, 59),
+			shouldStart: false,
+		},
+		{
+			testName:    "no job, missed, run once",
+			catchUp:     config.CatchUpOnce,
+			now:         day(17, 3, 0),
+			shouldStart: false,
+		},
+		{
+			testName:     "ran yesterday, on time",
+			now:          day(17, 2, 1),
+			lastStart:    day(16, 2, 0),
+			lastComplete: true,
+			shouldStart:  true,
+		},
+		{
+			testName:     "ran yesterday, still running",
+			now:          day(17, 2, 1),
+			lastStart:    day(16, 2, 0),
+			lastComplete: false,
+			shouldStart:  false,
+		},
+		{
+			testName:     "already ran today",
+			now:          day(17, 2, 3),
+			lastStart:    day(17, 2, 0),
+			lastComplete: true,
+			shouldStart:  false,
+		},
+		{
+			testName:     "missed, skip",
+			catchUp:      config.CatchUpSkip,
+			now:          day(17, 9, 0),
+			lastStart:    day(14, 2, 0),
+			lastComplete: true,
+			shouldStart:  false,
+		},
+		{
+			testName:     "missed, run once",
+			catchUp:      config.CatchUpOnce,
+			now:          day(17, 9, 0),
+			lastStart:    day(14, 2, 0),
+			lastComplete: true,
+			shouldStart:  true,
+		},
+		{
+			testName:     "caught up, run once",
+			catchUp:      config.CatchUpOnce,
+			now:          day(17, 9, 5),
+			lastStart:    day(17, 9, 0),
+			lastComplete: true,
+			shouldStart:  false,
+		},
+	}
+	sched, err := cron.Parse("0 2 * * *")
+	if err != nil {
+		t.Fatalf("Error parsing schedule: %v", err)
+	}
+	for _, tc := range testcases {
+		cfg := config.Config{
+			Periodics: []config.Periodic{{Name: "j", CatchUp: tc.catchUp}},
+		}
+		cfg.Periodics[0].SetCron(sched)
+
+		var jobs []kube.ProwJob
+		if !tc.lastStart.IsZero() {
+			jobs = []kube.ProwJob{{
+				Spec: kube.ProwJobSpec{
+					Type: kube.PeriodicJob,
+					Job:  "j",
+				},
+				Status: kube.ProwJobStatus{
+					StartTime: tc.lastStart,
+				},
+			}}
+			if tc.lastComplete {
+				jobs[0].Status.CompletionTime = tc.lastStart.Add(time.Minute)
+			}
+		}
+		kc := &fakeKube{jobs: jobs}
+		if err := sync(kc, &cfg, tc.now); err != nil {
+			t.Fatalf("For case %s, didn't expect error: %v", tc.testName, err)
+		}
+		if tc.shouldStart != kc.created {
+			t.Errorf("For case %s, did the wrong thing.", tc.testName)
+		}
+	}
+}
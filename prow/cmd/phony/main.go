@@ -19,12 +19,16 @@ package main
 import (
 	"bytes"
 	"flag"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/satori/go.uuid"
 
 	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/record"
 )
 
 var (
@@ -32,11 +36,39 @@ var (
 	hmac    = flag.String("hmac", "abcde12345", "HMAC token to sign payload with.")
 	event   = flag.String("event", "ping", "Type of event to send, such as pull_request.")
 	payload = flag.String("payload", "", "File to send as payload. If unspecified, sends \"{}\".")
+
+	replay = flag.String("replay", "", "Webhook recording file or directory made by hook's --record-dir to replay instead of sending a single payload.")
+	repo   = flag.String("repo", "", "Only replay webhooks for this repo, such as kubernetes/test-infra.")
+	pr     = flag.Int("pr", 0, "Only replay webhooks for this PR or issue number.")
+	since  = flag.String("since", "", "Only replay webhooks received at or after this RFC3339 time.")
+	until  = flag.String("until", "", "Only replay webhooks received at or before this RFC3339 time.")
+	speed  = flag.Float64("speed", 0, "Replay this many times faster than the webhooks were received. If 0, send them one after another without waiting.")
 )
 
 func main() {
 	flag.Parse()
 
+	if *replay != "" {
+		f := record.Filter{Repo: *repo, PR: *pr}
+		var err error
+		if f.Since, err = parseTime(*since); err != nil {
+			logrus.WithError(err).Fatal("Bad --since.")
+		}
+		if f.Until, err = parseTime(*until); err != nil {
+			logrus.WithError(err).Fatal("Bad --until.")
+		}
+		recs, err := record.Load(*replay)
+		if err != nil {
+			logrus.WithError(err).Fatal("Could not load recorded webhooks.")
+		}
+		n, err := replayRecords(&http.Client{}, recs, f, *speed, time.Sleep)
+		if err != nil {
+			logrus.WithError(err).Fatalf("Error replaying webhooks after sending %d.", n)
+		}
+		logrus.Infof("Replayed %d webhooks.", n)
+		return
+	}
+
 	var body []byte
 	if *payload == "" {
 		body = []byte("{}")
@@ -48,25 +80,80 @@ func main() {
 		body = d
 	}
 
+	if err := send(&http.Client{}, *event, nil, body); err != nil {
+		logrus.WithError(err).Fatal("Error sending webhook.")
+	}
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// originalDeliveryHeader holds the delivery ID a replayed webhook was
+// recorded with. Replays get a fresh X-GitHub-Delivery so that hook doesn't
+// mistake them for the original deliveries.
+const originalDeliveryHeader = "X-Prow-Original-Delivery"
+
+// replayRecords sends the records that match the filter in order, spacing
+// them out like they were received divided by speed. It returns how many it
+// sent.
+func replayRecords(c *http.Client, recs []record.Record, f record.Filter, speed float64, sleep func(time.Duration)) (int, error) {
+	var last time.Time
+	sent := 0
+	for _, rec := range recs {
+		if !f.Match(rec) {
+			continue
+		}
+		if speed > 0 && !last.IsZero() {
+			sleep(time.Duration(float64(rec.Time.Sub(last)) / speed))
+		}
+		last = rec.Time
+		headers := map[string]string{}
+		for k, v := range rec.Headers {
+			headers[k] = v
+		}
+		if id, ok := headers["X-GitHub-Delivery"]; ok {
+			headers[originalDeliveryHeader] = id
+		}
+		headers["X-GitHub-Delivery"] = uuid.NewV4().String()
+		if err := send(c, rec.Event, headers, rec.Payload); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// send signs the body with our HMAC token and sends it to the hook.
+func send(c *http.Client, eventType string, headers map[string]string, body []byte) error {
 	req, err := http.NewRequest(http.MethodPost, *address, bytes.NewBuffer(body))
 	if err != nil {
-		logrus.WithError(err).Fatal("Could not make request.")
+		return fmt.Errorf("could not make request: %v", err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
 	}
-	req.Header.Set("X-GitHub-Event", *event)
+	req.Header.Set("X-GitHub-Event", eventType)
 	req.Header.Set("X-Hub-Signature", github.PayloadSignature(body, []byte(*hmac)))
 
-	c := &http.Client{}
 	resp, err := c.Do(req)
 	if err != nil {
-		logrus.WithError(err).Fatal("Error making HTTP request.")
+		return fmt.Errorf("error making HTTP request: %v", err)
 	}
 	defer resp.Body.Close()
 	rb, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		logrus.WithError(err).Fatal("Error reading response body.")
+		return fmt.Errorf("error reading response body: %v", err)
 	}
 	logrus.WithFields(logrus.Fields{
-		"code": resp.StatusCode,
-		"body": string(bytes.TrimSpace(rb)),
+		"event":    eventType,
+		"delivery": headers["X-GitHub-Delivery"],
+		"original": headers[originalDeliveryHeader],
+		"code":     resp.StatusCode,
+		"body":     string(bytes.TrimSpace(rb)),
 	}).Info("HTTP response.")
+	return nil
 }
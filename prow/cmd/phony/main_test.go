@@ -0,0 +1,81 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/record"
+)
+
+func TestReplayRecords(t *testing.T) {
+	var deliveries, fresh []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if !github.ValidatePayload(body, r.Header.Get("X-Hub-Signature"), []byte(*hmac)) {
+			t.Errorf("Bad signature for %s.", r.Header.Get("X-GitHub-Delivery"))
+		}
+		if r.Header.Get("X-GitHub-Event") != "issues" {
+			t.Errorf("Expected an issues event, got %s.", r.Header.Get("X-GitHub-Event"))
+		}
+		deliveries = append(deliveries, r.Header.Get(originalDeliveryHeader))
+		fresh = append(fresh, r.Header.Get("X-GitHub-Delivery"))
+	}))
+	defer s.Close()
+	*address = s.URL
+
+	start := time.Now()
+	rec := func(id string, offset time.Duration, number string) record.Record {
+		return record.Record{
+			Time:    start.Add(offset),
+			Event:   "issues",
+			Headers: map[string]string{"X-GitHub-Delivery": id},
+			Payload: []byte(`{"repository":{"full_name":"org/repo"},"issue":{"number":` + number + `}}`),
+		}
+	}
+	recs := []record.Record{
+		rec("a", 0, "1"),
+		rec("b", 10*time.Second, "2"),
+		rec("c", 30*time.Second, "1"),
+		rec("d", 90*time.Second, "1"),
+	}
+	var slept []time.Duration
+	sleep := func(d time.Duration) { slept = append(slept, d) }
+
+	f := record.Filter{PR: 1, Until: start.Add(time.Minute)}
+	n, err := replayRecords(&http.Client{}, recs, f, 10, sleep)
+	if err != nil {
+		t.Fatalf("Error replaying: %v", err)
+	}
+	if n != 2 || !reflect.DeepEqual(deliveries, []string{"a", "c"}) {
+		t.Errorf("Expected a and c to be replayed, got %d: %v.", n, deliveries)
+	}
+	for i, id := range fresh {
+		if id == "" || id == deliveries[i] || (i > 0 && id == fresh[i-1]) {
+			t.Errorf("Expected a fresh delivery ID for %s, got %q.", deliveries[i], id)
+		}
+	}
+	if !reflect.DeepEqual(slept, []time.Duration{3 * time.Second}) {
+		t.Errorf("Expected to wait 3s between a and c, got %v.", slept)
+	}
+}
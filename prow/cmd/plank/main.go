@@ -20,18 +20,21 @@ import (
 	"bytes"
 	"flag"
 	"io/ioutil"
-	"time"
 
 	"github.com/Sirupsen/logrus"
 
+	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/jenkins"
 	"k8s.io/test-infra/prow/kube"
 	"k8s.io/test-infra/prow/plank"
 )
 
 var (
-	totURL   = flag.String("tot-url", "http://tot", "Tot URL")
-	crierURL = flag.String("crier-url", "http://crier", "Crier URL")
+	totURL = flag.String("tot-url", "http://tot", "Tot URL")
+
+	configPath = flag.String("config-path", "/etc/config/config", "Path to config.yaml.")
+
+	jobConfigPath = flag.String("job-config-path", "", "Path to a directory or glob of job config files.")
 
 	jenkinsURL       = flag.String("jenkins-url", "http://jenkins-proxy", "Jenkins URL")
 	jenkinsUserName  = flag.String("jenkins-user", "jenkins-trigger", "Jenkins username")
@@ -43,6 +46,11 @@ func main() {
 
 	logrus.SetFormatter(&logrus.JSONFormatter{})
 
+	ca := &config.ConfigAgent{}
+	if err := ca.Start(*configPath, *jobConfigPath); err != nil {
+		logrus.WithError(err).Fatal("Error starting config agent.")
+	}
+
 	kc, err := kube.NewClientInCluster("default")
 	if err != nil {
 		logrus.WithError(err).Fatal("Error getting kube client.")
@@ -56,12 +64,12 @@ func main() {
 
 	jc := jenkins.NewClient(*jenkinsURL, *jenkinsUserName, jenkinsToken)
 
-	c := plank.NewController(kc, jc, *crierURL, *totURL)
-	for range time.Tick(30 * time.Second) {
-		start := time.Now()
-		if err := c.Sync(); err != nil {
-			logrus.WithError(err).Error("Error syncing.")
-		}
-		logrus.Infof("Sync time: %v", time.Since(start))
+	stop := make(chan struct{})
+	kca := kube.NewCache(kc)
+	if err := kca.Start(stop); err != nil {
+		logrus.WithError(err).Fatal("Error starting kube cache.")
 	}
+
+	c := plank.NewController(kca, ca, jc, *totURL)
+	c.Run(stop)
 }
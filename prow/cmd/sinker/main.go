@@ -47,11 +47,17 @@ func main() {
 		return
 	}
 
+	ca := kube.NewCache(kc)
+	if err := ca.Start(make(chan struct{})); err != nil {
+		logrus.WithError(err).Error("Error starting kube cache.")
+		return
+	}
+
 	// Clean now and regularly from now on.
-	clean(kc)
+	clean(ca)
 	t := time.Tick(period)
 	for range t {
-		clean(kc)
+		clean(ca)
 	}
 }
 
@@ -41,6 +41,7 @@ var (
 	repoName       = flag.String("repo", "kubernetes", "Repo name")
 	logJson        = flag.Bool("log-json", false, "output log in JSON format")
 	configPath     = flag.String("config-path", "/etc/config/config", "Where is config.yaml.")
+	jobConfigPath  = flag.String("job-config-path", "", "Path to a directory or glob of job config files.")
 	maxBatchSize   = flag.Int("batch-size", 5, "Maximum batch size")
 )
 
@@ -272,7 +273,7 @@ func main() {
 	defer splicer.cleanup()
 
 	ca := &config.ConfigAgent{}
-	if err := ca.Start(*configPath); err != nil {
+	if err := ca.Start(*configPath, *jobConfigPath); err != nil {
 		log.WithError(err).Fatal("Could not start config agent.")
 	}
 
@@ -17,37 +17,70 @@ limitations under the License.
 package config
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/http"
 	"sync"
-	"time"
-
-	"github.com/Sirupsen/logrus"
 )
 
 // ConfigAgent watches a path and automatically loads the config stored
-// therein.
+// therein, along with any job config files.
 type ConfigAgent struct {
 	sync.Mutex
-	c *Config
+	c           *Config
+	version     string
+	subscribers []func(old, new *Config)
+	reloader    *Reloader
 }
 
-func (ca *ConfigAgent) Start(path string) error {
-	if c, err := Load(path); err != nil {
-		return err
-	} else {
-		ca.c = c
-	}
-	go func() {
-		for range time.Tick(1 * time.Minute) {
-			if c, err := Load(path); err != nil {
-				logrus.WithField("path", path).WithError(err).Error("Error loading config.")
-			} else {
-				ca.Lock()
-				ca.c = c
-				ca.Unlock()
-			}
+// Start loads the config and reloads it whenever any of its files change.
+// See Load for the meaning of jobConfigs.
+func (ca *ConfigAgent) Start(path string, jobConfigs ...string) error {
+	ca.reloader = ca.newReloader(path, jobConfigs)
+	return ca.reloader.Start()
+}
+
+func (ca *ConfigAgent) newReloader(path string, jobConfigs []string) *Reloader {
+	return NewReloader("config", append([]string{path}, jobConfigs...), func() (string, error) {
+		c, version, err := load(path, jobConfigs)
+		if err != nil {
+			return "", err
 		}
-	}()
-	return nil
+		ca.set(c, version)
+		return version, nil
+	})
+}
+
+// Subscribe calls fn with the old and new config whenever a config with
+// different contents is loaded. It is called from the goroutine that loaded
+// the config, so it should return quickly.
+func (ca *ConfigAgent) Subscribe(fn func(old, new *Config)) {
+	ca.Lock()
+	defer ca.Unlock()
+	ca.subscribers = append(ca.subscribers, fn)
+}
+
+func (ca *ConfigAgent) set(c *Config, version string) {
+	ca.Lock()
+	if version == ca.version {
+		ca.Unlock()
+		return
+	}
+	old := ca.c
+	ca.c = c
+	ca.version = version
+	subscribers := ca.subscribers
+	ca.Unlock()
+	for _, fn := range subscribers {
+		fn(old, c)
+	}
+}
+
+// Set sets the config. Useful for testing.
+func (ca *ConfigAgent) Set(c *Config) {
+	ca.Lock()
+	defer ca.Unlock()
+	ca.c = c
 }
 
 func (ca *ConfigAgent) Config() *Config {
@@ -55,3 +88,21 @@ func (ca *ConfigAgent) Config() *Config {
 	defer ca.Unlock()
 	return ca.c
 }
+
+// ServeHTTP serves the load status and the current config as JSON.
+func (ca *ConfigAgent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var status LoadStatus
+	if ca.reloader != nil {
+		status = ca.reloader.Status()
+	}
+	b, err := json.MarshalIndent(struct {
+		Status LoadStatus `json:"status"`
+		Config *Config    `json:"config"`
+	}{status, ca.Config()}, "", "  ")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error marshaling config: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
@@ -18,12 +18,15 @@ limitations under the License.
 package config
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"io/ioutil"
 	"regexp"
 	"time"
 
 	"github.com/ghodss/yaml"
+
+	"k8s.io/test-infra/prow/cron"
 )
 
 // Config is a read-only snapshot of the config.
@@ -34,38 +37,123 @@ type Config struct {
 
 	// Periodics are not associated with any repo.
 	Periodics []Periodic `json:"periodics,omitempty"`
+
+	// Presets are merged into the pod specs of matching jobs at load time.
+	Presets []Preset `json:"presets,omitempty"`
+
+	Plank Plank `json:"plank,omitempty"`
+
+	// URLTemplates build links to job results for plank, crier and deck.
+	URLTemplates URLTemplates `json:"url_templates,omitempty"`
+
+	Crier Crier `json:"crier,omitempty"`
+}
+
+// Plank is config for the plank controller.
+type Plank struct {
+	// MaxConcurrency is the most ProwJobs that plank will run at once. Zero
+	// means no limit.
+	MaxConcurrency int `json:"max_concurrency,omitempty"`
+	// DefaultTimeout is how long a job may run if it doesn't set its own
+	// timeout, such as "24h". Empty means no limit.
+	DefaultTimeout string `json:"default_timeout,omitempty"`
+	// MaxRetries is how many times to restart a job whose pod failed for
+	// reasons unrelated to the test, such as eviction or node loss. Defaults
+	// to 3. Zero turns retries off.
+	MaxRetries *int `json:"max_retries,omitempty"`
+
+	defaultTimeout time.Duration
 }
 
-// Load loads and parses the config at path.
-func Load(path string) (*Config, error) {
+// GetDefaultTimeout returns the parsed DefaultTimeout, or zero if there is
+// none.
+func (p Plank) GetDefaultTimeout() time.Duration {
+	return p.defaultTimeout
+}
+
+const defaultMaxRetries = 3
+
+// GetMaxRetries returns MaxRetries, or the default if it isn't set.
+func (p Plank) GetMaxRetries() int {
+	if p.MaxRetries == nil {
+		return defaultMaxRetries
+	}
+	return *p.MaxRetries
+}
+
+// Load loads and parses the config at path. Jobs from the job config files
+// named by jobConfigs, each of which is a directory or a glob, are merged
+// into it.
+func Load(path string, jobConfigs ...string) (*Config, error) {
+	c, _, err := load(path, jobConfigs)
+	return c, err
+}
+
+// load is Load that also returns the config version, which is a hash of the
+// contents of every file that went into the config.
+func load(path string, jobConfigs []string) (*Config, string, error) {
 	b, err := ioutil.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("error reading %s: %v", path, err)
+		return nil, "", fmt.Errorf("error reading %s: %v", path, err)
 	}
 	nc := &Config{}
 	if err := yaml.Unmarshal(b, nc); err != nil {
-		return nil, fmt.Errorf("error unmarshaling %s: %v", path, err)
+		return nil, "", fmt.Errorf("error unmarshaling %s: %v", path, err)
+	}
+	h := sha256.New()
+	h.Write(b)
+	if err := mergeJobConfigs(nc, path, b, jobConfigs, h); err != nil {
+		return nil, "", err
 	}
 	if err := parseConfig(nc); err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	return nc, nil
+	return nc, fmt.Sprintf("%x", h.Sum(nil))[:12], nil
 }
 
 func parseConfig(c *Config) error {
+	// Merge presets into the job specs before validating them.
+	for _, v := range c.Presubmits {
+		if err := setPresubmitPresets(c.Presets, v); err != nil {
+			return err
+		}
+	}
+	for _, v := range c.Postsubmits {
+		if err := setPostsubmitPresets(c.Presets, v); err != nil {
+			return err
+		}
+	}
+	if err := setPeriodicPresets(c.Presets, c.Periodics); err != nil {
+		return err
+	}
+
 	// Ensure that presubmit regexes are valid.
 	for _, v := range c.Presubmits {
 		if err := setRegexes(v); err != nil {
 			return fmt.Errorf("could not set regex: %v", err)
 		}
+		if err := setPresubmitTimeouts(v); err != nil {
+			return err
+		}
+		for _, j := range v {
+			if j.MaxConcurrency < 0 {
+				return fmt.Errorf("job %s has negative max_concurrency", j.Name)
+			}
+		}
 	}
 
 	// Ensure that postsubmits have a pod spec.
 	for _, js := range c.Postsubmits {
+		if err := setPostsubmitTimeouts(js); err != nil {
+			return err
+		}
 		for j := range js {
 			if js[j].Spec == nil {
 				return fmt.Errorf("job %s has no spec", js[j].Name)
 			}
+			if js[j].MaxConcurrency < 0 {
+				return fmt.Errorf("job %s has negative max_concurrency", js[j].Name)
+			}
 		}
 	}
 
@@ -74,11 +162,173 @@ func parseConfig(c *Config) error {
 		if c.Periodics[j].Spec == nil {
 			return fmt.Errorf("job %s has no spec", c.Periodics[j].Name)
 		}
-		d, err := time.ParseDuration(c.Periodics[j].Interval)
+		if c.Periodics[j].MaxConcurrency < 0 {
+			return fmt.Errorf("job %s has negative max_concurrency", c.Periodics[j].Name)
+		}
+		if err := setSchedule(&c.Periodics[j]); err != nil {
+			return err
+		}
+	}
+	if err := setPeriodicTimeouts(c.Periodics); err != nil {
+		return err
+	}
+
+	if c.Plank.MaxConcurrency < 0 {
+		return fmt.Errorf("plank has negative max_concurrency")
+	}
+	if c.Plank.GetMaxRetries() < 0 {
+		return fmt.Errorf("plank has negative max_retries")
+	}
+	if err := c.URLTemplates.parse(); err != nil {
+		return err
+	}
+	if err := c.Crier.parse(); err != nil {
+		return err
+	}
+	// Make sure that every reporter a job picks is defined.
+	for _, v := range c.Presubmits {
+		if err := checkPresubmitReporters(c.Crier, v); err != nil {
+			return err
+		}
+	}
+	for _, v := range c.Postsubmits {
+		if err := checkPostsubmitReporters(c.Crier, v); err != nil {
+			return err
+		}
+	}
+	if err := checkPeriodicReporters(c.Crier, c.Periodics); err != nil {
+		return err
+	}
+	if c.Plank.DefaultTimeout != "" {
+		d, err := parseTimeout(c.Plank.DefaultTimeout)
 		if err != nil {
-			return fmt.Errorf("cannot parse duration for %s: %v", c.Periodics[j].Name, err)
+			return fmt.Errorf("cannot parse plank default_timeout: %v", err)
+		}
+		c.Plank.defaultTimeout = d
+	}
+	return nil
+}
+
+func checkPresubmitReporters(cr Crier, js []Presubmit) error {
+	for _, j := range js {
+		if err := cr.checkReporters(j.Name, j.Reporters); err != nil {
+			return err
+		}
+		if err := checkPresubmitReporters(cr, j.RunAfterSuccess); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkPostsubmitReporters(cr Crier, js []Postsubmit) error {
+	for _, j := range js {
+		if err := cr.checkReporters(j.Name, j.Reporters); err != nil {
+			return err
+		}
+		if err := checkPostsubmitReporters(cr, j.RunAfterSuccess); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkPeriodicReporters(cr Crier, js []Periodic) error {
+	for _, j := range js {
+		if err := cr.checkReporters(j.Name, j.Reporters); err != nil {
+			return err
+		}
+		if err := checkPeriodicReporters(cr, j.RunAfterSuccess); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setSchedule parses either the interval or the cron schedule of a periodic.
+func setSchedule(p *Periodic) error {
+	if p.Interval != "" && p.Cron != "" {
+		return fmt.Errorf("job %s sets both interval and cron", p.Name)
+	}
+	if p.Cron != "" {
+		s, err := cron.Parse(p.Cron)
+		if err != nil {
+			return fmt.Errorf("cannot parse cron for %s: %v", p.Name, err)
+		}
+		p.cron = s
+		if p.CatchUp == "" {
+			p.CatchUp = CatchUpSkip
+		} else if p.CatchUp != CatchUpSkip && p.CatchUp != CatchUpOnce {
+			return fmt.Errorf("job %s has unknown catch_up %q", p.Name, p.CatchUp)
+		}
+		return nil
+	}
+	if p.CatchUp != "" {
+		return fmt.Errorf("job %s sets catch_up without cron", p.Name)
+	}
+	d, err := time.ParseDuration(p.Interval)
+	if err != nil {
+		return fmt.Errorf("cannot parse duration for %s: %v", p.Name, err)
+	}
+	p.interval = d
+	return nil
+}
+
+func parseTimeout(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("timeout %s is not positive", s)
+	}
+	return d, nil
+}
+
+func setPresubmitTimeouts(js []Presubmit) error {
+	for i := range js {
+		if js[i].Timeout != "" {
+			d, err := parseTimeout(js[i].Timeout)
+			if err != nil {
+				return fmt.Errorf("cannot parse timeout for %s: %v", js[i].Name, err)
+			}
+			js[i].timeout = d
+		}
+		if err := setPresubmitTimeouts(js[i].RunAfterSuccess); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setPostsubmitTimeouts(js []Postsubmit) error {
+	for i := range js {
+		if js[i].Timeout != "" {
+			d, err := parseTimeout(js[i].Timeout)
+			if err != nil {
+				return fmt.Errorf("cannot parse timeout for %s: %v", js[i].Name, err)
+			}
+			js[i].timeout = d
+		}
+		if err := setPostsubmitTimeouts(js[i].RunAfterSuccess); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setPeriodicTimeouts(js []Periodic) error {
+	for i := range js {
+		if js[i].Timeout != "" {
+			d, err := parseTimeout(js[i].Timeout)
+			if err != nil {
+				return fmt.Errorf("cannot parse timeout for %s: %v", js[i].Name, err)
+			}
+			js[i].timeout = d
+		}
+		if err := setPeriodicTimeouts(js[i].RunAfterSuccess); err != nil {
+			return err
 		}
-		c.Periodics[j].interval = d
 	}
 	return nil
 }
@@ -21,6 +21,11 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/ghodss/yaml"
+
+	"k8s.io/test-infra/prow/kube"
 )
 
 func TestConfigLoads(t *testing.T) {
@@ -73,3 +78,358 @@ func TestConfigSecurityJobsMatch(t *testing.T) {
 		}
 	}
 }
+
+func TestTimeouts(t *testing.T) {
+	var testcases = []struct {
+		name   string
+		config string
+
+		expectErr       bool
+		expectedDefault time.Duration
+		expectedJob     time.Duration
+		expectedChild   time.Duration
+	}{
+		{
+			name: "no timeouts",
+			config: `
+periodics:
+- name: p
+  interval: 1h
+  spec: {}`,
+		},
+		{
+			name: "job and default timeouts",
+			config: `
+plank:
+  default_timeout: 24h
+periodics:
+- name: p
+  interval: 1h
+  timeout: 2h
+  spec: {}
+  run_after_success:
+  - name: q
+    timeout: 30m
+    spec: {}`,
+			expectedDefault: 24 * time.Hour,
+			expectedJob:     2 * time.Hour,
+			expectedChild:   30 * time.Minute,
+		},
+		{
+			name: "bad job timeout",
+			config: `
+periodics:
+- name: p
+  interval: 1h
+  timeout: forever
+  spec: {}`,
+			expectErr: true,
+		},
+		{
+			name: "negative default timeout",
+			config: `
+plank:
+  default_timeout: -1h`,
+			expectErr: true,
+		},
+	}
+	for _, tc := range testcases {
+		c := &Config{}
+		if err := yaml.Unmarshal([]byte(tc.config), c); err != nil {
+			t.Fatalf("For case %s, bad test config: %v", tc.name, err)
+		}
+		err := parseConfig(c)
+		if err != nil {
+			if !tc.expectErr {
+				t.Errorf("For case %s, unexpected error: %v", tc.name, err)
+			}
+			continue
+		} else if tc.expectErr {
+			t.Errorf("For case %s, expected an error.", tc.name)
+			continue
+		}
+		if d := c.Plank.GetDefaultTimeout(); d != tc.expectedDefault {
+			t.Errorf("For case %s, expected default timeout %s, got %s.", tc.name, tc.expectedDefault, d)
+		}
+		if d := c.Periodics[0].GetTimeout(); d != tc.expectedJob {
+			t.Errorf("For case %s, expected job timeout %s, got %s.", tc.name, tc.expectedJob, d)
+		}
+		if len(c.Periodics[0].RunAfterSuccess) > 0 {
+			if d := c.Periodics[0].RunAfterSuccess[0].GetTimeout(); d != tc.expectedChild {
+				t.Errorf("For case %s, expected child timeout %s, got %s.", tc.name, tc.expectedChild, d)
+			}
+		}
+	}
+}
+
+func TestMaxRetries(t *testing.T) {
+	var testcases = []struct {
+		name     string
+		config   string
+		expected int
+		err      bool
+	}{
+		{
+			name:     "default",
+			config:   `plank: {}`,
+			expected: 3,
+		},
+		{
+			name:     "off",
+			config:   `plank: {max_retries: 0}`,
+			expected: 0,
+		},
+		{
+			name:     "set",
+			config:   `plank: {max_retries: 5}`,
+			expected: 5,
+		},
+		{
+			name:   "negative",
+			config: `plank: {max_retries: -1}`,
+			err:    true,
+		},
+	}
+	for _, tc := range testcases {
+		c := &Config{}
+		if err := yaml.Unmarshal([]byte(tc.config), c); err != nil {
+			t.Fatalf("For case %s, bad test config: %v", tc.name, err)
+		}
+		err := parseConfig(c)
+		if tc.err {
+			if err == nil {
+				t.Errorf("For case %s, expected an error.", tc.name)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("For case %s, unexpected error: %v", tc.name, err)
+			continue
+		}
+		if r := c.Plank.GetMaxRetries(); r != tc.expected {
+			t.Errorf("For case %s, expected %d retries, got %d.", tc.name, tc.expected, r)
+		}
+	}
+}
+
+func TestPeriodicSchedules(t *testing.T) {
+	var testcases = []struct {
+		name     string
+		periodic Periodic
+
+		expectErr bool
+		cron      bool
+		catchUp   CatchUpPolicy
+	}{
+		{
+			name:     "interval",
+			periodic: Periodic{Name: "p", Interval: "1h"},
+		},
+		{
+			name:     "cron",
+			periodic: Periodic{Name: "p", Cron: "0 2 * * 1-5"},
+			cron:     true,
+			catchUp:  CatchUpSkip,
+		},
+		{
+			name:     "cron, run once",
+			periodic: Periodic{Name: "p", Cron: "@daily", CatchUp: CatchUpOnce},
+			cron:     true,
+			catchUp:  CatchUpOnce,
+		},
+		{
+			name:      "neither",
+			periodic:  Periodic{Name: "p"},
+			expectErr: true,
+		},
+		{
+			name:      "both",
+			periodic:  Periodic{Name: "p", Interval: "1h", Cron: "@daily"},
+			expectErr: true,
+		},
+		{
+			name:      "bad cron",
+			periodic:  Periodic{Name: "p", Cron: "0 25 * * *"},
+			expectErr: true,
+		},
+		{
+			name:      "bad catch up",
+			periodic:  Periodic{Name: "p", Cron: "@daily", CatchUp: "twice"},
+			expectErr: true,
+		},
+		{
+			name:      "catch up without cron",
+			periodic:  Periodic{Name: "p", Interval: "1h", CatchUp: CatchUpOnce},
+			expectErr: true,
+		},
+	}
+	for _, tc := range testcases {
+		p := tc.periodic
+		err := setSchedule(&p)
+		if err != nil {
+			if !tc.expectErr {
+				t.Errorf("For case %s, unexpected error: %v", tc.name, err)
+			}
+			continue
+		} else if tc.expectErr {
+			t.Errorf("For case %s, expected an error.", tc.name)
+			continue
+		}
+		if (p.GetCron() != nil) != tc.cron {
+			t.Errorf("For case %s, wrong cron schedule.", tc.name)
+		}
+		if p.CatchUp != tc.catchUp {
+			t.Errorf("For case %s, expected catch up %q, got %q.", tc.name, tc.catchUp, p.CatchUp)
+		}
+	}
+}
+
+func TestCrierReporters(t *testing.T) {
+	var testcases = []struct {
+		name   string
+		config string
+
+		expectErr      bool
+		expectedStates []kube.ProwJobState
+		expectedDigest time.Duration
+	}{
+		{
+			name: "webhook with defaults",
+			config: `
+crier:
+  reporters:
+  - name: hook
+    type: webhook
+    url_file: /etc/crier/hook
+periodics:
+- name: p
+  interval: 1h
+  reporters: [hook]
+  spec: {}`,
+			expectedStates: []kube.ProwJobState{kube.SuccessState, kube.FailureState, kube.ErrorState},
+		},
+		{
+			name: "email digest",
+			config: `
+crier:
+  reporters:
+  - name: mail
+    type: email
+    smtp_server: smtp.example.com:25
+    from: prow@example.com
+    to: [oncall@example.com]
+    digest_interval: 6h
+periodics:
+- name: p
+  interval: 1h
+  reporters: [mail]
+  spec: {}`,
+			expectedStates: []kube.ProwJobState{kube.FailureState, kube.ErrorState},
+			expectedDigest: 6 * time.Hour,
+		},
+		{
+			name: "unknown reporter on a child job",
+			config: `
+crier:
+  reporters:
+  - name: hook
+    type: webhook
+    url_file: /etc/crier/hook
+periodics:
+- name: p
+  interval: 1h
+  spec: {}
+  run_after_success:
+  - name: q
+    reporters: [nope]
+    spec: {}`,
+			expectErr: true,
+		},
+		{
+			name: "github is built in",
+			config: `
+crier:
+  reporters:
+  - name: github
+    type: webhook
+    url_file: /etc/crier/hook`,
+			expectErr: true,
+		},
+		{
+			name: "duplicate names",
+			config: `
+crier:
+  reporters:
+  - name: hook
+    type: webhook
+    url_file: /etc/crier/hook
+  - name: hook
+    type: slack
+    url_file: /etc/crier/slack`,
+			expectErr: true,
+		},
+		{
+			name: "no url file",
+			config: `
+crier:
+  reporters:
+  - name: slack
+    type: slack
+    channel: "#ci"`,
+			expectErr: true,
+		},
+		{
+			name: "email without recipients",
+			config: `
+crier:
+  reporters:
+  - name: mail
+    type: email
+    smtp_server: smtp.example.com:25
+    from: prow@example.com`,
+			expectErr: true,
+		},
+		{
+			name: "unknown state",
+			config: `
+crier:
+  reporters:
+  - name: hook
+    type: webhook
+    url_file: /etc/crier/hook
+    states: [done]`,
+			expectErr: true,
+		},
+		{
+			name: "unknown type",
+			config: `
+crier:
+  reporters:
+  - name: pager
+    type: pager`,
+			expectErr: true,
+		},
+	}
+	for _, tc := range testcases {
+		c := &Config{}
+		if err := yaml.Unmarshal([]byte(tc.config), c); err != nil {
+			t.Fatalf("For case %s, bad test config: %v", tc.name, err)
+		}
+		err := parseConfig(c)
+		if err != nil {
+			if !tc.expectErr {
+				t.Errorf("For case %s, unexpected error: %v", tc.name, err)
+			}
+			continue
+		} else if tc.expectErr {
+			t.Errorf("For case %s, expected an error.", tc.name)
+			continue
+		}
+		r := c.Crier.Reporters[0]
+		if !reflect.DeepEqual(r.States, tc.expectedStates) {
+			t.Errorf("For case %s, expected states %v, got %v.", tc.name, tc.expectedStates, r.States)
+		}
+		if r.Type == EmailReporter && r.GetDigestInterval() != tc.expectedDigest {
+			t.Errorf("For case %s, expected digest interval %s, got %s.", tc.name, tc.expectedDigest, r.GetDigestInterval())
+		}
+	}
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/test-infra/prow/kube"
+)
+
+// Crier is config for the reporters that crier runs besides GitHub. Jobs
+// pick them by name in their reporters field.
+type Crier struct {
+	Reporters []Reporter `json:"reporters,omitempty"`
+}
+
+// ReporterType says how a reporter reports.
+type ReporterType string
+
+const (
+	// WebhookReporter POSTs each ProwJob as JSON to URL.
+	WebhookReporter ReporterType = "webhook"
+	// SlackReporter posts a message to a Slack-compatible incoming webhook.
+	SlackReporter ReporterType = "slack"
+	// EmailReporter mails a digest of the reported ProwJobs every
+	// DigestInterval.
+	EmailReporter ReporterType = "email"
+)
+
+// GitHubReporterName is the name of the built-in reporter that sets statuses
+// on PRs. Jobs turn it off with skip_report rather than picking it.
+const GitHubReporterName = "github"
+
+// Reporter configures one reporter.
+type Reporter struct {
+	Name string       `json:"name"`
+	Type ReporterType `json:"type"`
+	// States are the job states to report. Defaults to success, failure and
+	// error, or only failure and error for email.
+	States []kube.ProwJobState `json:"states,omitempty"`
+
+	// URLFile is the path to a file holding the URL that webhook and slack
+	// reporters post to. Such URLs work as credentials, so they belong in a
+	// secret rather than in the config, which is public.
+	URLFile string `json:"url_file,omitempty"`
+	// Channel overrides the default channel of a slack webhook.
+	Channel string `json:"channel,omitempty"`
+
+	// SMTPServer is the host:port of the mail server for email.
+	SMTPServer string   `json:"smtp_server,omitempty"`
+	From       string   `json:"from,omitempty"`
+	To         []string `json:"to,omitempty"`
+	// DigestInterval is how often email sends a digest, such as "24h".
+	// Defaults to a day.
+	DigestInterval string `json:"digest_interval,omitempty"`
+
+	digestInterval time.Duration
+}
+
+// GetDigestInterval returns the parsed DigestInterval, or a day if it isn't
+// set.
+func (r Reporter) GetDigestInterval() time.Duration {
+	if r.digestInterval == 0 {
+		return defaultDigestInterval
+	}
+	return r.digestInterval
+}
+
+// Reports returns whether the reporter reports jobs in the given state.
+func (r Reporter) Reports(state kube.ProwJobState) bool {
+	for _, s := range r.States {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// ReporterByName returns the reporter with the given name.
+func (c Crier) ReporterByName(name string) (Reporter, bool) {
+	for _, r := range c.Reporters {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Reporter{}, false
+}
+
+const defaultDigestInterval = 24 * time.Hour
+
+var reportableStates = map[kube.ProwJobState]bool{
+	kube.PendingState: true,
+	kube.SuccessState: true,
+	kube.FailureState: true,
+	kube.AbortedState: true,
+	kube.ErrorState:   true,
+}
+
+// parse validates the reporters and fills in their defaults.
+func (c *Crier) parse() error {
+	names := map[string]bool{}
+	for i := range c.Reporters {
+		r := &c.Reporters[i]
+		if r.Name == "" {
+			return fmt.Errorf("crier: reporter %d has no name", i)
+		}
+		if r.Name == GitHubReporterName {
+			return fmt.Errorf("crier: reporter name %s is reserved", r.Name)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("crier: reporter %s is defined more than once", r.Name)
+		}
+		names[r.Name] = true
+		for _, s := range r.States {
+			if !reportableStates[s] {
+				return fmt.Errorf("crier: reporter %s has unknown state %q", r.Name, s)
+			}
+		}
+		switch r.Type {
+		case WebhookReporter, SlackReporter:
+			if r.URLFile == "" {
+				return fmt.Errorf("crier: reporter %s needs url_file", r.Name)
+			}
+			if len(r.States) == 0 {
+				r.States = []kube.ProwJobState{kube.SuccessState, kube.FailureState, kube.ErrorState}
+			}
+		case EmailReporter:
+			if r.SMTPServer == "" || r.From == "" || len(r.To) == 0 {
+				return fmt.Errorf("crier: reporter %s needs smtp_server, from and to", r.Name)
+			}
+			if r.DigestInterval != "" {
+				d, err := parseTimeout(r.DigestInterval)
+				if err != nil {
+					return fmt.Errorf("crier: cannot parse digest_interval for %s: %v", r.Name, err)
+				}
+				r.digestInterval = d
+			}
+			if len(r.States) == 0 {
+				r.States = []kube.ProwJobState{kube.FailureState, kube.ErrorState}
+			}
+		default:
+			return fmt.Errorf("crier: reporter %s has unknown type %q", r.Name, r.Type)
+		}
+	}
+	return nil
+}
+
+// checkReporters returns an error if the job picks a reporter that isn't
+// defined.
+func (c Crier) checkReporters(job string, reporters []string) error {
+	for _, name := range reporters {
+		if _, ok := c.ReporterByName(name); !ok {
+			return fmt.Errorf("job %s uses unknown reporter %s", job, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// JobConfig is the contents of a job config file. Job config files hold only
+// jobs and are merged into the main config.
+type JobConfig struct {
+	Presubmits  map[string][]Presubmit  `json:"presubmits,omitempty"`
+	Postsubmits map[string][]Postsubmit `json:"postsubmits,omitempty"`
+	Periodics   []Periodic              `json:"periodics,omitempty"`
+}
+
+// jobConfigFiles expands each path into the job config files it names. A
+// directory names every .yaml or .yml file beneath it, anything else is
+// treated as a glob that must match at least one file.
+func jobConfigFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			return nil, fmt.Errorf("bad job config path %s: %v", p, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no job config files match %s", p)
+		}
+		for _, m := range matches {
+			err := filepath.Walk(m, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if info.IsDir() {
+					if path != m && isHidden(info.Name()) {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				// Explicitly named files are always loaded.
+				if path == m || strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
+					files = append(files, path)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("error walking %s: %v", m, err)
+			}
+		}
+	}
+	return files, nil
+}
+
+// mergeJobConfigs adds the jobs in the job config files to c, and writes
+// their contents to h. The main config is read from path with contents b. It
+// is an error for two jobs in the same repo to share a name or a context, or
+// for two periodics to share a name, no matter which files they are in.
+func mergeJobConfigs(c *Config, path string, b []byte, jobConfigs []string, h hash.Hash) error {
+	files, err := jobConfigFiles(jobConfigs)
+	if err != nil {
+		return err
+	}
+	seen := origins{}
+	if err := seen.addJobs(newFileLines(path, b), c.Presubmits, c.Postsubmits, c.Periodics); err != nil {
+		return err
+	}
+	for _, f := range files {
+		fb, err := ioutil.ReadFile(f)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", f, err)
+		}
+		h.Write([]byte(f))
+		h.Write(fb)
+		jc := &JobConfig{}
+		if err := yaml.Unmarshal(fb, jc); err != nil {
+			return fmt.Errorf("error unmarshaling %s: %v", f, err)
+		}
+		if err := seen.addJobs(newFileLines(f, fb), jc.Presubmits, jc.Postsubmits, jc.Periodics); err != nil {
+			return err
+		}
+		if len(jc.Presubmits) > 0 && c.Presubmits == nil {
+			c.Presubmits = map[string][]Presubmit{}
+		}
+		for repo, js := range jc.Presubmits {
+			c.Presubmits[repo] = append(c.Presubmits[repo], js...)
+		}
+		if len(jc.Postsubmits) > 0 && c.Postsubmits == nil {
+			c.Postsubmits = map[string][]Postsubmit{}
+		}
+		for repo, js := range jc.Postsubmits {
+			c.Postsubmits[repo] = append(c.Postsubmits[repo], js...)
+		}
+		c.Periodics = append(c.Periodics, jc.Periodics...)
+	}
+	return nil
+}
+
+// origins maps each job name and context to where it was first defined.
+type origins map[string]string
+
+func (o origins) add(key, desc, loc string) error {
+	if prev, ok := o[key]; ok {
+		return fmt.Errorf("%s is defined at both %s and %s", desc, prev, loc)
+	}
+	o[key] = loc
+	return nil
+}
+
+func (o origins) addJobs(f *fileLines, pres map[string][]Presubmit, posts map[string][]Postsubmit, pers []Periodic) error {
+	for repo, js := range pres {
+		start := f.section("presubmits", repo)
+		for _, j := range flattenPresubmits(js) {
+			if err := o.add("presubmit name "+repo+" "+j.Name, fmt.Sprintf("presubmit %s for %s", j.Name, repo), f.locate(start, "name", j.Name)); err != nil {
+				return err
+			}
+			if j.Context == "" {
+				continue
+			}
+			if err := o.add("presubmit context "+repo+" "+j.Context, fmt.Sprintf("context %s for %s", j.Context, repo), f.locate(start, "context", j.Context)); err != nil {
+				return err
+			}
+		}
+	}
+	for repo, js := range posts {
+		start := f.section("postsubmits", repo)
+		for _, j := range flattenPostsubmits(js) {
+			if err := o.add("postsubmit name "+repo+" "+j.Name, fmt.Sprintf("postsubmit %s for %s", j.Name, repo), f.locate(start, "name", j.Name)); err != nil {
+				return err
+			}
+		}
+	}
+	start := f.section("periodics", "")
+	for _, j := range flattenPeriodics(pers) {
+		if err := o.add("periodic name "+j.Name, fmt.Sprintf("periodic %s", j.Name), f.locate(start, "name", j.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flattenPresubmits returns the jobs and all of their children in the order
+// they appear in the file.
+func flattenPresubmits(js []Presubmit) []Presubmit {
+	var res []Presubmit
+	for _, j := range js {
+		res = append(res, j)
+		res = append(res, flattenPresubmits(j.RunAfterSuccess)...)
+	}
+	return res
+}
+
+func flattenPostsubmits(js []Postsubmit) []Postsubmit {
+	var res []Postsubmit
+	for _, j := range js {
+		res = append(res, j)
+		res = append(res, flattenPostsubmits(j.RunAfterSuccess)...)
+	}
+	return res
+}
+
+func flattenPeriodics(js []Periodic) []Periodic {
+	var res []Periodic
+	for _, j := range js {
+		res = append(res, j)
+		res = append(res, flattenPeriodics(j.RunAfterSuccess)...)
+	}
+	return res
+}
+
+// fileLines finds the lines that jobs were defined on so that errors can
+// point at them. It only looks at the raw text, so it may guess wrong for
+// unusual YAML, in which case the error names just the file.
+type fileLines struct {
+	path  string
+	lines []string
+	used  map[int]bool
+}
+
+func newFileLines(path string, b []byte) *fileLines {
+	return &fileLines{
+		path:  path,
+		lines: strings.Split(string(b), "\n"),
+		used:  map[int]bool{},
+	}
+}
+
+// section returns the index of the line that starts the given top-level key,
+// or the line of repo within it if repo is set. It returns 0 if there is no
+// such line.
+func (f *fileLines) section(key, repo string) int {
+	top := regexp.MustCompile(`^` + regexp.QuoteMeta(key) + `:`)
+	sub := regexp.MustCompile(`^\s+["']?` + regexp.QuoteMeta(repo) + `["']?:`)
+	for i, l := range f.lines {
+		if !top.MatchString(l) {
+			continue
+		}
+		if repo == "" {
+			return i
+		}
+		for j := i + 1; j < len(f.lines); j++ {
+			if sub.MatchString(f.lines[j]) {
+				return j
+			}
+		}
+		return i
+	}
+	return 0
+}
+
+// locate returns "path:line" for the first line at or after start that sets
+// field to value and has not been returned before.
+func (f *fileLines) locate(start int, field, value string) string {
+	re := regexp.MustCompile(`^\s*(-\s+)?` + regexp.QuoteMeta(field) + `:\s*["']?` + regexp.QuoteMeta(value) + `["']?\s*(#.*)?$`)
+	for i := start; i < len(f.lines); i++ {
+		if !f.used[i] && re.MatchString(f.lines[i]) {
+			f.used[i] = true
+			return fmt.Sprintf("%s:%d", f.path, i+1)
+		}
+	}
+	return f.path
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const mainConfig = `plank:
+  max_retries: 1
+presubmits:
+  org/repo:
+  - name: unit
+    context: unit
+    always_run: true
+`
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Error making dir: %v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Error writing %s: %v", name, err)
+		}
+	}
+}
+
+func TestLoadJobConfigs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobconfig")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"config.yaml": mainConfig,
+		"jobs/org/repo.yaml": `presubmits:
+  org/repo:
+  - name: e2e
+    context: e2e
+    spec:
+      containers:
+      - image: e2e
+postsubmits:
+  org/repo:
+  - name: deploy
+    spec:
+      containers:
+      - image: deploy
+`,
+		"jobs/other/periodics.yml": `periodics:
+- name: nightly
+  interval: 24h
+  spec:
+    containers:
+    - image: nightly
+`,
+		"jobs/README.md": "not a job config",
+		// Mounted ConfigMaps keep a copy of every file in a hidden directory.
+		"jobs/..2017_06_01/periodics.yaml": `periodics:
+- name: nightly
+  interval: 24h
+`,
+		"extra/one.yaml": `presubmits:
+  org/other:
+  - name: lint
+    context: lint
+`,
+	})
+
+	c, err := Load(filepath.Join(dir, "config.yaml"), filepath.Join(dir, "jobs"), filepath.Join(dir, "extra", "*.yaml"))
+	if err != nil {
+		t.Fatalf("Error loading config: %v", err)
+	}
+	if c.Plank.GetMaxRetries() != 1 {
+		t.Errorf("Expected max retries 1 from the main config, got %d.", c.Plank.GetMaxRetries())
+	}
+	if len(c.Presubmits["org/repo"]) != 2 {
+		t.Errorf("Expected 2 presubmits for org/repo, got %+v.", c.Presubmits["org/repo"])
+	}
+	if len(c.Presubmits["org/other"]) != 1 {
+		t.Errorf("Expected 1 presubmit for org/other, got %+v.", c.Presubmits["org/other"])
+	}
+	if len(c.Postsubmits["org/repo"]) != 1 {
+		t.Errorf("Expected 1 postsubmit for org/repo, got %+v.", c.Postsubmits["org/repo"])
+	}
+	if len(c.Periodics) != 1 || c.Periodics[0].GetInterval().Hours() != 24 {
+		t.Errorf("Expected the nightly periodic with a parsed interval, got %+v.", c.Periodics)
+	}
+	// Job configs are validated like the main config.
+	if ps := c.Presubmits["org/repo"]; len(ps) == 2 && ps[1].re == nil {
+		t.Errorf("Expected the e2e trigger regex to be set.")
+	}
+
+	if _, err := Load(filepath.Join(dir, "config.yaml"), filepath.Join(dir, "missing", "*.yaml")); err == nil {
+		t.Errorf("Expected an error for a glob that matches nothing.")
+	}
+}
+
+func TestLoadJobConfigDuplicates(t *testing.T) {
+	var testcases = []struct {
+		name     string
+		jobs     string
+		expected []string
+	}{
+		{
+			name: "duplicate presubmit name",
+			jobs: `presubmits:
+  org/repo:
+  - name: other
+    context: other
+  - name: unit
+    context: unit2
+`,
+			expected: []string{"presubmit unit for org/repo", "config.yaml:5", "jobs.yaml:5"},
+		},
+		{
+			name: "duplicate context",
+			jobs: `presubmits:
+  org/repo:
+  - name: unit2
+    context: unit
+`,
+			expected: []string{"context unit for org/repo", "config.yaml:6", "jobs.yaml:4"},
+		},
+		{
+			name: "duplicate run after success",
+			jobs: `presubmits:
+  org/repo:
+  - name: build
+    context: build
+    run_after_success:
+    - name: unit
+      context: unit2
+`,
+			expected: []string{"presubmit unit for org/repo", "config.yaml:5", "jobs.yaml:6"},
+		},
+		{
+			name: "duplicate periodic within a file",
+			jobs: `periodics:
+- name: nightly
+  interval: 24h
+- name: nightly
+  interval: 12h
+`,
+			expected: []string{"periodic nightly", "jobs.yaml:2", "jobs.yaml:4"},
+		},
+		{
+			name: "same name in another repo is fine",
+			jobs: `presubmits:
+  org/other:
+  - name: unit
+    context: unit
+`,
+		},
+		{
+			name: "postsubmit may share a presubmit name",
+			jobs: `postsubmits:
+  org/repo:
+  - name: unit
+    spec:
+      containers:
+      - image: unit
+`,
+		},
+	}
+	for _, tc := range testcases {
+		dir, err := ioutil.TempDir("", "jobconfig")
+		if err != nil {
+			t.Fatalf("Error making temp dir: %v", err)
+		}
+		writeFiles(t, dir, map[string]string{
+			"config.yaml": mainConfig,
+			"jobs.yaml":   tc.jobs,
+		})
+		_, err = Load(filepath.Join(dir, "config.yaml"), filepath.Join(dir, "jobs.yaml"))
+		os.RemoveAll(dir)
+		if len(tc.expected) == 0 {
+			if err != nil {
+				t.Errorf("For case %s, didn't expect error: %v", tc.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("For case %s, expected an error.", tc.name)
+			continue
+		}
+		for _, e := range tc.expected {
+			if !strings.Contains(err.Error(), e) {
+				t.Errorf("For case %s, expected error %q to contain %q.", tc.name, err, e)
+			}
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"regexp"
 	"time"
 
+	"k8s.io/test-infra/prow/cron"
 	"k8s.io/test-infra/prow/kube"
 )
 
@@ -39,8 +40,18 @@ type Presubmit struct {
 	RerunCommand string `json:"rerun_command"`
 	// Whether or not to skip commenting and setting status on GitHub.
 	SkipReport bool `json:"skip_report"`
+	// Reporters are the crier reporters that report this job besides
+	// GitHub.
+	Reporters []string `json:"reporters,omitempty"`
+	// Maximum number of this job running concurrently, 0 implies no limit.
+	MaxConcurrency int `json:"max_concurrency"`
+	// Abort the job if it runs for longer than this, such as "2h". Defaults
+	// to plank's default_timeout.
+	Timeout string `json:"timeout"`
 	// Kubernetes pod spec.
 	Spec *kube.PodSpec `json:"spec,omitempty"`
+	// Labels select the presets that apply to this job.
+	Labels map[string]string `json:"labels,omitempty"`
 	// Run these jobs after successfully running this one.
 	RunAfterSuccess []Presubmit `json:"run_after_success"`
 
@@ -49,29 +60,70 @@ type Presubmit struct {
 	// We'll set these when we load it.
 	re        *regexp.Regexp // from RerunCommand
 	reChanges *regexp.Regexp // from RunIfChanged
+	timeout   time.Duration  // from Timeout
 }
 
 // Postsubmit runs on push events.
 type Postsubmit struct {
 	Name string        `json:"name"`
 	Spec *kube.PodSpec `json:"spec,omitempty"`
+	// Labels select the presets that apply to this job.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Maximum number of this job running concurrently, 0 implies no limit.
+	MaxConcurrency int `json:"max_concurrency"`
+	// Abort the job if it runs for longer than this, such as "2h". Defaults
+	// to plank's default_timeout.
+	Timeout string `json:"timeout"`
+	// Reporters are the crier reporters that report this job besides
+	// GitHub.
+	Reporters []string `json:"reporters,omitempty"`
 
 	Brancher
 
 	RunAfterSuccess []Postsubmit `json:"run_after_success"`
+
+	timeout time.Duration
 }
 
 // Periodic runs on a timer.
 type Periodic struct {
-	Name     string        `json:"name"`
-	Spec     *kube.PodSpec `json:"spec,omitempty"`
-	Interval string        `json:"interval"`
+	Name string        `json:"name"`
+	Spec *kube.PodSpec `json:"spec,omitempty"`
+	// Labels select the presets that apply to this job.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Run the job every Interval after the last run started, such as "2h".
+	Interval string `json:"interval"`
+	// Run the job on a cron schedule in UTC, such as "0 2 * * 1-5". Only one
+	// of Interval and Cron may be set.
+	Cron string `json:"cron"`
+	// What to do about cron runs that were missed, for instance because
+	// horologium was down: "skip" them (the default) or run "once" to make
+	// up for all of them.
+	CatchUp CatchUpPolicy `json:"catch_up,omitempty"`
+	// Maximum number of this job running concurrently, 0 implies no limit.
+	MaxConcurrency int `json:"max_concurrency"`
+	// Abort the job if it runs for longer than this, such as "2h". Defaults
+	// to plank's default_timeout.
+	Timeout string `json:"timeout"`
+	// Reporters are the crier reporters that report this job besides
+	// GitHub.
+	Reporters []string `json:"reporters,omitempty"`
 
 	RunAfterSuccess []Periodic `json:"run_after_success"`
 
 	interval time.Duration
+	cron     *cron.Schedule
+	timeout  time.Duration
 }
 
+// CatchUpPolicy says how to handle missed runs of a cron job.
+type CatchUpPolicy string
+
+const (
+	CatchUpSkip CatchUpPolicy = "skip"
+	CatchUpOnce CatchUpPolicy = "once"
+)
+
 func (p *Periodic) SetInterval(d time.Duration) {
 	p.interval = d
 }
@@ -80,6 +132,31 @@ func (p *Periodic) GetInterval() time.Duration {
 	return p.interval
 }
 
+func (p *Periodic) SetCron(s *cron.Schedule) {
+	p.cron = s
+}
+
+// GetCron returns the parsed Cron schedule, or nil if the job runs on an
+// interval.
+func (p *Periodic) GetCron() *cron.Schedule {
+	return p.cron
+}
+
+// GetTimeout returns the parsed Timeout, or zero if there is none.
+func (p *Periodic) GetTimeout() time.Duration {
+	return p.timeout
+}
+
+// GetTimeout returns the parsed Timeout, or zero if there is none.
+func (ps *Presubmit) GetTimeout() time.Duration {
+	return ps.timeout
+}
+
+// GetTimeout returns the parsed Timeout, or zero if there is none.
+func (ps *Postsubmit) GetTimeout() time.Duration {
+	return ps.timeout
+}
+
 // Brancher is for shared code between jobs that only run against certain
 // branches. An empty brancher runs against all branches.
 type Brancher struct {
@@ -111,6 +188,11 @@ func (br Brancher) RunsAgainstBranch(branch string) bool {
 	return false
 }
 
+// TriggerMatches returns true if the comment body should trigger this job.
+func (ps Presubmit) TriggerMatches(body string) bool {
+	return ps.re.MatchString(body)
+}
+
 func (ps Presubmit) RunsAgainstChanges(changes []string) bool {
 	for _, change := range changes {
 		if ps.reChanges.MatchString(change) {
@@ -0,0 +1,123 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+
+	"k8s.io/test-infra/prow/kube"
+)
+
+// Preset is a set of environment variables, volumes and volume mounts that
+// is merged into the pod spec of every job that has all of its labels. A
+// preset without labels applies to every job.
+type Preset struct {
+	Labels       map[string]string  `json:"labels"`
+	Env          []kube.EnvVar      `json:"env"`
+	Volumes      []kube.Volume      `json:"volumes"`
+	VolumeMounts []kube.VolumeMount `json:"volumeMounts"`
+}
+
+func (p Preset) matches(labels map[string]string) bool {
+	for k, v := range p.Labels {
+		if lv, ok := labels[k]; !ok || lv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// mergePresets merges the matching presets into spec. It is an error for a
+// preset to set an env var, volume or mount path that the spec or another
+// preset already sets.
+func mergePresets(presets []Preset, labels map[string]string, spec *kube.PodSpec) error {
+	if spec == nil {
+		return nil
+	}
+	for _, p := range presets {
+		if !p.matches(labels) {
+			continue
+		}
+		for _, v := range p.Volumes {
+			for _, sv := range spec.Volumes {
+				if sv.Name == v.Name {
+					return fmt.Errorf("volume %s is already defined", v.Name)
+				}
+			}
+			spec.Volumes = append(spec.Volumes, v)
+		}
+		for i := range spec.Containers {
+			c := &spec.Containers[i]
+			for _, e := range p.Env {
+				for _, ce := range c.Env {
+					if ce.Name == e.Name {
+						return fmt.Errorf("env var %s is already defined", e.Name)
+					}
+				}
+				c.Env = append(c.Env, e)
+			}
+			for _, vm := range p.VolumeMounts {
+				for _, cvm := range c.VolumeMounts {
+					if cvm.Name == vm.Name {
+						return fmt.Errorf("volume mount %s is already defined", vm.Name)
+					}
+					if cvm.MountPath == vm.MountPath {
+						return fmt.Errorf("mount path %s is already used", vm.MountPath)
+					}
+				}
+				c.VolumeMounts = append(c.VolumeMounts, vm)
+			}
+		}
+	}
+	return nil
+}
+
+func setPresubmitPresets(presets []Preset, js []Presubmit) error {
+	for i := range js {
+		if err := mergePresets(presets, js[i].Labels, js[i].Spec); err != nil {
+			return fmt.Errorf("job %s: %v", js[i].Name, err)
+		}
+		if err := setPresubmitPresets(presets, js[i].RunAfterSuccess); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setPostsubmitPresets(presets []Preset, js []Postsubmit) error {
+	for i := range js {
+		if err := mergePresets(presets, js[i].Labels, js[i].Spec); err != nil {
+			return fmt.Errorf("job %s: %v", js[i].Name, err)
+		}
+		if err := setPostsubmitPresets(presets, js[i].RunAfterSuccess); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setPeriodicPresets(presets []Preset, js []Periodic) error {
+	for i := range js {
+		if err := mergePresets(presets, js[i].Labels, js[i].Spec); err != nil {
+			return fmt.Errorf("job %s: %v", js[i].Name, err)
+		}
+		if err := setPeriodicPresets(presets, js[i].RunAfterSuccess); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+
+	"github.com/ghodss/yaml"
+)
+
+func TestPresets(t *testing.T) {
+	var testcases = []struct {
+		name   string
+		config string
+
+		expectErr       bool
+		expectedEnv     []string
+		expectedVolumes []string
+		expectedMounts  []string
+	}{
+		{
+			name: "default and labeled presets",
+			config: `
+presets:
+- env:
+  - name: DEFAULT
+    value: "1"
+- labels:
+    preset-service-account: "true"
+  env:
+  - name: GOOGLE_APPLICATION_CREDENTIALS
+    value: /etc/service-account/service-account.json
+  volumes:
+  - name: service
+    secret:
+      secretName: service-account
+  volumeMounts:
+  - name: service
+    mountPath: /etc/service-account
+    readOnly: true
+- labels:
+    preset-other: "true"
+  env:
+  - name: OTHER
+    value: "1"
+presubmits:
+  o/r:
+  - name: j
+    labels:
+      preset-service-account: "true"
+    spec:
+      containers:
+      - env:
+        - name: OWN
+          value: "1"`,
+			expectedEnv:     []string{"OWN", "DEFAULT", "GOOGLE_APPLICATION_CREDENTIALS"},
+			expectedVolumes: []string{"service"},
+			expectedMounts:  []string{"service"},
+		},
+		{
+			name: "presets with the same env var",
+			config: `
+presets:
+- env:
+  - name: A
+    value: "1"
+- labels:
+    l: "true"
+  env:
+  - name: A
+    value: "2"
+presubmits:
+  o/r:
+  - name: j
+    labels:
+      l: "true"
+    spec:
+      containers:
+      - image: i`,
+			expectErr: true,
+		},
+		{
+			name: "preset and job with the same volume",
+			config: `
+presets:
+- volumes:
+  - name: v
+postsubmits:
+  o/r:
+  - name: j
+    spec:
+      volumes:
+      - name: v
+      containers:
+      - image: i`,
+			expectErr: true,
+		},
+		{
+			name: "presets with the same mount path",
+			config: `
+presets:
+- volumeMounts:
+  - name: a
+    mountPath: /etc/a
+- volumeMounts:
+  - name: b
+    mountPath: /etc/a
+periodics:
+- name: j
+  interval: 1h
+  spec:
+    containers:
+    - image: i`,
+			expectErr: true,
+		},
+	}
+	for _, tc := range testcases {
+		c := &Config{}
+		if err := yaml.Unmarshal([]byte(tc.config), c); err != nil {
+			t.Fatalf("For case %s, bad test config: %v", tc.name, err)
+		}
+		err := parseConfig(c)
+		if err != nil {
+			if !tc.expectErr {
+				t.Errorf("For case %s, unexpected error: %v", tc.name, err)
+			}
+			continue
+		} else if tc.expectErr {
+			t.Errorf("For case %s, expected an error.", tc.name)
+			continue
+		}
+		spec := c.Presubmits["o/r"][0].Spec
+		var env, volumes, mounts []string
+		for _, e := range spec.Containers[0].Env {
+			env = append(env, e.Name)
+		}
+		for _, v := range spec.Volumes {
+			volumes = append(volumes, v.Name)
+		}
+		for _, vm := range spec.Containers[0].VolumeMounts {
+			mounts = append(mounts, vm.Name)
+		}
+		if !equalStrings(env, tc.expectedEnv) {
+			t.Errorf("For case %s, expected env %v, got %v.", tc.name, tc.expectedEnv, env)
+		}
+		if !equalStrings(volumes, tc.expectedVolumes) {
+			t.Errorf("For case %s, expected volumes %v, got %v.", tc.name, tc.expectedVolumes, volumes)
+		}
+		if !equalStrings(mounts, tc.expectedMounts) {
+			t.Errorf("For case %s, expected mounts %v, got %v.", tc.name, tc.expectedMounts, mounts)
+		}
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
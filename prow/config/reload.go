@@ -0,0 +1,127 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	configVersion = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "prow_config_version",
+		Help: "Always 1, labeled with the version of the loaded config.",
+	}, []string{"config", "version"})
+	configLoadTime = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "prow_config_last_load_timestamp_seconds",
+		Help: "When the config was last loaded successfully.",
+	}, []string{"config"})
+	configLoadFailed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "prow_config_last_load_failed",
+		Help: "1 if the last attempt to load the config failed, 0 otherwise.",
+	}, []string{"config"})
+	configLoadErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "prow_config_load_errors_total",
+		Help: "How many attempts to load the config have failed.",
+	}, []string{"config"})
+)
+
+func init() {
+	prometheus.MustRegister(configVersion, configLoadTime, configLoadFailed, configLoadErrors)
+}
+
+// LoadStatus says what a Reloader has loaded and how the last attempt went.
+type LoadStatus struct {
+	Version  string    `json:"version"`
+	LoadTime time.Time `json:"load_time"`
+	// LastError is the most recent error, even if a later load worked.
+	LastError     string    `json:"last_error,omitempty"`
+	LastErrorTime time.Time `json:"last_error_time"`
+}
+
+// Reloader loads a config and loads it again whenever its files change. It
+// keeps the status of the last load for debugging and exports it as metrics.
+type Reloader struct {
+	name  string
+	paths []string
+	load  func() (string, error)
+
+	mut    sync.Mutex
+	status LoadStatus
+}
+
+// NewReloader returns a Reloader for the config called name that is stored
+// in paths. The load function loads and applies the config, and returns its
+// version.
+func NewReloader(name string, paths []string, load func() (string, error)) *Reloader {
+	return &Reloader{
+		name:  name,
+		paths: paths,
+		load:  load,
+	}
+}
+
+// Start loads the config and returns any error. Afterwards it reloads the
+// config whenever its files change, logging any errors.
+func (r *Reloader) Start() error {
+	if err := r.Reload(); err != nil {
+		return err
+	}
+	go watch(r.paths, func() { r.Reload() }, nil)
+	return nil
+}
+
+// Reload loads the config now.
+func (r *Reloader) Reload() error {
+	version, err := r.load()
+	now := time.Now()
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if err != nil {
+		logrus.WithField("config", r.name).WithError(err).Error("Error loading config.")
+		r.status.LastError = err.Error()
+		r.status.LastErrorTime = now
+		configLoadFailed.WithLabelValues(r.name).Set(1)
+		configLoadErrors.WithLabelValues(r.name).Inc()
+		return err
+	}
+	if version != r.status.Version {
+		logrus.WithFields(logrus.Fields{
+			"config":  r.name,
+			"version": version,
+		}).Info("Loaded new config.")
+		if r.status.Version != "" {
+			configVersion.DeleteLabelValues(r.name, r.status.Version)
+		}
+		configVersion.WithLabelValues(r.name, version).Set(1)
+	}
+	r.status.Version = version
+	r.status.LoadTime = now
+	configLoadTime.WithLabelValues(r.name).Set(float64(now.Unix()))
+	configLoadFailed.WithLabelValues(r.name).Set(0)
+	return nil
+}
+
+// Status returns the status of the last load.
+func (r *Reloader) Status() LoadStatus {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	return r.status
+}
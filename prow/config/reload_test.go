@@ -0,0 +1,252 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWatchDirs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"config/config":             "",
+		"jobs/a/a.yaml":             "",
+		"jobs/..2017_06_01/a.yaml":  "",
+		"globbed/sub/periodic.yaml": "",
+	})
+	dirs := watchDirs([]string{
+		filepath.Join(dir, "config", "config"),
+		filepath.Join(dir, "jobs"),
+		filepath.Join(dir, "globbed", "*", "*.yaml"),
+		"",
+	})
+	expected := []string{
+		filepath.Join(dir, "config"),
+		filepath.Join(dir, "jobs"),
+		filepath.Join(dir, "jobs", "a"),
+		filepath.Join(dir, "globbed"),
+		filepath.Join(dir, "globbed", "sub"),
+	}
+	if !reflect.DeepEqual(dirs, expected) {
+		t.Errorf("Expected dirs %v, got %v.", expected, dirs)
+	}
+}
+
+// TestWatchConfigMapSwap updates a directory the way the kubelet updates a
+// mounted ConfigMap: the file is a symlink through ..data, and ..data is
+// atomically replaced with a link to a new directory.
+func TestWatchConfigMapSwap(t *testing.T) {
+	if n, err := newNotifier(); err != nil {
+		t.Skipf("No file events: %v", err)
+	} else {
+		n.Close()
+	}
+	oldSettle := settlePeriod
+	settlePeriod = 10 * time.Millisecond
+	defer func() { settlePeriod = oldSettle }()
+
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"..v1/config": "one",
+		"..v2/config": "two",
+	})
+	if err := os.Symlink("..v1", filepath.Join(dir, "..data")); err != nil {
+		t.Fatalf("Error linking ..data: %v", err)
+	}
+	if err := os.Symlink(filepath.Join("..data", "config"), filepath.Join(dir, "config")); err != nil {
+		t.Fatalf("Error linking config: %v", err)
+	}
+
+	reloads := make(chan struct{}, 10)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		watch([]string{filepath.Join(dir, "config")}, func() {
+			select {
+			case reloads <- struct{}{}:
+			default:
+			}
+		}, stop)
+		close(done)
+	}()
+	// Stop the watcher before settlePeriod is restored.
+	defer func() {
+		close(stop)
+		<-done
+	}()
+	// Give the watcher a moment to add its watches.
+	time.Sleep(100 * time.Millisecond)
+
+	if err := os.Symlink("..v2", filepath.Join(dir, "..data_tmp")); err != nil {
+		t.Fatalf("Error linking ..data_tmp: %v", err)
+	}
+	if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatalf("Error swapping ..data: %v", err)
+	}
+	select {
+	case <-reloads:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for a reload.")
+	}
+}
+
+func TestNotifierClose(t *testing.T) {
+	n, err := newNotifier()
+	if err != nil {
+		t.Skipf("No file events: %v", err)
+	}
+	closed := make(chan error)
+	go func() { closed <- n.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Errorf("Error closing notifier: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out closing the notifier.")
+	}
+}
+
+type fakeNotifier struct {
+	events chan struct{}
+}
+
+func (n *fakeNotifier) Add(string) error        { return nil }
+func (n *fakeNotifier) Events() <-chan struct{} { return n.events }
+func (n *fakeNotifier) Close() error            { return nil }
+
+func TestWatchFallsBackToPolling(t *testing.T) {
+	n := &fakeNotifier{events: make(chan struct{})}
+	oldStart, oldPoll := startNotifier, pollPeriod
+	startNotifier = func() (notifier, error) { return n, nil }
+	pollPeriod = 10 * time.Millisecond
+	defer func() { startNotifier, pollPeriod = oldStart, oldPoll }()
+
+	reloads := make(chan struct{}, 10)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		watch(nil, func() {
+			select {
+			case reloads <- struct{}{}:
+			default:
+			}
+		}, stop)
+		close(done)
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+	close(n.events)
+	for i := 0; i < 3; i++ {
+		select {
+		case <-reloads:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for reload %d after the notifier died.", i)
+		}
+	}
+}
+
+func TestConfigAgentReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+	writeFiles(t, dir, map[string]string{"config.yaml": "plank:\n  max_retries: 1\n"})
+
+	// Reload by hand rather than starting the agent so that the test
+	// controls when reloads happen.
+	ca := &ConfigAgent{}
+	ca.reloader = ca.newReloader(path, nil)
+	if err := ca.reloader.Reload(); err != nil {
+		t.Fatalf("Error loading config: %v", err)
+	}
+	var changes []int
+	ca.Subscribe(func(old, new *Config) {
+		if old.Plank.GetMaxRetries() != 1 {
+			t.Errorf("Expected the old config to have max retries 1, got %d.", old.Plank.GetMaxRetries())
+		}
+		changes = append(changes, new.Plank.GetMaxRetries())
+	})
+	firstVersion := ca.reloader.Status().Version
+
+	// Reloading the same contents doesn't notify anyone.
+	if err := ca.reloader.Reload(); err != nil {
+		t.Fatalf("Error reloading: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Expected no changes, got %v.", changes)
+	}
+
+	writeFiles(t, dir, map[string]string{"config.yaml": "plank:\n  max_retries: 2\n"})
+	if err := ca.reloader.Reload(); err != nil {
+		t.Fatalf("Error reloading: %v", err)
+	}
+	if !reflect.DeepEqual(changes, []int{2}) {
+		t.Errorf("Expected one change to max retries 2, got %v.", changes)
+	}
+	secondVersion := ca.reloader.Status().Version
+	if secondVersion == firstVersion {
+		t.Errorf("Expected a new version, got %s both times.", firstVersion)
+	}
+
+	// A broken config is reported but doesn't replace the good one.
+	writeFiles(t, dir, map[string]string{"config.yaml": "plank:\n  max_retries: -1\n"})
+	if err := ca.reloader.Reload(); err == nil {
+		t.Fatalf("Expected an error reloading a broken config.")
+	}
+	if ca.Config().Plank.GetMaxRetries() != 2 {
+		t.Errorf("Expected to keep max retries 2, got %d.", ca.Config().Plank.GetMaxRetries())
+	}
+
+	rr := httptest.NewRecorder()
+	ca.ServeHTTP(rr, httptest.NewRequest("GET", "/config", nil))
+	var resp struct {
+		Status LoadStatus `json:"status"`
+		Config Config     `json:"config"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Error unmarshaling /config: %v", err)
+	}
+	if resp.Status.Version != secondVersion {
+		t.Errorf("Expected version %s, got %s.", secondVersion, resp.Status.Version)
+	}
+	if resp.Status.LastError == "" {
+		t.Errorf("Expected the last error to be reported.")
+	}
+	if resp.Config.Plank.GetMaxRetries() != 2 {
+		t.Errorf("Expected /config to show max retries 2, got %d.", resp.Config.Plank.GetMaxRetries())
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"k8s.io/test-infra/prow/kube"
+)
+
+// The defaults point at Gubernator, which knows kubernetes/kubernetes and
+// other kubernetes repos by their short names.
+const (
+	defaultJobURL = `https://k8s-gubernator.appspot.com/build/kubernetes-jenkins/` +
+		`{{if or (eq .ProwJob.Spec.Type "presubmit") (eq .ProwJob.Spec.Type "batch")}}pr-logs/pull{{else}}logs{{end}}` +
+		`{{with .ProwJob.Spec.Refs}}{{if ne .Org "kubernetes"}}{{if .Org}}/{{.Org}}_{{.Repo}}{{end}}{{else if ne .Repo "kubernetes"}}/{{.Repo}}{{end}}{{end}}` +
+		`{{if eq .ProwJob.Spec.Type "presubmit"}}/{{with index .ProwJob.Spec.Refs.Pulls 0}}{{.Number}}{{end}}{{else if eq .ProwJob.Spec.Type "batch"}}/batch{{end}}` +
+		`/{{.ProwJob.Spec.Job}}/{{.BuildID}}/`
+	defaultPRHistoryURL = `https://k8s-gubernator.appspot.com/pr/` +
+		`{{with .ProwJob.Spec.Refs}}{{if ne .Org "kubernetes"}}{{.Org}}_{{.Repo}}/{{else if ne .Repo "kubernetes"}}{{.Repo}}/{{end}}{{with index .Pulls 0}}{{.Number}}{{end}}{{end}}`
+	defaultDashboardURL = `https://k8s-gubernator.appspot.com/pr/{{with index .ProwJob.Spec.Refs.Pulls 0}}{{.Author}}{{end}}`
+)
+
+var (
+	defaultJobURLTemplate       = template.Must(template.New("job_url").Parse(defaultJobURL))
+	defaultPRHistoryURLTemplate = template.Must(template.New("pr_history_url").Parse(defaultPRHistoryURL))
+	defaultDashboardURLTemplate = template.Must(template.New("dashboard_url").Parse(defaultDashboardURL))
+)
+
+// URLTemplates are Go templates for links to job results. They are executed
+// with a URLData. Empty templates link to Gubernator.
+type URLTemplates struct {
+	// JobURLTemplate links to the results of a single build.
+	JobURLTemplate string `json:"job_url,omitempty"`
+	// PRHistoryURLTemplate links to the history of all jobs on a PR.
+	PRHistoryURLTemplate string `json:"pr_history_url,omitempty"`
+	// DashboardURLTemplate links to the PR dashboard of the author of a PR.
+	DashboardURLTemplate string `json:"dashboard_url,omitempty"`
+
+	jobURL       *template.Template
+	prHistoryURL *template.Template
+	dashboardURL *template.Template
+}
+
+// URLData is what the URL templates are executed with.
+type URLData struct {
+	ProwJob kube.ProwJob
+	// BuildID is only set for the job URL.
+	BuildID string
+}
+
+func (u *URLTemplates) parse() error {
+	var err error
+	if u.jobURL, err = parseURLTemplate("job_url", u.JobURLTemplate, defaultJobURLTemplate); err != nil {
+		return err
+	}
+	if u.prHistoryURL, err = parseURLTemplate("pr_history_url", u.PRHistoryURLTemplate, defaultPRHistoryURLTemplate); err != nil {
+		return err
+	}
+	if u.dashboardURL, err = parseURLTemplate("dashboard_url", u.DashboardURLTemplate, defaultDashboardURLTemplate); err != nil {
+		return err
+	}
+	return nil
+}
+
+func parseURLTemplate(name, s string, def *template.Template) (*template.Template, error) {
+	if s == "" {
+		return def, nil
+	}
+	t, err := template.New(name).Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s template: %v", name, err)
+	}
+	return t, nil
+}
+
+// JobURL returns the link to the results of the given build of pj.
+func (u URLTemplates) JobURL(pj kube.ProwJob, buildID string) (string, error) {
+	return executeURLTemplate(u.jobURL, defaultJobURLTemplate, URLData{ProwJob: pj, BuildID: buildID})
+}
+
+// PRHistoryURL returns the link to the history of the PR that pj tests.
+func (u URLTemplates) PRHistoryURL(pj kube.ProwJob) (string, error) {
+	return executeURLTemplate(u.prHistoryURL, defaultPRHistoryURLTemplate, URLData{ProwJob: pj})
+}
+
+// DashboardURL returns the link to the dashboard of the author of the PR that
+// pj tests.
+func (u URLTemplates) DashboardURL(pj kube.ProwJob) (string, error) {
+	return executeURLTemplate(u.dashboardURL, defaultDashboardURLTemplate, URLData{ProwJob: pj})
+}
+
+func executeURLTemplate(t, def *template.Template, data URLData) (string, error) {
+	if t == nil {
+		t = def
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("error executing %s template: %v", t.Name(), err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+
+	"k8s.io/test-infra/prow/kube"
+)
+
+func TestDefaultJobURL(t *testing.T) {
+	var testcases = []struct {
+		name     string
+		pj       kube.ProwJob
+		expected string
+	}{
+		{
+			name: "presubmit on kubernetes/kubernetes",
+			pj: kube.ProwJob{Spec: kube.ProwJobSpec{
+				Type: kube.PresubmitJob,
+				Job:  "pull-kubernetes-e2e",
+				Refs: kube.Refs{Org: "kubernetes", Repo: "kubernetes", Pulls: []kube.Pull{{Number: 123}}},
+			}},
+			expected: "https://k8s-gubernator.appspot.com/build/kubernetes-jenkins/pr-logs/pull/123/pull-kubernetes-e2e/42/",
+		},
+		{
+			name: "presubmit on another kubernetes repo",
+			pj: kube.ProwJob{Spec: kube.ProwJobSpec{
+				Type: kube.PresubmitJob,
+				Job:  "pull-test-infra-bazel",
+				Refs: kube.Refs{Org: "kubernetes", Repo: "test-infra", Pulls: []kube.Pull{{Number: 5}}},
+			}},
+			expected: "https://k8s-gubernator.appspot.com/build/kubernetes-jenkins/pr-logs/pull/test-infra/5/pull-test-infra-bazel/42/",
+		},
+		{
+			name: "batch on another org",
+			pj: kube.ProwJob{Spec: kube.ProwJobSpec{
+				Type: kube.BatchJob,
+				Job:  "pull-o-test",
+				Refs: kube.Refs{Org: "o", Repo: "r", Pulls: []kube.Pull{{Number: 1}, {Number: 2}}},
+			}},
+			expected: "https://k8s-gubernator.appspot.com/build/kubernetes-jenkins/pr-logs/pull/o_r/batch/pull-o-test/42/",
+		},
+		{
+			name: "postsubmit",
+			pj: kube.ProwJob{Spec: kube.ProwJobSpec{
+				Type: kube.PostsubmitJob,
+				Job:  "ci-kubernetes-build",
+				Refs: kube.Refs{Org: "kubernetes", Repo: "kubernetes"},
+			}},
+			expected: "https://k8s-gubernator.appspot.com/build/kubernetes-jenkins/logs/ci-kubernetes-build/42/",
+		},
+		{
+			name: "periodic",
+			pj: kube.ProwJob{Spec: kube.ProwJobSpec{
+				Type: kube.PeriodicJob,
+				Job:  "ci-kubernetes-e2e",
+			}},
+			expected: "https://k8s-gubernator.appspot.com/build/kubernetes-jenkins/logs/ci-kubernetes-e2e/42/",
+		},
+	}
+	c := &Config{}
+	if err := parseConfig(c); err != nil {
+		t.Fatalf("Error parsing empty config: %v", err)
+	}
+	for _, tc := range testcases {
+		url, err := c.URLTemplates.JobURL(tc.pj, "42")
+		if err != nil {
+			t.Errorf("For case %s, unexpected error: %v", tc.name, err)
+		} else if url != tc.expected {
+			t.Errorf("For case %s, expected %s, got %s", tc.name, tc.expected, url)
+		}
+	}
+}
+
+func TestDefaultPRLinks(t *testing.T) {
+	var testcases = []struct {
+		org    string
+		repo   string
+		number int
+		suffix string
+	}{
+		{
+			org:    "o",
+			repo:   "r",
+			number: 4,
+			suffix: "o_r/4",
+		},
+		{
+			org:    "kubernetes",
+			repo:   "test-infra",
+			number: 123,
+			suffix: "test-infra/123",
+		},
+		{
+			org:    "kubernetes",
+			repo:   "kubernetes",
+			number: 123,
+			suffix: "123",
+		},
+		{
+			org:    "o",
+			repo:   "kubernetes",
+			number: 456,
+			suffix: "o_kubernetes/456",
+		},
+	}
+	var urls URLTemplates
+	for _, tc := range testcases {
+		pj := kube.ProwJob{Spec: kube.ProwJobSpec{
+			Type: kube.PresubmitJob,
+			Refs: kube.Refs{Org: tc.org, Repo: tc.repo, Pulls: []kube.Pull{{Number: tc.number, Author: "a"}}},
+		}}
+		prl, err := urls.PRHistoryURL(pj)
+		if err != nil {
+			t.Errorf("Unexpected error for %+v: %v", tc, err)
+		} else if expected := "https://k8s-gubernator.appspot.com/pr/" + tc.suffix; prl != expected {
+			t.Errorf("Expected %s for %+v, got %s", expected, tc, prl)
+		}
+		if dl, err := urls.DashboardURL(pj); err != nil {
+			t.Errorf("Unexpected error for %+v: %v", tc, err)
+		} else if dl != "https://k8s-gubernator.appspot.com/pr/a" {
+			t.Errorf("Wrong dashboard link for %+v: %s", tc, dl)
+		}
+	}
+}
+
+func TestCustomURLTemplates(t *testing.T) {
+	c := &Config{
+		URLTemplates: URLTemplates{
+			JobURLTemplate:       "https://logs.example.com/{{.ProwJob.Spec.Job}}/{{.BuildID}}",
+			DashboardURLTemplate: "https://example.com/{{(index .ProwJob.Spec.Refs.Pulls 0).Author}}",
+		},
+	}
+	if err := parseConfig(c); err != nil {
+		t.Fatalf("Error parsing config: %v", err)
+	}
+	pj := kube.ProwJob{Spec: kube.ProwJobSpec{
+		Type: kube.PresubmitJob,
+		Job:  "j",
+		Refs: kube.Refs{Org: "o", Repo: "r", Pulls: []kube.Pull{{Number: 1, Author: "a"}}},
+	}}
+	if url, err := c.URLTemplates.JobURL(pj, "7"); err != nil || url != "https://logs.example.com/j/7" {
+		t.Errorf("Wrong job URL %s, error %v", url, err)
+	}
+	if url, err := c.URLTemplates.DashboardURL(pj); err != nil || url != "https://example.com/a" {
+		t.Errorf("Wrong dashboard URL %s, error %v", url, err)
+	}
+	// Unset templates keep their defaults.
+	if url, err := c.URLTemplates.PRHistoryURL(pj); err != nil || url != "https://k8s-gubernator.appspot.com/pr/o_r/1" {
+		t.Errorf("Wrong PR history URL %s, error %v", url, err)
+	}
+
+	bad := &Config{URLTemplates: URLTemplates{JobURLTemplate: "{{.Nope"}}
+	if err := parseConfig(bad); err == nil {
+		t.Error("Expected an error parsing a bad template.")
+	}
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+var (
+	// How long to wait for a burst of file events to end before reloading.
+	// Updating a mounted ConfigMap creates, renames and removes several
+	// files in quick succession.
+	settlePeriod = time.Second
+	// How often to reload anyway, in case we missed an event.
+	resyncPeriod = 10 * time.Minute
+	// How often to reload when we can't watch for file events at all.
+	pollPeriod = time.Minute
+	// startNotifier is a variable so that tests can fake file events.
+	startNotifier = newNotifier
+)
+
+// notifier sends on Events whenever something changes in a watched
+// directory. It closes Events if it stops working.
+type notifier interface {
+	Add(dir string) error
+	Events() <-chan struct{}
+	Close() error
+}
+
+// watch calls reload whenever the files named by paths may have changed,
+// until stop is closed. Paths are files, directories or globs, as for Load.
+func watch(paths []string, reload func(), stop <-chan struct{}) {
+	period := resyncPeriod
+	var events <-chan struct{}
+	n, err := startNotifier()
+	if err != nil {
+		logrus.WithError(err).Warning("Cannot watch for file changes, polling instead.")
+		period = pollPeriod
+	} else {
+		defer n.Close()
+		events = n.Events()
+		addWatches(n, paths)
+	}
+	ticker := time.NewTicker(period)
+	defer func() { ticker.Stop() }()
+	// If the notifier dies, poll instead, starting with a reload in case we
+	// missed something.
+	fallBack := func() {
+		logrus.Warning("Stopped getting file events, polling instead.")
+		events = nil
+		ticker.Stop()
+		ticker = time.NewTicker(pollPeriod)
+	}
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case _, ok := <-events:
+			if !ok {
+				fallBack()
+				break
+			}
+			settle := time.After(settlePeriod)
+			for settling := true; settling; {
+				select {
+				case <-stop:
+					return
+				case _, ok := <-events:
+					if !ok {
+						fallBack()
+					}
+				case <-settle:
+					settling = false
+				}
+			}
+		}
+		reload()
+		if n != nil {
+			// Pick up any new directories.
+			addWatches(n, paths)
+		}
+	}
+}
+
+func addWatches(n notifier, paths []string) {
+	for _, d := range watchDirs(paths) {
+		if err := n.Add(d); err != nil {
+			logrus.WithField("dir", d).WithError(err).Warning("Cannot watch directory.")
+		}
+	}
+}
+
+// watchDirs returns the directories to watch for changes to paths. Files are
+// watched through their parent directory, which also sees the symlink swap
+// that Kubernetes does when it updates a mounted ConfigMap. Directories are
+// watched along with all of their subdirectories, and globs from the last
+// directory before the first wildcard.
+func watchDirs(paths []string) []string {
+	seen := map[string]bool{}
+	var dirs []string
+	add := func(d string) {
+		if !seen[d] {
+			seen[d] = true
+			dirs = append(dirs, d)
+		}
+	}
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		for strings.ContainsAny(p, `*?[\`) {
+			p = filepath.Dir(p)
+		}
+		fi, err := os.Stat(p)
+		if err != nil || !fi.IsDir() {
+			add(filepath.Dir(p))
+			continue
+		}
+		filepath.Walk(p, func(path string, info os.FileInfo, err error) error {
+			if err != nil || !info.IsDir() {
+				return nil
+			}
+			if path != p && isHidden(info.Name()) {
+				return filepath.SkipDir
+			}
+			add(path)
+			return nil
+		})
+	}
+	return dirs
+}
+
+// isHidden is true for dot files, which include the timestamped directories
+// that back a mounted ConfigMap.
+func isHidden(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
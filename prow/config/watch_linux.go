@@ -0,0 +1,118 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY |
+	unix.IN_CLOSE_WRITE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO
+
+// inotify is a notifier backed by Linux inotify. We don't care which file
+// changed, only that one did.
+type inotify struct {
+	fd int
+	// Close writes to wake to stop the reader, which may be blocked in poll.
+	wake   [2]int
+	events chan struct{}
+	done   chan struct{}
+}
+
+func newNotifier() (notifier, error) {
+	// The runtime poller doesn't manage these fds, so we poll them ourselves
+	// rather than rely on how os.File treats a non-blocking fd.
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing inotify: %v", err)
+	}
+	var wake [2]int
+	if err := unix.Pipe2(wake[:], unix.O_CLOEXEC); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("error making pipe: %v", err)
+	}
+	n := &inotify{
+		fd:     fd,
+		wake:   wake,
+		events: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	go n.read()
+	return n, nil
+}
+
+// read sends on events whenever there are file events to read. It closes
+// events if it stops for any reason other than Close.
+func (n *inotify) read() {
+	defer close(n.done)
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	fds := []unix.PollFd{
+		{Fd: int32(n.fd), Events: unix.POLLIN},
+		{Fd: int32(n.wake[0]), Events: unix.POLLIN},
+	}
+	for {
+		if _, err := unix.Poll(fds, -1); err == unix.EINTR {
+			continue
+		} else if err != nil {
+			logrus.WithError(err).Error("Error waiting for file events.")
+			close(n.events)
+			return
+		}
+		if fds[1].Revents != 0 {
+			return
+		}
+		if fds[0].Revents&(unix.POLLERR|unix.POLLHUP|unix.POLLNVAL) != 0 {
+			logrus.Errorf("Error waiting for file events: poll returned %#x.", fds[0].Revents)
+			close(n.events)
+			return
+		}
+		if _, err := unix.Read(n.fd, buf); err == unix.EAGAIN || err == unix.EINTR {
+			continue
+		} else if err != nil {
+			logrus.WithError(err).Error("Error reading file events.")
+			close(n.events)
+			return
+		}
+		select {
+		case n.events <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (n *inotify) Add(dir string) error {
+	if _, err := unix.InotifyAddWatch(n.fd, dir, inotifyMask); err != nil {
+		return fmt.Errorf("error watching %s: %v", dir, err)
+	}
+	return nil
+}
+
+func (n *inotify) Events() <-chan struct{} {
+	return n.events
+}
+
+// Close stops the reader and then closes the fds it was using.
+func (n *inotify) Close() error {
+	unix.Write(n.wake[1], []byte{0})
+	<-n.done
+	unix.Close(n.wake[0])
+	unix.Close(n.wake[1])
+	return unix.Close(n.fd)
+}
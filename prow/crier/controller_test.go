@@ -0,0 +1,494 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crier
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/kube"
+)
+
+type fakeGitHub struct {
+	ics    []github.IssueComment
+	status github.Status
+	// statuses are keyed by ref.
+	statuses map[string]github.Status
+	lastIC   int
+}
+
+func (f *fakeGitHub) BotName() string {
+	return "k8s-ci-robot"
+}
+
+func (f *fakeGitHub) CreateStatus(org, repo, ref string, s github.Status) error {
+	f.status = s
+	if f.statuses == nil {
+		f.statuses = map[string]github.Status{}
+	}
+	f.statuses[ref] = s
+	return nil
+}
+
+func (f *fakeGitHub) ListIssueComments(org, repo string, number int) ([]github.IssueComment, error) {
+	return f.ics, nil
+}
+
+func (f *fakeGitHub) CreateComment(org, repo string, number int, comment string) error {
+	f.lastIC++
+	f.ics = append(f.ics, github.IssueComment{
+		ID:   f.lastIC,
+		Body: comment,
+		User: github.User{Login: "k8s-ci-robot"},
+	})
+	return nil
+}
+
+func (f *fakeGitHub) EditComment(org, repo string, ID int, comment string) error {
+	for i, ic := range f.ics {
+		if ic.ID == ID {
+			f.ics[i].Body = comment
+			return nil
+		}
+	}
+	return fmt.Errorf("issue comment not found: %d", ID)
+}
+
+func (f *fakeGitHub) DeleteComment(org, repo string, ID int) error {
+	var nics []github.IssueComment
+	for _, ic := range f.ics {
+		if ic.ID != ID {
+			nics = append(nics, ic)
+		}
+	}
+	f.ics = nics
+	return nil
+}
+
+type fkc struct {
+	prowjobs []kube.ProwJob
+}
+
+func (f *fkc) GetProwJob(name string) (kube.ProwJob, error) {
+	for _, pj := range f.prowjobs {
+		if pj.Metadata.Name == name {
+			return pj, nil
+		}
+	}
+	return kube.ProwJob{}, kube.NotFoundError{}
+}
+
+func (f *fkc) ListProwJobs(map[string]string) ([]kube.ProwJob, error) {
+	return f.prowjobs, nil
+}
+
+func (f *fkc) ReplaceProwJob(name string, job kube.ProwJob) (kube.ProwJob, error) {
+	for i := range f.prowjobs {
+		if f.prowjobs[i].Metadata.Name == name {
+			f.prowjobs[i] = job
+			return job, nil
+		}
+	}
+	return kube.ProwJob{}, fmt.Errorf("did not find prowjob %s", name)
+}
+
+type fca struct {
+	c *config.Config
+}
+
+func (f fca) Config() *config.Config {
+	return f.c
+}
+
+func TestGitHubShouldReport(t *testing.T) {
+	pull := []kube.Pull{{Number: 1, SHA: "abc"}}
+	var testcases = []struct {
+		name     string
+		spec     kube.ProwJobSpec
+		state    kube.ProwJobState
+		expected bool
+	}{
+		{
+			name:     "pending",
+			spec:     kube.ProwJobSpec{Report: true, Refs: kube.Refs{Pulls: pull}},
+			state:    kube.PendingState,
+			expected: true,
+		},
+		{
+			name:     "finished",
+			spec:     kube.ProwJobSpec{Report: true, Refs: kube.Refs{Pulls: pull}},
+			state:    kube.FailureState,
+			expected: true,
+		},
+		{
+			name:  "not started",
+			spec:  kube.ProwJobSpec{Report: true, Refs: kube.Refs{Pulls: pull}},
+			state: kube.TriggeredState,
+		},
+		{
+			name:  "aborted",
+			spec:  kube.ProwJobSpec{Report: true, Refs: kube.Refs{Pulls: pull}},
+			state: kube.AbortedState,
+		},
+		{
+			name:  "report off",
+			spec:  kube.ProwJobSpec{Refs: kube.Refs{Pulls: pull}},
+			state: kube.PendingState,
+		},
+		{
+			name:  "presubmit on two PRs",
+			spec:  kube.ProwJobSpec{Type: kube.PresubmitJob, Report: true, Refs: kube.Refs{Pulls: append(pull, kube.Pull{Number: 2})}},
+			state: kube.PendingState,
+		},
+		{
+			name:     "batch",
+			spec:     kube.ProwJobSpec{Type: kube.BatchJob, Report: true, Refs: kube.Refs{Pulls: append(pull, kube.Pull{Number: 2})}},
+			state:    kube.PendingState,
+			expected: true,
+		},
+		{
+			name:  "periodic",
+			spec:  kube.ProwJobSpec{Report: true},
+			state: kube.FailureState,
+		},
+	}
+	r := &githubReporter{}
+	for _, tc := range testcases {
+		pj := kube.ProwJob{Spec: tc.spec, Status: kube.ProwJobStatus{State: tc.state}}
+		if actual := r.ShouldReport(pj); actual != tc.expected {
+			t.Errorf("For case %s, expected %t but got %t.", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+// Test that reports follow the ProwJob through its states and that the
+// failure comment is kept up to date.
+func TestSyncProwJob(t *testing.T) {
+	fghc := &fakeGitHub{}
+	fkc := &fkc{}
+	c := &Controller{
+		kc:  fkc,
+		ghc: fghc,
+		ca:  fca{&config.Config{}},
+	}
+	update := func(context string, state kube.ProwJobState) {
+		pj := kube.ProwJob{
+			Metadata: kube.ObjectMeta{Name: context},
+			Spec: kube.ProwJobSpec{
+				Context: context,
+				Report:  true,
+				Refs:    kube.Refs{Pulls: []kube.Pull{{Number: 1}}},
+			},
+		}
+		if old, err := fkc.GetProwJob(context); err == nil {
+			pj.Status = old.Status
+		} else {
+			fkc.prowjobs = append(fkc.prowjobs, pj)
+		}
+		pj.Status.State = state
+		fkc.ReplaceProwJob(context, pj)
+		if err := c.SyncProwJob(context); err != nil {
+			t.Fatalf("Error syncing %s: %v", context, err)
+		}
+		if pj, _ := fkc.GetProwJob(context); pj.Status.LastReported["github"].State != state {
+			t.Errorf("Expected %s to be reported as %s, got %+v.", context, state, pj.Status.LastReported)
+		}
+	}
+
+	update("bla", kube.PendingState)
+	if fghc.status.Context != "bla" {
+		t.Errorf("Incorrect context for status: got %s want bla", fghc.status.Context)
+	}
+	fghc.status = github.Status{}
+	if err := c.SyncProwJob("bla"); err != nil {
+		t.Fatalf("Error syncing bla: %v", err)
+	}
+	if fghc.status.Context != "" {
+		t.Errorf("Should not have reported bla again: %+v", fghc.status)
+	}
+
+	fghc.CreateComment("", "", 0, "foo test **failed** for a thingamabob")
+	update("foo test", kube.SuccessState)
+	if len(fghc.ics) != 0 {
+		t.Errorf("There shouldn't be any comments here: %v", fghc.ics)
+	}
+
+	update("bar test", kube.FailureState)
+	if len(fghc.ics) != 1 {
+		t.Errorf("There should be one comment here: %v", fghc.ics)
+	}
+
+	update("foo test", kube.FailureState)
+	if len(fghc.ics) != 1 {
+		t.Errorf("There should be one comment here: %v", fghc.ics)
+	}
+	id := fghc.ics[0].ID
+	update("foo test", kube.SuccessState)
+	if id != fghc.ics[0].ID || strings.Contains(fghc.ics[0].Body, "foo test") {
+		t.Errorf("Should have updated comment %d:\n%s\n", id, fghc.ics[0].Body)
+	}
+}
+
+// Test that a periodic job is passed to the reporters it picks, that each
+// reporter's report is recorded separately, and that one failing doesn't stop
+// the others.
+func TestReporters(t *testing.T) {
+	var hooks, slacks []string
+	failHook := true
+	hookServ := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var pj kube.ProwJob
+		if err := json.NewDecoder(r.Body).Decode(&pj); err != nil {
+			t.Errorf("Error decoding webhook: %v", err)
+		}
+		if failHook {
+			http.Error(w, "nope", http.StatusInternalServerError)
+			return
+		}
+		hooks = append(hooks, string(pj.Status.State))
+	}))
+	defer hookServ.Close()
+	slackServ := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg struct {
+			Text    string `json:"text"`
+			Channel string `json:"channel"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("Error decoding slack message: %v", err)
+		}
+		if msg.Channel != "#ci" {
+			t.Errorf("Expected channel #ci, got %s.", msg.Channel)
+		}
+		slacks = append(slacks, msg.Text)
+	}))
+	defer slackServ.Close()
+	dir, err := ioutil.TempDir("", "crier")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	hookFile := filepath.Join(dir, "hook")
+	slackFile := filepath.Join(dir, "slack")
+	if err := ioutil.WriteFile(hookFile, []byte(hookServ.URL+"\n"), 0600); err != nil {
+		t.Fatalf("Error writing url file: %v", err)
+	}
+	if err := ioutil.WriteFile(slackFile, []byte(slackServ.URL), 0600); err != nil {
+		t.Fatalf("Error writing url file: %v", err)
+	}
+
+	cfg := &config.Config{
+		Crier: config.Crier{
+			Reporters: []config.Reporter{
+				{
+					Name:    "hook",
+					Type:    config.WebhookReporter,
+					URLFile: hookFile,
+					States:  []kube.ProwJobState{kube.PendingState, kube.FailureState},
+				},
+				{
+					Name:    "slack",
+					Type:    config.SlackReporter,
+					URLFile: slackFile,
+					Channel: "#ci",
+					States:  []kube.ProwJobState{kube.FailureState},
+				},
+			},
+		},
+	}
+	fkc := &fkc{
+		prowjobs: []kube.ProwJob{{
+			Metadata: kube.ObjectMeta{Name: "p"},
+			Spec: kube.ProwJobSpec{
+				Type:      kube.PeriodicJob,
+				Job:       "ci-foo",
+				Reporters: []string{"hook", "slack", "gone"},
+			},
+			Status: kube.ProwJobStatus{
+				State:       kube.PendingState,
+				Description: "Job triggered.",
+				URL:         "https://logs/1",
+			},
+		}},
+	}
+	c := &Controller{
+		kc:  fkc,
+		ghc: &fakeGitHub{},
+		ca:  fca{cfg},
+		hc:  &http.Client{},
+	}
+
+	if err := c.SyncProwJob("p"); err == nil {
+		t.Error("Expected an error from the failing webhook.")
+	}
+	if len(hooks) != 0 || len(slacks) != 0 {
+		t.Errorf("Expected no reports, got webhooks %v and slack messages %v.", hooks, slacks)
+	}
+	if len(fkc.prowjobs[0].Status.LastReported) != 0 {
+		t.Errorf("Expected nothing to be recorded, got %+v.", fkc.prowjobs[0].Status.LastReported)
+	}
+
+	failHook = false
+	if err := c.SyncProwJob("p"); err != nil {
+		t.Fatalf("Error syncing: %v", err)
+	}
+	fkc.prowjobs[0].Status.State = kube.FailureState
+	fkc.prowjobs[0].Status.Description = "Job failed."
+	for i := 0; i < 2; i++ {
+		if err := c.SyncProwJob("p"); err != nil {
+			t.Fatalf("Error syncing: %v", err)
+		}
+	}
+	if expected := []string{"pending", "failure"}; !reflect.DeepEqual(hooks, expected) {
+		t.Errorf("Expected webhooks %v, got %v.", expected, hooks)
+	}
+	if expected := []string{"ci-foo: failure. Job failed. <https://logs/1|Details>"}; !reflect.DeepEqual(slacks, expected) {
+		t.Errorf("Expected slack messages %v, got %v.", expected, slacks)
+	}
+	expected := map[string]kube.Reported{
+		"hook":  {State: kube.FailureState, URL: "https://logs/1"},
+		"slack": {State: kube.FailureState, URL: "https://logs/1"},
+	}
+	if actual := fkc.prowjobs[0].Status.LastReported; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected last reported %+v, got %+v.", expected, actual)
+	}
+}
+
+func TestBatchReport(t *testing.T) {
+	fghc := &fakeGitHub{}
+	fkc := &fkc{
+		prowjobs: []kube.ProwJob{{
+			Metadata: kube.ObjectMeta{Name: "b"},
+			Spec: kube.ProwJobSpec{
+				Type:    kube.BatchJob,
+				Job:     "pull-foo",
+				Context: "foo test",
+				Report:  true,
+				Refs: kube.Refs{
+					Org:  "org",
+					Repo: "repo",
+					Pulls: []kube.Pull{
+						{Number: 1, SHA: "a"},
+						{Number: 2, SHA: "b"},
+						{Number: 3, SHA: "c"},
+					},
+				},
+			},
+			Status: kube.ProwJobStatus{
+				State:       kube.FailureState,
+				Description: "Job failed.",
+				URL:         "https://logs/batch/1",
+			},
+		}},
+	}
+	c := &Controller{
+		kc:  fkc,
+		ghc: fghc,
+		ca:  fca{&config.Config{}},
+	}
+	if err := c.SyncProwJob("b"); err != nil {
+		t.Fatalf("Error syncing: %v", err)
+	}
+	expected := map[string]github.Status{
+		"a": {State: "failure", Context: "foo test (batch)", TargetURL: "https://logs/batch/1", Description: "Job failed. Tested with #2, #3."},
+		"b": {State: "failure", Context: "foo test (batch)", TargetURL: "https://logs/batch/1", Description: "Job failed. Tested with #1, #3."},
+		"c": {State: "failure", Context: "foo test (batch)", TargetURL: "https://logs/batch/1", Description: "Job failed. Tested with #1, #2."},
+	}
+	if !reflect.DeepEqual(fghc.statuses, expected) {
+		t.Errorf("Expected statuses %+v, got %+v.", expected, fghc.statuses)
+	}
+	if len(fghc.ics) != 0 {
+		t.Errorf("Expected no comments, got %v.", fghc.ics)
+	}
+}
+
+func TestBatchDescription(t *testing.T) {
+	var pulls []kube.Pull
+	for i := 1; i <= 40; i++ {
+		pulls = append(pulls, kube.Pull{Number: i})
+	}
+	pj := kube.ProwJob{
+		Spec:   kube.ProwJobSpec{Refs: kube.Refs{Pulls: pulls}},
+		Status: kube.ProwJobStatus{Description: "Job succeeded."},
+	}
+	d := batchDescription(pj, 1)
+	if len(d) != maxDescription || !strings.HasPrefix(d, "Job succeeded. Tested with #2, #3,") || !strings.HasSuffix(d, "...") {
+		t.Errorf("Bad description: %q", d)
+	}
+}
+
+func TestReadURL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crier")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	var testcases = []struct {
+		name     string
+		contents string
+		expected string
+		err      bool
+	}{
+		{
+			name:     "trims whitespace",
+			contents: " https://hooks.example.com/secret\n",
+			expected: "https://hooks.example.com/secret",
+		},
+		{
+			name:     "no scheme",
+			contents: "hooks.example.com/secret",
+			err:      true,
+		},
+		{
+			name:     "empty",
+			contents: "",
+			err:      true,
+		},
+	}
+	for _, tc := range testcases {
+		path := filepath.Join(dir, "url")
+		if err := ioutil.WriteFile(path, []byte(tc.contents), 0600); err != nil {
+			t.Fatalf("Error writing url file: %v", err)
+		}
+		u, err := readURL(path)
+		if tc.err {
+			if err == nil {
+				t.Errorf("For case %s, expected an error.", tc.name)
+			} else if strings.Contains(err.Error(), "secret") {
+				t.Errorf("For case %s, the error leaks the URL: %v", tc.name, err)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("For case %s, didn't expect error: %v", tc.name, err)
+			continue
+		}
+		if u != tc.expected {
+			t.Errorf("For case %s, expected %s, got %s", tc.name, tc.expected, u)
+		}
+	}
+	if _, err := readURL(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected an error for a missing file.")
+	}
+}
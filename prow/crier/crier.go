@@ -14,27 +14,38 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-// Package crier sets GitHub statuses and writes comments for test statuses.
+// Package crier watches ProwJobs and reports them. It sets GitHub statuses and
+// writes comments for them, and passes them to the webhook, Slack and email
+// reporters that jobs pick in the config.
 package crier
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 
+	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/kube"
 	"k8s.io/test-infra/prow/plugins"
 )
 
 const (
-	commentTag  = "<!-- test report -->"
-	guberPrefix = "https://k8s-gubernator.appspot.com/pr/"
+	commentTag = "<!-- test report -->"
+	// How often Run syncs every ProwJob, in case we missed an event.
+	resyncPeriod = 10 * time.Minute
+	// How often Run checks for email digests that are due.
+	digestPeriod = time.Minute
+	// The longest status description that GitHub accepts.
+	maxDescription = 140
 )
 
+// Report is what crier tells GitHub about a ProwJob.
 type Report struct {
 	RepoOwner string `json:"repo_owner"`
 	RepoName  string `json:"repo_name"`
@@ -42,6 +53,7 @@ type Report struct {
 	Number    int    `json:"number"`
 	Commit    string `json:"commit"`
 
+	Job          string `json:"job"`
 	Context      string `json:"context"`
 	State        string `json:"state"`
 	Description  string `json:"description"`
@@ -49,12 +61,6 @@ type Report struct {
 	URL          string `json:"url"`
 }
 
-type Server struct {
-	rc     chan Report
-	ghc    GitHubClient
-	notify chan struct{}
-}
-
 type GitHubClient interface {
 	BotName() string
 	CreateStatus(org, repo, ref string, s github.Status) error
@@ -64,49 +70,285 @@ type GitHubClient interface {
 	EditComment(org, repo string, ID int, comment string) error
 }
 
-func NewServer(ghc GitHubClient) *Server {
-	return &Server{
-		rc:  make(chan Report),
-		ghc: ghc,
-	}
+type kubeClient interface {
+	GetProwJob(string) (kube.ProwJob, error)
+	ListProwJobs(map[string]string) ([]kube.ProwJob, error)
+	ReplaceProwJob(string, kube.ProwJob) (kube.ProwJob, error)
 }
 
-func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/status" {
-		http.Error(w, fmt.Sprintf("Bad path: %s", r.URL.Path), http.StatusNotFound)
-		return
+type configAgent interface {
+	Config() *config.Config
+}
+
+// Reporter tells someone about ProwJobs.
+type Reporter interface {
+	// Name identifies the reporter in the ProwJob's status.
+	Name() string
+	// ShouldReport returns whether the reporter reports the ProwJob in its
+	// current state.
+	ShouldReport(pj kube.ProwJob) bool
+	// Report reports the ProwJob in its current state.
+	Report(pj kube.ProwJob) error
+}
+
+// errDeferred is returned by reporters that report later, such as email
+// digests. They record the report in the ProwJob themselves once it is made.
+var errDeferred = errors.New("report deferred")
+
+// SMTPCredentials log in to the mail servers of email reporters. If Username
+// is empty, mail is sent without logging in.
+type SMTPCredentials struct {
+	Username string
+	Password string
+}
+
+// Controller reports ProwJobs as their states change, to GitHub and to the
+// reporters that each job picks in the config. What each reporter last
+// reported is recorded in the ProwJob's status, so crier picks up where it
+// left off after a restart and never reports the same state twice.
+type Controller struct {
+	kc    kubeClient
+	ghc   GitHubClient
+	ca    configAgent
+	hc    *http.Client
+	creds SMTPCredentials
+
+	cache *kube.Cache
+
+	lock    sync.Mutex
+	digests map[string]*emailDigest
+}
+
+// NewController creates a controller that reads from and writes through the
+// cache. The cache must be started before calling Run.
+func NewController(kca *kube.Cache, ghc GitHubClient, ca *config.ConfigAgent, creds SMTPCredentials) *Controller {
+	return &Controller{
+		kc:    kca,
+		ghc:   ghc,
+		ca:    ca,
+		hc:    &http.Client{Timeout: time.Minute},
+		creds: creds,
+		cache: kca,
 	}
-	if r.Method != http.MethodPost {
-		http.Error(w, fmt.Sprintf("Method not POST: %s", r.Method), http.StatusMethodNotAllowed)
-		return
+}
+
+// Run syncs ProwJobs as the cache reports changes to them until stop is
+// closed. Every resyncPeriod it syncs all of them regardless, which retries
+// reports that failed. Email digests that are due are sent every
+// digestPeriod.
+func (c *Controller) Run(stop <-chan struct{}) {
+	q := kube.NewWorkQueue()
+	c.cache.AddProwJobHandler(func(e kube.ProwJobEvent) {
+		if e.Type != kube.Deleted {
+			q.Add(e.ProwJob.Metadata.Name)
+		}
+	})
+	go func() {
+		resync := time.NewTicker(resyncPeriod)
+		defer resync.Stop()
+		digest := time.NewTicker(digestPeriod)
+		defer digest.Stop()
+		c.enqueueAll(q)
+		for {
+			select {
+			case <-resync.C:
+				c.enqueueAll(q)
+			case now := <-digest.C:
+				c.sendDigests(now)
+			case <-stop:
+				q.ShutDown()
+				return
+			}
+		}
+	}()
+	for {
+		name, ok := q.Get()
+		if !ok {
+			return
+		}
+		if err := c.SyncProwJob(name); err != nil {
+			logrus.WithField("prowjob", name).WithError(err).Error("Error reporting prow job.")
+		}
+		q.Done(name)
 	}
-	body, err := ioutil.ReadAll(r.Body)
+}
+
+func (c *Controller) enqueueAll(q *kube.WorkQueue) {
+	pjs, err := c.kc.ListProwJobs(nil)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error reading request body: %v", err), http.StatusInternalServerError)
+		logrus.WithError(err).Error("Error listing prow jobs.")
 		return
 	}
-	var report Report
-	if err := json.Unmarshal(body, &report); err != nil {
-		http.Error(w, fmt.Sprintf("Error unmarshaling JSON: %v", err), http.StatusBadRequest)
-		return
+	for _, pj := range pjs {
+		q.Add(pj.Metadata.Name)
 	}
-	go func() {
-		s.rc <- report
-	}()
 }
 
-func (s *Server) Run() {
-	go func() {
-		for report := range s.rc {
-			if err := s.handle(report); err != nil {
-				logrus.WithFields(logrus.Fields{
-					"org":  report.RepoOwner,
-					"repo": report.RepoName,
-					"pr":   report.Number,
-				}).WithError(err).Error("Handle failed.")
-			}
+// SyncProwJob passes the ProwJob to each of its reporters whose last report is
+// out of date, and then records what they reported. A reporter that fails is
+// tried again on the next sync without holding up the others.
+func (c *Controller) SyncProwJob(name string) error {
+	pj, err := c.kc.GetProwJob(name)
+	if kube.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("error getting prow job: %v", err)
+	}
+	// Copy the map rather than writing to the cached one.
+	reported := map[string]kube.Reported{}
+	for k, v := range pj.Status.LastReported {
+		reported[k] = v
+	}
+	current := kube.Reported{State: pj.Status.State, URL: pj.Status.URL}
+	var errs []string
+	for _, r := range c.reportersFor(pj) {
+		if !r.ShouldReport(pj) || reported[r.Name()] == current {
+			continue
 		}
-	}()
+		if err := r.Report(pj); err == errDeferred {
+			continue
+		} else if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", r.Name(), err))
+			continue
+		}
+		reported[r.Name()] = current
+	}
+	if len(reported) != len(pj.Status.LastReported) || !sameReports(reported, pj.Status.LastReported) {
+		pj.Status.LastReported = reported
+		// If this conflicts with another write then we will report again
+		// when we see that write, which does no harm.
+		if _, err := c.kc.ReplaceProwJob(name, pj); err != nil {
+			errs = append(errs, fmt.Sprintf("error recording reports: %v", err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("errors reporting: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func sameReports(a, b map[string]kube.Reported) bool {
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// reportersFor returns GitHub if the job reports to it, and the reporters
+// that the job picks that are still in the config.
+func (c *Controller) reportersFor(pj kube.ProwJob) []Reporter {
+	var rs []Reporter
+	if pj.Spec.Report {
+		rs = append(rs, &githubReporter{ghc: c.ghc, ca: c.ca})
+	}
+	cr := c.ca.Config().Crier
+	for _, name := range pj.Spec.Reporters {
+		rc, ok := cr.ReporterByName(name)
+		if !ok {
+			logrus.WithField("prowjob", pj.Metadata.Name).Warningf("Unknown reporter %s.", name)
+			continue
+		}
+		switch rc.Type {
+		case config.WebhookReporter:
+			rs = append(rs, &webhookReporter{config: rc, hc: c.hc})
+		case config.SlackReporter:
+			rs = append(rs, &slackReporter{config: rc, hc: c.hc})
+		case config.EmailReporter:
+			rs = append(rs, c.digest(rc))
+		}
+	}
+	return rs
+}
+
+// githubReporter sets the status of a PR's commit and comments on the PR
+// when tests fail.
+type githubReporter struct {
+	ghc GitHubClient
+	ca  configAgent
+}
+
+func (g *githubReporter) Name() string {
+	return config.GitHubReporterName
+}
+
+// ShouldReport returns whether the ProwJob has news for its PRs. Batch jobs
+// are reported on every PR in the batch and other jobs only if they are on a
+// single PR. Triggered jobs haven't started yet and aborted jobs have been
+// replaced by a newer run that reports instead.
+func (g *githubReporter) ShouldReport(pj kube.ProwJob) bool {
+	if !pj.Spec.Report || len(pj.Spec.Refs.Pulls) == 0 {
+		return false
+	}
+	if pj.Spec.Type != kube.BatchJob && len(pj.Spec.Refs.Pulls) != 1 {
+		return false
+	}
+	return pj.Status.State != kube.TriggeredState && pj.Status.State != kube.AbortedState
+}
+
+func (g *githubReporter) Report(pj kube.ProwJob) error {
+	if pj.Spec.Type == kube.BatchJob {
+		return g.reportBatch(pj)
+	}
+	return g.report(reportFor(pj))
+}
+
+// reportBatch sets a status on every PR in the batch. It uses its own context
+// so that a batch that failed because of some other PR doesn't overwrite the
+// PR's own result. Batch failures aren't added to the failure comment.
+func (g *githubReporter) reportBatch(pj kube.ProwJob) error {
+	refs := pj.Spec.Refs
+	for _, pull := range refs.Pulls {
+		if err := g.ghc.CreateStatus(refs.Org, refs.Repo, pull.SHA, github.Status{
+			State:       string(pj.Status.State),
+			Description: batchDescription(pj, pull.Number),
+			Context:     batchContext(pj),
+			TargetURL:   pj.Status.URL,
+		}); err != nil {
+			return fmt.Errorf("error setting status on #%d: %v", pull.Number, err)
+		}
+	}
+	return nil
+}
+
+func batchContext(pj kube.ProwJob) string {
+	return pj.Spec.Context + " (batch)"
+}
+
+// batchDescription adds the other PRs in the batch to the job's description,
+// such as "Job failed. Tested with #2, #3.", cut short to fit in a status.
+func batchDescription(pj kube.ProwJob, number int) string {
+	var others []string
+	for _, pull := range pj.Spec.Refs.Pulls {
+		if pull.Number != number {
+			others = append(others, fmt.Sprintf("#%d", pull.Number))
+		}
+	}
+	d := pj.Status.Description
+	if len(others) > 0 {
+		d = strings.TrimSpace(fmt.Sprintf("%s Tested with %s.", d, strings.Join(others, ", ")))
+	}
+	if len(d) > maxDescription {
+		d = d[:maxDescription-3] + "..."
+	}
+	return d
+}
+
+func reportFor(pj kube.ProwJob) Report {
+	return Report{
+		RepoOwner:    pj.Spec.Refs.Org,
+		RepoName:     pj.Spec.Refs.Repo,
+		Author:       pj.Spec.Refs.Pulls[0].Author,
+		Number:       pj.Spec.Refs.Pulls[0].Number,
+		Commit:       pj.Spec.Refs.Pulls[0].SHA,
+		Job:          pj.Spec.Job,
+		Context:      pj.Spec.Context,
+		State:        string(pj.Status.State),
+		RerunCommand: pj.Spec.RerunCommand,
+		Description:  pj.Status.Description,
+		URL:          pj.Status.URL,
+	}
 }
 
 // parseIssueComments returns a list of comments to delete, a list of table
@@ -192,27 +434,32 @@ func createEntry(r Report) string {
 	}, " | ")
 }
 
-func prLink(r Report) string {
-	var suffix string
-	if r.RepoOwner == "kubernetes" {
-		if r.RepoName == "kubernetes" {
-			suffix = fmt.Sprintf("%d", r.Number)
-		} else {
-			suffix = fmt.Sprintf("%s/%d", r.RepoName, r.Number)
-		}
-	} else {
-		suffix = fmt.Sprintf("%s_%s/%d", r.RepoOwner, r.RepoName, r.Number)
+// prowJob fills in enough of a ProwJob from the report to build links.
+func (r Report) prowJob() kube.ProwJob {
+	return kube.ProwJob{
+		Spec: kube.ProwJobSpec{
+			Type:    kube.PresubmitJob,
+			Job:     r.Job,
+			Context: r.Context,
+			Refs: kube.Refs{
+				Org:  r.RepoOwner,
+				Repo: r.RepoName,
+				Pulls: []kube.Pull{
+					{
+						Number: r.Number,
+						Author: r.Author,
+						SHA:    r.Commit,
+					},
+				},
+			},
+		},
 	}
-	return guberPrefix + suffix
 }
 
-func dashLink(r Report) string {
-	return guberPrefix + r.Author
-}
-
-// createComment takes a report and a list of entries generated with
-// createEntry and returns a nicely formatted comment.
-func createComment(r Report, entries []string) string {
+// createComment takes a report, a list of entries generated with createEntry
+// and links to the PR history and author dashboard and returns a nicely
+// formatted comment.
+func createComment(r Report, entries []string, prLink, dashLink string) string {
 	lines := []string{
 		fmt.Sprintf("@%s: The following test(s) **failed**:", r.Author),
 		"",
@@ -222,7 +469,7 @@ func createComment(r Report, entries []string) string {
 	lines = append(lines, entries...)
 	lines = append(lines, []string{
 		"",
-		fmt.Sprintf("[Full PR test history](%s). [Your PR dashboard](%s). Please help us cut down on flakes by [linking to](https://github.com/kubernetes/community/blob/master/contributors/devel/flaky-tests.md#filing-issues-for-flaky-tests) an [open issue](https://github.com/%s/%s/issues?q=is:issue+is:open) when you hit one in your PR.", prLink(r), dashLink(r), r.RepoOwner, r.RepoName),
+		fmt.Sprintf("[Full PR test history](%s). [Your PR dashboard](%s). Please help us cut down on flakes by [linking to](https://github.com/kubernetes/community/blob/master/contributors/devel/flaky-tests.md#filing-issues-for-flaky-tests) an [open issue](https://github.com/%s/%s/issues?q=is:issue+is:open) when you hit one in your PR.", prLink, dashLink, r.RepoOwner, r.RepoName),
 		"",
 		"<details>",
 		"",
@@ -233,13 +480,10 @@ func createComment(r Report, entries []string) string {
 	return strings.Join(lines, "\n")
 }
 
-func (s *Server) handle(r Report) error {
-	defer func() {
-		if s.notify != nil {
-			s.notify <- struct{}{}
-		}
-	}()
-	if err := s.ghc.CreateStatus(r.RepoOwner, r.RepoName, r.Commit, github.Status{
+// report sets the status for the report and updates the comment that lists
+// the PR's failed tests.
+func (g *githubReporter) report(r Report) error {
+	if err := g.ghc.CreateStatus(r.RepoOwner, r.RepoName, r.Commit, github.Status{
 		State:       r.State,
 		Description: r.Description,
 		Context:     r.Context,
@@ -250,24 +494,34 @@ func (s *Server) handle(r Report) error {
 	if r.State != github.StatusSuccess && r.State != github.StatusFailure {
 		return nil
 	}
-	ics, err := s.ghc.ListIssueComments(r.RepoOwner, r.RepoName, r.Number)
+	ics, err := g.ghc.ListIssueComments(r.RepoOwner, r.RepoName, r.Number)
 	if err != nil {
 		return fmt.Errorf("error listing comments: %v", err)
 	}
-	deletes, entries, updateID := parseIssueComments(r, s.ghc.BotName(), ics)
+	deletes, entries, updateID := parseIssueComments(r, g.ghc.BotName(), ics)
 	for _, delete := range deletes {
-		if err := s.ghc.DeleteComment(r.RepoOwner, r.RepoName, delete); err != nil {
+		if err := g.ghc.DeleteComment(r.RepoOwner, r.RepoName, delete); err != nil {
 			return fmt.Errorf("error deleting comment: %v", err)
 		}
 	}
-	if len(entries) > 0 && updateID == 0 {
-		if err := s.ghc.CreateComment(r.RepoOwner, r.RepoName, r.Number, createComment(r, entries)); err != nil {
+	if len(entries) == 0 {
+		return nil
+	}
+	urls := g.ca.Config().URLTemplates
+	prLink, err := urls.PRHistoryURL(r.prowJob())
+	if err != nil {
+		return err
+	}
+	dashLink, err := urls.DashboardURL(r.prowJob())
+	if err != nil {
+		return err
+	}
+	if updateID == 0 {
+		if err := g.ghc.CreateComment(r.RepoOwner, r.RepoName, r.Number, createComment(r, entries, prLink, dashLink)); err != nil {
 			return fmt.Errorf("error creating comment: %v", err)
 		}
-	} else if len(entries) > 0 {
-		if err := s.ghc.EditComment(r.RepoOwner, r.RepoName, updateID, createComment(r, entries)); err != nil {
-			return fmt.Errorf("error updating comment: %v", err)
-		}
+	} else if err := g.ghc.EditComment(r.RepoOwner, r.RepoName, updateID, createComment(r, entries, prLink, dashLink)); err != nil {
+		return fmt.Errorf("error updating comment: %v", err)
 	}
 	return nil
 }
@@ -23,50 +23,6 @@ import (
 	"k8s.io/test-infra/prow/github"
 )
 
-func TestPRLink(t *testing.T) {
-	var testcases = []struct {
-		org    string
-		repo   string
-		number int
-		suffix string
-	}{
-		{
-			org:    "o",
-			repo:   "r",
-			number: 4,
-			suffix: "o_r/4",
-		},
-		{
-			org:    "kubernetes",
-			repo:   "test-infra",
-			number: 123,
-			suffix: "test-infra/123",
-		},
-		{
-			org:    "kubernetes",
-			repo:   "kubernetes",
-			number: 123,
-			suffix: "123",
-		},
-		{
-			org:    "o",
-			repo:   "kubernetes",
-			number: 456,
-			suffix: "o_kubernetes/456",
-		},
-	}
-	for _, tc := range testcases {
-		prl := prLink(Report{
-			RepoOwner: tc.org,
-			RepoName:  tc.repo,
-			Number:    tc.number,
-		})
-		if prl[len(guberPrefix):] != tc.suffix {
-			t.Errorf("Expected failed case %+v, got %s", tc, prl)
-		}
-	}
-}
-
 func TestParseIssueComment(t *testing.T) {
 	var testcases = []struct {
 		name             string
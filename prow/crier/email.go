@@ -0,0 +1,281 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crier
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/kube"
+)
+
+// smtpTimeout bounds how long mailing a digest may take, so that a hung mail
+// server can't hold up crier.
+const smtpTimeout = time.Minute
+
+// emailDigest collects the ProwJobs it is given and mails them all at once
+// every digest interval. Collected jobs only live in memory, so they are
+// recorded as reported only once the digest is sent. If crier restarts before
+// then, it collects them again.
+type emailDigest struct {
+	lock     sync.Mutex
+	config   config.Reporter
+	auth     smtp.Auth
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+	// jobs holds the latest state of each collected job, in the order they
+	// were first collected.
+	jobs     []kube.ProwJob
+	lastSent time.Time
+}
+
+func (d *emailDigest) Name() string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.config.Name
+}
+
+func (d *emailDigest) ShouldReport(pj kube.ProwJob) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.config.Reports(pj.Status.State)
+}
+
+// Report collects the job and returns errDeferred. A job that is collected
+// again replaces its earlier state.
+func (d *emailDigest) Report(pj kube.ProwJob) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for i := range d.jobs {
+		if d.jobs[i].Metadata.Name == pj.Metadata.Name {
+			d.jobs[i] = pj
+			return errDeferred
+		}
+	}
+	d.jobs = append(d.jobs, pj)
+	return errDeferred
+}
+
+// send mails the digest if it is due and returns the jobs that it mailed. If
+// there is nothing to send then the next digest is due an interval from now.
+// The lock isn't held while mailing, so jobs may still be collected. Only one
+// send may run at a time.
+func (d *emailDigest) send(now time.Time) ([]kube.ProwJob, error) {
+	d.lock.Lock()
+	if now.Sub(d.lastSent) < d.config.GetDigestInterval() {
+		d.lock.Unlock()
+		return nil, nil
+	}
+	if len(d.jobs) == 0 {
+		d.lastSent = now
+		d.lock.Unlock()
+		return nil, nil
+	}
+	jobs := d.jobs
+	sendMail, auth, rc, msg := d.sendMail, d.auth, d.config, d.message()
+	d.lock.Unlock()
+
+	if err := sendMail(rc.SMTPServer, auth, rc.From, rc.To, msg); err != nil {
+		return nil, fmt.Errorf("error sending digest: %v", err)
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	// Keep the jobs that were collected or changed while we were mailing.
+	sent := map[string]kube.Reported{}
+	for _, pj := range jobs {
+		sent[pj.Metadata.Name] = reportedAs(pj)
+	}
+	var left []kube.ProwJob
+	for _, pj := range d.jobs {
+		if r, ok := sent[pj.Metadata.Name]; !ok || r != reportedAs(pj) {
+			left = append(left, pj)
+		}
+	}
+	d.jobs = left
+	d.lastSent = now
+	return jobs, nil
+}
+
+func reportedAs(pj kube.ProwJob) kube.Reported {
+	return kube.Reported{State: pj.Status.State, URL: pj.Status.URL}
+}
+
+// message must be called with the lock held.
+func (d *emailDigest) message() []byte {
+	lines := []string{
+		"From: " + d.config.From,
+		"To: " + strings.Join(d.config.To, ", "),
+		fmt.Sprintf("Subject: [prow] %s: %d jobs", d.config.Name, len(d.jobs)),
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		fmt.Sprintf("These jobs were reported since %s:", d.lastSent.UTC().Format(time.RFC1123)),
+		"",
+	}
+	for _, pj := range d.jobs {
+		lines = append(lines, summary(pj))
+		if pj.Status.URL != "" {
+			lines = append(lines, "  "+pj.Status.URL)
+		}
+	}
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+// sendMail is smtp.SendMail with a deadline on the whole conversation.
+func sendMail(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+	conn, err := net.DialTimeout("tcp", addr, smtpTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(smtpTimeout)); err != nil {
+		return err
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if a != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := c.Auth(a); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// digest returns the email digest for the reporter, creating it if this is
+// the first job for it.
+func (c *Controller) digest(rc config.Reporter) *emailDigest {
+	var auth smtp.Auth
+	if c.creds.Username != "" {
+		host, _, err := net.SplitHostPort(rc.SMTPServer)
+		if err != nil {
+			host = rc.SMTPServer
+		}
+		auth = smtp.PlainAuth("", c.creds.Username, c.creds.Password, host)
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.digests == nil {
+		c.digests = map[string]*emailDigest{}
+	}
+	d, ok := c.digests[rc.Name]
+	if !ok {
+		d = &emailDigest{
+			sendMail: sendMail,
+			lastSent: time.Now(),
+		}
+		c.digests[rc.Name] = d
+	}
+	// The config may have changed since we last saw it.
+	d.lock.Lock()
+	d.config = rc
+	d.auth = auth
+	d.lock.Unlock()
+	return d
+}
+
+// sendDigests sends the email digests that are due and records the jobs in
+// them as reported. Digests of reporters that were removed from the config
+// are dropped. The controller's lock isn't held while mailing, so syncing
+// carries on.
+func (c *Controller) sendDigests(now time.Time) {
+	cr := c.ca.Config().Crier
+	c.lock.Lock()
+	due := map[string]*emailDigest{}
+	for name, d := range c.digests {
+		if rc, ok := cr.ReporterByName(name); !ok || rc.Type != config.EmailReporter {
+			delete(c.digests, name)
+			continue
+		}
+		due[name] = d
+	}
+	c.lock.Unlock()
+	for name, d := range due {
+		jobs, err := d.send(now)
+		if err != nil {
+			logrus.WithField("reporter", name).WithError(err).Error("Error sending email digest.")
+			continue
+		}
+		for _, pj := range jobs {
+			if err := c.recordReport(pj.Metadata.Name, name, reportedAs(pj)); err != nil {
+				// The job will be collected again and mailed in the next
+				// digest.
+				logrus.WithField("prowjob", pj.Metadata.Name).WithError(err).Error("Error recording email report.")
+			}
+		}
+	}
+}
+
+// recordReport records in the ProwJob what the reporter reported.
+func (c *Controller) recordReport(name, reporter string, r kube.Reported) error {
+	pj, err := c.kc.GetProwJob(name)
+	if kube.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("error getting prow job: %v", err)
+	}
+	// Copy the map rather than writing to the cached one.
+	reported := map[string]kube.Reported{reporter: r}
+	for k, v := range pj.Status.LastReported {
+		if k != reporter {
+			reported[k] = v
+		}
+	}
+	pj.Status.LastReported = reported
+	_, err = c.kc.ReplaceProwJob(name, pj)
+	return err
+}
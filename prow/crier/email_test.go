@@ -0,0 +1,149 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crier
+
+import (
+	"errors"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/kube"
+)
+
+func TestEmailDigest(t *testing.T) {
+	cfg := &config.Config{
+		Crier: config.Crier{
+			Reporters: []config.Reporter{{
+				Name:       "mail",
+				Type:       config.EmailReporter,
+				SMTPServer: "smtp.example.com:25",
+				From:       "prow@example.com",
+				To:         []string{"a@example.com", "b@example.com"},
+				States:     []kube.ProwJobState{kube.FailureState},
+			}},
+		},
+	}
+	fkc := &fkc{}
+	for _, job := range []string{"ci-foo", "ci-bar"} {
+		fkc.prowjobs = append(fkc.prowjobs, kube.ProwJob{
+			Metadata: kube.ObjectMeta{Name: job},
+			Spec:     kube.ProwJobSpec{Type: kube.PeriodicJob, Job: job, Reporters: []string{"mail"}},
+			Status:   kube.ProwJobStatus{State: kube.FailureState, URL: "https://logs/" + job},
+		})
+	}
+	c := &Controller{kc: fkc, ca: fca{cfg}}
+	d := c.digest(cfg.Crier.Reporters[0])
+	if d != c.digest(cfg.Crier.Reporters[0]) {
+		t.Fatal("Expected the same digest for the same reporter.")
+	}
+	var sent []string
+	d.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		if addr != "smtp.example.com:25" || from != "prow@example.com" || len(to) != 2 {
+			t.Errorf("Bad mail to %s from %s to %v.", addr, from, to)
+		}
+		// Syncing must not wait for the mail to go out.
+		c.digest(cfg.Crier.Reporters[0])
+		sent = append(sent, string(msg))
+		return nil
+	}
+	start := d.lastSent
+
+	if !d.ShouldReport(kube.ProwJob{Status: kube.ProwJobStatus{State: kube.FailureState}}) {
+		t.Error("Expected failures to be reported.")
+	}
+	if d.ShouldReport(kube.ProwJob{Status: kube.ProwJobStatus{State: kube.SuccessState}}) {
+		t.Error("Expected successes not to be reported.")
+	}
+	// Syncing twice collects each job once and records nothing yet.
+	for i := 0; i < 2; i++ {
+		for _, job := range []string{"ci-foo", "ci-bar"} {
+			if err := c.SyncProwJob(job); err != nil {
+				t.Fatalf("Error syncing: %v", err)
+			}
+		}
+	}
+	for _, pj := range fkc.prowjobs {
+		if len(pj.Status.LastReported) != 0 {
+			t.Errorf("Expected %s not to be recorded before the digest is sent, got %+v.", pj.Metadata.Name, pj.Status.LastReported)
+		}
+	}
+	c.sendDigests(start.Add(12 * time.Hour))
+	if len(sent) != 0 {
+		t.Fatalf("Sent a digest too early: %v", sent)
+	}
+	c.sendDigests(start.Add(24 * time.Hour))
+	if len(sent) != 1 {
+		t.Fatalf("Expected one digest, got %d.", len(sent))
+	}
+	for _, s := range []string{"Subject: [prow] mail: 2 jobs", "To: a@example.com, b@example.com", "ci-foo: failure.", "https://logs/ci-bar"} {
+		if !strings.Contains(sent[0], s) {
+			t.Errorf("Expected digest to contain %q:\n%s", s, sent[0])
+		}
+	}
+	for _, pj := range fkc.prowjobs {
+		expected := kube.Reported{State: kube.FailureState, URL: "https://logs/" + pj.Metadata.Name}
+		if r := pj.Status.LastReported["mail"]; r != expected {
+			t.Errorf("Expected %s to be recorded as %+v, got %+v.", pj.Metadata.Name, expected, r)
+		}
+		if err := c.SyncProwJob(pj.Metadata.Name); err != nil {
+			t.Fatalf("Error syncing: %v", err)
+		}
+	}
+	c.sendDigests(start.Add(72 * time.Hour))
+	if len(sent) != 1 {
+		t.Errorf("Sent a digest again: %v", sent[1:])
+	}
+
+	// Removing the reporter drops its digest.
+	cfg.Crier.Reporters = nil
+	c.sendDigests(start.Add(96 * time.Hour))
+	if len(c.digests) != 0 {
+		t.Errorf("Expected the digest to be dropped, got %v.", c.digests)
+	}
+}
+
+func TestEmailDigestFailure(t *testing.T) {
+	rc := config.Reporter{
+		Name:       "mail",
+		Type:       config.EmailReporter,
+		SMTPServer: "smtp.example.com:25",
+		States:     []kube.ProwJobState{kube.FailureState},
+	}
+	fkc := &fkc{prowjobs: []kube.ProwJob{{
+		Metadata: kube.ObjectMeta{Name: "ci-foo"},
+		Spec:     kube.ProwJobSpec{Type: kube.PeriodicJob, Job: "ci-foo", Reporters: []string{"mail"}},
+		Status:   kube.ProwJobStatus{State: kube.FailureState},
+	}}}
+	c := &Controller{kc: fkc, ca: fca{&config.Config{Crier: config.Crier{Reporters: []config.Reporter{rc}}}}}
+	d := c.digest(rc)
+	d.sendMail = func(string, smtp.Auth, string, []string, []byte) error {
+		return errors.New("connection refused")
+	}
+	if err := c.SyncProwJob("ci-foo"); err != nil {
+		t.Fatalf("Error syncing: %v", err)
+	}
+	c.sendDigests(d.lastSent.Add(24 * time.Hour))
+	if len(fkc.prowjobs[0].Status.LastReported) != 0 {
+		t.Errorf("Expected nothing to be recorded, got %+v.", fkc.prowjobs[0].Status.LastReported)
+	}
+	if len(d.jobs) != 1 {
+		t.Errorf("Expected the job to be kept for the next digest, got %d jobs.", len(d.jobs))
+	}
+}
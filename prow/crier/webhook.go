@@ -0,0 +1,147 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/kube"
+)
+
+// webhookReporter POSTs the ProwJob as JSON.
+type webhookReporter struct {
+	config config.Reporter
+	hc     *http.Client
+}
+
+func (r *webhookReporter) Name() string {
+	return r.config.Name
+}
+
+func (r *webhookReporter) ShouldReport(pj kube.ProwJob) bool {
+	return r.config.Reports(pj.Status.State)
+}
+
+func (r *webhookReporter) Report(pj kube.ProwJob) error {
+	// The reporter bookkeeping is of no use to the receiver.
+	pj.Status.LastReported = nil
+	u, err := readURL(r.config.URLFile)
+	if err != nil {
+		return err
+	}
+	return post(r.hc, u, pj)
+}
+
+// slackReporter posts a message to a Slack-compatible incoming webhook.
+type slackReporter struct {
+	config config.Reporter
+	hc     *http.Client
+}
+
+func (r *slackReporter) Name() string {
+	return r.config.Name
+}
+
+func (r *slackReporter) ShouldReport(pj kube.ProwJob) bool {
+	return r.config.Reports(pj.Status.State)
+}
+
+func (r *slackReporter) Report(pj kube.ProwJob) error {
+	text := summary(pj)
+	if pj.Status.URL != "" {
+		text += fmt.Sprintf(" <%s|Details>", pj.Status.URL)
+	}
+	u, err := readURL(r.config.URLFile)
+	if err != nil {
+		return err
+	}
+	return post(r.hc, u, struct {
+		Text    string `json:"text"`
+		Channel string `json:"channel,omitempty"`
+	}{
+		Text:    text,
+		Channel: r.config.Channel,
+	})
+}
+
+// summary describes the ProwJob and its state in a line, such as
+// "ci-foo on org/repo#12: failure. Job failed."
+func summary(pj kube.ProwJob) string {
+	var on string
+	refs := pj.Spec.Refs
+	if refs.Org != "" {
+		on = fmt.Sprintf(" on %s/%s", refs.Org, refs.Repo)
+		var pulls []string
+		for _, pull := range refs.Pulls {
+			pulls = append(pulls, fmt.Sprintf("#%d", pull.Number))
+		}
+		if len(pulls) > 0 {
+			on += strings.Join(pulls, ",")
+		} else if refs.BaseRef != "" {
+			on += "@" + refs.BaseRef
+		}
+	}
+	s := fmt.Sprintf("%s%s: %s.", pj.Spec.Job, on, pj.Status.State)
+	if pj.Status.Description != "" {
+		s += " " + pj.Status.Description
+	}
+	return s
+}
+
+// readURL reads the URL that a reporter posts to from its secret file. It
+// is read on every report so that a rotated secret takes effect right away.
+func readURL(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading url file: %v", err)
+	}
+	s := strings.TrimSpace(string(b))
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("url file %s doesn't hold an http or https URL", path)
+	}
+	return s, nil
+}
+
+// post sends v as JSON and returns an error unless the response is 2xx. The
+// errors leave out the URL, since it is a secret.
+func post(hc *http.Client, u string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error marshaling JSON: %v", err)
+	}
+	resp, err := hc.Post(u, "application/json", bytes.NewReader(b))
+	if ue, ok := err.(*url.Error); ok {
+		return fmt.Errorf("error posting: %v", ue.Err)
+	} else if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("response %d: %s", resp.StatusCode, body)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	var testcases = []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"@often",
+		"0 0 30 2 *",
+	}
+	for _, tc := range testcases {
+		if _, err := Parse(tc); err == nil {
+			t.Errorf("Expected an error parsing %q.", tc)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	// A Wednesday.
+	start := time.Date(2017, time.May, 17, 10, 30, 15, 0, time.UTC)
+	var testcases = []struct {
+		spec     string
+		expected time.Time
+	}{
+		{
+			spec:     "* * * * *",
+			expected: time.Date(2017, time.May, 17, 10, 31, 0, 0, time.UTC),
+		},
+		{
+			spec:     "*/15 * * * *",
+			expected: time.Date(2017, time.May, 17, 10, 45, 0, 0, time.UTC),
+		},
+		{
+			spec:     "0 2 * * *",
+			expected: time.Date(2017, time.May, 18, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			spec:     "@daily",
+			expected: time.Date(2017, time.May, 18, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			spec:     "0 2 * * sat,sun",
+			expected: time.Date(2017, time.May, 20, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			spec:     "0 2 * * 7",
+			expected: time.Date(2017, time.May, 21, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			spec:     "30 9 * * 1-5",
+			expected: time.Date(2017, time.May, 18, 9, 30, 0, 0, time.UTC),
+		},
+		{
+			spec:     "0 0 1 jan *",
+			expected: time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			spec:     "5/20 10 * * *",
+			expected: time.Date(2017, time.May, 17, 10, 45, 0, 0, time.UTC),
+		},
+		{
+			// Either day field may match when both are restricted.
+			spec:     "0 0 1 * fri",
+			expected: time.Date(2017, time.May, 19, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			spec:     "0 0 29 2 *",
+			expected: time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tc := range testcases {
+		s, err := Parse(tc.spec)
+		if err != nil {
+			t.Errorf("Error parsing %q: %v", tc.spec, err)
+			continue
+		}
+		if next := s.Next(start); !next.Equal(tc.expected) {
+			t.Errorf("For %q, expected %s, got %s.", tc.spec, tc.expected, next)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// machineManPreview is the media type of the GitHub App API.
+	machineManPreview = "application/vnd.github.machine-man-preview+json"
+	// jwtLifetime is how long each JWT is valid. GitHub allows ten minutes.
+	jwtLifetime = 9 * time.Minute
+	// tokenRefreshMargin is how long before it expires that an installation
+	// token is replaced.
+	tokenRefreshMargin = 5 * time.Minute
+)
+
+// appAuth authenticates as the installations of a GitHub App. It signs JWTs
+// with the app's private key, trades them for installation tokens, and
+// replaces each token shortly before it expires.
+type appAuth struct {
+	appID  string
+	key    *rsa.PrivateKey
+	base   string
+	client *http.Client
+
+	// listing is held while listing installations, and minting[id] while
+	// minting a token for installation id, so that concurrent requests wait
+	// for the one already talking to GitHub. Neither is held with mut.
+	listing sync.Mutex
+	minting map[int]*sync.Mutex
+
+	mut sync.Mutex
+	// installations maps lowercased account logins to installation IDs.
+	installations map[string]int
+	loaded        time.Time
+	tokens        map[int]installationToken
+}
+
+type installationToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Installation is an installation of a GitHub App on an org or user.
+type Installation struct {
+	ID      int  `json:"id"`
+	Account User `json:"account"`
+}
+
+// NewAppClient creates a client that authenticates as the installations of
+// the GitHub App with the given ID. The private key is the PEM file that
+// GitHub generated for the app. Each request uses the installation on the
+// org or user that it is about.
+func NewAppClient(botName, appID string, privateKey []byte, endpoint string) (*Client, error) {
+	return newAppClient(botName, appID, privateKey, endpoint, false)
+}
+
+// NewDryRunAppClient is like NewAppClient, except that the client will not
+// perform mutating actions, like NewDryRunClient.
+func NewDryRunAppClient(botName, appID string, privateKey []byte, endpoint string) (*Client, error) {
+	return newAppClient(botName, appID, privateKey, endpoint, true)
+}
+
+func newAppClient(botName, appID string, privateKey []byte, base string, dry bool) (*Client, error) {
+	key, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	base = strings.TrimSuffix(base, "/")
+	return &Client{
+		client:  &http.Client{Transport: newCachingTransport(nil, defaultCacheBytes)},
+		botName: botName,
+		base:    base,
+		dry:     dry,
+		app: &appAuth{
+			appID:   appID,
+			key:     key,
+			base:    base,
+			client:  &http.Client{Timeout: time.Minute},
+			tokens:  map[int]installationToken{},
+			minting: map[int]*sync.Mutex{},
+		},
+	}, nil
+}
+
+func parsePrivateKey(b []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing private key: %v", err)
+	}
+	key, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// jwt returns a token that authenticates as the app itself.
+func (a *appAuth) jwt(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		// Backdate it a little in case our clock is ahead of GitHub's.
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(jwtLifetime).Unix(),
+		"iss": a.appID,
+	})
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", fmt.Errorf("error signing JWT: %v", err)
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// appRequest makes a request authenticated as the app and unmarshals the
+// response into ret.
+func (a *appAuth) appRequest(method, path string, code int, ret interface{}) (*http.Response, error) {
+	jwt, err := a.jwt(timeNow())
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", machineManPreview)
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != code {
+		return nil, fmt.Errorf("status code %d not %d, body: %s", resp.StatusCode, code, string(b))
+	}
+	return resp, json.Unmarshal(b, ret)
+}
+
+// loadInstallations lists the app's installations. The caller must hold the
+// listing lock.
+func (a *appAuth) loadInstallations() error {
+	installations := map[string]int{}
+	nextURL := a.base + "/app/installations?per_page=100"
+	for nextURL != "" {
+		var is []Installation
+		resp, err := a.appRequest(http.MethodGet, nextURL, http.StatusOK, &is)
+		if err != nil {
+			return fmt.Errorf("error listing installations: %v", err)
+		}
+		for _, i := range is {
+			installations[strings.ToLower(i.Account.Login)] = i.ID
+		}
+		nextURL = parseLinks(resp.Header.Get("Link"))["next"]
+	}
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	a.installations = installations
+	a.loaded = timeNow()
+	return nil
+}
+
+// shouldList returns whether to list the installations before looking up
+// the one on org.
+func (a *appAuth) shouldList(org string) bool {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	_, ok := a.installations[org]
+	// The app may have been installed since we last looked, but don't look
+	// again for every request about an org that it isn't installed on.
+	return a.installations == nil || (org != "" && !ok && timeNow().Sub(a.loaded) > time.Minute)
+}
+
+// installationFor returns the ID of the installation on org. If org is
+// empty, it returns the only installation.
+func (a *appAuth) installationFor(org string) (int, error) {
+	org = strings.ToLower(org)
+	if a.shouldList(org) {
+		a.listing.Lock()
+		var err error
+		// Another request may have listed them while we waited.
+		if a.shouldList(org) {
+			err = a.loadInstallations()
+		}
+		a.listing.Unlock()
+		if err != nil {
+			return 0, err
+		}
+	}
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	if org == "" {
+		if len(a.installations) != 1 {
+			return 0, fmt.Errorf("request isn't for an org, and there are %d installations to choose from", len(a.installations))
+		}
+		for _, id := range a.installations {
+			return id, nil
+		}
+	}
+	id, ok := a.installations[org]
+	if !ok {
+		return 0, fmt.Errorf("app %s is not installed on %s", a.appID, org)
+	}
+	return id, nil
+}
+
+// cachedToken returns the token for the installation if it isn't about to
+// expire.
+func (a *appAuth) cachedToken(id int) (string, bool) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	t, ok := a.tokens[id]
+	return t.Token, ok && t.ExpiresAt.Sub(timeNow()) > tokenRefreshMargin
+}
+
+// tokenFor returns a token for the installation on org, minting a new one if
+// the last one is about to expire.
+func (a *appAuth) tokenFor(org string) (string, error) {
+	id, err := a.installationFor(org)
+	if err != nil {
+		return "", err
+	}
+	if t, ok := a.cachedToken(id); ok {
+		return t, nil
+	}
+	a.mut.Lock()
+	minting, ok := a.minting[id]
+	if !ok {
+		minting = &sync.Mutex{}
+		a.minting[id] = minting
+	}
+	a.mut.Unlock()
+	minting.Lock()
+	defer minting.Unlock()
+	// Another request may have minted one while we waited.
+	if t, ok := a.cachedToken(id); ok {
+		return t, nil
+	}
+	var t installationToken
+	if _, err := a.appRequest(http.MethodPost, fmt.Sprintf("%s/app/installations/%d/access_tokens", a.base, id), http.StatusCreated, &t); err != nil {
+		return "", fmt.Errorf("error minting token for installation %d: %v", id, err)
+	}
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	a.tokens[id] = t
+	return t.Token, nil
+}
+
+// orgForRequest returns the org or user that a request to GitHub is about,
+// or the empty string if it isn't about one.
+func orgForRequest(base, path string) string {
+	u, err := url.Parse(strings.TrimPrefix(path, base))
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch {
+	case len(parts) >= 2 && (parts[0] == "repos" || parts[0] == "orgs" || parts[0] == "users"):
+		return parts[1]
+	case len(parts) == 2 && parts[0] == "search":
+		// Searches are about the org or repo that they are limited to.
+		for _, term := range strings.Fields(u.Query().Get("q")) {
+			for _, qualifier := range []string{"repo:", "org:", "user:"} {
+				if strings.HasPrefix(term, qualifier) {
+					return strings.Split(strings.TrimPrefix(term, qualifier), "/")[0]
+				}
+			}
+		}
+	}
+	return ""
+}
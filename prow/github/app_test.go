@@ -0,0 +1,194 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newFakeApp starts a server that fakes the App API and records the token
+// used for each org, and returns a client that authenticates as the app.
+func newFakeApp(t *testing.T, lifetime time.Duration) (*Client, *fakeAppServer, map[string]string, func()) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating key: %v", err)
+	}
+	fas := &fakeAppServer{
+		AppID: "42",
+		Key:   &key.PublicKey,
+		Installations: []Installation{
+			{ID: 1, Account: User{Login: "k8s"}},
+			{ID: 2, Account: User{Login: "Other"}},
+		},
+		TokenLifetime: lifetime,
+	}
+	used := map[string]string{}
+	mux := http.NewServeMux()
+	mux.Handle("/app/", fas)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		org := orgForRequest("", r.URL.String())
+		used[org] = strings.TrimPrefix(r.Header.Get("Authorization"), "Token ")
+		switch r.Method {
+		case http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.Write([]byte(`{}`))
+		}
+	})
+	ts := httptest.NewServer(mux)
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	c, err := newAppClient("bot", "42", pemKey, ts.URL, false)
+	if err != nil {
+		t.Fatalf("Error creating client: %v", err)
+	}
+	return c, fas, used, ts.Close
+}
+
+func TestAppInstallationTokens(t *testing.T) {
+	c, fas, used, stop := newFakeApp(t, time.Hour)
+	defer stop()
+
+	if err := c.CreateComment("k8s", "kuber", 5, "hello"); err != nil {
+		t.Fatalf("Didn't expect error: %v", err)
+	}
+	if err := c.CreateComment("other", "repo", 5, "hello"); err != nil {
+		t.Fatalf("Didn't expect error: %v", err)
+	}
+	if _, err := c.FindIssues("abcdef+repo:k8s/kuber+type:pr"); err != nil {
+		t.Fatalf("Didn't expect error: %v", err)
+	}
+	if used["k8s"] != "installation-1-token-1" {
+		t.Errorf("Expected k8s to use the first token of installation 1, got %q", used["k8s"])
+	}
+	if used["other"] != "installation-2-token-1" {
+		t.Errorf("Expected other to use the first token of installation 2, got %q", used["other"])
+	}
+	if fas.Minted(1) != 1 || fas.Minted(2) != 1 {
+		t.Errorf("Expected one token per installation, got %d and %d", fas.Minted(1), fas.Minted(2))
+	}
+
+	if err := c.CreateComment("nope", "repo", 5, "hello"); err == nil {
+		t.Error("Expected an error for an org without the app.")
+	}
+	if _, err := c.GetRef("k8s", "kuber", "heads/master"); err != nil {
+		t.Errorf("Didn't expect error: %v", err)
+	}
+}
+
+func TestAppTokenRefresh(t *testing.T) {
+	// Tokens that last less than the refresh margin are replaced every time.
+	c, fas, used, stop := newFakeApp(t, tokenRefreshMargin-time.Minute)
+	defer stop()
+	for i := 0; i < 3; i++ {
+		if err := c.CreateComment("k8s", "kuber", 5, "hello"); err != nil {
+			t.Fatalf("Didn't expect error: %v", err)
+		}
+	}
+	if fas.Minted(1) != 3 {
+		t.Errorf("Expected three tokens to be minted, got %d", fas.Minted(1))
+	}
+	if used["k8s"] != "installation-1-token-3" {
+		t.Errorf("Expected the latest token to be used, got %q", used["k8s"])
+	}
+}
+
+func TestAppConcurrentTokens(t *testing.T) {
+	c, fas, _, stop := newFakeApp(t, time.Hour)
+	defer stop()
+	release := fas.holdMints(1)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.app.tokenFor("k8s")
+			errs <- err
+		}()
+	}
+	// Minting for one installation doesn't hold up the others.
+	done := make(chan error)
+	go func() {
+		_, err := c.app.tokenFor("other")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Didn't expect error: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		close(release)
+		t.Fatal("Timed out waiting for a token while another installation's was minted.")
+	}
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Didn't expect error: %v", err)
+		}
+	}
+	if fas.Minted(1) != 1 {
+		t.Errorf("Expected concurrent requests to share one token, got %d minted", fas.Minted(1))
+	}
+}
+
+func TestAppBadKey(t *testing.T) {
+	c, _, _, stop := newFakeApp(t, time.Hour)
+	defer stop()
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating key: %v", err)
+	}
+	c.app.key = other
+	if err := c.CreateComment("k8s", "kuber", 5, "hello"); err == nil {
+		t.Error("Expected an error when the JWT is signed with the wrong key.")
+	}
+	if _, err := parsePrivateKey([]byte("not a key")); err == nil {
+		t.Error("Expected an error parsing a bad key.")
+	}
+}
+
+func TestOrgForRequest(t *testing.T) {
+	var testcases = []struct {
+		path string
+		org  string
+	}{
+		{path: "https://api.github.com/repos/k8s/kuber/issues/5/comments", org: "k8s"},
+		{path: "https://api.github.com/orgs/k8s/members/person", org: "k8s"},
+		{path: "https://api.github.com/search/issues?q=abc+repo%3Ak8s%2Fkuber+type%3Apr", org: "k8s"},
+		{path: "https://api.github.com/search/issues?q=is%3Aopen+org%3Ak8s", org: "k8s"},
+		{path: "https://api.github.com/search/issues?q=is%3Aopen", org: ""},
+		{path: "https://api.github.com/user", org: ""},
+	}
+	for _, tc := range testcases {
+		if got := orgForRequest("https://api.github.com", tc.path); got != tc.org {
+			t.Errorf("For %s, expected org %q, got %q", tc.path, tc.org, got)
+		}
+	}
+}
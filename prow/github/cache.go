@@ -0,0 +1,122 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+
+	"github.com/gregjones/httpcache"
+)
+
+// defaultCacheBytes is how much of GitHub's responses the client keeps to
+// make conditional requests with.
+const defaultCacheBytes = 64 << 20
+
+// newCachingTransport returns a transport that keeps responses to GETs and
+// revalidates them with If-None-Match on every request. GitHub doesn't count
+// a 304 Not Modified against the rate limit. If delegate is nil, it uses
+// http.DefaultTransport.
+func newCachingTransport(delegate http.RoundTripper, maxBytes int) http.RoundTripper {
+	t := httpcache.NewTransport(newLRUCache(maxBytes))
+	t.Transport = delegate
+	t.MarkCachedResponses = true
+	return &revalidatingTransport{delegate: t}
+}
+
+// revalidatingTransport sets max-age=0 on every request. Otherwise the cache
+// would answer from memory for as long as GitHub's Cache-Control allows,
+// which is too stale for checking things like whether a PR is mergeable.
+type revalidatingTransport struct {
+	delegate http.RoundTripper
+}
+
+func (t *revalidatingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.Header.Set("Cache-Control", "max-age=0")
+	return t.delegate.RoundTrip(req)
+}
+
+// lruCache is an httpcache.Cache that holds at most maxBytes of responses and
+// evicts the least recently used ones first.
+type lruCache struct {
+	mut      sync.Mutex
+	maxBytes int
+	bytes    int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type lruEntry struct {
+	key  string
+	resp []byte
+}
+
+func newLRUCache(maxBytes int) *lruCache {
+	return &lruCache{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    map[string]*list.Element{},
+	}
+}
+
+func (c *lruCache) Get(key string) ([]byte, bool) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*lruEntry).resp, true
+}
+
+func (c *lruCache) Set(key string, resp []byte) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if len(resp) > c.maxBytes {
+		c.remove(key)
+		return
+	}
+	if e, ok := c.items[key]; ok {
+		c.bytes += len(resp) - len(e.Value.(*lruEntry).resp)
+		e.Value.(*lruEntry).resp = resp
+		c.ll.MoveToFront(e)
+	} else {
+		c.items[key] = c.ll.PushFront(&lruEntry{key: key, resp: resp})
+		c.bytes += len(resp)
+	}
+	for c.bytes > c.maxBytes {
+		c.remove(c.ll.Back().Value.(*lruEntry).key)
+	}
+}
+
+func (c *lruCache) Delete(key string) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.remove(key)
+}
+
+func (c *lruCache) remove(key string) {
+	e, ok := c.items[key]
+	if !ok {
+		return
+	}
+	c.ll.Remove(e)
+	delete(c.items, key)
+	c.bytes -= len(e.Value.(*lruEntry).resp)
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLRUCache(t *testing.T) {
+	c := newLRUCache(10)
+	c.Set("a", []byte("aaaa"))
+	c.Set("b", []byte("bbbb"))
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("Expected a to be cached.")
+	}
+	// b is now the least recently used, so it goes first.
+	c.Set("c", []byte("cccc"))
+	if _, ok := c.Get("b"); ok {
+		t.Error("Expected b to be evicted.")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("Expected a to still be cached.")
+	}
+	// Replacing a value accounts for the old one.
+	c.Set("a", []byte("a"))
+	c.Set("d", []byte("ddddd"))
+	if c.bytes != 10 {
+		t.Errorf("Expected 10 bytes cached, got %d", c.bytes)
+	}
+	// Values bigger than the whole cache aren't kept.
+	c.Set("e", []byte("eeeeeeeeeee"))
+	if _, ok := c.Get("e"); ok {
+		t.Error("Expected e not to be cached.")
+	}
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok || c.bytes != 9 {
+		t.Errorf("Expected a to be deleted, leaving 9 bytes, got %d bytes", c.bytes)
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	var requests, notModified int
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "private, max-age=60")
+		w.Write([]byte(`{"number": 5}`))
+	}))
+	defer ts.Close()
+	c := getClient(ts.URL)
+	c.client.Transport = newCachingTransport(&http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}, defaultCacheBytes)
+	for i := 0; i < 3; i++ {
+		pr, err := c.GetPullRequest("k8s", "kuber", 5)
+		if err != nil {
+			t.Fatalf("Didn't expect error: %v", err)
+		}
+		if pr.Number != 5 {
+			t.Errorf("Expected PR 5, got %d", pr.Number)
+		}
+	}
+	if requests != 3 || notModified != 2 {
+		t.Errorf("Expected every request to go to GitHub and two to be revalidated, got %d requests and %d not modified", requests, notModified)
+	}
+}
@@ -26,6 +26,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/gregjones/httpcache"
 )
 
 type Logger interface {
@@ -42,23 +44,31 @@ type Client struct {
 	base    string
 	dry     bool
 	fake    bool
+
+	throttle throttler
+	// If app is set, requests authenticate as its installations rather than
+	// with token.
+	app *appAuth
 }
 
+// DefaultEndpoint is the base URL of GitHub's API.
+const DefaultEndpoint = "https://api.github.com"
+
 const (
-	githubBase    = "https://api.github.com"
 	maxRetries    = 8
 	max404Retries = 2
 	maxSleepTime  = 2 * time.Minute
 	initialDelay  = 2 * time.Second
 )
 
-// NewClient creates a new fully operational GitHub client.
-func NewClient(botName, token string) *Client {
+// NewClient creates a new fully operational GitHub client. The endpoint is
+// the base URL of the API, usually DefaultEndpoint.
+func NewClient(botName, token, endpoint string) *Client {
 	return &Client{
-		client:  &http.Client{},
+		client:  &http.Client{Transport: newCachingTransport(nil, defaultCacheBytes)},
 		botName: botName,
 		token:   token,
-		base:    githubBase,
+		base:    strings.TrimSuffix(endpoint, "/"),
 		dry:     false,
 	}
 }
@@ -66,12 +76,12 @@ func NewClient(botName, token string) *Client {
 // NewDryRunClient creates a new client that will not perform mutating actions
 // such as setting statuses or commenting, but it will still query GitHub and
 // use up API tokens.
-func NewDryRunClient(botName, token string) *Client {
+func NewDryRunClient(botName, token, endpoint string) *Client {
 	return &Client{
-		client:  &http.Client{},
+		client:  &http.Client{Transport: newCachingTransport(nil, defaultCacheBytes)},
 		botName: botName,
 		token:   token,
-		base:    githubBase,
+		base:    strings.TrimSuffix(endpoint, "/"),
 		dry:     true,
 	}
 }
@@ -85,7 +95,17 @@ func NewFakeClient(botName string) *Client {
 	}
 }
 
+// Throttle limits the client to hourlyTokens API calls per hour, in bursts of
+// at most burst calls. Passing zero for either removes the limit. Regardless,
+// the client slows down when GitHub reports that the token is nearly used up.
+func (c *Client) Throttle(hourlyTokens, burst int) {
+	c.log("Throttle", hourlyTokens, burst)
+	c.throttle.configure(hourlyTokens, burst)
+}
+
+// log counts the call in the API call metrics and logs it if c.Logger is set.
 func (c *Client) log(methodName string, args ...interface{}) {
+	apiCalls.WithLabelValues(methodName).Inc()
 	if c.Logger == nil {
 		return
 	}
@@ -139,14 +159,29 @@ func (c *Client) request(r *request, ret interface{}) (int, error) {
 }
 
 // Retry on transport failures. Retries on 500s, retries after sleep on
-// ratelimit exceeded, and retries 404s a couple times.
+// ratelimit exceeded, and retries 404s a couple times. Each attempt waits for
+// the throttle first.
 func (c *Client) requestRetry(method, path string, body interface{}) (*http.Response, error) {
 	var resp *http.Response
 	var err error
 	backoff := initialDelay
 	for retries := 0; retries < maxRetries; retries++ {
-		resp, err = c.doRequest(method, path, body)
+		if d := c.throttle.wait(timeNow()); d > 0 {
+			throttleSeconds.Add(d.Seconds())
+			timeSleep(d)
+		}
+		// Get the token for every attempt, since an App's installation
+		// token may expire while we back off. Failing to get one isn't
+		// worth retrying.
+		var token string
+		if token, err = c.authToken(path); err != nil {
+			return nil, err
+		}
+		resp, err = c.doRequest(method, path, token, body)
 		if err == nil {
+			c.throttle.update(resp.Header)
+			cached := strconv.FormatBool(resp.Header.Get(httpcache.XFromCache) != "")
+			apiResponses.WithLabelValues(strconv.Itoa(resp.StatusCode), cached).Inc()
 			if resp.StatusCode == 404 && retries < max404Retries {
 				// Retry 404s a couple times. Sometimes GitHub is inconsistent in
 				// the sense that they send us an event such as "PR opened" but an
@@ -189,7 +224,15 @@ func (c *Client) requestRetry(method, path string, body interface{}) (*http.Resp
 	return resp, err
 }
 
-func (c *Client) doRequest(method, path string, body interface{}) (*http.Response, error) {
+// authToken returns the token to authenticate a request for path with.
+func (c *Client) authToken(path string) (string, error) {
+	if c.app == nil {
+		return c.token, nil
+	}
+	return c.app.tokenFor(orgForRequest(c.base, path))
+}
+
+func (c *Client) doRequest(method, path, token string, body interface{}) (*http.Response, error) {
 	var buf io.Reader
 	if body != nil {
 		b, err := json.Marshal(body)
@@ -202,7 +245,7 @@ func (c *Client) doRequest(method, path string, body interface{}) (*http.Respons
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Authorization", "Token "+c.token)
+	req.Header.Set("Authorization", "Token "+token)
 	if strings.HasSuffix(path, "reactions") {
 		req.Header.Add("Accept", "application/vnd.github.squirrel-girl-preview")
 	} else if strings.HasSuffix(path, "requested_reviewers") {
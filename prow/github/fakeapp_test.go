@@ -0,0 +1,166 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeAppServer fakes the parts of GitHub's App API that the client uses, so
+// that App authentication can be tested offline. It checks the JWT on each
+// request against Key, lists Installations, and mints installation tokens.
+type fakeAppServer struct {
+	AppID         string
+	Key           *rsa.PublicKey
+	Installations []Installation
+	// TokenLifetime is how long minted tokens last. Defaults to an hour.
+	TokenLifetime time.Duration
+
+	mut    sync.Mutex
+	minted map[int]int
+	// beforeMint, if set, is called before minting a token for an
+	// installation.
+	beforeMint func(id int)
+}
+
+// holdMints makes minting tokens for the installation wait until the
+// returned channel is closed.
+func (f *fakeAppServer) holdMints(id int) chan struct{} {
+	release := make(chan struct{})
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.beforeMint = func(i int) {
+		if i == id {
+			<-release
+		}
+	}
+	return release
+}
+
+// Minted returns how many tokens have been minted for the installation.
+func (f *fakeAppServer) Minted(id int) int {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	return f.minted[id]
+}
+
+func (f *fakeAppServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := f.checkJWT(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "app" && parts[1] == "installations":
+		b, err := json.Marshal(f.Installations)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(b)
+	case r.Method == http.MethodPost && len(parts) == 4 && parts[0] == "app" && parts[1] == "installations" && parts[3] == "access_tokens":
+		id, err := strconv.Atoi(parts[2])
+		if err != nil || !f.installed(id) {
+			http.Error(w, "404 Not Found", http.StatusNotFound)
+			return
+		}
+		f.mut.Lock()
+		beforeMint := f.beforeMint
+		f.mut.Unlock()
+		if beforeMint != nil {
+			beforeMint(id)
+		}
+		lifetime := f.TokenLifetime
+		if lifetime == 0 {
+			lifetime = time.Hour
+		}
+		f.mut.Lock()
+		if f.minted == nil {
+			f.minted = map[int]int{}
+		}
+		f.minted[id]++
+		t := installationToken{
+			Token:     fmt.Sprintf("installation-%d-token-%d", id, f.minted[id]),
+			ExpiresAt: time.Now().Add(lifetime),
+		}
+		f.mut.Unlock()
+		b, err := json.Marshal(t)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write(b)
+	default:
+		http.Error(w, "404 Not Found", http.StatusNotFound)
+	}
+}
+
+func (f *fakeAppServer) installed(id int) bool {
+	for _, i := range f.Installations {
+		if i.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// checkJWT checks that the JWT is signed by Key, issued by AppID and
+// unexpired.
+func (f *fakeAppServer) checkJWT(jwt string) error {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("malformed JWT")
+	}
+	enc := base64.RawURLEncoding
+	sig, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("malformed JWT signature: %v", err)
+	}
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(f.Key, crypto.SHA256, sum[:], sig); err != nil {
+		return fmt.Errorf("bad JWT signature: %v", err)
+	}
+	b, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return fmt.Errorf("malformed JWT claims: %v", err)
+	}
+	var claims struct {
+		Issuer    string `json:"iss"`
+		ExpiresAt int64  `json:"exp"`
+	}
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return fmt.Errorf("malformed JWT claims: %v", err)
+	}
+	if claims.Issuer != f.AppID {
+		return fmt.Errorf("JWT issued by %q, not %q", claims.Issuer, f.AppID)
+	}
+	if time.Unix(claims.ExpiresAt, 0).Before(time.Now()) {
+		return fmt.Errorf("JWT expired")
+	}
+	return nil
+}
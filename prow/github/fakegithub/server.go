@@ -0,0 +1,801 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fakegithub
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"k8s.io/test-infra/prow/github"
+)
+
+// Server is a fake GitHub that serves the parts of the REST API that prow
+// uses from memory: issues, comments, labels, statuses, PR files, refs,
+// reactions, assignees, review requests, org membership and issue search.
+// If HookURL is set, it sends webhooks there as the state changes, so that
+// hook, its plugins and the rest of prow can be tested together against it.
+// Tests set up repos and act as users with its methods, then check the
+// resulting state. Serve it with httptest and point github.NewClient at it.
+type Server struct {
+	// BotName is the user that API requests act as.
+	BotName string
+	// HookURL is where webhooks are sent. If empty, none are sent.
+	HookURL string
+	// HMACSecret signs the webhooks.
+	HMACSecret []byte
+
+	mut     sync.Mutex
+	repos   map[string]*repo
+	members map[string]map[string]bool
+	nextID  int
+
+	// hookMut keeps webhooks in the order of the changes that caused them.
+	hookMut    sync.Mutex
+	deliveries int
+	hookErrors []error
+}
+
+type repo struct {
+	github.Repo
+	labels           []string
+	issues           map[int]*github.Issue
+	pulls            map[int]*github.PullRequest
+	comments         map[int][]github.IssueComment
+	files            map[int][]github.PullRequestChange
+	statuses         map[string][]github.Status
+	refs             map[string]string
+	issueReactions   map[int][]string
+	commentReactions map[int][]string
+	nextNumber       int
+}
+
+type webhook struct {
+	eventType string
+	payload   interface{}
+}
+
+// NewServer returns a fake GitHub without any repos.
+func NewServer(botName string) *Server {
+	return &Server{
+		BotName: botName,
+		repos:   map[string]*repo{},
+		members: map[string]map[string]bool{},
+	}
+}
+
+// AddRepo creates an empty repo with the given labels.
+func (s *Server) AddRepo(org, name string, labels ...string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.repos[org+"/"+name] = &repo{
+		Repo: github.Repo{
+			Owner:    github.User{Login: org},
+			Name:     name,
+			FullName: org + "/" + name,
+			HTMLURL:  fmt.Sprintf("https://github.com/%s/%s", org, name),
+		},
+		labels:           labels,
+		issues:           map[int]*github.Issue{},
+		pulls:            map[int]*github.PullRequest{},
+		comments:         map[int][]github.IssueComment{},
+		files:            map[int][]github.PullRequestChange{},
+		statuses:         map[string][]github.Status{},
+		refs:             map[string]string{},
+		issueReactions:   map[int][]string{},
+		commentReactions: map[int][]string{},
+		nextNumber:       1,
+	}
+}
+
+// AddMember makes the user a member of the org. Only members may be assigned
+// issues or asked for reviews.
+func (s *Server) AddMember(org, login string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if s.members[org] == nil {
+		s.members[org] = map[string]bool{}
+	}
+	s.members[org][login] = true
+}
+
+// SetRef points a ref, such as "heads/master", at a SHA.
+func (s *Server) SetRef(org, name, ref, sha string) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	r, ok := s.repos[org+"/"+name]
+	if !ok {
+		return fmt.Errorf("no repo %s/%s", org, name)
+	}
+	r.refs[ref] = sha
+	return nil
+}
+
+// OpenIssue opens an issue as the author and returns its number.
+func (s *Server) OpenIssue(org, name, author, title, body string) (int, error) {
+	s.mut.Lock()
+	r, ok := s.repos[org+"/"+name]
+	if !ok {
+		s.mut.Unlock()
+		return 0, fmt.Errorf("no repo %s/%s", org, name)
+	}
+	i := r.newIssue(author, title, body)
+	hook := webhook{"issues", github.IssueEvent{Action: "opened", Issue: *i, Repo: r.Repo}}
+	s.mut.Unlock()
+	s.send(hook)
+	return i.Number, nil
+}
+
+// OpenPullRequest opens a PR as the author against master, with its head at
+// sha and changing files, and returns its number.
+func (s *Server) OpenPullRequest(org, name, author, title, sha string, files []github.PullRequestChange) (int, error) {
+	s.mut.Lock()
+	r, ok := s.repos[org+"/"+name]
+	if !ok {
+		s.mut.Unlock()
+		return 0, fmt.Errorf("no repo %s/%s", org, name)
+	}
+	i := r.newIssue(author, title, "")
+	i.PullRequest = &struct{}{}
+	i.HTMLURL = fmt.Sprintf("%s/pull/%d", r.HTMLURL, i.Number)
+	r.pulls[i.Number] = &github.PullRequest{
+		Number:  i.Number,
+		HTMLURL: i.HTMLURL,
+		User:    i.User,
+		Base: github.PullRequestBranch{
+			Ref:  "master",
+			SHA:  r.refs["heads/master"],
+			Repo: r.Repo,
+		},
+		Head: github.PullRequestBranch{
+			Ref:  fmt.Sprintf("%s-%d", author, i.Number),
+			SHA:  sha,
+			Repo: r.Repo,
+		},
+	}
+	r.files[i.Number] = files
+	hook := webhook{"pull_request", r.pullRequestEvent("opened", i.Number)}
+	s.mut.Unlock()
+	s.send(hook)
+	return i.Number, nil
+}
+
+// Comment comments on an issue or PR as the author.
+func (s *Server) Comment(org, name string, number int, author, body string) error {
+	s.mut.Lock()
+	r, ok := s.repos[org+"/"+name]
+	if !ok || r.issues[number] == nil {
+		s.mut.Unlock()
+		return fmt.Errorf("no issue %s/%s#%d", org, name, number)
+	}
+	hook := s.comment(r, number, author, body)
+	s.mut.Unlock()
+	s.send(hook)
+	return nil
+}
+
+// Issue returns an issue or the issue of a PR.
+func (s *Server) Issue(org, name string, number int) (github.Issue, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	r, ok := s.repos[org+"/"+name]
+	if !ok || r.issues[number] == nil {
+		return github.Issue{}, fmt.Errorf("no issue %s/%s#%d", org, name, number)
+	}
+	return copyIssue(*r.issues[number]), nil
+}
+
+// PullRequest returns a PR.
+func (s *Server) PullRequest(org, name string, number int) (github.PullRequest, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	r, ok := s.repos[org+"/"+name]
+	if !ok || r.pulls[number] == nil {
+		return github.PullRequest{}, fmt.Errorf("no PR %s/%s#%d", org, name, number)
+	}
+	return r.pullRequest(number), nil
+}
+
+// Comments returns the comments on an issue or PR, oldest first.
+func (s *Server) Comments(org, name string, number int) []github.IssueComment {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if r, ok := s.repos[org+"/"+name]; ok {
+		return append([]github.IssueComment(nil), r.comments[number]...)
+	}
+	return nil
+}
+
+// Statuses returns every status set on the SHA, oldest first.
+func (s *Server) Statuses(org, name, sha string) []github.Status {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if r, ok := s.repos[org+"/"+name]; ok {
+		return append([]github.Status(nil), r.statuses[sha]...)
+	}
+	return nil
+}
+
+// IssueReactions returns the reactions to an issue or PR.
+func (s *Server) IssueReactions(org, name string, number int) []string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if r, ok := s.repos[org+"/"+name]; ok {
+		return append([]string(nil), r.issueReactions[number]...)
+	}
+	return nil
+}
+
+// CommentReactions returns the reactions to a comment.
+func (s *Server) CommentReactions(org, name string, id int) []string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if r, ok := s.repos[org+"/"+name]; ok {
+		return append([]string(nil), r.commentReactions[id]...)
+	}
+	return nil
+}
+
+// WebhookErrors returns the errors from sending webhooks to HookURL.
+func (s *Server) WebhookErrors() []error {
+	s.hookMut.Lock()
+	defer s.hookMut.Unlock()
+	return append([]error(nil), s.hookErrors...)
+}
+
+func (r *repo) newIssue(author, title, body string) *github.Issue {
+	i := &github.Issue{
+		User:    github.User{Login: author},
+		Number:  r.nextNumber,
+		Title:   title,
+		Body:    body,
+		State:   "open",
+		HTMLURL: fmt.Sprintf("%s/issues/%d", r.HTMLURL, r.nextNumber),
+	}
+	r.issues[i.Number] = i
+	r.nextNumber++
+	return i
+}
+
+// pullRequest returns a copy of the PR with the labels, assignees and state
+// of its issue.
+func (r *repo) pullRequest(number int) github.PullRequest {
+	pr := *r.pulls[number]
+	i := copyIssue(*r.issues[number])
+	pr.State = i.State
+	pr.Labels = i.Labels
+	pr.Assignees = i.Assignees
+	pr.Body = i.Body
+	pr.RequestedReviewers = append([]github.User(nil), pr.RequestedReviewers...)
+	return pr
+}
+
+func (r *repo) pullRequestEvent(action string, number int) github.PullRequestEvent {
+	return github.PullRequestEvent{
+		Action:      action,
+		Number:      number,
+		PullRequest: r.pullRequest(number),
+	}
+}
+
+// issueWebhook returns the issues or pull_request webhook for the action.
+func (r *repo) issueWebhook(action string, number int) webhook {
+	if r.pulls[number] != nil {
+		return webhook{"pull_request", r.pullRequestEvent(action, number)}
+	}
+	return webhook{"issues", github.IssueEvent{Action: action, Issue: copyIssue(*r.issues[number]), Repo: r.Repo}}
+}
+
+func copyIssue(i github.Issue) github.Issue {
+	i.Labels = append([]github.Label(nil), i.Labels...)
+	i.Assignees = append([]github.User(nil), i.Assignees...)
+	return i
+}
+
+// comment adds a comment. The caller must hold the lock.
+func (s *Server) comment(r *repo, number int, author, body string) webhook {
+	s.nextID++
+	ic := github.IssueComment{
+		ID:      s.nextID,
+		Body:    body,
+		User:    github.User{Login: author},
+		HTMLURL: fmt.Sprintf("%s#issuecomment-%d", r.issues[number].HTMLURL, s.nextID),
+	}
+	r.comments[number] = append(r.comments[number], ic)
+	return webhook{"issue_comment", github.IssueCommentEvent{
+		Action:  "created",
+		Issue:   copyIssue(*r.issues[number]),
+		Comment: ic,
+		Repo:    r.Repo,
+	}}
+}
+
+// findComment returns the issue number and index of a comment.
+func (r *repo) findComment(id int) (int, int, bool) {
+	for number, ics := range r.comments {
+		for i, ic := range ics {
+			if ic.ID == id {
+				return number, i, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// send delivers the webhooks to HookURL in order.
+func (s *Server) send(hooks ...webhook) {
+	if s.HookURL == "" {
+		return
+	}
+	s.hookMut.Lock()
+	defer s.hookMut.Unlock()
+	for _, h := range hooks {
+		if err := s.deliver(h); err != nil {
+			s.hookErrors = append(s.hookErrors, fmt.Errorf("error sending %s webhook: %v", h.eventType, err))
+		}
+	}
+}
+
+func (s *Server) deliver(h webhook) error {
+	b, err := json.Marshal(h.payload)
+	if err != nil {
+		return err
+	}
+	s.deliveries++
+	req, err := http.NewRequest(http.MethodPost, s.HookURL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-GitHub-Event", h.eventType)
+	req.Header.Set("X-GitHub-Delivery", fmt.Sprintf("fake-%d", s.deliveries))
+	req.Header.Set("X-Hub-Signature", github.PayloadSignature(b, s.HMACSecret))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("hook returned %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// apiError is an HTTP error from the fake API.
+type apiError struct {
+	code int
+	msg  string
+}
+
+func (e apiError) Error() string {
+	return e.msg
+}
+
+func notFound(format string, args ...interface{}) error {
+	return apiError{http.StatusNotFound, fmt.Sprintf(format, args...)}
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return apiError{http.StatusBadRequest, fmt.Sprintf(format, args...)}
+}
+
+// ServeHTTP serves the API.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mut.Lock()
+	code, v, hooks, err := s.route(r)
+	s.mut.Unlock()
+	if err != nil {
+		code = http.StatusInternalServerError
+		if ae, ok := err.(apiError); ok {
+			code = ae.code
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]string{"message": err.Error()})
+		return
+	}
+	// Send the webhooks before responding, so that they arrive in order
+	// even if the caller makes more changes as soon as this one is done.
+	s.send(hooks...)
+	if v == nil {
+		w.WriteHeader(code)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// route handles a request with the lock held. It returns the status code,
+// the value to respond with, and the webhooks to send.
+func (s *Server) route(req *http.Request) (int, interface{}, []webhook, error) {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 4 && parts[0] == "orgs" && parts[2] == "members" && req.Method == http.MethodGet:
+		if s.members[parts[1]][parts[3]] {
+			return http.StatusNoContent, nil, nil, nil
+		}
+		return 0, nil, nil, notFound("%s is not a member of %s", parts[3], parts[1])
+	case len(parts) == 2 && parts[0] == "search" && parts[1] == "issues" && req.Method == http.MethodGet:
+		issues := s.search(req.URL.Query().Get("q"))
+		return http.StatusOK, github.IssuesSearchResult{Total: len(issues), Issues: issues}, nil, nil
+	case len(parts) >= 4 && parts[0] == "repos":
+		r, ok := s.repos[parts[1]+"/"+parts[2]]
+		if !ok {
+			return 0, nil, nil, notFound("no repo %s/%s", parts[1], parts[2])
+		}
+		return s.routeRepo(r, req, parts[3:])
+	}
+	return 0, nil, nil, notFound("unknown API %s %s", req.Method, req.URL.Path)
+}
+
+func (s *Server) routeRepo(r *repo, req *http.Request, parts []string) (int, interface{}, []webhook, error) {
+	method := req.Method
+	switch {
+	case len(parts) == 1 && parts[0] == "labels" && method == http.MethodGet:
+		var labels []github.Label
+		for _, l := range r.labels {
+			labels = append(labels, github.Label{Name: l})
+		}
+		return http.StatusOK, labels, nil, nil
+	case len(parts) == 2 && parts[0] == "statuses" && method == http.MethodPost:
+		var st github.Status
+		if err := decode(req, &st); err != nil {
+			return 0, nil, nil, err
+		}
+		r.statuses[parts[1]] = append(r.statuses[parts[1]], st)
+		return http.StatusCreated, st, []webhook{{"status", github.StatusEvent{
+			SHA:         parts[1],
+			State:       st.State,
+			Description: st.Description,
+			TargetURL:   st.TargetURL,
+			Context:     st.Context,
+			Sender:      github.User{Login: s.BotName},
+			Repo:        r.Repo,
+		}}}, nil
+	case len(parts) >= 3 && parts[0] == "git" && parts[1] == "refs" && method == http.MethodGet:
+		ref := strings.Join(parts[2:], "/")
+		sha, ok := r.refs[ref]
+		if !ok {
+			return 0, nil, nil, notFound("no ref %s", ref)
+		}
+		return http.StatusOK, map[string]interface{}{
+			"ref":    "refs/" + ref,
+			"object": map[string]string{"sha": sha, "type": "commit"},
+		}, nil, nil
+	case len(parts) >= 3 && parts[0] == "issues" && parts[1] == "comments":
+		return s.routeComment(r, req, parts[2:])
+	case len(parts) >= 2 && parts[0] == "issues":
+		number, err := strconv.Atoi(parts[1])
+		if err != nil || r.issues[number] == nil {
+			return 0, nil, nil, notFound("no issue %s", parts[1])
+		}
+		return s.routeIssue(r, req, number, parts[2:])
+	case len(parts) >= 2 && parts[0] == "pulls":
+		number, err := strconv.Atoi(parts[1])
+		if err != nil || r.pulls[number] == nil {
+			return 0, nil, nil, notFound("no PR %s", parts[1])
+		}
+		return s.routePull(r, req, number, parts[2:])
+	}
+	return 0, nil, nil, notFound("unknown API %s %s", method, req.URL.Path)
+}
+
+func (s *Server) routeComment(r *repo, req *http.Request, parts []string) (int, interface{}, []webhook, error) {
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, nil, nil, notFound("no comment %s", parts[0])
+	}
+	number, idx, ok := r.findComment(id)
+	if !ok {
+		return 0, nil, nil, notFound("no comment %d", id)
+	}
+	ic := r.comments[number][idx]
+	event := func(action string, ic github.IssueComment) []webhook {
+		return []webhook{{"issue_comment", github.IssueCommentEvent{
+			Action:  action,
+			Issue:   copyIssue(*r.issues[number]),
+			Comment: ic,
+			Repo:    r.Repo,
+		}}}
+	}
+	switch {
+	case len(parts) == 1 && req.Method == http.MethodPatch:
+		var edit github.IssueComment
+		if err := decode(req, &edit); err != nil {
+			return 0, nil, nil, err
+		}
+		ic.Body = edit.Body
+		r.comments[number][idx] = ic
+		return http.StatusOK, ic, event("edited", ic), nil
+	case len(parts) == 1 && req.Method == http.MethodDelete:
+		ics := r.comments[number]
+		r.comments[number] = append(ics[:idx:idx], ics[idx+1:]...)
+		return http.StatusNoContent, nil, event("deleted", ic), nil
+	case len(parts) == 2 && parts[1] == "reactions" && req.Method == http.MethodPost:
+		var reaction github.Reaction
+		if err := decode(req, &reaction); err != nil {
+			return 0, nil, nil, err
+		}
+		r.commentReactions[id] = append(r.commentReactions[id], reaction.Content)
+		return http.StatusCreated, reaction, nil, nil
+	}
+	return 0, nil, nil, notFound("unknown API %s %s", req.Method, req.URL.Path)
+}
+
+func (s *Server) routeIssue(r *repo, req *http.Request, number int, parts []string) (int, interface{}, []webhook, error) {
+	issue := r.issues[number]
+	method := req.Method
+	switch {
+	case len(parts) == 0 && method == http.MethodGet:
+		return http.StatusOK, copyIssue(*issue), nil, nil
+	case len(parts) == 0 && method == http.MethodPatch:
+		var edit struct {
+			State string `json:"state"`
+		}
+		if err := decode(req, &edit); err != nil {
+			return 0, nil, nil, err
+		}
+		var hooks []webhook
+		if edit.State != "" && edit.State != issue.State {
+			if edit.State != "open" && edit.State != "closed" {
+				return 0, nil, nil, badRequest("invalid state %q", edit.State)
+			}
+			issue.State = edit.State
+			action := "closed"
+			if edit.State == "open" {
+				action = "reopened"
+			}
+			hooks = append(hooks, r.issueWebhook(action, number))
+		}
+		return http.StatusOK, copyIssue(*issue), hooks, nil
+	case len(parts) == 1 && parts[0] == "comments" && method == http.MethodGet:
+		return http.StatusOK, append([]github.IssueComment{}, r.comments[number]...), nil, nil
+	case len(parts) == 1 && parts[0] == "comments" && method == http.MethodPost:
+		var ic github.IssueComment
+		if err := decode(req, &ic); err != nil {
+			return 0, nil, nil, err
+		}
+		hook := s.comment(r, number, s.BotName, ic.Body)
+		comments := r.comments[number]
+		return http.StatusCreated, comments[len(comments)-1], []webhook{hook}, nil
+	case len(parts) == 1 && parts[0] == "reactions" && method == http.MethodPost:
+		var reaction github.Reaction
+		if err := decode(req, &reaction); err != nil {
+			return 0, nil, nil, err
+		}
+		r.issueReactions[number] = append(r.issueReactions[number], reaction.Content)
+		return http.StatusCreated, reaction, nil, nil
+	case len(parts) == 1 && parts[0] == "labels" && method == http.MethodPost:
+		var labels []string
+		if err := decode(req, &labels); err != nil {
+			return 0, nil, nil, err
+		}
+		var hooks []webhook
+		for _, l := range labels {
+			if issue.HasLabel(l) {
+				continue
+			}
+			// Like GitHub, create labels that don't exist yet.
+			if !contains(r.labels, l) {
+				r.labels = append(r.labels, l)
+			}
+			issue.Labels = append(issue.Labels, github.Label{Name: l})
+			h := r.issueWebhook("labeled", number)
+			if pe, ok := h.payload.(github.PullRequestEvent); ok {
+				pe.Label = github.Label{Name: l}
+				h.payload = pe
+			}
+			hooks = append(hooks, h)
+		}
+		return http.StatusOK, copyIssue(*issue).Labels, hooks, nil
+	case len(parts) >= 2 && parts[0] == "labels" && method == http.MethodDelete:
+		// Labels may contain slashes.
+		label := strings.Join(parts[1:], "/")
+		for i, l := range issue.Labels {
+			if strings.ToLower(l.Name) != strings.ToLower(label) {
+				continue
+			}
+			issue.Labels = append(issue.Labels[:i:i], issue.Labels[i+1:]...)
+			h := r.issueWebhook("unlabeled", number)
+			if pe, ok := h.payload.(github.PullRequestEvent); ok {
+				pe.Label = l
+				h.payload = pe
+			}
+			return http.StatusOK, copyIssue(*issue).Labels, []webhook{h}, nil
+		}
+		return 0, nil, nil, notFound("label %s is not on #%d", label, number)
+	case len(parts) == 1 && parts[0] == "assignees" && (method == http.MethodPost || method == http.MethodDelete):
+		var body struct {
+			Assignees []string `json:"assignees"`
+		}
+		if err := decode(req, &body); err != nil {
+			return 0, nil, nil, err
+		}
+		var hooks []webhook
+		for _, login := range body.Assignees {
+			if method == http.MethodPost {
+				// GitHub quietly ignores users who can't be assigned.
+				if issue.IsAssignee(login) || !s.members[r.Owner.Login][login] {
+					continue
+				}
+				issue.Assignees = append(issue.Assignees, github.User{Login: login})
+				hooks = append(hooks, r.issueWebhook("assigned", number))
+				continue
+			}
+			for i, a := range issue.Assignees {
+				if a.Login == login {
+					issue.Assignees = append(issue.Assignees[:i:i], issue.Assignees[i+1:]...)
+					hooks = append(hooks, r.issueWebhook("unassigned", number))
+					break
+				}
+			}
+		}
+		code := http.StatusOK
+		if method == http.MethodPost {
+			code = http.StatusCreated
+		}
+		return code, copyIssue(*issue), hooks, nil
+	}
+	return 0, nil, nil, notFound("unknown API %s %s", method, req.URL.Path)
+}
+
+func (s *Server) routePull(r *repo, req *http.Request, number int, parts []string) (int, interface{}, []webhook, error) {
+	pr := r.pulls[number]
+	method := req.Method
+	switch {
+	case len(parts) == 0 && method == http.MethodGet:
+		return http.StatusOK, r.pullRequest(number), nil, nil
+	case len(parts) == 1 && parts[0] == "files" && method == http.MethodGet:
+		return http.StatusOK, append([]github.PullRequestChange{}, r.files[number]...), nil, nil
+	case len(parts) == 1 && parts[0] == "requested_reviewers" && method == http.MethodPost:
+		var body struct {
+			Reviewers []string `json:"reviewers"`
+		}
+		if err := decode(req, &body); err != nil {
+			return 0, nil, nil, err
+		}
+		// GitHub refuses the whole request if anyone can't review.
+		for _, login := range body.Reviewers {
+			if !s.members[r.Owner.Login][login] {
+				return 0, nil, nil, apiError{http.StatusUnprocessableEntity, fmt.Sprintf("%s is not a collaborator", login)}
+			}
+		}
+		var hooks []webhook
+		for _, login := range body.Reviewers {
+			if !hasUser(pr.RequestedReviewers, login) {
+				pr.RequestedReviewers = append(pr.RequestedReviewers, github.User{Login: login})
+				hooks = append(hooks, webhook{"pull_request", r.pullRequestEvent("review_requested", number)})
+			}
+		}
+		return http.StatusCreated, r.pullRequest(number), hooks, nil
+	case len(parts) == 1 && parts[0] == "requested_reviewers" && method == http.MethodDelete:
+		var body struct {
+			Reviewers []string `json:"reviewers"`
+		}
+		if err := decode(req, &body); err != nil {
+			return 0, nil, nil, err
+		}
+		var hooks []webhook
+		for _, login := range body.Reviewers {
+			for i, u := range pr.RequestedReviewers {
+				if u.Login == login {
+					pr.RequestedReviewers = append(pr.RequestedReviewers[:i:i], pr.RequestedReviewers[i+1:]...)
+					hooks = append(hooks, webhook{"pull_request", r.pullRequestEvent("review_request_removed", number)})
+					break
+				}
+			}
+		}
+		return http.StatusOK, r.pullRequest(number), hooks, nil
+	}
+	return 0, nil, nil, notFound("unknown API %s %s", method, req.URL.Path)
+}
+
+// search finds the issues and PRs that match every term of the query. It
+// understands the repo, org, is, type, state and label qualifiers. Other
+// terms match the head SHA of a PR or appear in the title or body.
+func (s *Server) search(q string) []github.Issue {
+	var names []string
+	for name := range s.repos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var issues []github.Issue
+	for _, name := range names {
+		r := s.repos[name]
+		var numbers []int
+		for n := range r.issues {
+			numbers = append(numbers, n)
+		}
+		sort.Ints(numbers)
+		for _, n := range numbers {
+			if r.matches(r.issues[n], q) {
+				issues = append(issues, copyIssue(*r.issues[n]))
+			}
+		}
+	}
+	return issues
+}
+
+func (r *repo) matches(i *github.Issue, q string) bool {
+	for _, term := range strings.Fields(q) {
+		var ok bool
+		switch {
+		case strings.HasPrefix(term, "repo:"):
+			ok = strings.TrimPrefix(term, "repo:") == r.FullName
+		case strings.HasPrefix(term, "org:"), strings.HasPrefix(term, "user:"):
+			ok = term[strings.Index(term, ":")+1:] == r.Owner.Login
+		case term == "is:pr" || term == "type:pr":
+			ok = i.IsPullRequest()
+		case term == "is:issue" || term == "type:issue":
+			ok = !i.IsPullRequest()
+		case term == "is:open" || term == "state:open":
+			ok = i.State == "open"
+		case term == "is:closed" || term == "state:closed":
+			ok = i.State == "closed"
+		case strings.HasPrefix(term, "label:"):
+			ok = i.HasLabel(strings.Trim(strings.TrimPrefix(term, "label:"), `"`))
+		default:
+			if pr := r.pulls[i.Number]; pr != nil && strings.HasPrefix(pr.Head.SHA, term) {
+				ok = true
+			} else {
+				t := strings.ToLower(term)
+				ok = strings.Contains(strings.ToLower(i.Title), t) || strings.Contains(strings.ToLower(i.Body), t)
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func decode(req *http.Request, v interface{}) error {
+	b, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return badRequest("invalid request body: %v", err)
+	}
+	return nil
+}
+
+func contains(ss []string, s string) bool {
+	for _, x := range ss {
+		if strings.ToLower(x) == strings.ToLower(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasUser(us []github.User, login string) bool {
+	for _, u := range us {
+		if u.Login == login {
+			return true
+		}
+	}
+	return false
+}
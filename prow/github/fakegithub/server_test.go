@@ -0,0 +1,239 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fakegithub
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"k8s.io/test-infra/prow/github"
+)
+
+// receiver records the webhooks it gets, checking their signatures.
+type receiver struct {
+	t      *testing.T
+	secret []byte
+
+	mut    sync.Mutex
+	events []string
+	labels []string
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	payload, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		rc.t.Errorf("Error reading webhook: %v", err)
+	}
+	if !github.ValidatePayload(payload, r.Header.Get("X-Hub-Signature"), rc.secret) {
+		rc.t.Errorf("Invalid signature on %s webhook.", r.Header.Get("X-GitHub-Event"))
+	}
+	var e struct {
+		Action string       `json:"action"`
+		Label  github.Label `json:"label"`
+	}
+	if err := json.Unmarshal(payload, &e); err != nil {
+		rc.t.Errorf("Error unmarshaling webhook: %v", err)
+	}
+	rc.mut.Lock()
+	defer rc.mut.Unlock()
+	event := r.Header.Get("X-GitHub-Event")
+	if e.Action != "" {
+		event += "/" + e.Action
+	}
+	rc.events = append(rc.events, event)
+	if e.Label.Name != "" {
+		rc.labels = append(rc.labels, e.Label.Name)
+	}
+}
+
+func TestServer(t *testing.T) {
+	rc := &receiver{t: t, secret: []byte("secret")}
+	hook := httptest.NewServer(rc)
+	defer hook.Close()
+
+	s := NewServer("bot")
+	s.HookURL = hook.URL
+	s.HMACSecret = rc.secret
+	s.AddRepo("org", "repo", "lgtm")
+	s.AddMember("org", "alice")
+	if err := s.SetRef("org", "repo", "heads/master", "base"); err != nil {
+		t.Fatalf("Error setting ref: %v", err)
+	}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	c := github.NewClient("bot", "", ts.URL)
+
+	files := []github.PullRequestChange{{Filename: "a.go", Status: "modified"}}
+	number, err := s.OpenPullRequest("org", "repo", "bob", "Fix it", "abcdef", files)
+	if err != nil {
+		t.Fatalf("Error opening PR: %v", err)
+	}
+	if err := s.Comment("org", "repo", number, "alice", "/lgtm"); err != nil {
+		t.Fatalf("Error commenting: %v", err)
+	}
+
+	if ok, err := c.IsMember("org", "alice"); err != nil || !ok {
+		t.Errorf("Expected alice to be a member, got %t, %v.", ok, err)
+	}
+	if sha, err := c.GetRef("org", "repo", "heads/master"); err != nil || sha != "base" {
+		t.Errorf("Expected master at base, got %q, %v.", sha, err)
+	}
+	if err := c.AssignIssue("org", "repo", number, []string{"alice"}); err != nil {
+		t.Errorf("Error assigning alice: %v", err)
+	}
+	if _, ok := c.AssignIssue("org", "repo", number, []string{"bob"}).(github.MissingUsers); !ok {
+		t.Errorf("Expected bob not to be assignable.")
+	}
+	if err := c.AddLabel("org", "repo", number, "lgtm"); err != nil {
+		t.Errorf("Error adding lgtm: %v", err)
+	}
+	if err := c.AddLabel("org", "repo", number, "area/prow"); err != nil {
+		t.Errorf("Error adding area/prow: %v", err)
+	}
+	if err := c.RemoveLabel("org", "repo", number, "area/prow"); err != nil {
+		t.Errorf("Error removing area/prow: %v", err)
+	}
+	if err := c.CreateComment("org", "repo", number, "Thanks!"); err != nil {
+		t.Errorf("Error commenting: %v", err)
+	}
+	if err := c.CreateIssueReaction("org", "repo", number, "+1"); err != nil {
+		t.Errorf("Error reacting: %v", err)
+	}
+	if err := c.CreateStatus("org", "repo", "abcdef", github.Status{State: "success", Context: "test"}); err != nil {
+		t.Errorf("Error creating status: %v", err)
+	}
+	err = c.RequestReview("org", "repo", number, []string{"alice", "carol"})
+	if mu, ok := err.(github.MissingUsers); !ok || !reflect.DeepEqual(mu.Users, []string{"carol"}) {
+		t.Errorf("Expected carol to be missing, got %v.", err)
+	}
+
+	pr, err := c.GetPullRequest("org", "repo", number)
+	if err != nil {
+		t.Fatalf("Error getting PR: %v", err)
+	}
+	if pr.Head.SHA != "abcdef" || pr.Base.SHA != "base" {
+		t.Errorf("Expected head abcdef and base base, got %s and %s.", pr.Head.SHA, pr.Base.SHA)
+	}
+	if len(pr.RequestedReviewers) != 1 || pr.RequestedReviewers[0].Login != "alice" {
+		t.Errorf("Expected a review request for alice, got %v.", pr.RequestedReviewers)
+	}
+	changes, err := c.GetPullRequestChanges(*pr)
+	if err != nil || !reflect.DeepEqual(changes, files) {
+		t.Errorf("Expected changes %v, got %v, %v.", files, changes, err)
+	}
+	if err := c.CloseIssue("org", "repo", number); err != nil {
+		t.Errorf("Error closing PR: %v", err)
+	}
+
+	i, err := s.Issue("org", "repo", number)
+	if err != nil {
+		t.Fatalf("Error getting issue: %v", err)
+	}
+	if !i.HasLabel("lgtm") || i.HasLabel("area/prow") || !i.IsAssignee("alice") || i.State != "closed" {
+		t.Errorf("Wrong final state: %+v", i)
+	}
+	var bodies []string
+	for _, ic := range s.Comments("org", "repo", number) {
+		bodies = append(bodies, ic.User.Login+": "+ic.Body)
+	}
+	if expected := []string{"alice: /lgtm", "bot: Thanks!"}; !reflect.DeepEqual(bodies, expected) {
+		t.Errorf("Expected comments %v, got %v.", expected, bodies)
+	}
+	if r := s.IssueReactions("org", "repo", number); !reflect.DeepEqual(r, []string{"+1"}) {
+		t.Errorf("Expected a +1, got %v.", r)
+	}
+	if st := s.Statuses("org", "repo", "abcdef"); len(st) != 1 || st[0].State != "success" {
+		t.Errorf("Expected a successful status, got %v.", st)
+	}
+
+	if errs := s.WebhookErrors(); len(errs) > 0 {
+		t.Errorf("Unexpected webhook errors: %v", errs)
+	}
+	rc.mut.Lock()
+	defer rc.mut.Unlock()
+	expected := []string{
+		"pull_request/opened",
+		"issue_comment/created",
+		"pull_request/assigned",
+		"pull_request/labeled",
+		"pull_request/labeled",
+		"pull_request/unlabeled",
+		"issue_comment/created",
+		"status",
+		"pull_request/review_requested",
+		"pull_request/closed",
+	}
+	if !reflect.DeepEqual(rc.events, expected) {
+		t.Errorf("Expected webhooks %v, got %v.", expected, rc.events)
+	}
+	if expected := []string{"lgtm", "area/prow", "area/prow"}; !reflect.DeepEqual(rc.labels, expected) {
+		t.Errorf("Expected labels %v in webhooks, got %v.", expected, rc.labels)
+	}
+}
+
+func TestServerSearch(t *testing.T) {
+	s := NewServer("bot")
+	s.AddRepo("org", "repo")
+	s.AddRepo("other", "repo")
+	s.OpenIssue("org", "repo", "bob", "Flaky test", "It flakes.")
+	s.OpenPullRequest("org", "repo", "bob", "Fix flaky test", "abc123", nil)
+	s.OpenPullRequest("other", "repo", "bob", "Add a feature", "def456", nil)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	c := github.NewClient("bot", "", ts.URL)
+	if err := c.AddLabel("org", "repo", 2, "lgtm"); err != nil {
+		t.Fatalf("Error adding label: %v", err)
+	}
+	if err := c.CloseIssue("org", "repo", 1); err != nil {
+		t.Fatalf("Error closing issue: %v", err)
+	}
+
+	testcases := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{"everything", "", []string{"Flaky test", "Fix flaky test", "Add a feature"}},
+		{"repo", "repo:org/repo", []string{"Flaky test", "Fix flaky test"}},
+		{"org", "org:other", []string{"Add a feature"}},
+		{"PRs", "is:pr", []string{"Fix flaky test", "Add a feature"}},
+		{"closed issues", "type:issue+state:closed", []string{"Flaky test"}},
+		{"label", "is:open+label:lgtm", []string{"Fix flaky test"}},
+		{"sha", "def4", []string{"Add a feature"}},
+		{"words", "repo:org/repo+flaky+is:open", []string{"Fix flaky test"}},
+		{"nothing", "nothing", nil},
+	}
+	for _, tc := range testcases {
+		issues, err := c.FindIssues(tc.query)
+		if err != nil {
+			t.Errorf("For case %s, error searching: %v", tc.name, err)
+			continue
+		}
+		var titles []string
+		for _, i := range issues {
+			titles = append(titles, i.Title)
+		}
+		if !reflect.DeepEqual(titles, tc.expected) {
+			t.Errorf("For case %s, expected %v, got %v.", tc.name, tc.expected, titles)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	apiCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "github_api_calls_total",
+		Help: "How many times each GitHub client method was called.",
+	}, []string{"method"})
+	apiResponses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "github_responses_total",
+		Help: "How many responses GitHub sent, by status code and whether they were revalidated from the cache.",
+	}, []string{"code", "cached"})
+	rateLimitRemaining = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "github_ratelimit_remaining",
+		Help: "How many API tokens were left according to the last response.",
+	})
+	throttleSeconds = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "github_throttle_seconds_total",
+		Help: "How long requests have waited on the client's throttle.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(apiCalls, apiResponses, rateLimitRemaining, throttleSeconds)
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// throttleReserve is how many API tokens may remain before the client starts
+// spreading requests over the time left until the rate limit resets.
+const throttleReserve = 300
+
+var timeNow = time.Now
+
+// throttler spaces out requests so that bursts don't use up the API token.
+// It combines an optional token bucket, set with Client.Throttle, with the
+// rate limit that GitHub reports on every response.
+type throttler struct {
+	mut sync.Mutex
+
+	// The token bucket. It is disabled while rate is 0.
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	// The rate limit from the most recent response. The zero reset time
+	// means that we haven't seen one yet.
+	remaining int
+	reset     time.Time
+	// next is the earliest time at which a request may go out once fewer
+	// than throttleReserve tokens remain.
+	next time.Time
+}
+
+// configure sets up the token bucket. It is disabled if either argument is
+// not positive.
+func (t *throttler) configure(hourlyTokens, burst int) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if hourlyTokens <= 0 || burst <= 0 {
+		t.rate = 0
+		return
+	}
+	t.rate = float64(hourlyTokens) / time.Hour.Seconds()
+	t.burst = float64(burst)
+	t.tokens = t.burst
+	t.last = timeNow()
+}
+
+// wait reserves a request at now and returns how long to sleep before
+// sending it.
+func (t *throttler) wait(now time.Time) time.Duration {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	var d time.Duration
+	if t.rate > 0 {
+		t.tokens += now.Sub(t.last).Seconds() * t.rate
+		if t.tokens > t.burst {
+			t.tokens = t.burst
+		}
+		t.last = now
+		// Tokens go negative while requests queue up, so that each waits
+		// for its own token.
+		t.tokens--
+		if t.tokens < 0 {
+			d = time.Duration(-t.tokens / t.rate * float64(time.Second))
+		}
+	}
+	if t.remaining < throttleReserve && t.reset.After(now) {
+		// Spread what is left evenly until the reset. Count this request
+		// now, since concurrent ones won't see its response in time.
+		spacing := t.reset.Sub(now) / time.Duration(t.remaining+1)
+		slot := t.next
+		if slot.Before(now) {
+			slot = now
+		}
+		t.next = slot.Add(spacing)
+		if t.remaining > 0 {
+			t.remaining--
+		}
+		if s := slot.Sub(now); s > d {
+			d = s
+		}
+	}
+	if d > maxSleepTime {
+		d = maxSleepTime
+	}
+	return d
+}
+
+// update records the rate limit headers of a response.
+func (t *throttler) update(h http.Header) {
+	remaining, err := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	t.remaining = remaining
+	t.reset = time.Unix(reset, 0)
+	rateLimitRemaining.Set(float64(remaining))
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestThrottleTokenBucket(t *testing.T) {
+	now := time.Unix(1000, 0)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	var th throttler
+	// One token every 10 seconds, in bursts of two.
+	th.configure(360, 2)
+	var testcases = []struct {
+		name    string
+		advance time.Duration
+		wait    time.Duration
+	}{
+		{name: "first of burst"},
+		{name: "second of burst"},
+		{name: "waits for a token", wait: 10 * time.Second},
+		{name: "queues behind the last", wait: 20 * time.Second},
+		{name: "bucket partly refilled", advance: 25 * time.Second, wait: 5 * time.Second},
+		{name: "bucket refilled", advance: time.Hour},
+	}
+	for _, tc := range testcases {
+		now = now.Add(tc.advance)
+		if got := th.wait(now); got != tc.wait {
+			t.Errorf("For case %s, expected to wait %v, got %v", tc.name, tc.wait, got)
+		}
+	}
+
+	th.configure(0, 0)
+	for i := 0; i < 10; i++ {
+		if got := th.wait(now); got != 0 {
+			t.Fatalf("Expected no wait with the bucket disabled, got %v", got)
+		}
+	}
+}
+
+func TestThrottleRateLimitHeaders(t *testing.T) {
+	now := time.Unix(1000, 0)
+	var th throttler
+	h := http.Header{}
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(throttleReserve))
+	h.Set("X-RateLimit-Reset", "2000")
+	th.update(h)
+	if got := th.wait(now); got != 0 {
+		t.Errorf("Expected no wait with plenty of tokens left, got %v", got)
+	}
+
+	// Three tokens left for 120 seconds. Each request takes the next slot,
+	// and the slots are spread over what is left, so they go out at 0, 30
+	// and 70 seconds. The fourth would go out at 130 seconds, so it waits as
+	// long as it may.
+	h.Set("X-RateLimit-Remaining", "3")
+	h.Set("X-RateLimit-Reset", "1120")
+	th.update(h)
+	for i, expected := range []time.Duration{0, 30 * time.Second, 70 * time.Second, maxSleepTime} {
+		if got := th.wait(now); got != expected {
+			t.Errorf("Request %d: expected to wait %v, got %v", i, expected, got)
+		}
+	}
+
+	// Once the limit resets, nothing waits.
+	if got := th.wait(time.Unix(1121, 0)); got != 0 {
+		t.Errorf("Expected no wait after the reset, got %v", got)
+	}
+}
+
+func TestRequestRetryThrottles(t *testing.T) {
+	var slept []time.Duration
+	timeSleep = func(d time.Duration) { slept = append(slept, d) }
+	defer func() { timeSleep = time.Sleep }()
+	reset := time.Now().Add(time.Minute).Unix()
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "1")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+	}))
+	defer ts.Close()
+	c := getClient(ts.URL)
+	for i := 0; i < 3; i++ {
+		resp, err := c.requestRetry(http.MethodGet, c.base, nil)
+		if err != nil {
+			t.Fatalf("Error from request: %v", err)
+		}
+		resp.Body.Close()
+	}
+	// The first request doesn't know the limit yet, and the second one takes
+	// the first slot. The third waits for the next slot, since the single
+	// token left is spread over the minute until the reset.
+	if len(slept) != 1 {
+		t.Fatalf("Expected to sleep once, slept %v", slept)
+	}
+	for _, d := range slept {
+		if d < 10*time.Second || d > time.Minute {
+			t.Errorf("Expected to sleep for a good part of a minute, slept %v", d)
+		}
+	}
+}
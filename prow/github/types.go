@@ -72,6 +72,9 @@ type PullRequest struct {
 	Base               PullRequestBranch `json:"base"`
 	Head               PullRequestBranch `json:"head"`
 	Body               string            `json:"body"`
+	State              string            `json:"state"`
+	Labels             []Label           `json:"labels"`
+	Assignees          []User            `json:"assignees"`
 	RequestedReviewers []User            `json:"requested_reviewers"`
 }
 
@@ -205,3 +208,111 @@ type Commit struct {
 	Removed  []string `json:"removed"`
 	Modified []string `json:"modified"`
 }
+
+// These are possible State entries for a Review.
+const (
+	ReviewStateApproved         = "approved"
+	ReviewStateChangesRequested = "changes_requested"
+	ReviewStateCommented        = "commented"
+	ReviewStateDismissed        = "dismissed"
+)
+
+// ReviewEvent is what GitHub sends us when a PR review is changed.
+type ReviewEvent struct {
+	Action      string      `json:"action"`
+	PullRequest PullRequest `json:"pull_request"`
+	Repo        Repo        `json:"repository"`
+	Review      Review      `json:"review"`
+}
+
+// Review describes a PR review.
+type Review struct {
+	ID      int    `json:"id"`
+	User    User   `json:"user"`
+	Body    string `json:"body"`
+	State   string `json:"state"`
+	HTMLURL string `json:"html_url"`
+}
+
+// ReviewCommentEvent is what GitHub sends us when a PR review comment is
+// changed.
+type ReviewCommentEvent struct {
+	Action      string        `json:"action"`
+	PullRequest PullRequest   `json:"pull_request"`
+	Repo        Repo          `json:"repository"`
+	Comment     ReviewComment `json:"comment"`
+}
+
+// ReviewComment describes a comment on a line of a PR's diff.
+type ReviewComment struct {
+	ID       int    `json:"id"`
+	ReviewID int    `json:"pull_request_review_id"`
+	User     User   `json:"user"`
+	Body     string `json:"body"`
+	Path     string `json:"path"`
+	CommitID string `json:"commit_id"`
+	HTMLURL  string `json:"html_url"`
+}
+
+// These are possible RefType entries for CreateEvent and DeleteEvent.
+const (
+	RefTypeBranch = "branch"
+	RefTypeTag    = "tag"
+)
+
+// CreateEvent is what GitHub sends us when a branch or tag is created.
+type CreateEvent struct {
+	Ref     string `json:"ref"`
+	RefType string `json:"ref_type"`
+	Repo    Repo   `json:"repository"`
+	Sender  User   `json:"sender"`
+}
+
+// DeleteEvent is what GitHub sends us when a branch or tag is deleted.
+type DeleteEvent struct {
+	Ref     string `json:"ref"`
+	RefType string `json:"ref_type"`
+	Repo    Repo   `json:"repository"`
+	Sender  User   `json:"sender"`
+}
+
+// ReleaseEvent is what GitHub sends us when a release is published.
+type ReleaseEvent struct {
+	Action  string  `json:"action"`
+	Release Release `json:"release"`
+	Repo    Repo    `json:"repository"`
+	Sender  User    `json:"sender"`
+}
+
+// Release describes a GitHub release.
+type Release struct {
+	ID         int    `json:"id"`
+	TagName    string `json:"tag_name"`
+	Name       string `json:"name"`
+	Body       string `json:"body"`
+	Draft      bool   `json:"draft"`
+	Prerelease bool   `json:"prerelease"`
+	HTMLURL    string `json:"html_url"`
+	Author     User   `json:"author"`
+}
+
+// CheckRunEvent is what GitHub sends us when a check run is created, updated
+// or rerequested.
+type CheckRunEvent struct {
+	Action   string   `json:"action"`
+	CheckRun CheckRun `json:"check_run"`
+	Repo     Repo     `json:"repository"`
+	Sender   User     `json:"sender"`
+}
+
+// CheckRun describes a check run. Status is queued, in_progress or
+// completed, and Conclusion is only set once it is completed.
+type CheckRun struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	HeadSHA    string `json:"head_sha"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+	HTMLURL    string `json:"html_url"`
+	DetailsURL string `json:"details_url"`
+}
@@ -244,3 +244,27 @@ func (c *Client) GetLog(job string, build int) ([]byte, error) {
 	}
 	return buf, nil
 }
+
+// GetProgressiveLog returns the console text of the build from byte offset
+// start on, the offset to ask for next, and whether the build may still write
+// more.
+func (c *Client) GetProgressiveLog(job string, build int, start int64) ([]byte, int64, bool, error) {
+	u := fmt.Sprintf("%s/job/%s/%d/logText/progressiveText?start=%d", c.baseURL, job, build, start)
+	resp, err := c.request(http.MethodGet, u)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, 0, false, fmt.Errorf("response not 2XX: %s: (%s)", resp.Status, u)
+	}
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	next, err := strconv.ParseInt(resp.Header.Get("X-Text-Size"), 10, 64)
+	if err != nil {
+		next = start + int64(len(buf))
+	}
+	return buf, next, resp.Header.Get("X-More-Data") == "true", nil
+}
@@ -0,0 +1,422 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// How long to wait before trying to watch or list again after a failure.
+var cacheRetryDelay = 5 * time.Second
+
+// Cache is an in-memory copy of the ProwJobs and Pods in a namespace that is
+// kept up to date by watching the apiserver. Reads are served from memory.
+// Writes go to the apiserver and their results are recorded in the cache
+// right away so that callers see their own writes.
+type Cache struct {
+	client *Client
+
+	mut      sync.RWMutex
+	prowJobs map[string]ProwJob
+	pods     map[string]Pod
+	// Job name -> set of ProwJob names.
+	byJob map[string]map[string]struct{}
+	// Pod name -> ProwJob name.
+	byPod map[string]string
+
+	handlerMut  sync.Mutex
+	pjHandlers  []func(ProwJobEvent)
+	podHandlers []func(PodEvent)
+}
+
+// NewCache creates a cache backed by the given client. Call Start to fill it.
+func NewCache(c *Client) *Cache {
+	return &Cache{
+		client:   c,
+		prowJobs: map[string]ProwJob{},
+		pods:     map[string]Pod{},
+		byJob:    map[string]map[string]struct{}{},
+		byPod:    map[string]string{},
+	}
+}
+
+// AddProwJobHandler registers fn to be called for every ProwJob change the
+// cache sees, including writes made through the cache. Handlers are called
+// synchronously, so they should hand work off rather than block.
+func (ca *Cache) AddProwJobHandler(fn func(ProwJobEvent)) {
+	ca.handlerMut.Lock()
+	defer ca.handlerMut.Unlock()
+	ca.pjHandlers = append(ca.pjHandlers, fn)
+}
+
+// AddPodHandler registers fn to be called for every Pod change the cache
+// sees. See AddProwJobHandler.
+func (ca *Cache) AddPodHandler(fn func(PodEvent)) {
+	ca.handlerMut.Lock()
+	defer ca.handlerMut.Unlock()
+	ca.podHandlers = append(ca.podHandlers, fn)
+}
+
+// Start lists ProwJobs and Pods and then keeps watching them until stop is
+// closed. It returns once the initial lists are in the cache.
+func (ca *Cache) Start(stop <-chan struct{}) error {
+	pjrv, err := ca.relistProwJobs()
+	if err != nil {
+		return fmt.Errorf("error listing prow jobs: %v", err)
+	}
+	podrv, err := ca.relistPods()
+	if err != nil {
+		return fmt.Errorf("error listing pods: %v", err)
+	}
+	go ca.run(stop, pjrv, ca.relistProwJobs, func(rv *string) error {
+		return ca.client.WatchProwJobs(nil, *rv, stop, func(e ProwJobEvent) error {
+			*rv = e.ProwJob.Metadata.ResourceVersion
+			ca.applyProwJob(e)
+			return nil
+		})
+	})
+	go ca.run(stop, podrv, ca.relistPods, func(rv *string) error {
+		return ca.client.WatchPods(nil, *rv, stop, func(e PodEvent) error {
+			*rv = e.Pod.Metadata.ResourceVersion
+			ca.applyPod(e)
+			return nil
+		})
+	})
+	return nil
+}
+
+// run watches from rv, resuming from the last version it saw whenever the
+// apiserver closes the stream and listing again when that version expires.
+func (ca *Cache) run(stop <-chan struct{}, rv string, relist func() (string, error), watch func(*string) error) {
+	var expired bool
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if expired {
+			var err error
+			if rv, err = relist(); err != nil {
+				ca.client.log("RelistError", err)
+				time.Sleep(cacheRetryDelay)
+				continue
+			}
+			expired = false
+		}
+		if err := watch(&rv); err == ErrExpired {
+			expired = true
+		} else if err != nil {
+			ca.client.log("WatchError", err)
+			time.Sleep(cacheRetryDelay)
+		}
+	}
+}
+
+// relistProwJobs replaces the cached prow jobs with a fresh list and sends
+// events for whatever changed while we weren't watching.
+func (ca *Cache) relistProwJobs() (string, error) {
+	pjs, rv, err := ca.client.listProwJobs(nil)
+	if err != nil {
+		return "", err
+	}
+	seen := map[string]bool{}
+	for _, pj := range pjs {
+		seen[pj.Metadata.Name] = true
+		ca.mut.RLock()
+		old, ok := ca.prowJobs[pj.Metadata.Name]
+		ca.mut.RUnlock()
+		if !ok {
+			ca.applyProwJob(ProwJobEvent{Type: Added, ProwJob: pj})
+		} else if old.Metadata.ResourceVersion != pj.Metadata.ResourceVersion {
+			ca.applyProwJob(ProwJobEvent{Type: Modified, ProwJob: pj})
+		}
+	}
+	for _, pj := range ca.cachedProwJobs() {
+		// Jobs created through the cache since the list aren't in it.
+		if !seen[pj.Metadata.Name] && !newer(rv, pj.Metadata.ResourceVersion) {
+			ca.applyProwJob(ProwJobEvent{Type: Deleted, ProwJob: pj})
+		}
+	}
+	return rv, nil
+}
+
+// relistPods is relistProwJobs for pods.
+func (ca *Cache) relistPods() (string, error) {
+	pods, rv, err := ca.client.listPods(nil)
+	if err != nil {
+		return "", err
+	}
+	seen := map[string]bool{}
+	for _, pod := range pods {
+		seen[pod.Metadata.Name] = true
+		ca.mut.RLock()
+		old, ok := ca.pods[pod.Metadata.Name]
+		ca.mut.RUnlock()
+		if !ok {
+			ca.applyPod(PodEvent{Type: Added, Pod: pod})
+		} else if old.Metadata.ResourceVersion != pod.Metadata.ResourceVersion {
+			ca.applyPod(PodEvent{Type: Modified, Pod: pod})
+		}
+	}
+	ca.mut.RLock()
+	var gone []Pod
+	for name, pod := range ca.pods {
+		if !seen[name] && !newer(rv, pod.Metadata.ResourceVersion) {
+			gone = append(gone, pod)
+		}
+	}
+	ca.mut.RUnlock()
+	for _, pod := range gone {
+		ca.applyPod(PodEvent{Type: Deleted, Pod: pod})
+	}
+	return rv, nil
+}
+
+func (ca *Cache) cachedProwJobs() []ProwJob {
+	ca.mut.RLock()
+	defer ca.mut.RUnlock()
+	pjs := make([]ProwJob, 0, len(ca.prowJobs))
+	for _, pj := range ca.prowJobs {
+		pjs = append(pjs, pj)
+	}
+	return pjs
+}
+
+// newer returns whether resource version b is newer than a. Resource versions
+// are opaque, but the apiserver hands out increasing integers so compare them
+// as such when we can.
+func newer(a, b string) bool {
+	ai, aerr := strconv.ParseUint(a, 10, 64)
+	bi, berr := strconv.ParseUint(b, 10, 64)
+	if aerr != nil || berr != nil {
+		return a != b
+	}
+	return bi > ai
+}
+
+// stale returns whether an event for an object at resource version rv is
+// older than the cached version, cached, and so should be dropped. Deletes
+// may carry the cached version itself, but not an older one.
+func stale(t EventType, cached, rv string) bool {
+	if t == Deleted {
+		return newer(rv, cached)
+	}
+	return !newer(cached, rv)
+}
+
+// applyProwJob records the event in the cache and passes it along to the
+// handlers. Events older than what we already have are dropped.
+func (ca *Cache) applyProwJob(e ProwJobEvent) {
+	name := e.ProwJob.Metadata.Name
+	ca.mut.Lock()
+	old, ok := ca.prowJobs[name]
+	if ok && stale(e.Type, old.Metadata.ResourceVersion, e.ProwJob.Metadata.ResourceVersion) {
+		ca.mut.Unlock()
+		return
+	}
+	if ok {
+		ca.unindexProwJob(old)
+	}
+	if e.Type == Deleted {
+		delete(ca.prowJobs, name)
+	} else {
+		ca.prowJobs[name] = e.ProwJob
+		ca.indexProwJob(e.ProwJob)
+	}
+	ca.mut.Unlock()
+
+	ca.handlerMut.Lock()
+	hs := ca.pjHandlers
+	ca.handlerMut.Unlock()
+	for _, fn := range hs {
+		fn(e)
+	}
+}
+
+// applyPod is applyProwJob for pods.
+func (ca *Cache) applyPod(e PodEvent) {
+	name := e.Pod.Metadata.Name
+	ca.mut.Lock()
+	old, ok := ca.pods[name]
+	if ok && stale(e.Type, old.Metadata.ResourceVersion, e.Pod.Metadata.ResourceVersion) {
+		ca.mut.Unlock()
+		return
+	}
+	if e.Type == Deleted {
+		delete(ca.pods, name)
+	} else {
+		ca.pods[name] = e.Pod
+	}
+	ca.mut.Unlock()
+
+	ca.handlerMut.Lock()
+	hs := ca.podHandlers
+	ca.handlerMut.Unlock()
+	for _, fn := range hs {
+		fn(e)
+	}
+}
+
+// indexProwJob must be called with the write lock held.
+func (ca *Cache) indexProwJob(pj ProwJob) {
+	if _, ok := ca.byJob[pj.Spec.Job]; !ok {
+		ca.byJob[pj.Spec.Job] = map[string]struct{}{}
+	}
+	ca.byJob[pj.Spec.Job][pj.Metadata.Name] = struct{}{}
+	if pj.Status.PodName != "" {
+		ca.byPod[pj.Status.PodName] = pj.Metadata.Name
+	}
+}
+
+// unindexProwJob must be called with the write lock held.
+func (ca *Cache) unindexProwJob(pj ProwJob) {
+	delete(ca.byJob[pj.Spec.Job], pj.Metadata.Name)
+	if len(ca.byJob[pj.Spec.Job]) == 0 {
+		delete(ca.byJob, pj.Spec.Job)
+	}
+	if ca.byPod[pj.Status.PodName] == pj.Metadata.Name {
+		delete(ca.byPod, pj.Status.PodName)
+	}
+}
+
+func matchesLabels(selector, labels map[string]string) bool {
+	for k, v := range selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// ListProwJobs returns the cached ProwJobs that have all of the given labels.
+func (ca *Cache) ListProwJobs(labels map[string]string) ([]ProwJob, error) {
+	ca.mut.RLock()
+	defer ca.mut.RUnlock()
+	var pjs []ProwJob
+	for _, pj := range ca.prowJobs {
+		if matchesLabels(labels, pj.Metadata.Labels) {
+			pjs = append(pjs, pj)
+		}
+	}
+	return pjs, nil
+}
+
+// ListPods returns the cached Pods that have all of the given labels.
+func (ca *Cache) ListPods(labels map[string]string) ([]Pod, error) {
+	ca.mut.RLock()
+	defer ca.mut.RUnlock()
+	var pods []Pod
+	for _, pod := range ca.pods {
+		if matchesLabels(labels, pod.Metadata.Labels) {
+			pods = append(pods, pod)
+		}
+	}
+	return pods, nil
+}
+
+// GetProwJob returns the cached ProwJob or a NotFoundError.
+func (ca *Cache) GetProwJob(name string) (ProwJob, error) {
+	ca.mut.RLock()
+	defer ca.mut.RUnlock()
+	pj, ok := ca.prowJobs[name]
+	if !ok {
+		return ProwJob{}, NotFoundError{body: fmt.Sprintf("prowjob %s not in cache", name)}
+	}
+	return pj, nil
+}
+
+// GetPod returns the cached Pod or a NotFoundError.
+func (ca *Cache) GetPod(name string) (Pod, error) {
+	ca.mut.RLock()
+	defer ca.mut.RUnlock()
+	pod, ok := ca.pods[name]
+	if !ok {
+		return Pod{}, NotFoundError{body: fmt.Sprintf("pod %s not in cache", name)}
+	}
+	return pod, nil
+}
+
+// ProwJobsForJob returns every cached ProwJob for the given job name.
+func (ca *Cache) ProwJobsForJob(job string) []ProwJob {
+	ca.mut.RLock()
+	defer ca.mut.RUnlock()
+	var pjs []ProwJob
+	for name := range ca.byJob[job] {
+		pjs = append(pjs, ca.prowJobs[name])
+	}
+	return pjs
+}
+
+// ProwJobForPod returns the cached ProwJob that is running the given pod.
+func (ca *Cache) ProwJobForPod(pod string) (ProwJob, bool) {
+	ca.mut.RLock()
+	defer ca.mut.RUnlock()
+	name, ok := ca.byPod[pod]
+	if !ok {
+		return ProwJob{}, false
+	}
+	pj, ok := ca.prowJobs[name]
+	return pj, ok
+}
+
+func (ca *Cache) CreateProwJob(pj ProwJob) (ProwJob, error) {
+	npj, err := ca.client.CreateProwJob(pj)
+	if err == nil {
+		ca.applyProwJob(ProwJobEvent{Type: Added, ProwJob: npj})
+	}
+	return npj, err
+}
+
+func (ca *Cache) ReplaceProwJob(name string, pj ProwJob) (ProwJob, error) {
+	npj, err := ca.client.ReplaceProwJob(name, pj)
+	if err == nil {
+		ca.applyProwJob(ProwJobEvent{Type: Modified, ProwJob: npj})
+	}
+	return npj, err
+}
+
+func (ca *Cache) DeleteProwJob(name string) error {
+	err := ca.client.DeleteProwJob(name)
+	if err == nil || IsNotFound(err) {
+		if pj, gerr := ca.GetProwJob(name); gerr == nil {
+			ca.applyProwJob(ProwJobEvent{Type: Deleted, ProwJob: pj})
+		}
+	}
+	return err
+}
+
+func (ca *Cache) CreatePod(p Pod) (Pod, error) {
+	np, err := ca.client.CreatePod(p)
+	if err == nil {
+		ca.applyPod(PodEvent{Type: Added, Pod: np})
+	}
+	return np, err
+}
+
+func (ca *Cache) DeletePod(name string) error {
+	err := ca.client.DeletePod(name)
+	if err == nil || IsNotFound(err) {
+		if pod, gerr := ca.GetPod(name); gerr == nil {
+			ca.applyPod(PodEvent{Type: Deleted, Pod: pod})
+		}
+	}
+	return err
+}
@@ -0,0 +1,269 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWatchProwJobs(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apis/prow.k8s.io/v1/namespaces/ns/prowjobs" {
+			t.Errorf("Bad request path: %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("watch") != "true" {
+			t.Errorf("Not a watch: %s", r.URL.RawQuery)
+		}
+		if rv := r.URL.Query().Get("resourceVersion"); rv != "5" {
+			t.Errorf("Wrong resource version: %s", rv)
+		}
+		fmt.Fprint(w, `{"type": "ADDED", "object": {"metadata": {"name": "a", "resourceVersion": "6"}}}
+{"type": "MODIFIED", "object": {"metadata": {"name": "a", "resourceVersion": "7"}}}
+{"type": "DELETED", "object": {"metadata": {"name": "a", "resourceVersion": "8"}}}`)
+	}))
+	defer ts.Close()
+	c := getClient(ts.URL)
+	var events []ProwJobEvent
+	err := c.WatchProwJobs(nil, "5", make(chan struct{}), func(e ProwJobEvent) error {
+		events = append(events, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Didn't expect error: %v", err)
+	}
+	expected := []EventType{Added, Modified, Deleted}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events, got %d.", len(expected), len(events))
+	}
+	for i := range expected {
+		if events[i].Type != expected[i] {
+			t.Errorf("Event %d: expected %s, got %s.", i, expected[i], events[i].Type)
+		}
+		if events[i].ProwJob.Metadata.Name != "a" {
+			t.Errorf("Event %d: wrong name %s.", i, events[i].ProwJob.Metadata.Name)
+		}
+	}
+}
+
+func TestWatchExpired(t *testing.T) {
+	var testcases = []struct {
+		name string
+		code int
+		body string
+	}{
+		{
+			name: "410 response",
+			code: http.StatusGone,
+		},
+		{
+			name: "410 error event",
+			code: http.StatusOK,
+			body: `{"type": "ERROR", "object": {"code": 410, "reason": "Gone", "message": "too old resource version"}}`,
+		},
+	}
+	for _, tc := range testcases {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.code)
+			fmt.Fprint(w, tc.body)
+		}))
+		c := getClient(ts.URL)
+		err := c.WatchPods(nil, "1", make(chan struct{}), func(PodEvent) error {
+			t.Errorf("For case %s, got unexpected event.", tc.name)
+			return nil
+		})
+		if err != ErrExpired {
+			t.Errorf("For case %s, expected ErrExpired, got %v.", tc.name, err)
+		}
+		ts.Close()
+	}
+}
+
+func TestCache(t *testing.T) {
+	// The first watch on each resource closes right away with an expired
+	// version, forcing a relist. The second one blocks until we're done.
+	var pjWatches, podWatches int
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		watch := r.URL.Query().Get("watch") == "true"
+		switch r.URL.Path {
+		case "/apis/prow.k8s.io/v1/namespaces/ns/prowjobs":
+			if !watch {
+				if pjWatches == 0 {
+					fmt.Fprint(w, `{"metadata": {"resourceVersion": "10"}, "items": [
+						{"metadata": {"name": "a", "resourceVersion": "3"}, "spec": {"job": "j"}, "status": {"pod_name": "j-1"}},
+						{"metadata": {"name": "b", "resourceVersion": "4"}, "spec": {"job": "j"}}]}`)
+				} else {
+					fmt.Fprint(w, `{"metadata": {"resourceVersion": "20"}, "items": [
+						{"metadata": {"name": "a", "resourceVersion": "15"}, "spec": {"job": "j"}, "status": {"pod_name": "j-1", "state": "success"}}]}`)
+				}
+				return
+			}
+			pjWatches++
+			if pjWatches == 1 {
+				w.WriteHeader(http.StatusGone)
+				return
+			}
+			<-done
+		case "/api/v1/namespaces/ns/pods":
+			if !watch {
+				fmt.Fprint(w, `{"metadata": {"resourceVersion": "10"}, "items": [{"metadata": {"name": "j-1", "resourceVersion": "2"}}]}`)
+				return
+			}
+			podWatches++
+			w.(http.Flusher).Flush()
+			if podWatches == 1 {
+				fmt.Fprint(w, `{"type": "MODIFIED", "object": {"metadata": {"name": "j-1", "resourceVersion": "11"}, "status": {"phase": "Succeeded"}}}`)
+				return
+			}
+			<-done
+		default:
+			t.Errorf("Bad request path: %s", r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+	defer close(done)
+
+	ca := NewCache(getClient(ts.URL))
+	pjEvents := make(chan ProwJobEvent, 10)
+	podEvents := make(chan PodEvent, 10)
+	ca.AddProwJobHandler(func(e ProwJobEvent) { pjEvents <- e })
+	ca.AddPodHandler(func(e PodEvent) { podEvents <- e })
+	if err := ca.Start(done); err != nil {
+		t.Fatalf("Error starting cache: %v", err)
+	}
+	if pjs := ca.ProwJobsForJob("j"); len(pjs) != 2 {
+		t.Errorf("Expected two prow jobs for job j, got %d.", len(pjs))
+	}
+	if pj, ok := ca.ProwJobForPod("j-1"); !ok || pj.Metadata.Name != "a" {
+		t.Errorf("Expected prow job a for pod j-1, got %v.", pj.Metadata.Name)
+	}
+
+	// Initial list: two prow jobs and one pod. After the relist: a is
+	// modified and b is deleted. The pod watch modifies the pod.
+	expectPJ := []struct {
+		t    EventType
+		name string
+	}{
+		{Added, "a"},
+		{Added, "b"},
+		{Modified, "a"},
+		{Deleted, "b"},
+	}
+	for _, exp := range expectPJ {
+		select {
+		case e := <-pjEvents:
+			if e.Type != exp.t || e.ProwJob.Metadata.Name != exp.name {
+				t.Errorf("Expected %s %s, got %s %s.", exp.t, exp.name, e.Type, e.ProwJob.Metadata.Name)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %s %s.", exp.t, exp.name)
+		}
+	}
+	for _, exp := range []EventType{Added, Modified} {
+		select {
+		case e := <-podEvents:
+			if e.Type != exp {
+				t.Errorf("Expected pod event %s, got %s.", exp, e.Type)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for pod event %s.", exp)
+		}
+	}
+	pj, err := ca.GetProwJob("a")
+	if err != nil {
+		t.Fatalf("Error getting prow job a: %v", err)
+	}
+	if pj.Status.State != SuccessState {
+		t.Errorf("Expected prow job a to be updated by the relist, got state %s.", pj.Status.State)
+	}
+	if _, err := ca.GetProwJob("b"); !IsNotFound(err) {
+		t.Errorf("Expected prow job b to be gone after the relist, got %v.", err)
+	}
+	pod, err := ca.GetPod("j-1")
+	if err != nil {
+		t.Fatalf("Error getting pod: %v", err)
+	}
+	if pod.Status.Phase != PodSucceeded {
+		t.Errorf("Expected pod to be updated by the watch, got phase %s.", pod.Status.Phase)
+	}
+}
+
+func TestCacheDropsStaleEvents(t *testing.T) {
+	ca := NewCache(NewFakeClient())
+	ca.applyProwJob(ProwJobEvent{Type: Added, ProwJob: ProwJob{Metadata: ObjectMeta{Name: "a", ResourceVersion: "5"}, Spec: ProwJobSpec{Job: "new"}}})
+	ca.applyProwJob(ProwJobEvent{Type: Modified, ProwJob: ProwJob{Metadata: ObjectMeta{Name: "a", ResourceVersion: "4"}, Spec: ProwJobSpec{Job: "old"}}})
+	pj, err := ca.GetProwJob("a")
+	if err != nil {
+		t.Fatalf("Error getting prow job: %v", err)
+	}
+	if pj.Spec.Job != "new" {
+		t.Errorf("Stale event overwrote the cache.")
+	}
+	if len(ca.ProwJobsForJob("old")) != 0 || len(ca.ProwJobsForJob("new")) != 1 {
+		t.Errorf("Stale event changed the index.")
+	}
+}
+
+func TestCacheDropsStaleDeletes(t *testing.T) {
+	ca := NewCache(NewFakeClient())
+	ca.applyPod(PodEvent{Type: Added, Pod: Pod{Metadata: ObjectMeta{Name: "p", ResourceVersion: "5"}}})
+	ca.applyPod(PodEvent{Type: Deleted, Pod: Pod{Metadata: ObjectMeta{Name: "p", ResourceVersion: "4"}}})
+	if _, err := ca.GetPod("p"); err != nil {
+		t.Fatalf("Stale delete removed the pod: %v", err)
+	}
+	// Deleting through the cache sends the cached version itself.
+	ca.applyPod(PodEvent{Type: Deleted, Pod: Pod{Metadata: ObjectMeta{Name: "p", ResourceVersion: "5"}}})
+	if _, err := ca.GetPod("p"); !IsNotFound(err) {
+		t.Errorf("Expected the pod to be deleted, got %v.", err)
+	}
+}
+
+func TestRelistKeepsNewerObjects(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"metadata": {"resourceVersion": "10"}, "items": []}`)
+	}))
+	defer ts.Close()
+	ca := NewCache(getClient(ts.URL))
+	// old went away before the list. new was created through the cache
+	// after it, so the list can't have seen it.
+	for name, rv := range map[string]string{"old": "4", "new": "12"} {
+		ca.applyProwJob(ProwJobEvent{Type: Added, ProwJob: ProwJob{Metadata: ObjectMeta{Name: name, ResourceVersion: rv}}})
+		ca.applyPod(PodEvent{Type: Added, Pod: Pod{Metadata: ObjectMeta{Name: name, ResourceVersion: rv}}})
+	}
+	if _, err := ca.relistProwJobs(); err != nil {
+		t.Fatalf("Error relisting prow jobs: %v", err)
+	}
+	if _, err := ca.relistPods(); err != nil {
+		t.Fatalf("Error relisting pods: %v", err)
+	}
+	if _, err := ca.GetProwJob("old"); !IsNotFound(err) {
+		t.Errorf("Expected prow job old to be gone, got %v.", err)
+	}
+	if _, err := ca.GetPod("old"); !IsNotFound(err) {
+		t.Errorf("Expected pod old to be gone, got %v.", err)
+	}
+	if _, err := ca.GetProwJob("new"); err != nil {
+		t.Errorf("Relist dropped prow job new: %v", err)
+	}
+	if _, err := ca.GetPod("new"); err != nil {
+		t.Errorf("Relist dropped pod new: %v", err)
+	}
+}
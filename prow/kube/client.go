@@ -18,6 +18,7 @@ package kube
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
@@ -25,6 +26,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -64,6 +66,21 @@ func (c *Client) log(methodName string, args ...interface{}) {
 
 type ConflictError error
 
+// NotFoundError is returned when the requested object does not exist.
+type NotFoundError struct {
+	body string
+}
+
+func (e NotFoundError) Error() string {
+	return fmt.Sprintf("not found, body: %s", e.body)
+}
+
+// IsNotFound returns whether err is a NotFoundError.
+func IsNotFound(err error) bool {
+	_, ok := err.(NotFoundError)
+	return ok
+}
+
 type request struct {
 	method      string
 	path        string
@@ -112,6 +129,8 @@ func (c *Client) requestRetry(r *request) ([]byte, error) {
 	}
 	if resp.StatusCode == 409 {
 		return nil, ConflictError(fmt.Errorf("body: %s", string(rb)))
+	} else if resp.StatusCode == 404 {
+		return nil, NotFoundError{body: string(rb)}
 	} else if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		return nil, fmt.Errorf("response has status \"%s\" and body \"%s\"", resp.Status, string(rb))
 	}
@@ -119,6 +138,14 @@ func (c *Client) requestRetry(r *request) ([]byte, error) {
 }
 
 func (c *Client) doRequest(method, urlPath string, query map[string]string, body interface{}) (*http.Response, error) {
+	req, err := c.newRequest(method, urlPath, query, body)
+	if err != nil {
+		return nil, err
+	}
+	return c.client.Do(req)
+}
+
+func (c *Client) newRequest(method, urlPath string, query map[string]string, body interface{}) (*http.Request, error) {
 	url := c.baseURL + urlPath
 	var buf io.Reader
 	if body != nil {
@@ -132,7 +159,9 @@ func (c *Client) doRequest(method, urlPath string, query map[string]string, body
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Authorization", "Bearer "+c.token)
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
 	if method == http.MethodPatch {
 		req.Header.Set("Content-Type", "application/strategic-merge-patch+json")
 	} else {
@@ -144,8 +173,7 @@ func (c *Client) doRequest(method, urlPath string, query map[string]string, body
 		q.Add(k, v)
 	}
 	req.URL.RawQuery = q.Encode()
-
-	return c.client.Do(req)
+	return req, nil
 }
 
 // NewFakeClient creates a client that doesn't do anything.
@@ -156,6 +184,16 @@ func NewFakeClient() *Client {
 	}
 }
 
+// NewClient creates a Client for the apiserver at baseURL that doesn't
+// authenticate, such as a fake apiserver in tests or one behind kubectl proxy.
+func NewClient(baseURL, namespace string) *Client {
+	return &Client{
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		client:    &http.Client{},
+		namespace: namespace,
+	}
+}
+
 // NewClientInCluster creates a Client that works from within a pod.
 func NewClientInCluster(namespace string) (*Client, error) {
 	tokenFile := "/var/run/secrets/kubernetes.io/serviceaccount/token"
@@ -208,15 +246,23 @@ func (c *Client) GetPod(name string) (Pod, error) {
 
 func (c *Client) ListPods(labels map[string]string) ([]Pod, error) {
 	c.log("ListPods", labels)
+	pods, _, err := c.listPods(labels)
+	return pods, err
+}
+
+// listPods returns the pods along with the resource version of the list,
+// which may be used to start a watch.
+func (c *Client) listPods(labels map[string]string) ([]Pod, string, error) {
 	var pl struct {
-		Items []Pod `json:"items"`
+		Metadata listMeta `json:"metadata"`
+		Items    []Pod    `json:"items"`
 	}
 	err := c.request(&request{
 		method: http.MethodGet,
 		path:   fmt.Sprintf("/api/v1/namespaces/%s/pods", c.namespace),
 		query:  map[string]string{"labelSelector": labelsToSelector(labels)},
 	}, &pl)
-	return pl.Items, err
+	return pl.Items, pl.Metadata.ResourceVersion, err
 }
 
 func (c *Client) DeletePod(name string) error {
@@ -250,15 +296,23 @@ func (c *Client) GetProwJob(name string) (ProwJob, error) {
 
 func (c *Client) ListProwJobs(labels map[string]string) ([]ProwJob, error) {
 	c.log("ListProwJobs", labels)
+	pjs, _, err := c.listProwJobs(labels)
+	return pjs, err
+}
+
+// listProwJobs returns the prow jobs along with the resource version of the
+// list, which may be used to start a watch.
+func (c *Client) listProwJobs(labels map[string]string) ([]ProwJob, string, error) {
 	var jl struct {
-		Items []ProwJob `json:"items"`
+		Metadata listMeta  `json:"metadata"`
+		Items    []ProwJob `json:"items"`
 	}
 	err := c.request(&request{
 		method: http.MethodGet,
 		path:   fmt.Sprintf("/apis/prow.k8s.io/v1/namespaces/%s/prowjobs", c.namespace),
 		query:  map[string]string{"labelSelector": labelsToSelector(labels)},
 	}, &jl)
-	return jl.Items, err
+	return jl.Items, jl.Metadata.ResourceVersion, err
 }
 
 func (c *Client) DeleteProwJob(name string) error {
@@ -372,3 +426,66 @@ func (c *Client) GetLog(pod string) ([]byte, error) {
 		path:   fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/log", c.namespace, pod),
 	})
 }
+
+// LogOptions select which part of a pod's log to stream.
+type LogOptions struct {
+	// Follow keeps the stream open until the container exits.
+	Follow bool
+	// TailLines starts the log that many lines from the end. Zero means
+	// the whole log.
+	TailLines int
+}
+
+// StreamLog opens the log of the pod. The caller must close it. Closing stop
+// ends the stream early.
+func (c *Client) StreamLog(pod string, opts LogOptions, stop <-chan struct{}) (io.ReadCloser, error) {
+	c.log("StreamLog", pod, opts)
+	if c.fake {
+		return ioutil.NopCloser(strings.NewReader("")), nil
+	}
+	query := map[string]string{}
+	if opts.Follow {
+		query["follow"] = "true"
+	}
+	if opts.TailLines > 0 {
+		query["tailLines"] = strconv.Itoa(opts.TailLines)
+	}
+	req, err := c.newRequest(http.MethodGet, fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/log", c.namespace, pod), query, nil)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer cancel()
+		defer resp.Body.Close()
+		rb, _ := ioutil.ReadAll(resp.Body)
+		if resp.StatusCode == 404 {
+			return nil, NotFoundError{body: string(rb)}
+		}
+		return nil, fmt.Errorf("response has status \"%s\" and body \"%s\"", resp.Status, string(rb))
+	}
+	return &logStream{ReadCloser: resp.Body, cancel: cancel}, nil
+}
+
+// logStream stops watching for stop once it is closed.
+type logStream struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (s *logStream) Close() error {
+	s.cancel()
+	return s.ReadCloser.Close()
+}
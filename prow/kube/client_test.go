@@ -18,6 +18,7 @@ package kube
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -233,3 +234,70 @@ func TestPatchJobStatus(t *testing.T) {
 		t.Errorf("Didn't expect error: %v", err)
 	}
 }
+
+func TestStreamLog(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/ns/pods/po/log" {
+			t.Errorf("Bad request path: %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("follow") != "true" {
+			t.Errorf("Expected follow, got query %s", r.URL.RawQuery)
+		}
+		if r.URL.Query().Get("tailLines") != "10" {
+			t.Errorf("Expected 10 tail lines, got query %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, "line 1\n")
+		w.(http.Flusher).Flush()
+		fmt.Fprint(w, "line 2\n")
+	}))
+	defer ts.Close()
+	c := getClient(ts.URL)
+	rc, err := c.StreamLog("po", LogOptions{Follow: true, TailLines: 10}, make(chan struct{}))
+	if err != nil {
+		t.Fatalf("Didn't expect error: %v", err)
+	}
+	defer rc.Close()
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("Error reading log: %v", err)
+	}
+	if string(b) != "line 1\nline 2\n" {
+		t.Errorf("Wrong log: %q", string(b))
+	}
+}
+
+func TestStreamLogStop(t *testing.T) {
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "started\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer ts.Close()
+	defer close(done)
+	c := getClient(ts.URL)
+	stop := make(chan struct{})
+	rc, err := c.StreamLog("po", LogOptions{Follow: true}, stop)
+	if err != nil {
+		t.Fatalf("Didn't expect error: %v", err)
+	}
+	defer rc.Close()
+	close(stop)
+	if _, err := ioutil.ReadAll(rc); err == nil {
+		t.Error("Expected the stream to end with an error once stopped.")
+	}
+}
+
+func TestStreamLogNotFound(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such pod", http.StatusNotFound)
+	}))
+	defer ts.Close()
+	c := getClient(ts.URL)
+	if _, err := c.StreamLog("po", LogOptions{}, make(chan struct{})); !IsNotFound(err) {
+		t.Errorf("Expected a NotFoundError, got %v", err)
+	}
+}
@@ -0,0 +1,622 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fakekube provides an in-memory Kubernetes apiserver for testing
+// prow components against.
+package fakekube
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/test-infra/prow/kube"
+)
+
+const (
+	prowJobsPrefix = "/apis/prow.k8s.io/v1/namespaces/"
+	podsPrefix     = "/api/v1/namespaces/"
+)
+
+// Server is a fake apiserver that keeps ProwJobs and Pods in memory. It
+// supports create, get, list, replace, delete and watch with label selectors
+// and resource versions, and rejects stale replaces with 409 Conflict like the
+// real one. It doesn't run pods: they stay Pending until a test moves them
+// along with SetPodPhase. Serve it with httptest and point kube.NewClient at
+// it, then call Close before closing the httptest server to end any watches.
+type Server struct {
+	mut      sync.Mutex
+	rv       int
+	prowJobs map[string]kube.ProwJob
+	pods     map[string]kube.Pod
+	logs     map[string]string
+	// history holds every change after the resource version compacted.
+	history   []event
+	compacted int
+	// changed is closed and replaced whenever something changes.
+	changed chan struct{}
+	closed  chan struct{}
+}
+
+type event struct {
+	resource  string
+	namespace string
+	labels    map[string]string
+	eventType kube.EventType
+	rv        int
+	object    interface{}
+}
+
+// NewServer returns an apiserver without any objects.
+func NewServer() *Server {
+	return &Server{
+		prowJobs: map[string]kube.ProwJob{},
+		pods:     map[string]kube.Pod{},
+		logs:     map[string]string{},
+		changed:  make(chan struct{}),
+		closed:   make(chan struct{}),
+	}
+}
+
+// Close ends all watches, now and in the future.
+func (s *Server) Close() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	select {
+	case <-s.closed:
+	default:
+		close(s.closed)
+	}
+}
+
+// Compact forgets past changes, so that watches from any earlier resource
+// version fail with 410 Gone and their callers must list again.
+func (s *Server) Compact() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.history = nil
+	s.compacted = s.rv
+}
+
+// ProwJob returns the ProwJob, if it exists.
+func (s *Server) ProwJob(namespace, name string) (kube.ProwJob, bool) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	pj, ok := s.prowJobs[namespace+"/"+name]
+	return pj, ok
+}
+
+// ProwJobs returns every ProwJob in the namespace, sorted by name.
+func (s *Server) ProwJobs(namespace string) []kube.ProwJob {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	var pjs []kube.ProwJob
+	for _, k := range sortedKeys(s.prowJobs) {
+		if pj := s.prowJobs[k]; pj.Metadata.Namespace == namespace {
+			pjs = append(pjs, pj)
+		}
+	}
+	return pjs
+}
+
+// Pod returns the pod, if it exists.
+func (s *Server) Pod(namespace, name string) (kube.Pod, bool) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	pod, ok := s.pods[namespace+"/"+name]
+	return pod, ok
+}
+
+// SetPodPhase moves a pod to the given phase. Pods only move forward, from
+// Pending to Running to Succeeded or Failed, and may skip Running. Each
+// container is marked running or terminated to match.
+func (s *Server) SetPodPhase(namespace, name string, phase kube.PodPhase) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	key := namespace + "/" + name
+	pod, ok := s.pods[key]
+	if !ok {
+		return fmt.Errorf("no pod %s", key)
+	}
+	if phaseOrder(phase) <= phaseOrder(pod.Status.Phase) {
+		return fmt.Errorf("pod %s can't go from %s to %s", key, pod.Status.Phase, phase)
+	}
+	now := time.Now()
+	if pod.Status.StartTime.IsZero() {
+		pod.Status.StartTime = now
+	}
+	pod.Status.Phase = phase
+	pod.Status.ContainerStatuses = nil
+	for _, c := range pod.Spec.Containers {
+		cs := kube.ContainerStatus{Name: c.Name, Image: c.Image}
+		switch phase {
+		case kube.PodRunning:
+			cs.Ready = true
+			cs.State.Running = &kube.ContainerStateRunning{StartedAt: pod.Status.StartTime}
+		case kube.PodSucceeded, kube.PodFailed:
+			exitCode := 0
+			if phase == kube.PodFailed {
+				exitCode = 1
+			}
+			cs.State.Terminated = &kube.ContainerStateTerminated{
+				ExitCode:   exitCode,
+				StartedAt:  pod.Status.StartTime,
+				FinishedAt: now,
+			}
+		}
+		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, cs)
+	}
+	s.putPod(kube.Modified, pod)
+	return nil
+}
+
+func phaseOrder(phase kube.PodPhase) int {
+	switch phase {
+	case kube.PodPending:
+		return 0
+	case kube.PodRunning:
+		return 1
+	case kube.PodSucceeded, kube.PodFailed:
+		return 2
+	}
+	return -1
+}
+
+// AppendPodLog adds to the log of the pod, which is served once the pod is
+// no longer Pending.
+func (s *Server) AppendPodLog(namespace, name, log string) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	key := namespace + "/" + name
+	if _, ok := s.pods[key]; !ok {
+		return fmt.Errorf("no pod %s", key)
+	}
+	s.logs[key] += log
+	return nil
+}
+
+// record notes a change for watches. The caller must hold the lock.
+func (s *Server) record(e event) {
+	s.history = append(s.history, e)
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *Server) putProwJob(t kube.EventType, pj kube.ProwJob) kube.ProwJob {
+	s.rv++
+	pj.Metadata.ResourceVersion = strconv.Itoa(s.rv)
+	key := pj.Metadata.Namespace + "/" + pj.Metadata.Name
+	if t == kube.Deleted {
+		delete(s.prowJobs, key)
+	} else {
+		s.prowJobs[key] = pj
+	}
+	s.record(event{"prowjobs", pj.Metadata.Namespace, pj.Metadata.Labels, t, s.rv, pj})
+	return pj
+}
+
+func (s *Server) putPod(t kube.EventType, pod kube.Pod) kube.Pod {
+	s.rv++
+	pod.Metadata.ResourceVersion = strconv.Itoa(s.rv)
+	key := pod.Metadata.Namespace + "/" + pod.Metadata.Name
+	if t == kube.Deleted {
+		delete(s.pods, key)
+		delete(s.logs, key)
+	} else {
+		s.pods[key] = pod
+	}
+	s.record(event{"pods", pod.Metadata.Namespace, pod.Metadata.Labels, t, s.rv, pod})
+	return pod
+}
+
+// status is the body of an error response, and of ERROR watch events.
+type status struct {
+	Kind    string `json:"kind"`
+	Code    int    `json:"code"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+func writeError(w http.ResponseWriter, code int, format string, args ...interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status{
+		Kind:    "Status",
+		Code:    code,
+		Reason:  strings.Replace(http.StatusText(code), " ", "", -1),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// ServeHTTP serves the ProwJob and Pod APIs.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var resource, rest string
+	switch {
+	case strings.HasPrefix(r.URL.Path, prowJobsPrefix):
+		resource, rest = "prowjobs", strings.TrimPrefix(r.URL.Path, prowJobsPrefix)
+	case strings.HasPrefix(r.URL.Path, podsPrefix):
+		resource, rest = "pods", strings.TrimPrefix(r.URL.Path, podsPrefix)
+	default:
+		writeError(w, http.StatusNotFound, "unknown path %s", r.URL.Path)
+		return
+	}
+	// namespace/resource[/name[/log]]
+	parts := strings.Split(rest, "/")
+	if len(parts) < 2 || len(parts) > 4 || parts[1] != resource || parts[0] == "" {
+		writeError(w, http.StatusNotFound, "unknown path %s", r.URL.Path)
+		return
+	}
+	namespace := parts[0]
+	selector, err := parseSelector(r.URL.Query().Get("labelSelector"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	switch {
+	case len(parts) == 2 && r.Method == http.MethodGet && r.URL.Query().Get("watch") == "true":
+		s.watch(w, r, resource, namespace, selector)
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		s.list(w, resource, namespace, selector)
+	case len(parts) == 2 && r.Method == http.MethodPost:
+		s.create(w, r, resource, namespace)
+	case len(parts) == 3 && r.Method == http.MethodGet:
+		s.get(w, resource, namespace, parts[2])
+	case len(parts) == 3 && r.Method == http.MethodPut && resource == "prowjobs":
+		s.replaceProwJob(w, r, namespace, parts[2])
+	case len(parts) == 3 && r.Method == http.MethodDelete:
+		s.delete(w, resource, namespace, parts[2])
+	case len(parts) == 4 && parts[3] == "log" && r.Method == http.MethodGet && resource == "pods":
+		s.log(w, namespace, parts[2])
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "%s %s is not supported", r.Method, r.URL.Path)
+	}
+}
+
+func (s *Server) list(w http.ResponseWriter, resource, namespace string, selector labelSelector) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	var items []interface{}
+	if resource == "prowjobs" {
+		for _, k := range sortedKeys(s.prowJobs) {
+			pj := s.prowJobs[k]
+			if pj.Metadata.Namespace == namespace && selector.matches(pj.Metadata.Labels) {
+				items = append(items, pj)
+			}
+		}
+	} else {
+		for _, k := range sortedKeys(s.pods) {
+			pod := s.pods[k]
+			if pod.Metadata.Namespace == namespace && selector.matches(pod.Metadata.Labels) {
+				items = append(items, pod)
+			}
+		}
+	}
+	if items == nil {
+		items = []interface{}{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"metadata": map[string]string{"resourceVersion": strconv.Itoa(s.rv)},
+		"items":    items,
+	})
+}
+
+func (s *Server) create(w http.ResponseWriter, r *http.Request, resource, namespace string) {
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error reading body: %v", err)
+		return
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if resource == "prowjobs" {
+		var pj kube.ProwJob
+		if err := json.Unmarshal(b, &pj); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid prow job: %v", err)
+			return
+		}
+		if !s.prepareCreate(w, &pj.Metadata, namespace, s.prowJobs[namespace+"/"+pj.Metadata.Name].Metadata.Name != "") {
+			return
+		}
+		writeJSON(w, http.StatusCreated, s.putProwJob(kube.Added, pj))
+		return
+	}
+	var pod kube.Pod
+	if err := json.Unmarshal(b, &pod); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid pod: %v", err)
+		return
+	}
+	if !s.prepareCreate(w, &pod.Metadata, namespace, s.pods[namespace+"/"+pod.Metadata.Name].Metadata.Name != "") {
+		return
+	}
+	pod.Status = kube.PodStatus{Phase: kube.PodPending}
+	writeJSON(w, http.StatusCreated, s.putPod(kube.Added, pod))
+}
+
+// prepareCreate validates the metadata of a new object and fills it in. It
+// writes the error and returns false if the object can't be created.
+func (s *Server) prepareCreate(w http.ResponseWriter, meta *kube.ObjectMeta, namespace string, exists bool) bool {
+	if meta.Name == "" {
+		writeError(w, http.StatusUnprocessableEntity, "name is required")
+		return false
+	}
+	if meta.Namespace != "" && meta.Namespace != namespace {
+		writeError(w, http.StatusBadRequest, "namespace %s doesn't match %s", meta.Namespace, namespace)
+		return false
+	}
+	if exists {
+		writeError(w, http.StatusConflict, "%s already exists", meta.Name)
+		return false
+	}
+	meta.Namespace = namespace
+	meta.UID = fmt.Sprintf("uid-%d", s.rv+1)
+	return true
+}
+
+func (s *Server) get(w http.ResponseWriter, resource, namespace, name string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	key := namespace + "/" + name
+	if resource == "prowjobs" {
+		if pj, ok := s.prowJobs[key]; ok {
+			writeJSON(w, http.StatusOK, pj)
+			return
+		}
+	} else if pod, ok := s.pods[key]; ok {
+		writeJSON(w, http.StatusOK, pod)
+		return
+	}
+	writeError(w, http.StatusNotFound, "%s %s not found", resource, name)
+}
+
+func (s *Server) replaceProwJob(w http.ResponseWriter, r *http.Request, namespace, name string) {
+	var pj kube.ProwJob
+	if err := json.NewDecoder(r.Body).Decode(&pj); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid prow job: %v", err)
+		return
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	old, ok := s.prowJobs[namespace+"/"+name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "prowjobs %s not found", name)
+		return
+	}
+	if pj.Metadata.Name != name {
+		writeError(w, http.StatusBadRequest, "name %s doesn't match %s", pj.Metadata.Name, name)
+		return
+	}
+	// An empty resource version means to replace unconditionally.
+	if rv := pj.Metadata.ResourceVersion; rv != "" && rv != old.Metadata.ResourceVersion {
+		writeError(w, http.StatusConflict, "prowjobs %s has been modified, it is at %s, not %s", name, old.Metadata.ResourceVersion, rv)
+		return
+	}
+	pj.Metadata.Namespace = namespace
+	pj.Metadata.UID = old.Metadata.UID
+	writeJSON(w, http.StatusOK, s.putProwJob(kube.Modified, pj))
+}
+
+func (s *Server) delete(w http.ResponseWriter, resource, namespace, name string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	key := namespace + "/" + name
+	if resource == "prowjobs" {
+		if pj, ok := s.prowJobs[key]; ok {
+			s.putProwJob(kube.Deleted, pj)
+			writeJSON(w, http.StatusOK, status{Kind: "Status", Code: http.StatusOK})
+			return
+		}
+	} else if pod, ok := s.pods[key]; ok {
+		s.putPod(kube.Deleted, pod)
+		writeJSON(w, http.StatusOK, status{Kind: "Status", Code: http.StatusOK})
+		return
+	}
+	writeError(w, http.StatusNotFound, "%s %s not found", resource, name)
+}
+
+func (s *Server) log(w http.ResponseWriter, namespace, name string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	key := namespace + "/" + name
+	pod, ok := s.pods[key]
+	if !ok {
+		writeError(w, http.StatusNotFound, "pods %s not found", name)
+		return
+	}
+	if pod.Status.Phase == kube.PodPending {
+		writeError(w, http.StatusBadRequest, "pod %s is waiting to start", name)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprint(w, s.logs[key])
+}
+
+// watch streams the matching changes after the requested resource version
+// until the client goes away, timeoutSeconds pass, or the server is closed.
+// Without a resource version it starts with an ADDED event for every
+// matching object, like the real apiserver.
+func (s *Server) watch(w http.ResponseWriter, r *http.Request, resource, namespace string, selector labelSelector) {
+	q := r.URL.Query()
+	var timeout <-chan time.Time
+	if secs, err := strconv.Atoi(q.Get("timeoutSeconds")); err == nil && secs > 0 {
+		timer := time.NewTimer(time.Duration(secs) * time.Second)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	s.mut.Lock()
+	var pending []event
+	var rv int
+	if v := q.Get("resourceVersion"); v == "" || v == "0" {
+		rv = s.rv
+		if resource == "prowjobs" {
+			for _, k := range sortedKeys(s.prowJobs) {
+				pj := s.prowJobs[k]
+				pending = append(pending, event{resource, pj.Metadata.Namespace, pj.Metadata.Labels, kube.Added, rv, pj})
+			}
+		} else {
+			for _, k := range sortedKeys(s.pods) {
+				pod := s.pods[k]
+				pending = append(pending, event{resource, pod.Metadata.Namespace, pod.Metadata.Labels, kube.Added, rv, pod})
+			}
+		}
+	} else {
+		var err error
+		if rv, err = strconv.Atoi(v); err != nil {
+			s.mut.Unlock()
+			writeError(w, http.StatusBadRequest, "invalid resourceVersion %q", v)
+			return
+		}
+		if rv < s.compacted {
+			s.mut.Unlock()
+			writeError(w, http.StatusGone, "too old resource version: %d (%d)", rv, s.compacted)
+			return
+		}
+	}
+	s.mut.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	for {
+		for _, e := range pending {
+			if e.resource != resource || e.namespace != namespace || !selector.matches(e.labels) {
+				continue
+			}
+			if err := enc.Encode(map[string]interface{}{"type": e.eventType, "object": e.object}); err != nil {
+				return
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		s.mut.Lock()
+		pending = nil
+		for _, e := range s.history {
+			if e.rv > rv {
+				pending = append(pending, e)
+				rv = e.rv
+			}
+		}
+		changed := s.changed
+		s.mut.Unlock()
+		if len(pending) > 0 {
+			continue
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		case <-s.closed:
+			return
+		case <-timeout:
+			return
+		}
+	}
+}
+
+// labelSelector is a parsed labelSelector query, such as "a = b,c != d,e".
+type labelSelector []requirement
+
+type requirement struct {
+	key    string
+	value  string
+	op     string
+	exists bool
+}
+
+func parseSelector(sel string) (labelSelector, error) {
+	var ls labelSelector
+	for _, term := range strings.Split(sel, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		var r requirement
+		switch {
+		case strings.Contains(term, "!="):
+			parts := strings.SplitN(term, "!=", 2)
+			r = requirement{key: parts[0], value: parts[1], op: "!="}
+		case strings.Contains(term, "=="):
+			parts := strings.SplitN(term, "==", 2)
+			r = requirement{key: parts[0], value: parts[1], op: "="}
+		case strings.Contains(term, "="):
+			parts := strings.SplitN(term, "=", 2)
+			r = requirement{key: parts[0], value: parts[1], op: "="}
+		case strings.HasPrefix(term, "!"):
+			r = requirement{key: term[1:], op: "exists", exists: false}
+		default:
+			r = requirement{key: term, op: "exists", exists: true}
+		}
+		r.key = strings.TrimSpace(r.key)
+		r.value = strings.TrimSpace(r.value)
+		if r.key == "" {
+			return nil, fmt.Errorf("invalid label selector term %q", term)
+		}
+		ls = append(ls, r)
+	}
+	return ls, nil
+}
+
+func (ls labelSelector) matches(labels map[string]string) bool {
+	for _, r := range ls {
+		v, ok := labels[r.key]
+		switch r.op {
+		case "=":
+			if !ok || v != r.value {
+				return false
+			}
+		case "!=":
+			if ok && v == r.value {
+				return false
+			}
+		case "exists":
+			if ok != r.exists {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]kube.ProwJob:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]kube.Pod:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,246 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fakekube
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/test-infra/prow/kube"
+)
+
+func newClient(t *testing.T) (*Server, *kube.Client, func()) {
+	s := NewServer()
+	ts := httptest.NewServer(s)
+	return s, kube.NewClient(ts.URL, "ns"), func() {
+		s.Close()
+		ts.Close()
+	}
+}
+
+func prowJob(name string, labels map[string]string) kube.ProwJob {
+	return kube.ProwJob{Metadata: kube.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func TestProwJobs(t *testing.T) {
+	_, c, done := newClient(t)
+	defer done()
+
+	a, err := c.CreateProwJob(prowJob("a", map[string]string{"type": "presubmit"}))
+	if err != nil {
+		t.Fatalf("Error creating a: %v", err)
+	}
+	if a.Metadata.Namespace != "ns" || a.Metadata.ResourceVersion == "" || a.Metadata.UID == "" {
+		t.Errorf("Expected namespace, resource version and UID to be set, got %+v.", a.Metadata)
+	}
+	if _, err := c.CreateProwJob(prowJob("b", map[string]string{"type": "periodic"})); err != nil {
+		t.Fatalf("Error creating b: %v", err)
+	}
+	if _, err := c.CreateProwJob(prowJob("a", nil)); err == nil {
+		t.Errorf("Expected an error creating a again.")
+	}
+
+	testcases := []struct {
+		name     string
+		labels   map[string]string
+		expected []string
+	}{
+		{"all", nil, []string{"a", "b"}},
+		{"match", map[string]string{"type": "periodic"}, []string{"b"}},
+		{"no match", map[string]string{"type": "batch"}, nil},
+	}
+	for _, tc := range testcases {
+		pjs, err := c.ListProwJobs(tc.labels)
+		if err != nil {
+			t.Errorf("For case %s, error listing: %v", tc.name, err)
+			continue
+		}
+		var names []string
+		for _, pj := range pjs {
+			names = append(names, pj.Metadata.Name)
+		}
+		if !reflect.DeepEqual(names, tc.expected) {
+			t.Errorf("For case %s, expected %v, got %v.", tc.name, tc.expected, names)
+		}
+	}
+
+	a.Status.State = kube.PendingState
+	updated, err := c.ReplaceProwJob("a", a)
+	if err != nil {
+		t.Fatalf("Error replacing a: %v", err)
+	}
+	if updated.Metadata.ResourceVersion == a.Metadata.ResourceVersion {
+		t.Errorf("Expected the resource version to change.")
+	}
+	// a is now stale.
+	a.Status.State = kube.SuccessState
+	if _, err := c.ReplaceProwJob("a", a); err == nil {
+		t.Errorf("Expected a conflict replacing a stale prow job.")
+	} else if _, ok := err.(kube.ConflictError); !ok {
+		t.Errorf("Expected a ConflictError, got %v.", err)
+	}
+	if got, err := c.GetProwJob("a"); err != nil || got.Status.State != kube.PendingState {
+		t.Errorf("Expected a to be pending, got %+v, %v.", got.Status, err)
+	}
+
+	if err := c.DeleteProwJob("a"); err != nil {
+		t.Errorf("Error deleting a: %v", err)
+	}
+	if _, err := c.GetProwJob("a"); !kube.IsNotFound(err) {
+		t.Errorf("Expected a to be gone, got %v.", err)
+	}
+	if err := c.DeleteProwJob("a"); !kube.IsNotFound(err) {
+		t.Errorf("Expected not found deleting a twice, got %v.", err)
+	}
+}
+
+func TestPods(t *testing.T) {
+	s, c, done := newClient(t)
+	defer done()
+
+	pod, err := c.CreatePod(kube.Pod{
+		Metadata: kube.ObjectMeta{Name: "po"},
+		Spec:     kube.PodSpec{Containers: []kube.Container{{Name: "test"}}},
+	})
+	if err != nil {
+		t.Fatalf("Error creating pod: %v", err)
+	}
+	if pod.Status.Phase != kube.PodPending {
+		t.Errorf("Expected a new pod to be pending, got %s.", pod.Status.Phase)
+	}
+	if _, err := c.GetLog("po"); err == nil {
+		t.Errorf("Expected an error getting the log of a pending pod.")
+	}
+
+	if err := s.SetPodPhase("ns", "po", kube.PodRunning); err != nil {
+		t.Fatalf("Error starting pod: %v", err)
+	}
+	if err := s.AppendPodLog("ns", "po", "hello\n"); err != nil {
+		t.Fatalf("Error writing log: %v", err)
+	}
+	if err := s.AppendPodLog("ns", "po", "world\n"); err != nil {
+		t.Fatalf("Error writing log: %v", err)
+	}
+	if log, err := c.GetLog("po"); err != nil || string(log) != "hello\nworld\n" {
+		t.Errorf("Expected the log, got %q, %v.", log, err)
+	}
+	if err := s.SetPodPhase("ns", "po", kube.PodFailed); err != nil {
+		t.Fatalf("Error failing pod: %v", err)
+	}
+	if err := s.SetPodPhase("ns", "po", kube.PodSucceeded); err == nil {
+		t.Errorf("Expected an error moving a failed pod to succeeded.")
+	}
+	pod, err = c.GetPod("po")
+	if err != nil {
+		t.Fatalf("Error getting pod: %v", err)
+	}
+	if pod.Status.Phase != kube.PodFailed || pod.Status.StartTime.IsZero() {
+		t.Errorf("Expected a started, failed pod, got %+v.", pod.Status)
+	}
+	if cs := pod.Status.ContainerStatuses; len(cs) != 1 || cs[0].State.Terminated == nil || cs[0].State.Terminated.ExitCode != 1 {
+		t.Errorf("Expected the container to have exited with 1, got %+v.", cs)
+	}
+
+	if err := c.DeletePod("po"); err != nil {
+		t.Errorf("Error deleting pod: %v", err)
+	}
+	if pods, err := c.ListPods(nil); err != nil || len(pods) != 0 {
+		t.Errorf("Expected no pods, got %v, %v.", pods, err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	s, c, done := newClient(t)
+	defer done()
+
+	if _, err := c.CreateProwJob(prowJob("a", nil)); err != nil {
+		t.Fatalf("Error creating a: %v", err)
+	}
+	ca := kube.NewCache(c)
+	stop := make(chan struct{})
+	defer close(stop)
+	if err := ca.Start(stop); err != nil {
+		t.Fatalf("Error starting cache: %v", err)
+	}
+	// Only watch events from here on.
+	events := make(chan kube.ProwJobEvent, 10)
+	ca.AddProwJobHandler(func(e kube.ProwJobEvent) { events <- e })
+
+	b, err := c.CreateProwJob(prowJob("b", nil))
+	if err != nil {
+		t.Fatalf("Error creating b: %v", err)
+	}
+	b.Status.State = kube.SuccessState
+	if _, err := c.ReplaceProwJob("b", b); err != nil {
+		t.Fatalf("Error replacing b: %v", err)
+	}
+	if err := c.DeleteProwJob("a"); err != nil {
+		t.Fatalf("Error deleting a: %v", err)
+	}
+	expected := []string{"ADDED b", "MODIFIED b", "DELETED a"}
+	for _, ex := range expected {
+		select {
+		case e := <-events:
+			if got := string(e.Type) + " " + e.ProwJob.Metadata.Name; got != ex {
+				t.Errorf("Expected %s, got %s.", ex, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %s.", ex)
+		}
+	}
+	if pjs, _ := ca.ListProwJobs(nil); len(pjs) != 1 || pjs[0].Status.State != kube.SuccessState {
+		t.Errorf("Expected the cache to hold successful b, got %+v.", pjs)
+	}
+
+	// Watches from before a compaction have to list again.
+	s.Compact()
+	err = c.WatchProwJobs(nil, b.Metadata.ResourceVersion, stop, func(kube.ProwJobEvent) error { return nil })
+	if err != kube.ErrExpired {
+		t.Errorf("Expected ErrExpired, got %v.", err)
+	}
+}
+
+func TestSelector(t *testing.T) {
+	labels := map[string]string{"a": "1", "b": "2"}
+	testcases := []struct {
+		selector string
+		matches  bool
+	}{
+		{"", true},
+		{"a = 1", true},
+		{"a=1,b==2", true},
+		{"a = 2", false},
+		{"a != 2", true},
+		{"c != 2", true},
+		{"b", true},
+		{"c", false},
+		{"!c", true},
+		{"a=1,c", false},
+	}
+	for _, tc := range testcases {
+		sel, err := parseSelector(tc.selector)
+		if err != nil {
+			t.Errorf("For case %q, error parsing: %v", tc.selector, err)
+			continue
+		}
+		if sel.matches(labels) != tc.matches {
+			t.Errorf("For case %q, expected match %t.", tc.selector, tc.matches)
+		}
+	}
+}
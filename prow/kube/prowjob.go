@@ -64,6 +64,16 @@ type ProwJobSpec struct {
 	Report       bool   `json:"report,omitempty"`
 	Context      string `json:"context,omitempty"`
 	RerunCommand string `json:"rerun_command,omitempty"`
+	// Reporters are the crier reporters that report this job besides
+	// GitHub.
+	Reporters []string `json:"reporters,omitempty"`
+
+	// MaxConcurrency restricts the number of ProwJobs for this job that may
+	// run at once. Zero means no limit.
+	MaxConcurrency int `json:"max_concurrency,omitempty"`
+	// Timeout is how long the job may run before it is aborted. Zero means
+	// the controller's default.
+	Timeout time.Duration `json:"timeout,omitempty"`
 
 	PodSpec PodSpec `json:"pod_spec,omitempty"`
 
@@ -80,6 +90,27 @@ type ProwJobStatus struct {
 	JenkinsQueueURL string       `json:"jenkins_queue_url,omitempty"`
 	JenkinsEnqueued bool         `json:"jenkins_enqueued,omitempty"`
 	JenkinsBuildID  string       `json:"jenkins_build_id,omitempty"`
+	// Retries is how many times the job was restarted after an
+	// infrastructure failure.
+	Retries int `json:"retries,omitempty"`
+	// LastReported is what each crier reporter last reported for this job,
+	// keyed by reporter name, so that each reports every change once.
+	LastReported map[string]Reported `json:"last_reported,omitempty"`
+	// History records every change of state or description, oldest first.
+	History []StatusChange `json:"history,omitempty"`
+}
+
+// StatusChange is an entry in a ProwJob's history.
+type StatusChange struct {
+	Time        time.Time    `json:"time"`
+	State       ProwJobState `json:"state"`
+	Description string       `json:"description,omitempty"`
+}
+
+// Reported is the state and URL of a ProwJob that a reporter reported.
+type Reported struct {
+	State ProwJobState `json:"state,omitempty"`
+	URL   string       `json:"url,omitempty"`
 }
 
 func (j *ProwJob) Complete() bool {
@@ -30,6 +30,10 @@ type ObjectMeta struct {
 	UID             string `json:"uid,omitempty"`
 }
 
+type listMeta struct {
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
 type Secret struct {
 	Metadata ObjectMeta        `json:"metadata,omitempty"`
 	Data     map[string]string `json:"data,omitempty"`
@@ -101,6 +105,57 @@ type PodStatus struct {
 	Message   string    `json:"message,omitempty"`
 	Reason    string    `json:"reason,omitempty"`
 	StartTime time.Time `json:"startTime,omitempty"`
+
+	Conditions            []PodCondition    `json:"conditions,omitempty"`
+	InitContainerStatuses []ContainerStatus `json:"initContainerStatuses,omitempty"`
+	ContainerStatuses     []ContainerStatus `json:"containerStatuses,omitempty"`
+}
+
+type PodConditionType string
+
+const (
+	PodScheduled PodConditionType = "PodScheduled"
+	PodReady     PodConditionType = "Ready"
+)
+
+type PodCondition struct {
+	Type               PodConditionType `json:"type"`
+	Status             string           `json:"status"`
+	LastTransitionTime time.Time        `json:"lastTransitionTime,omitempty"`
+	Reason             string           `json:"reason,omitempty"`
+	Message            string           `json:"message,omitempty"`
+}
+
+type ContainerStatus struct {
+	Name         string         `json:"name"`
+	State        ContainerState `json:"state,omitempty"`
+	Ready        bool           `json:"ready"`
+	RestartCount int            `json:"restartCount"`
+	Image        string         `json:"image"`
+}
+
+// ContainerState holds exactly one of its fields.
+type ContainerState struct {
+	Waiting    *ContainerStateWaiting    `json:"waiting,omitempty"`
+	Running    *ContainerStateRunning    `json:"running,omitempty"`
+	Terminated *ContainerStateTerminated `json:"terminated,omitempty"`
+}
+
+type ContainerStateWaiting struct {
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type ContainerStateRunning struct {
+	StartedAt time.Time `json:"startedAt,omitempty"`
+}
+
+type ContainerStateTerminated struct {
+	ExitCode   int       `json:"exitCode"`
+	Reason     string    `json:"reason,omitempty"`
+	Message    string    `json:"message,omitempty"`
+	StartedAt  time.Time `json:"startedAt,omitempty"`
+	FinishedAt time.Time `json:"finishedAt,omitempty"`
 }
 
 type Volume struct {
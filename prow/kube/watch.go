@@ -0,0 +1,167 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+// watchTimeout is how long we ask the apiserver to keep a watch open. When it
+// closes we pick up again from the last resource version we saw.
+const watchTimeout = 5 * 60
+
+// ErrExpired is returned from a watch when the resource version it started
+// from is too old. The caller must list again to get a fresh version.
+var ErrExpired = errors.New("resource version expired")
+
+type EventType string
+
+const (
+	Added    EventType = "ADDED"
+	Modified EventType = "MODIFIED"
+	Deleted  EventType = "DELETED"
+
+	errorEvent EventType = "ERROR"
+)
+
+// ProwJobEvent is a single change to a ProwJob.
+type ProwJobEvent struct {
+	Type    EventType
+	ProwJob ProwJob
+}
+
+// PodEvent is a single change to a Pod.
+type PodEvent struct {
+	Type EventType
+	Pod  Pod
+}
+
+type watchEvent struct {
+	Type   EventType       `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// status is what the apiserver sends in ERROR events.
+type status struct {
+	Code    int    `json:"code"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// WatchProwJobs calls fn for every change to a matching ProwJob after
+// resourceVersion. It returns nil when the apiserver closes the watch or stop
+// is closed, ErrExpired if resourceVersion is too old, and otherwise the first
+// error that fn returns.
+func (c *Client) WatchProwJobs(labels map[string]string, resourceVersion string, stop <-chan struct{}, fn func(ProwJobEvent) error) error {
+	c.log("WatchProwJobs", labels, resourceVersion)
+	return c.watch(fmt.Sprintf("/apis/prow.k8s.io/v1/namespaces/%s/prowjobs", c.namespace), labels, resourceVersion, stop, func(t EventType, o json.RawMessage) error {
+		var pj ProwJob
+		if err := json.Unmarshal(o, &pj); err != nil {
+			return fmt.Errorf("error unmarshaling prow job: %v", err)
+		}
+		return fn(ProwJobEvent{Type: t, ProwJob: pj})
+	})
+}
+
+// WatchPods calls fn for every change to a matching Pod after
+// resourceVersion. It behaves like WatchProwJobs.
+func (c *Client) WatchPods(labels map[string]string, resourceVersion string, stop <-chan struct{}, fn func(PodEvent) error) error {
+	c.log("WatchPods", labels, resourceVersion)
+	return c.watch(fmt.Sprintf("/api/v1/namespaces/%s/pods", c.namespace), labels, resourceVersion, stop, func(t EventType, o json.RawMessage) error {
+		var pod Pod
+		if err := json.Unmarshal(o, &pod); err != nil {
+			return fmt.Errorf("error unmarshaling pod: %v", err)
+		}
+		return fn(PodEvent{Type: t, Pod: pod})
+	})
+}
+
+func (c *Client) watch(path string, labels map[string]string, resourceVersion string, stop <-chan struct{}, fn func(EventType, json.RawMessage) error) error {
+	if c.fake {
+		<-stop
+		return nil
+	}
+	req, err := c.newRequest(http.MethodGet, path, map[string]string{
+		"watch":           "true",
+		"labelSelector":   labelsToSelector(labels),
+		"resourceVersion": resourceVersion,
+		"timeoutSeconds":  strconv.Itoa(watchTimeout),
+	}, nil)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		select {
+		case <-stop:
+			return nil
+		default:
+			return err
+		}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusGone {
+		return ErrExpired
+	} else if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		rb, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("response has status \"%s\" and body \"%s\"", resp.Status, string(rb))
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var e watchEvent
+		if err := dec.Decode(&e); err == io.EOF {
+			return nil
+		} else if err != nil {
+			select {
+			case <-stop:
+				return nil
+			default:
+				return fmt.Errorf("error decoding watch event: %v", err)
+			}
+		}
+		if e.Type == errorEvent {
+			var s status
+			if err := json.Unmarshal(e.Object, &s); err != nil {
+				return fmt.Errorf("error unmarshaling watch error: %v", err)
+			}
+			if s.Code == http.StatusGone {
+				return ErrExpired
+			}
+			return fmt.Errorf("watch error %d (%s): %s", s.Code, s.Reason, s.Message)
+		}
+		if err := fn(e.Type, e.Object); err != nil {
+			return err
+		}
+	}
+}
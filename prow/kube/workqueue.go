@@ -0,0 +1,99 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"sync"
+)
+
+// WorkQueue is a FIFO of object names to sync, for controllers that react to
+// cache events. A name is only ever in the queue once. If a name is added
+// while it is being synced, it is queued again once the sync is done so that
+// we never miss the latest change.
+type WorkQueue struct {
+	cond *sync.Cond
+
+	items      []string
+	queued     map[string]bool
+	processing map[string]bool
+	dirty      map[string]bool
+	closed     bool
+}
+
+// NewWorkQueue returns an empty queue.
+func NewWorkQueue() *WorkQueue {
+	return &WorkQueue{
+		cond:       sync.NewCond(&sync.Mutex{}),
+		queued:     map[string]bool{},
+		processing: map[string]bool{},
+		dirty:      map[string]bool{},
+	}
+}
+
+// Add queues the name unless it is already queued.
+func (q *WorkQueue) Add(name string) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if q.closed || q.queued[name] {
+		return
+	}
+	if q.processing[name] {
+		q.dirty[name] = true
+		return
+	}
+	q.queued[name] = true
+	q.items = append(q.items, name)
+	q.cond.Signal()
+}
+
+// Get blocks until there is a name to sync. It returns false once the queue
+// has been shut down.
+func (q *WorkQueue) Get() (string, bool) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	for len(q.items) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return "", false
+	}
+	name := q.items[0]
+	q.items = q.items[1:]
+	delete(q.queued, name)
+	q.processing[name] = true
+	return name, true
+}
+
+// Done must be called after each successful Get.
+func (q *WorkQueue) Done(name string) {
+	q.cond.L.Lock()
+	delete(q.processing, name)
+	requeue := q.dirty[name]
+	delete(q.dirty, name)
+	q.cond.L.Unlock()
+	if requeue {
+		q.Add(name)
+	}
+}
+
+// ShutDown makes Get return false.
+func (q *WorkQueue) ShutDown() {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"testing"
+)
+
+func TestWorkQueue(t *testing.T) {
+	q := NewWorkQueue()
+	q.Add("a")
+	q.Add("b")
+	q.Add("a")
+	name, ok := q.Get()
+	if !ok || name != "a" {
+		t.Fatalf("Expected a, got %s.", name)
+	}
+	// Adding a while it is being synced should hold it until done.
+	q.Add("a")
+	if name, _ := q.Get(); name != "b" {
+		t.Fatalf("Expected b, got %s.", name)
+	}
+	q.Done("b")
+	q.Done("a")
+	if name, _ := q.Get(); name != "a" {
+		t.Fatalf("Expected a to be requeued, got %s.", name)
+	}
+	q.Done("a")
+	q.ShutDown()
+	if _, ok := q.Get(); ok {
+		t.Fatal("Expected get to fail after shutdown.")
+	}
+}
@@ -23,23 +23,46 @@ import (
 	"strconv"
 	"time"
 
-	"k8s.io/test-infra/prow/crier"
+	"github.com/Sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/jenkins"
 	"k8s.io/test-infra/prow/kube"
 )
 
 const (
-	guberBasePR   = "https://k8s-gubernator.appspot.com/build/kubernetes-jenkins/pr-logs/pull"
-	guberBasePush = "https://k8s-gubernator.appspot.com/build/kubernetes-jenkins/logs"
-	testInfra     = "https://github.com/kubernetes/test-infra/issues"
+	testInfra = "https://github.com/kubernetes/test-infra/issues"
+)
+
+const (
+	// How often Run syncs every ProwJob, in case we missed an event.
+	resyncPeriod = 10 * time.Minute
+	// How often Run checks on running Jenkins builds.
+	jenkinsPollPeriod = 30 * time.Second
+	// How many state changes to keep in a ProwJob's history.
+	maxHistory = 50
+)
+
+// Plank labels what it creates so that a sync whose ProwJob update lost a
+// conflict, say with crier, finds it again rather than creating it twice.
+const (
+	// prowJobLabel is the name of the ProwJob that a pod runs.
+	prowJobLabel = "prow.k8s.io/prowjob"
+	// attemptLabel is how many times the ProwJob was retried before the pod.
+	attemptLabel = "prow.k8s.io/attempt"
+	// parentLabel is the name of the ProwJob that started a RunAfterSuccess
+	// ProwJob.
+	parentLabel = "prow.k8s.io/parent"
 )
 
 type kubeClient interface {
 	CreateProwJob(kube.ProwJob) (kube.ProwJob, error)
+	GetProwJob(string) (kube.ProwJob, error)
 	ListProwJobs(map[string]string) ([]kube.ProwJob, error)
 	ReplaceProwJob(string, kube.ProwJob) (kube.ProwJob, error)
 
 	CreatePod(kube.Pod) (kube.Pod, error)
+	GetPod(string) (kube.Pod, error)
 	ListPods(map[string]string) ([]kube.Pod, error)
 	DeletePod(string) error
 }
@@ -50,22 +73,156 @@ type jenkinsClient interface {
 	Status(job, id string) (*jenkins.Status, error)
 }
 
+type configAgent interface {
+	Config() *config.Config
+	Subscribe(func(old, new *config.Config))
+}
+
 type Controller struct {
-	kc       kubeClient
-	jc       jenkinsClient
-	crierURL string
-	totURL   string
+	kc     kubeClient
+	jc     jenkinsClient
+	ca     configAgent
+	totURL string
+
+	cache *kube.Cache
 }
 
-func NewController(kc *kube.Client, jc *jenkins.Client, crierURL, totURL string) *Controller {
+// NewController creates a controller that reads from and writes through the
+// cache. The cache must be started before calling Run.
+func NewController(kca *kube.Cache, ca *config.ConfigAgent, jc *jenkins.Client, totURL string) *Controller {
 	return &Controller{
-		kc:       kc,
-		jc:       jc,
-		crierURL: crierURL,
-		totURL:   totURL,
+		kc:     kca,
+		jc:     jc,
+		ca:     ca,
+		totURL: totURL,
+		cache:  kca,
+	}
+}
+
+// Run syncs ProwJobs as the cache reports changes to them or to their pods
+// until stop is closed. Every resyncPeriod it syncs all of them regardless.
+// ProwJobs are synced one at a time, so Run must not be called concurrently
+// with Sync.
+func (c *Controller) Run(stop <-chan struct{}) {
+	q := kube.NewWorkQueue()
+	c.cache.AddProwJobHandler(func(e kube.ProwJobEvent) {
+		if e.Type != kube.Deleted {
+			q.Add(e.ProwJob.Metadata.Name)
+		}
+		// A slot may have freed up for ProwJobs waiting to start.
+		if e.Type == kube.Deleted || e.ProwJob.Complete() {
+			c.enqueue(q, func(pj kube.ProwJob) bool {
+				return pj.Status.State == kube.TriggeredState
+			})
+		}
+	})
+	// Concurrency limits and timeouts may have changed.
+	c.ca.Subscribe(func(_, _ *config.Config) {
+		c.enqueue(q, func(pj kube.ProwJob) bool { return !pj.Complete() })
+	})
+	c.cache.AddPodHandler(func(e kube.PodEvent) {
+		if pj, ok := c.cache.ProwJobForPod(e.Pod.Metadata.Name); ok {
+			q.Add(pj.Metadata.Name)
+		}
+	})
+	go func() {
+		resync := time.NewTicker(resyncPeriod)
+		defer resync.Stop()
+		// Jenkins doesn't tell us when builds change, so poll those.
+		poll := time.NewTicker(jenkinsPollPeriod)
+		defer poll.Stop()
+		c.enqueue(q, func(kube.ProwJob) bool { return true })
+		for {
+			select {
+			case <-resync.C:
+				c.enqueue(q, func(kube.ProwJob) bool { return true })
+			case <-poll.C:
+				c.enqueue(q, func(pj kube.ProwJob) bool {
+					return pj.Spec.Agent == kube.JenkinsAgent && !pj.Complete()
+				})
+			case <-stop:
+				q.ShutDown()
+				return
+			}
+		}
+	}()
+	for {
+		name, ok := q.Get()
+		if !ok {
+			return
+		}
+		if err := c.SyncProwJob(name); err != nil {
+			logrus.WithField("prowjob", name).WithError(err).Error("Error syncing prow job.")
+		}
+		q.Done(name)
+	}
+}
+
+// enqueue adds every ProwJob that matches filter to the queue.
+func (c *Controller) enqueue(q *kube.WorkQueue, filter func(kube.ProwJob) bool) {
+	pjs, err := c.kc.ListProwJobs(nil)
+	if err != nil {
+		logrus.WithError(err).Error("Error listing prow jobs.")
+		return
+	}
+	for _, pj := range pjs {
+		if filter(pj) {
+			q.Add(pj.Metadata.Name)
+		}
+	}
+}
+
+// SyncProwJob syncs a single ProwJob and the pod it is running in, if any.
+func (c *Controller) SyncProwJob(name string) error {
+	pj, err := c.kc.GetProwJob(name)
+	if kube.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("error getting prow job: %v", err)
+	}
+	if pj.Spec.Type == kube.PresubmitJob && !pj.Complete() {
+		pjs, err := c.kc.ListProwJobs(nil)
+		if err != nil {
+			return fmt.Errorf("error listing prow jobs: %v", err)
+		}
+		if err := c.terminateDupes(pjs); err != nil {
+			return err
+		}
+		// terminateDupes may have aborted this one.
+		if pj, err = c.kc.GetProwJob(name); err != nil {
+			return fmt.Errorf("error getting prow job: %v", err)
+		}
+	}
+	if pj.Status.State == kube.TriggeredState {
+		pjs, err := c.kc.ListProwJobs(nil)
+		if err != nil {
+			return fmt.Errorf("error listing prow jobs: %v", err)
+		}
+		if QueuePositions(pjs, c.ca.Config().Plank.MaxConcurrency)[name] > 0 {
+			// Wait for a slot to free up.
+			return nil
+		}
+	}
+	switch pj.Spec.Agent {
+	case kube.KubernetesAgent:
+		pm := map[string]kube.Pod{}
+		if pj.Status.PodName != "" {
+			pod, err := c.kc.GetPod(pj.Status.PodName)
+			if err == nil {
+				pm[pod.Metadata.Name] = pod
+			} else if !kube.IsNotFound(err) {
+				return fmt.Errorf("error getting pod: %v", err)
+			}
+		}
+		return c.syncKubernetesJob(pj, pm)
+	case kube.JenkinsAgent:
+		return c.syncJenkinsJob(pj)
+	default:
+		return fmt.Errorf("job %s has unsupported agent %s", pj.Metadata.Name, pj.Spec.Agent)
 	}
 }
 
+// Sync syncs every ProwJob.
 func (c *Controller) Sync() error {
 	pjs, err := c.kc.ListProwJobs(nil)
 	if err != nil {
@@ -83,7 +240,12 @@ func (c *Controller) Sync() error {
 	if err := c.terminateDupes(pjs); err != nil {
 		errs = append(errs, err)
 	}
+	positions := QueuePositions(pjs, c.ca.Config().Plank.MaxConcurrency)
 	for _, pj := range pjs {
+		if positions[pj.Metadata.Name] > 0 {
+			// Wait for a slot to free up.
+			continue
+		}
 		if pj.Spec.Agent == kube.KubernetesAgent {
 			if err := c.syncKubernetesJob(pj, pm); err != nil {
 				errs = append(errs, err)
@@ -122,8 +284,16 @@ func (c *Controller) terminateDupes(pjs []kube.ProwJob) error {
 			toCancel = prev
 			dupes[n] = pj
 		}
+		prevState, prevDescription := toCancel.Status.State, toCancel.Status.Description
 		toCancel.Status.CompletionTime = time.Now()
 		toCancel.Status.State = kube.AbortedState
+		toCancel.Status.Description = "Aborted by a newer run of this job."
+		recordChange(&toCancel, prevState, prevDescription)
+		if toCancel.Spec.Agent == kube.KubernetesAgent && toCancel.Status.PodName != "" {
+			if err := c.kc.DeletePod(toCancel.Status.PodName); err != nil && !kube.IsNotFound(err) {
+				return fmt.Errorf("error deleting pod %s: %v", toCancel.Status.PodName, err)
+			}
+		}
 		if _, err := c.kc.ReplaceProwJob(toCancel.Metadata.Name, toCancel); err != nil {
 			return err
 		}
@@ -132,6 +302,7 @@ func (c *Controller) terminateDupes(pjs []kube.ProwJob) error {
 }
 
 func (c *Controller) syncJenkinsJob(pj kube.ProwJob) error {
+	prevState, prevDescription := pj.Status.State, pj.Status.Description
 	var jerr error
 	if pj.Complete() {
 		return nil
@@ -160,9 +331,6 @@ func (c *Controller) syncJenkinsJob(pj kube.ProwJob) error {
 			pj.Status.JenkinsEnqueued = true
 			pj.Status.Description = "Jenkins job triggered."
 		}
-		if err := c.report(pj); err != nil {
-			return fmt.Errorf("error reporting to crier: %v", err)
-		}
 	} else if pj.Status.JenkinsEnqueued {
 		if eq, err := c.jc.Enqueued(pj.Status.JenkinsQueueURL); err != nil {
 			jerr = fmt.Errorf("error checking queue status: %v", err)
@@ -171,9 +339,6 @@ func (c *Controller) syncJenkinsJob(pj kube.ProwJob) error {
 			pj.Status.State = kube.ErrorState
 			pj.Status.URL = testInfra
 			pj.Status.Description = "Error checking queue status."
-			if err := c.report(pj); err != nil {
-				return fmt.Errorf("error reporting to crier: %v", err)
-			}
 		} else if eq {
 			// Still in queue.
 			return nil
@@ -186,16 +351,10 @@ func (c *Controller) syncJenkinsJob(pj kube.ProwJob) error {
 		pj.Status.State = kube.ErrorState
 		pj.Status.URL = testInfra
 		pj.Status.Description = "Error checking job status."
-		if err := c.report(pj); err != nil {
-			return fmt.Errorf("error reporting to crier: %v", err)
-		}
 	} else {
-		if url := guberURL(pj, strconv.Itoa(status.Number)); pj.Status.URL != url {
+		if url := c.jobURL(pj, strconv.Itoa(status.Number)); pj.Status.URL != url {
 			pj.Status.URL = url
 			pj.Status.PodName = fmt.Sprintf("%s-%d", pj.Spec.Job, status.Number)
-			if err := c.report(pj); err != nil {
-				return fmt.Errorf("error reporting to crier: %v", err)
-			}
 		} else if status.Building {
 			// Build still going.
 			return nil
@@ -204,23 +363,16 @@ func (c *Controller) syncJenkinsJob(pj kube.ProwJob) error {
 			pj.Status.CompletionTime = time.Now()
 			pj.Status.State = kube.SuccessState
 			pj.Status.Description = "Jenkins job succeeded."
-			if err := c.report(pj); err != nil {
-				return fmt.Errorf("error reporting to crier: %v", err)
-			}
-			for _, nj := range pj.Spec.RunAfterSuccess {
-				if _, err := c.kc.CreateProwJob(NewProwJob(nj)); err != nil {
-					return fmt.Errorf("error starting next prowjob: %v", err)
-				}
+			if err := c.startChildren(pj); err != nil {
+				return err
 			}
 		} else if !status.Building {
 			pj.Status.CompletionTime = time.Now()
 			pj.Status.State = kube.FailureState
 			pj.Status.Description = "Jenkins job failed."
-			if err := c.report(pj); err != nil {
-				return fmt.Errorf("error reporting to crier: %v", err)
-			}
 		}
 	}
+	recordChange(&pj, prevState, prevDescription)
 	_, rerr := c.kc.ReplaceProwJob(pj.Metadata.Name, pj)
 	if rerr != nil || jerr != nil {
 		return fmt.Errorf("jenkins error: %v, error replacing prow job: %v", jerr, rerr)
@@ -229,6 +381,10 @@ func (c *Controller) syncJenkinsJob(pj kube.ProwJob) error {
 }
 
 func (c *Controller) syncKubernetesJob(pj kube.ProwJob, pm map[string]kube.Pod) error {
+	prevState, prevDescription := pj.Status.State, pj.Status.Description
+	// The pod to delete once the ProwJob no longer refers to it. Deleting it
+	// first would look like a missing pod if the update then failed.
+	var deletePod string
 	if pj.Complete() {
 		// ProwJob is complete. Do nothing.
 		return nil
@@ -237,78 +393,148 @@ func (c *Controller) syncKubernetesJob(pj kube.ProwJob, pm map[string]kube.Pod)
 		pj.Status.State = kube.PendingState
 		if id, pn, err := c.startPod(pj); err == nil {
 			pj.Status.PodName = pn
-			pj.Status.URL = guberURL(pj, id)
+			pj.Status.URL = c.jobURL(pj, id)
 		} else {
 			return fmt.Errorf("error starting pod: %v", err)
 		}
 		pj.Status.Description = "Job triggered."
-		if err := c.report(pj); err != nil {
-			return fmt.Errorf("error reporting to crier: %v", err)
-		}
 	} else if pod, ok := pm[pj.Status.PodName]; !ok {
 		// Pod is missing. This shouldn't happen normally, but if someone goes
-		// in and manually deletes the pod then we'll hit it. Start a new pod.
-		pj.Status.PodName = ""
-		pj.Status.State = kube.PendingState
-	} else if pod.Status.Phase == kube.PodUnknown {
-		// Pod is in Unknown state. This can happen if there is a problem with
-		// the node. Delete the old pod, we'll start a new one next loop.
-		if err := c.kc.DeletePod(pj.Status.PodName); err != nil {
-			return fmt.Errorf("error deleting pod %s: %v", pj.Status.PodName, err)
-		}
-		pj.Status.PodName = ""
-		pj.Status.State = kube.PendingState
+		// in and manually deletes the pod then we'll hit it.
+		c.retry(&pj, "pod went missing")
+	} else if reason := infraFailure(pod); reason != "" {
+		// Pod failed for reasons that have nothing to do with the test, such
+		// as losing its node. Try again on a new pod.
+		deletePod = pod.Metadata.Name
+		c.retry(&pj, reason)
 	} else if pod.Status.Phase == kube.PodSucceeded {
-		// Pod succeeded. Update ProwJob, talk to crier, and start next jobs.
+		// Pod succeeded. Update ProwJob and start next jobs.
 		pj.Status.CompletionTime = time.Now()
 		pj.Status.State = kube.SuccessState
 		pj.Status.Description = "Job succeeded."
-		if err := c.report(pj); err != nil {
-			return fmt.Errorf("error reporting to crier: %v", err)
-		}
-		for _, nj := range pj.Spec.RunAfterSuccess {
-			if _, err := c.kc.CreateProwJob(NewProwJob(nj)); err != nil {
-				return fmt.Errorf("error starting next prowjob: %v", err)
-			}
+		if err := c.startChildren(pj); err != nil {
+			return err
 		}
 	} else if pod.Status.Phase == kube.PodFailed {
-		// Pod failed. Update ProwJob, talk to crier.
+		// Pod failed. Update ProwJob.
 		pj.Status.CompletionTime = time.Now()
 		pj.Status.State = kube.FailureState
 		pj.Status.Description = "Job failed."
-		if err := c.report(pj); err != nil {
-			return fmt.Errorf("error reporting to crier: %v", err)
-		}
+	} else if timeout := c.timeout(pj); timeout > 0 && !pod.Status.StartTime.IsZero() && time.Since(pod.Status.StartTime) > timeout {
+		// Pod has been running for too long. Delete it and give up.
+		deletePod = pod.Metadata.Name
+		pj.Status.CompletionTime = time.Now()
+		pj.Status.State = kube.ErrorState
+		pj.Status.Description = fmt.Sprintf("Job timed out after %s.", timeout)
 	} else {
 		// Pod is running. Do nothing.
 		return nil
 	}
-	_, err := c.kc.ReplaceProwJob(pj.Metadata.Name, pj)
-	return err
+	recordChange(&pj, prevState, prevDescription)
+	if _, err := c.kc.ReplaceProwJob(pj.Metadata.Name, pj); err != nil {
+		return err
+	}
+	if deletePod != "" {
+		if err := c.kc.DeletePod(deletePod); err != nil && !kube.IsNotFound(err) {
+			return fmt.Errorf("error deleting pod %s: %v", deletePod, err)
+		}
+	}
+	return nil
 }
 
-func (c *Controller) report(pj kube.ProwJob) error {
-	if !pj.Spec.Report {
+// startChildren starts the ProwJobs that run after pj succeeds, skipping any
+// that an earlier sync already started.
+func (c *Controller) startChildren(pj kube.ProwJob) error {
+	if len(pj.Spec.RunAfterSuccess) == 0 {
 		return nil
 	}
-	if len(pj.Spec.Refs.Pulls) != 1 {
-		return fmt.Errorf("prowjob %s has %d pulls, not 1", pj.Metadata.Name, len(pj.Spec.Refs.Pulls))
-	}
-	return crier.ReportToCrier(c.crierURL, crier.Report{
-		RepoOwner:    pj.Spec.Refs.Org,
-		RepoName:     pj.Spec.Refs.Repo,
-		Author:       pj.Spec.Refs.Pulls[0].Author,
-		Number:       pj.Spec.Refs.Pulls[0].Number,
-		Commit:       pj.Spec.Refs.Pulls[0].SHA,
-		Context:      pj.Spec.Context,
-		State:        string(pj.Status.State),
-		RerunCommand: pj.Spec.RerunCommand,
-		Description:  pj.Status.Description,
-		URL:          pj.Status.URL,
+	children, err := c.kc.ListProwJobs(map[string]string{parentLabel: pj.Metadata.Name})
+	if err != nil {
+		return fmt.Errorf("error listing next prowjobs: %v", err)
+	}
+	started := map[string]bool{}
+	for _, child := range children {
+		started[child.Spec.Job] = true
+	}
+	for _, nj := range pj.Spec.RunAfterSuccess {
+		if started[nj.Job] {
+			continue
+		}
+		child := NewProwJob(nj)
+		child.Metadata.Labels = map[string]string{parentLabel: pj.Metadata.Name}
+		if _, err := c.kc.CreateProwJob(child); err != nil {
+			return fmt.Errorf("error starting next prowjob: %v", err)
+		}
+	}
+	return nil
+}
+
+// recordChange adds the ProwJob's state and description to its history if
+// either changed. Only the last maxHistory changes are kept.
+func recordChange(pj *kube.ProwJob, prevState kube.ProwJobState, prevDescription string) {
+	if pj.Status.State == prevState && pj.Status.Description == prevDescription {
+		return
+	}
+	// Copy the history rather than appending to the cached one.
+	h := pj.Status.History
+	if len(h) >= maxHistory {
+		h = h[len(h)-maxHistory+1:]
+	}
+	history := make([]kube.StatusChange, len(h), len(h)+1)
+	copy(history, h)
+	pj.Status.History = append(history, kube.StatusChange{
+		Time:        time.Now(),
+		State:       pj.Status.State,
+		Description: pj.Status.Description,
 	})
 }
 
+// retry resets a ProwJob that hit an infrastructure failure so that the next
+// sync starts a new pod. Once the job is out of retries it is marked as an
+// error instead. The caller deletes the old pod.
+func (c *Controller) retry(pj *kube.ProwJob, reason string) {
+	if pj.Status.Retries < c.ca.Config().Plank.GetMaxRetries() {
+		pj.Status.Retries++
+		pj.Status.PodName = ""
+		pj.Status.State = kube.PendingState
+		pj.Status.Description = fmt.Sprintf("Retrying after infrastructure failure: %s.", reason)
+		return
+	}
+	pj.Status.CompletionTime = time.Now()
+	pj.Status.State = kube.ErrorState
+	pj.Status.Description = fmt.Sprintf("Infrastructure failure: %s.", reason)
+}
+
+// timeout returns how long the ProwJob may run, or zero if there is no limit.
+func (c *Controller) timeout(pj kube.ProwJob) time.Duration {
+	if pj.Spec.Timeout > 0 {
+		return pj.Spec.Timeout
+	}
+	return c.ca.Config().Plank.GetDefaultTimeout()
+}
+
+// startPod starts a pod for the ProwJob's current attempt and returns its
+// build ID and name. If an earlier sync started one but failed to record it,
+// startPod returns that one instead. It deletes pods left over from earlier
+// attempts.
 func (c *Controller) startPod(pj kube.ProwJob) (string, string, error) {
+	pods, err := c.kc.ListPods(map[string]string{prowJobLabel: pj.Metadata.Name})
+	if err != nil {
+		return "", "", fmt.Errorf("error listing pods: %v", err)
+	}
+	attempt := strconv.Itoa(pj.Status.Retries)
+	var started *kube.Pod
+	for i, pod := range pods {
+		if pod.Metadata.Labels[attemptLabel] == attempt {
+			started = &pods[i]
+		} else if err := c.kc.DeletePod(pod.Metadata.Name); err != nil && !kube.IsNotFound(err) {
+			return "", "", fmt.Errorf("error deleting pod %s: %v", pod.Metadata.Name, err)
+		}
+	}
+	if started != nil {
+		return buildIDOf(*started), started.Metadata.Name, nil
+	}
+
 	buildID, err := c.getBuildID(c.totURL, pj.Spec.Job)
 	if err != nil {
 		return "", "", fmt.Errorf("error getting build ID: %v", err)
@@ -391,6 +617,10 @@ func (c *Controller) startPod(pj kube.ProwJob) (string, string, error) {
 	p := kube.Pod{
 		Metadata: kube.ObjectMeta{
 			Name: podName,
+			Labels: map[string]string{
+				prowJobLabel: pj.Metadata.Name,
+				attemptLabel: attempt,
+			},
 		},
 		Spec: spec,
 	}
@@ -401,6 +631,18 @@ func (c *Controller) startPod(pj kube.ProwJob) (string, string, error) {
 	return buildID, actual.Metadata.Name, nil
 }
 
+// buildIDOf returns the build ID that startPod gave the pod.
+func buildIDOf(pod kube.Pod) string {
+	for _, c := range pod.Spec.Containers {
+		for _, e := range c.Env {
+			if e.Name == "BUILD_NUMBER" {
+				return e.Value
+			}
+		}
+	}
+	return ""
+}
+
 func (c *Controller) getBuildID(server, name string) (string, error) {
 	var err error
 	url := server + "/vend/" + name
@@ -426,29 +668,12 @@ func (c *Controller) getBuildID(server, name string) (string, error) {
 	return "", err
 }
 
-// TODO(spxtr): Template this.
-func guberURL(pj kube.ProwJob, build string) string {
-	var url string
-	if pj.Spec.Type == kube.PresubmitJob || pj.Spec.Type == kube.BatchJob {
-		url = guberBasePR
-	} else {
-		url = guberBasePush
-	}
-	if pj.Spec.Refs.Org != "kubernetes" {
-		url = fmt.Sprintf("%s/%s_%s", url, pj.Spec.Refs.Org, pj.Spec.Refs.Repo)
-	} else if pj.Spec.Refs.Repo != "kubernetes" {
-		url = fmt.Sprintf("%s/%s", url, pj.Spec.Refs.Repo)
-	}
-	switch t := pj.Spec.Type; t {
-	case kube.PresubmitJob:
-		return fmt.Sprintf("%s/%s/%s/%s/", url, strconv.Itoa(pj.Spec.Refs.Pulls[0].Number), pj.Spec.Job, build)
-	case kube.PostsubmitJob:
-		return fmt.Sprintf("%s/%s/%s/", url, pj.Spec.Job, build)
-	case kube.PeriodicJob:
-		return fmt.Sprintf("%s/%s/%s/", url, pj.Spec.Job, build)
-	case kube.BatchJob:
-		return fmt.Sprintf("%s/batch/%s/%s/", url, pj.Spec.Job, build)
-	default:
-		return testInfra
+// jobURL returns the link to the results of the build, or the empty string if
+// the template fails.
+func (c *Controller) jobURL(pj kube.ProwJob, build string) string {
+	url, err := c.ca.Config().URLTemplates.JobURL(pj, build)
+	if err != nil {
+		logrus.WithField("prowjob", pj.Metadata.Name).WithError(err).Error("Error building job URL.")
 	}
+	return url
 }
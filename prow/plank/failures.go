@@ -0,0 +1,71 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plank
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/test-infra/prow/kube"
+)
+
+// How long a pod may be unschedulable before we give up on it. Pods are
+// routinely unschedulable for a while when the cluster is busy.
+const unschedulableTimeout = 30 * time.Minute
+
+// Waiting reasons that mean the container will never start on its own. The
+// kubelet retries ErrImagePull, and only backs off once that keeps failing.
+var badWaitingReasons = map[string]bool{
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+}
+
+// infraFailure returns why the pod failed if the reason has nothing to do
+// with the test itself, or the empty string if the pod is healthy or the test
+// genuinely failed.
+func infraFailure(pod kube.Pod) string {
+	switch pod.Status.Phase {
+	case kube.PodUnknown:
+		return "lost contact with the node"
+	case kube.PodFailed:
+		if pod.Status.Reason == "Evicted" {
+			return "pod was evicted"
+		}
+		if pod.Status.Reason == "NodeLost" {
+			return "node was lost"
+		}
+		for _, cs := range pod.Status.InitContainerStatuses {
+			if t := cs.State.Terminated; t != nil && t.Reason == "OOMKilled" {
+				return fmt.Sprintf("init container %s ran out of memory", cs.Name)
+			}
+		}
+	case kube.PodPending:
+		for _, cs := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+			if w := cs.State.Waiting; w != nil && badWaitingReasons[w.Reason] {
+				return fmt.Sprintf("container %s cannot start: %s", cs.Name, w.Reason)
+			}
+		}
+		for _, cond := range pod.Status.Conditions {
+			if cond.Type == kube.PodScheduled && cond.Status == "False" && cond.Reason == "Unschedulable" &&
+				!cond.LastTransitionTime.IsZero() && time.Since(cond.LastTransitionTime) > unschedulableTimeout {
+				return fmt.Sprintf("pod was unschedulable for %s", unschedulableTimeout)
+			}
+		}
+	}
+	return ""
+}
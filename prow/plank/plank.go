@@ -17,6 +17,7 @@ limitations under the License.
 package plank
 
 import (
+	"sort"
 	"time"
 
 	"github.com/satori/go.uuid"
@@ -27,6 +28,7 @@ import (
 
 // NewProwJob initializes a ProwJob out of a ProwJobSpec.
 func NewProwJob(spec kube.ProwJobSpec) kube.ProwJob {
+	now := time.Now()
 	return kube.ProwJob{
 		APIVersion: "prow.k8s.io/v1",
 		Kind:       "ProwJob",
@@ -36,8 +38,9 @@ func NewProwJob(spec kube.ProwJobSpec) kube.ProwJob {
 		},
 		Spec: spec,
 		Status: kube.ProwJobStatus{
-			StartTime: time.Now(),
+			StartTime: now,
 			State:     kube.TriggeredState,
+			History:   []kube.StatusChange{{Time: now, State: kube.TriggeredState}},
 		},
 	}
 }
@@ -52,6 +55,10 @@ func PresubmitSpec(p config.Presubmit, refs kube.Refs) kube.ProwJobSpec {
 		Report:       !p.SkipReport,
 		Context:      p.Context,
 		RerunCommand: p.RerunCommand,
+		Reporters:    p.Reporters,
+
+		MaxConcurrency: p.MaxConcurrency,
+		Timeout:        p.GetTimeout(),
 	}
 	if p.Spec == nil {
 		pjs.Agent = kube.JenkinsAgent
@@ -71,6 +78,11 @@ func PostsubmitSpec(p config.Postsubmit, refs kube.Refs) kube.ProwJobSpec {
 		Type: kube.PostsubmitJob,
 		Job:  p.Name,
 		Refs: refs,
+
+		Reporters: p.Reporters,
+
+		MaxConcurrency: p.MaxConcurrency,
+		Timeout:        p.GetTimeout(),
 	}
 	if p.Spec == nil {
 		pjs.Agent = kube.JenkinsAgent
@@ -89,6 +101,11 @@ func PeriodicSpec(p config.Periodic) kube.ProwJobSpec {
 	pjs := kube.ProwJobSpec{
 		Type: kube.PeriodicJob,
 		Job:  p.Name,
+
+		Reporters: p.Reporters,
+
+		MaxConcurrency: p.MaxConcurrency,
+		Timeout:        p.GetTimeout(),
 	}
 	if p.Spec == nil {
 		pjs.Agent = kube.JenkinsAgent
@@ -109,6 +126,12 @@ func BatchSpec(p config.Presubmit, refs kube.Refs) kube.ProwJobSpec {
 		Job:     p.Name,
 		Refs:    refs,
 		Context: p.Context, // The Submit Queue's getCompleteBatches needs this.
+
+		Report:    !p.SkipReport,
+		Reporters: p.Reporters,
+
+		MaxConcurrency: p.MaxConcurrency,
+		Timeout:        p.GetTimeout(),
 	}
 	if p.Spec == nil {
 		pjs.Agent = kube.JenkinsAgent
@@ -121,3 +144,51 @@ func BatchSpec(p config.Presubmit, refs kube.Refs) kube.ProwJobSpec {
 	}
 	return pjs
 }
+
+// QueuePositions returns the place in line of every ProwJob that is waiting to
+// start. ProwJobs start in the order they were created as long as neither
+// their own MaxConcurrency nor maxConcurrency across all jobs is reached. A
+// position of zero means that the ProwJob may start now. Zero maxConcurrency
+// means no global limit.
+func QueuePositions(pjs []kube.ProwJob, maxConcurrency int) map[string]int {
+	running := 0
+	runningJobs := map[string]int{}
+	var waiting []kube.ProwJob
+	for _, pj := range pjs {
+		if pj.Complete() {
+			continue
+		}
+		if pj.Status.State == kube.TriggeredState {
+			waiting = append(waiting, pj)
+		} else {
+			running++
+			runningJobs[pj.Spec.Job]++
+		}
+	}
+	sort.Sort(byCreation(waiting))
+	positions := map[string]int{}
+	position := 0
+	for _, pj := range waiting {
+		if (maxConcurrency == 0 || running < maxConcurrency) &&
+			(pj.Spec.MaxConcurrency == 0 || runningJobs[pj.Spec.Job] < pj.Spec.MaxConcurrency) {
+			positions[pj.Metadata.Name] = 0
+			running++
+			runningJobs[pj.Spec.Job]++
+		} else {
+			position++
+			positions[pj.Metadata.Name] = position
+		}
+	}
+	return positions
+}
+
+type byCreation []kube.ProwJob
+
+func (a byCreation) Len() int      { return len(a) }
+func (a byCreation) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byCreation) Less(i, j int) bool {
+	if a[i].Status.StartTime.Equal(a[j].Status.StartTime) {
+		return a[i].Metadata.Name < a[j].Metadata.Name
+	}
+	return a[i].Status.StartTime.Before(a[j].Status.StartTime)
+}
@@ -22,14 +22,29 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"testing"
 	"time"
 
 	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/crier"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
 	"k8s.io/test-infra/prow/jenkins"
 	"k8s.io/test-infra/prow/kube"
+	"k8s.io/test-infra/prow/kube/fakekube"
 )
 
+type fca struct {
+	c *config.Config
+}
+
+func (f fca) Config() *config.Config {
+	return f.c
+}
+
+func (f fca) Subscribe(func(old, new *config.Config)) {}
+
 type fkc struct {
 	prowjobs []kube.ProwJob
 	pods     []kube.Pod
@@ -40,8 +55,23 @@ func (f *fkc) CreateProwJob(pj kube.ProwJob) (kube.ProwJob, error) {
 	return pj, nil
 }
 
-func (f *fkc) ListProwJobs(map[string]string) ([]kube.ProwJob, error) {
-	return f.prowjobs, nil
+func (f *fkc) GetProwJob(name string) (kube.ProwJob, error) {
+	for _, pj := range f.prowjobs {
+		if pj.Metadata.Name == name {
+			return pj, nil
+		}
+	}
+	return kube.ProwJob{}, kube.NotFoundError{}
+}
+
+func (f *fkc) ListProwJobs(labels map[string]string) ([]kube.ProwJob, error) {
+	var pjs []kube.ProwJob
+	for _, pj := range f.prowjobs {
+		if hasLabels(pj.Metadata.Labels, labels) {
+			pjs = append(pjs, pj)
+		}
+	}
+	return pjs, nil
 }
 
 func (f *fkc) ReplaceProwJob(name string, job kube.ProwJob) (kube.ProwJob, error) {
@@ -59,8 +89,32 @@ func (f *fkc) CreatePod(pod kube.Pod) (kube.Pod, error) {
 	return pod, nil
 }
 
-func (f *fkc) ListPods(map[string]string) ([]kube.Pod, error) {
-	return f.pods, nil
+func (f *fkc) GetPod(name string) (kube.Pod, error) {
+	for _, pod := range f.pods {
+		if pod.Metadata.Name == name {
+			return pod, nil
+		}
+	}
+	return kube.Pod{}, kube.NotFoundError{}
+}
+
+func (f *fkc) ListPods(labels map[string]string) ([]kube.Pod, error) {
+	var pods []kube.Pod
+	for _, pod := range f.pods {
+		if hasLabels(pod.Metadata.Labels, labels) {
+			pods = append(pods, pod)
+		}
+	}
+	return pods, nil
+}
+
+func hasLabels(labels, selector map[string]string) bool {
+	for k, v := range selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
 }
 
 func (f *fkc) DeletePod(name string) error {
@@ -70,7 +124,7 @@ func (f *fkc) DeletePod(name string) error {
 			return nil
 		}
 	}
-	return fmt.Errorf("did not find pod %s", name)
+	return kube.NotFoundError{}
 }
 
 func TestTerminateDupes(t *testing.T) {
@@ -132,18 +186,21 @@ func TestTerminateDupes(t *testing.T) {
 		var pj = kube.ProwJob{
 			Metadata: kube.ObjectMeta{Name: tc.name},
 			Spec: kube.ProwJobSpec{
-				Type: kube.PresubmitJob,
-				Job:  tc.job,
-				Refs: kube.Refs{Pulls: []kube.Pull{{}}},
+				Type:  kube.PresubmitJob,
+				Agent: kube.KubernetesAgent,
+				Job:   tc.job,
+				Refs:  kube.Refs{Pulls: []kube.Pull{{}}},
 			},
 			Status: kube.ProwJobStatus{
 				StartTime: tc.startTime,
+				PodName:   tc.name,
 			},
 		}
 		if tc.complete {
 			pj.Status.CompletionTime = now
 		}
 		fkc.prowjobs = append(fkc.prowjobs, pj)
+		fkc.pods = append(fkc.pods, kube.Pod{Metadata: kube.ObjectMeta{Name: tc.name}})
 	}
 	if err := c.terminateDupes(fkc.prowjobs); err != nil {
 		t.Fatalf("Error terminating dupes: %v", err)
@@ -153,6 +210,9 @@ func TestTerminateDupes(t *testing.T) {
 		if terminated != testcases[i].shouldTerminate {
 			t.Errorf("Wrong termination for %s", testcases[i].name)
 		}
+		if _, err := fkc.GetPod(testcases[i].name); kube.IsNotFound(err) != testcases[i].shouldTerminate {
+			t.Errorf("Wrong pod deletion for %s", testcases[i].name)
+		}
 	}
 }
 
@@ -194,9 +254,6 @@ func handleTot(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, "42")
 }
 
-func handleCrier(w http.ResponseWriter, r *http.Request) {
-}
-
 func TestSyncJenkinsJob(t *testing.T) {
 	var testcases = []struct {
 		name string
@@ -209,7 +266,6 @@ func TestSyncJenkinsJob(t *testing.T) {
 		expectedState    kube.ProwJobState
 		expectedBuild    bool
 		expectedComplete bool
-		expectedReport   bool
 		expectedEnqueued bool
 		expectedError    bool
 	}{
@@ -248,7 +304,6 @@ func TestSyncJenkinsJob(t *testing.T) {
 				},
 			},
 			expectedBuild:    true,
-			expectedReport:   true,
 			expectedState:    kube.PendingState,
 			expectedEnqueued: true,
 		},
@@ -267,7 +322,6 @@ func TestSyncJenkinsJob(t *testing.T) {
 				},
 			},
 			err:              errors.New("oh no!"),
-			expectedReport:   true,
 			expectedState:    kube.ErrorState,
 			expectedComplete: true,
 			expectedError:    true,
@@ -316,7 +370,6 @@ func TestSyncJenkinsJob(t *testing.T) {
 			expectedState:    kube.ErrorState,
 			expectedError:    true,
 			expectedComplete: true,
-			expectedReport:   true,
 		},
 		{
 			name: "building",
@@ -348,7 +401,6 @@ func TestSyncJenkinsJob(t *testing.T) {
 			expectedState:    kube.ErrorState,
 			expectedError:    true,
 			expectedComplete: true,
-			expectedReport:   true,
 		},
 		{
 			name: "finished, success",
@@ -380,12 +432,6 @@ func TestSyncJenkinsJob(t *testing.T) {
 		},
 	}
 	for _, tc := range testcases {
-		var reported bool
-		var handleCrier = func(w http.ResponseWriter, r *http.Request) {
-			reported = true
-		}
-		crierServ := httptest.NewServer(http.HandlerFunc(handleCrier))
-		defer crierServ.Close()
 		fjc := &fjc{
 			enqueued: tc.enqueued,
 			status:   tc.status,
@@ -396,9 +442,9 @@ func TestSyncJenkinsJob(t *testing.T) {
 		}
 
 		c := Controller{
-			kc:       fkc,
-			jc:       fjc,
-			crierURL: crierServ.URL,
+			kc: fkc,
+			jc: fjc,
+			ca: fca{&config.Config{}},
 		}
 		if err := c.syncJenkinsJob(tc.pj); err != nil != tc.expectedError {
 			t.Errorf("for case %s got wrong error: %v", tc.name, err)
@@ -411,9 +457,6 @@ func TestSyncJenkinsJob(t *testing.T) {
 		if actual.Complete() != tc.expectedComplete {
 			t.Errorf("for case %s got wrong completion", tc.name)
 		}
-		if reported != tc.expectedReport {
-			t.Errorf("for case %s got wrong report", tc.name)
-		}
 		if fjc.built != tc.expectedBuild {
 			t.Errorf("for case %s got wrong built", tc.name)
 		}
@@ -429,12 +472,15 @@ func TestSyncKubernetesJob(t *testing.T) {
 
 		pj   kube.ProwJob
 		pods []kube.Pod
+		// Other ProwJobs that already exist.
+		pjs []kube.ProwJob
 
 		expectedState      kube.ProwJobState
 		expectedPodName    string
 		expectedNumPods    int
 		expectedComplete   bool
 		expectedCreatedPJs int
+		expectedRetries    int
 	}{
 		{
 			name: "completed prow job",
@@ -461,6 +507,61 @@ func TestSyncKubernetesJob(t *testing.T) {
 			expectedPodName: "boop-42",
 			expectedNumPods: 1,
 		},
+		{
+			name: "pod started by a sync that failed to record it",
+			pj: kube.ProwJob{
+				Metadata: kube.ObjectMeta{Name: "pj"},
+				Spec: kube.ProwJobSpec{
+					Job: "boop",
+				},
+				Status: kube.ProwJobStatus{
+					State: kube.TriggeredState,
+				},
+			},
+			pods: []kube.Pod{
+				{
+					Metadata: kube.ObjectMeta{
+						Name:   "boop-41",
+						Labels: map[string]string{prowJobLabel: "pj", attemptLabel: "0"},
+					},
+					Spec: kube.PodSpec{
+						Containers: []kube.Container{{Env: []kube.EnvVar{{Name: "BUILD_NUMBER", Value: "41"}}}},
+					},
+				},
+			},
+			expectedState:   kube.PendingState,
+			expectedPodName: "boop-41",
+			expectedNumPods: 1,
+		},
+		{
+			name: "start pod for a retry",
+			pj: kube.ProwJob{
+				Metadata: kube.ObjectMeta{Name: "pj"},
+				Spec: kube.ProwJobSpec{
+					Job: "boop",
+				},
+				Status: kube.ProwJobStatus{
+					State:   kube.PendingState,
+					Retries: 1,
+				},
+			},
+			pods: []kube.Pod{
+				{
+					Metadata: kube.ObjectMeta{
+						Name:   "boop-41",
+						Labels: map[string]string{prowJobLabel: "pj", attemptLabel: "0"},
+					},
+					Status: kube.PodStatus{
+						Phase:  kube.PodFailed,
+						Reason: "Evicted",
+					},
+				},
+			},
+			expectedState:   kube.PendingState,
+			expectedPodName: "boop-42",
+			expectedNumPods: 1,
+			expectedRetries: 1,
+		},
 		{
 			name: "reset when pod goes missing",
 			pj: kube.ProwJob{
@@ -471,6 +572,7 @@ func TestSyncKubernetesJob(t *testing.T) {
 			},
 			expectedState:   kube.PendingState,
 			expectedPodName: "",
+			expectedRetries: 1,
 		},
 		{
 			name: "delete pod in unknown state",
@@ -493,10 +595,184 @@ func TestSyncKubernetesJob(t *testing.T) {
 			expectedState:   kube.PendingState,
 			expectedPodName: "",
 			expectedNumPods: 0,
+			expectedRetries: 1,
+		},
+		{
+			name: "retry evicted pod",
+			pj: kube.ProwJob{
+				Status: kube.ProwJobStatus{
+					State:   kube.PendingState,
+					PodName: "boop-41",
+				},
+			},
+			pods: []kube.Pod{
+				{
+					Metadata: kube.ObjectMeta{
+						Name: "boop-41",
+					},
+					Status: kube.PodStatus{
+						Phase:  kube.PodFailed,
+						Reason: "Evicted",
+					},
+				},
+			},
+			expectedState:   kube.PendingState,
+			expectedPodName: "",
+			expectedNumPods: 0,
+			expectedRetries: 1,
+		},
+		{
+			name: "retry image pull error",
+			pj: kube.ProwJob{
+				Status: kube.ProwJobStatus{
+					State:   kube.PendingState,
+					PodName: "boop-41",
+				},
+			},
+			pods: []kube.Pod{
+				{
+					Metadata: kube.ObjectMeta{
+						Name: "boop-41",
+					},
+					Status: kube.PodStatus{
+						Phase: kube.PodPending,
+						ContainerStatuses: []kube.ContainerStatus{
+							{
+								Name: "test",
+								State: kube.ContainerState{
+									Waiting: &kube.ContainerStateWaiting{Reason: "ImagePullBackOff"},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectedState:   kube.PendingState,
+			expectedPodName: "",
+			expectedNumPods: 0,
+			expectedRetries: 1,
+		},
+		{
+			name: "image pull that the kubelet still retries",
+			pj: kube.ProwJob{
+				Status: kube.ProwJobStatus{
+					State:   kube.PendingState,
+					PodName: "boop-41",
+				},
+			},
+			pods: []kube.Pod{
+				{
+					Metadata: kube.ObjectMeta{
+						Name: "boop-41",
+					},
+					Status: kube.PodStatus{
+						Phase: kube.PodPending,
+						ContainerStatuses: []kube.ContainerStatus{
+							{
+								Name: "test",
+								State: kube.ContainerState{
+									Waiting: &kube.ContainerStateWaiting{Reason: "ErrImagePull"},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectedState:   kube.PendingState,
+			expectedPodName: "boop-41",
+			expectedNumPods: 1,
+		},
+		{
+			name: "retry OOMKilled init container",
+			pj: kube.ProwJob{
+				Status: kube.ProwJobStatus{
+					State:   kube.PendingState,
+					PodName: "boop-41",
+				},
+			},
+			pods: []kube.Pod{
+				{
+					Metadata: kube.ObjectMeta{
+						Name: "boop-41",
+					},
+					Status: kube.PodStatus{
+						Phase: kube.PodFailed,
+						InitContainerStatuses: []kube.ContainerStatus{
+							{
+								Name: "clone",
+								State: kube.ContainerState{
+									Terminated: &kube.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectedState:   kube.PendingState,
+			expectedPodName: "",
+			expectedNumPods: 0,
+			expectedRetries: 1,
+		},
+		{
+			name: "error after retries run out",
+			pj: kube.ProwJob{
+				Status: kube.ProwJobStatus{
+					State:   kube.PendingState,
+					PodName: "boop-41",
+					Retries: 1,
+				},
+			},
+			pods: []kube.Pod{
+				{
+					Metadata: kube.ObjectMeta{
+						Name: "boop-41",
+					},
+					Status: kube.PodStatus{
+						Phase:  kube.PodFailed,
+						Reason: "Evicted",
+					},
+				},
+			},
+			expectedComplete: true,
+			expectedState:    kube.ErrorState,
+			expectedPodName:  "boop-41",
+			expectedNumPods:  0,
+			expectedRetries:  1,
+		},
+		{
+			name: "pending pod that is just slow",
+			pj: kube.ProwJob{
+				Status: kube.ProwJobStatus{
+					State:   kube.PendingState,
+					PodName: "boop-41",
+				},
+			},
+			pods: []kube.Pod{
+				{
+					Metadata: kube.ObjectMeta{
+						Name: "boop-41",
+					},
+					Status: kube.PodStatus{
+						Phase: kube.PodPending,
+						Conditions: []kube.PodCondition{
+							{
+								Type:               kube.PodScheduled,
+								Status:             "False",
+								Reason:             "Unschedulable",
+								LastTransitionTime: time.Now().Add(-time.Minute),
+							},
+						},
+					},
+				},
+			},
+			expectedState:   kube.PendingState,
+			expectedPodName: "boop-41",
+			expectedNumPods: 1,
 		},
 		{
 			name: "succeeded pod",
 			pj: kube.ProwJob{
+				Metadata: kube.ObjectMeta{Name: "pj"},
 				Spec: kube.ProwJobSpec{
 					RunAfterSuccess: []kube.ProwJobSpec{{}},
 				},
@@ -521,6 +797,43 @@ func TestSyncKubernetesJob(t *testing.T) {
 			expectedNumPods:    1,
 			expectedCreatedPJs: 1,
 		},
+		{
+			name: "succeeded pod whose next job a sync already started",
+			pj: kube.ProwJob{
+				Metadata: kube.ObjectMeta{Name: "pj"},
+				Spec: kube.ProwJobSpec{
+					RunAfterSuccess: []kube.ProwJobSpec{{Job: "next"}, {Job: "other"}},
+				},
+				Status: kube.ProwJobStatus{
+					State:   kube.PendingState,
+					PodName: "boop-42",
+				},
+			},
+			pods: []kube.Pod{
+				{
+					Metadata: kube.ObjectMeta{
+						Name: "boop-42",
+					},
+					Status: kube.PodStatus{
+						Phase: kube.PodSucceeded,
+					},
+				},
+			},
+			pjs: []kube.ProwJob{
+				{
+					Metadata: kube.ObjectMeta{
+						Name:   "child",
+						Labels: map[string]string{parentLabel: "pj"},
+					},
+					Spec: kube.ProwJobSpec{Job: "next"},
+				},
+			},
+			expectedComplete:   true,
+			expectedState:      kube.SuccessState,
+			expectedPodName:    "boop-42",
+			expectedNumPods:    1,
+			expectedCreatedPJs: 1,
+		},
 		{
 			name: "failed pod",
 			pj: kube.ProwJob{
@@ -572,24 +885,76 @@ func TestSyncKubernetesJob(t *testing.T) {
 			expectedPodName: "boop-42",
 			expectedNumPods: 1,
 		},
+		{
+			name: "running pod within timeout",
+			pj: kube.ProwJob{
+				Spec: kube.ProwJobSpec{
+					Timeout: time.Hour,
+				},
+				Status: kube.ProwJobStatus{
+					State:   kube.PendingState,
+					PodName: "boop-42",
+				},
+			},
+			pods: []kube.Pod{
+				{
+					Metadata: kube.ObjectMeta{
+						Name: "boop-42",
+					},
+					Status: kube.PodStatus{
+						Phase:     kube.PodRunning,
+						StartTime: time.Now().Add(-time.Minute),
+					},
+				},
+			},
+			expectedState:   kube.PendingState,
+			expectedPodName: "boop-42",
+			expectedNumPods: 1,
+		},
+		{
+			name: "timed out pod",
+			pj: kube.ProwJob{
+				Spec: kube.ProwJobSpec{
+					Timeout: time.Hour,
+				},
+				Status: kube.ProwJobStatus{
+					State:   kube.PendingState,
+					PodName: "boop-42",
+				},
+			},
+			pods: []kube.Pod{
+				{
+					Metadata: kube.ObjectMeta{
+						Name: "boop-42",
+					},
+					Status: kube.PodStatus{
+						Phase:     kube.PodRunning,
+						StartTime: time.Now().Add(-2 * time.Hour),
+					},
+				},
+			},
+			expectedComplete: true,
+			expectedState:    kube.ErrorState,
+			expectedPodName:  "boop-42",
+			expectedNumPods:  0,
+		},
 	}
 	for _, tc := range testcases {
 		totServ := httptest.NewServer(http.HandlerFunc(handleTot))
 		defer totServ.Close()
-		crierServ := httptest.NewServer(http.HandlerFunc(handleCrier))
-		defer crierServ.Close()
 		pm := make(map[string]kube.Pod)
 		for i := range tc.pods {
 			pm[tc.pods[i].Metadata.Name] = tc.pods[i]
 		}
 		fc := &fkc{
-			prowjobs: []kube.ProwJob{tc.pj},
+			prowjobs: append([]kube.ProwJob{tc.pj}, tc.pjs...),
 			pods:     tc.pods,
 		}
+		maxRetries := 1
 		c := Controller{
-			kc:       fc,
-			totURL:   totServ.URL,
-			crierURL: crierServ.URL,
+			kc:     fc,
+			ca:     fca{&config.Config{Plank: config.Plank{MaxRetries: &maxRetries}}},
+			totURL: totServ.URL,
 		}
 		if err := c.syncKubernetesJob(tc.pj, pm); err != nil {
 			t.Errorf("for case %s got an error: %v", tc.name, err)
@@ -608,8 +973,11 @@ func TestSyncKubernetesJob(t *testing.T) {
 		if actual.Complete() != tc.expectedComplete {
 			t.Errorf("for case %s got wrong completion", tc.name)
 		}
-		if len(fc.prowjobs) != tc.expectedCreatedPJs+1 {
-			t.Errorf("for case %s got %d created prowjobs", tc.name, len(fc.prowjobs)-1)
+		if created := len(fc.prowjobs) - len(tc.pjs) - 1; created != tc.expectedCreatedPJs {
+			t.Errorf("for case %s got %d created prowjobs", tc.name, created)
+		}
+		if actual.Status.Retries != tc.expectedRetries {
+			t.Errorf("for case %s got %d retries", tc.name, actual.Status.Retries)
 		}
 	}
 }
@@ -620,8 +988,6 @@ func TestBatch(t *testing.T) {
 		Name:    "pr-some-job",
 		Context: "Some Job Context",
 	}
-	crierServ := httptest.NewServer(http.HandlerFunc(handleCrier))
-	defer crierServ.Close()
 	fc := &fkc{
 		prowjobs: []kube.ProwJob{NewProwJob(BatchSpec(pre, kube.Refs{
 			Org:     "o",
@@ -642,9 +1008,9 @@ func TestBatch(t *testing.T) {
 	}
 	jc := &fjc{}
 	c := Controller{
-		kc:       fc,
-		jc:       jc,
-		crierURL: crierServ.URL,
+		kc: fc,
+		jc: jc,
+		ca: fca{&config.Config{}},
 	}
 
 	if err := c.Sync(); err != nil {
@@ -714,15 +1080,13 @@ func TestPeriodic(t *testing.T) {
 
 	totServ := httptest.NewServer(http.HandlerFunc(handleTot))
 	defer totServ.Close()
-	crierServ := httptest.NewServer(http.HandlerFunc(handleCrier))
-	defer crierServ.Close()
 	fc := &fkc{
 		prowjobs: []kube.ProwJob{NewProwJob(PeriodicSpec(per))},
 	}
 	c := Controller{
-		kc:       fc,
-		totURL:   totServ.URL,
-		crierURL: crierServ.URL,
+		kc:     fc,
+		ca:     fca{&config.Config{}},
+		totURL: totServ.URL,
 	}
 
 	if err := c.Sync(); err != nil {
@@ -769,3 +1133,355 @@ func TestPeriodic(t *testing.T) {
 		t.Fatalf("Wrong number of pods: %d", len(fc.pods))
 	}
 }
+
+func TestSyncProwJob(t *testing.T) {
+	pj := NewProwJob(PeriodicSpec(config.Periodic{
+		Name: "ci-periodic-job",
+		Spec: &kube.PodSpec{Containers: []kube.Container{{}}},
+	}))
+	pj.Status.PodName = "ci-periodic-job-42"
+	pj.Status.State = kube.PendingState
+	fc := &fkc{
+		prowjobs: []kube.ProwJob{pj},
+		pods: []kube.Pod{
+			{
+				Metadata: kube.ObjectMeta{Name: "ci-periodic-job-42"},
+				Status:   kube.PodStatus{Phase: kube.PodSucceeded},
+			},
+			{
+				Metadata: kube.ObjectMeta{Name: "other"},
+				Status:   kube.PodStatus{Phase: kube.PodPending},
+			},
+		},
+	}
+	c := Controller{kc: fc, ca: fca{&config.Config{}}}
+	if err := c.SyncProwJob("missing"); err != nil {
+		t.Fatalf("Error syncing missing prow job: %v", err)
+	}
+	if err := c.SyncProwJob(pj.Metadata.Name); err != nil {
+		t.Fatalf("Error syncing prow job: %v", err)
+	}
+	if fc.prowjobs[0].Status.State != kube.SuccessState {
+		t.Errorf("Expected success, got %s.", fc.prowjobs[0].Status.State)
+	}
+}
+
+func TestQueuePositions(t *testing.T) {
+	now := time.Now()
+	newPJ := func(name, job string, state kube.ProwJobState, max int, age time.Duration) kube.ProwJob {
+		return kube.ProwJob{
+			Metadata: kube.ObjectMeta{Name: name},
+			Spec: kube.ProwJobSpec{
+				Job:            job,
+				MaxConcurrency: max,
+			},
+			Status: kube.ProwJobStatus{
+				StartTime: now.Add(-age),
+				State:     state,
+			},
+		}
+	}
+	var testcases = []struct {
+		name           string
+		pjs            []kube.ProwJob
+		maxConcurrency int
+		expected       map[string]int
+	}{
+		{
+			name: "no limits",
+			pjs: []kube.ProwJob{
+				newPJ("a", "j", kube.PendingState, 0, 3*time.Minute),
+				newPJ("b", "j", kube.TriggeredState, 0, 2*time.Minute),
+				newPJ("c", "k", kube.TriggeredState, 0, time.Minute),
+			},
+			expected: map[string]int{"b": 0, "c": 0},
+		},
+		{
+			name: "per-job limit",
+			pjs: []kube.ProwJob{
+				newPJ("a", "j", kube.PendingState, 1, 3*time.Minute),
+				newPJ("b", "j", kube.TriggeredState, 1, 2*time.Minute),
+				newPJ("c", "k", kube.TriggeredState, 1, time.Minute),
+			},
+			expected: map[string]int{"b": 1, "c": 0},
+		},
+		{
+			name: "global limit is FIFO",
+			pjs: []kube.ProwJob{
+				newPJ("a", "j", kube.PendingState, 0, 4*time.Minute),
+				newPJ("d", "k", kube.TriggeredState, 0, time.Minute),
+				newPJ("c", "k", kube.TriggeredState, 0, 2*time.Minute),
+				newPJ("b", "j", kube.TriggeredState, 0, 3*time.Minute),
+			},
+			maxConcurrency: 2,
+			expected:       map[string]int{"b": 0, "c": 1, "d": 2},
+		},
+		{
+			name: "complete jobs don't count",
+			pjs: []kube.ProwJob{
+				func() kube.ProwJob {
+					pj := newPJ("a", "j", kube.SuccessState, 1, 3*time.Minute)
+					pj.Status.CompletionTime = now
+					return pj
+				}(),
+				newPJ("b", "j", kube.TriggeredState, 1, 2*time.Minute),
+				newPJ("c", "j", kube.TriggeredState, 1, time.Minute),
+			},
+			maxConcurrency: 1,
+			expected:       map[string]int{"b": 0, "c": 1},
+		},
+	}
+	for _, tc := range testcases {
+		positions := QueuePositions(tc.pjs, tc.maxConcurrency)
+		if len(positions) != len(tc.expected) {
+			t.Errorf("For case %s, expected %v, got %v.", tc.name, tc.expected, positions)
+			continue
+		}
+		for name, pos := range tc.expected {
+			if p, ok := positions[name]; !ok || p != pos {
+				t.Errorf("For case %s, expected %s at %d, got %v.", tc.name, name, pos, positions)
+			}
+		}
+	}
+}
+
+func TestMaxConcurrency(t *testing.T) {
+	totServ := httptest.NewServer(http.HandlerFunc(handleTot))
+	defer totServ.Close()
+	per := config.Periodic{
+		Name:           "ci-periodic-job",
+		Spec:           &kube.PodSpec{Containers: []kube.Container{{}}},
+		MaxConcurrency: 1,
+	}
+	first := NewProwJob(PeriodicSpec(per))
+	second := NewProwJob(PeriodicSpec(per))
+	second.Status.StartTime = first.Status.StartTime.Add(time.Second)
+	fc := &fkc{prowjobs: []kube.ProwJob{first, second}}
+	c := Controller{
+		kc:     fc,
+		ca:     fca{&config.Config{}},
+		totURL: totServ.URL,
+	}
+	if err := c.Sync(); err != nil {
+		t.Fatalf("Error on first sync: %v", err)
+	}
+	if len(fc.pods) != 1 {
+		t.Fatalf("Expected one pod, got %d.", len(fc.pods))
+	}
+	if fc.prowjobs[1].Status.State != kube.TriggeredState {
+		t.Fatalf("Second job should still be triggered, got %s.", fc.prowjobs[1].Status.State)
+	}
+	fc.pods[0].Status.Phase = kube.PodSucceeded
+	if err := c.Sync(); err != nil {
+		t.Fatalf("Error on second sync: %v", err)
+	}
+	if err := c.SyncProwJob(second.Metadata.Name); err != nil {
+		t.Fatalf("Error syncing second job: %v", err)
+	}
+	if len(fc.pods) != 2 {
+		t.Fatalf("Expected the second job to start, got %d pods.", len(fc.pods))
+	}
+	if fc.prowjobs[1].Status.State != kube.PendingState {
+		t.Fatalf("Second job should be pending, got %s.", fc.prowjobs[1].Status.State)
+	}
+}
+
+// TestLifecycle runs a presubmit from start to finish with the controller
+// watching a fake apiserver.
+func TestLifecycle(t *testing.T) {
+	totServ := httptest.NewServer(http.HandlerFunc(handleTot))
+	defer totServ.Close()
+	apiserver := fakekube.NewServer()
+	kubeServ := httptest.NewServer(apiserver)
+	defer kubeServ.Close()
+	defer apiserver.Close()
+
+	kca := kube.NewCache(kube.NewClient(kubeServ.URL, "default"))
+	stop := make(chan struct{})
+	defer close(stop)
+	if err := kca.Start(stop); err != nil {
+		t.Fatalf("Error starting cache: %v", err)
+	}
+	c := &Controller{
+		kc:     kca,
+		ca:     fca{&config.Config{}},
+		totURL: totServ.URL,
+		cache:  kca,
+	}
+	go c.Run(stop)
+
+	// Crier watches the same cache and reports to a fake GitHub.
+	fgh := fakegithub.NewServer("k8s-ci-robot")
+	fgh.AddRepo("org", "repo")
+	gh := httptest.NewServer(fgh)
+	defer gh.Close()
+	number, err := fgh.OpenPullRequest("org", "repo", "bob", "Fix the thing", "abcdef", nil)
+	if err != nil {
+		t.Fatalf("Error opening PR: %v", err)
+	}
+	ca := &config.ConfigAgent{}
+	ca.Set(&config.Config{})
+	cc := crier.NewController(kca, github.NewClient("k8s-ci-robot", "", gh.URL), ca, crier.SMTPCredentials{})
+	go cc.Run(stop)
+
+	pre := config.Presubmit{
+		Name:    "pull-some-job",
+		Context: "Some Job Context",
+		Spec:    &kube.PodSpec{Containers: []kube.Container{{Image: "test"}}},
+	}
+	pj, err := kca.CreateProwJob(NewProwJob(PresubmitSpec(pre, kube.Refs{
+		Org:   "org",
+		Repo:  "repo",
+		Pulls: []kube.Pull{{Number: number, Author: "bob", SHA: "abcdef"}},
+	})))
+	if err != nil {
+		t.Fatalf("Error creating prow job: %v", err)
+	}
+	name := pj.Metadata.Name
+
+	waitFor := func(what string, done func(kube.ProwJob) bool) kube.ProwJob {
+		for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(10 * time.Millisecond) {
+			if pj, ok := apiserver.ProwJob("default", name); ok && done(pj) {
+				return pj
+			}
+		}
+		t.Fatalf("Timed out waiting for %s.", what)
+		return kube.ProwJob{}
+	}
+	pj = waitFor("the pod to start", func(pj kube.ProwJob) bool { return pj.Status.PodName != "" })
+	if pj.Status.State != kube.PendingState {
+		t.Errorf("Expected the prow job to be pending, got %s.", pj.Status.State)
+	}
+	waitFor("crier to report pending", func(pj kube.ProwJob) bool {
+		return pj.Status.LastReported[config.GitHubReporterName].State == kube.PendingState
+	})
+	if err := apiserver.SetPodPhase("default", pj.Status.PodName, kube.PodRunning); err != nil {
+		t.Fatalf("Error starting pod: %v", err)
+	}
+	if err := apiserver.SetPodPhase("default", pj.Status.PodName, kube.PodSucceeded); err != nil {
+		t.Fatalf("Error finishing pod: %v", err)
+	}
+	pj = waitFor("the prow job to finish", func(pj kube.ProwJob) bool { return pj.Complete() })
+	if pj.Status.State != kube.SuccessState {
+		t.Errorf("Expected the prow job to succeed, got %s.", pj.Status.State)
+	}
+	var history []kube.ProwJobState
+	for _, h := range pj.Status.History {
+		history = append(history, h.State)
+	}
+	if expected := []kube.ProwJobState{kube.TriggeredState, kube.PendingState, kube.SuccessState}; !reflect.DeepEqual(history, expected) {
+		t.Errorf("Expected history %v, got %v.", expected, history)
+	}
+
+	pj = waitFor("crier to report success", func(pj kube.ProwJob) bool {
+		return pj.Status.LastReported[config.GitHubReporterName].State == kube.SuccessState
+	})
+	var reported []string
+	for _, st := range fgh.Statuses("org", "repo", "abcdef") {
+		if st.Context != "Some Job Context" {
+			t.Errorf("Expected context %q, got %q.", "Some Job Context", st.Context)
+		}
+		reported = append(reported, st.State)
+	}
+	if expected := []string{"pending", "success"}; !reflect.DeepEqual(reported, expected) {
+		t.Errorf("Expected statuses %v, got %v.", expected, reported)
+	}
+}
+
+// conflictingKubeClient fails the next conflicts ProwJob replaces, like
+// the apiserver does when crier updated the ProwJob first.
+type conflictingKubeClient struct {
+	*fkc
+	conflicts int
+}
+
+func (c *conflictingKubeClient) ReplaceProwJob(name string, pj kube.ProwJob) (kube.ProwJob, error) {
+	if c.conflicts > 0 {
+		c.conflicts--
+		return kube.ProwJob{}, kube.ConflictError(fmt.Errorf("conflict"))
+	}
+	return c.fkc.ReplaceProwJob(name, pj)
+}
+
+func TestSyncAfterConflict(t *testing.T) {
+	totServ := httptest.NewServer(http.HandlerFunc(handleTot))
+	defer totServ.Close()
+	pj := NewProwJob(kube.ProwJobSpec{
+		Agent:           kube.KubernetesAgent,
+		Job:             "boop",
+		RunAfterSuccess: []kube.ProwJobSpec{{Agent: kube.KubernetesAgent, Job: "next"}},
+	})
+	fc := &conflictingKubeClient{fkc: &fkc{prowjobs: []kube.ProwJob{pj}}}
+	maxRetries := 1
+	c := Controller{
+		kc:     fc,
+		ca:     fca{&config.Config{Plank: config.Plank{MaxRetries: &maxRetries}}},
+		totURL: totServ.URL,
+	}
+	sync := func() {
+		fc.conflicts = 1
+		if err := c.SyncProwJob(pj.Metadata.Name); err == nil {
+			t.Fatal("Expected the conflict to fail the sync.")
+		}
+		if err := c.SyncProwJob(pj.Metadata.Name); err != nil {
+			t.Fatalf("Error syncing: %v", err)
+		}
+	}
+
+	sync()
+	if len(fc.pods) != 1 {
+		t.Fatalf("Expected one pod after starting, got %d.", len(fc.pods))
+	}
+	pod := fc.pods[0].Metadata.Name
+
+	fc.pods[0].Status.Phase = kube.PodFailed
+	fc.pods[0].Status.Reason = "Evicted"
+	sync()
+	if pj, _ = fc.GetProwJob(pj.Metadata.Name); pj.Status.Retries != 1 {
+		t.Errorf("Expected one retry, got %d.", pj.Status.Retries)
+	}
+	if _, err := fc.GetPod(pod); !kube.IsNotFound(err) {
+		t.Errorf("Expected the evicted pod to be deleted, got %v.", err)
+	}
+
+	sync()
+	if len(fc.pods) != 1 {
+		t.Fatalf("Expected one pod after retrying, got %d.", len(fc.pods))
+	}
+	fc.pods[0].Status.Phase = kube.PodSucceeded
+	sync()
+	if pj, _ = fc.GetProwJob(pj.Metadata.Name); pj.Status.State != kube.SuccessState {
+		t.Errorf("Expected the prow job to succeed, got %s.", pj.Status.State)
+	}
+	if len(fc.prowjobs) != 2 {
+		t.Errorf("Expected one next prow job, got %d prow jobs.", len(fc.prowjobs))
+	}
+}
+
+func TestRecordChange(t *testing.T) {
+	pj := NewProwJob(kube.ProwJobSpec{})
+	recordChange(&pj, kube.TriggeredState, "")
+	if len(pj.Status.History) != 1 {
+		t.Fatalf("Recorded a change that didn't happen: %+v", pj.Status.History)
+	}
+	cached := pj
+	pj.Status.State = kube.PendingState
+	pj.Status.Description = "Job triggered."
+	recordChange(&pj, kube.TriggeredState, "")
+	if len(pj.Status.History) != 2 || pj.Status.History[1].Description != "Job triggered." {
+		t.Fatalf("Expected the change to be recorded, got %+v", pj.Status.History)
+	}
+	if len(cached.Status.History) != 1 {
+		t.Errorf("Changed the history of the cached copy: %+v", cached.Status.History)
+	}
+	for i := 0; i < 2*maxHistory; i++ {
+		pj.Status.Description = fmt.Sprintf("Retry %d.", i)
+		recordChange(&pj, kube.PendingState, "")
+	}
+	if len(pj.Status.History) != maxHistory {
+		t.Errorf("Expected %d changes, got %d.", maxHistory, len(pj.Status.History))
+	}
+	if d := pj.Status.History[maxHistory-1].Description; d != fmt.Sprintf("Retry %d.", 2*maxHistory-1) {
+		t.Errorf("Expected the newest change last, got %s.", d)
+	}
+}
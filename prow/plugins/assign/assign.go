@@ -38,6 +38,29 @@ func init() {
 	plugins.RegisterIssueCommentHandler(pluginName, handleIssueComment)
 	plugins.RegisterIssueHandler(pluginName, handleIssue)
 	plugins.RegisterPullRequestHandler(pluginName, handlePullRequest)
+	plugins.RegisterHelpProvider(pluginName, helpProvider)
+}
+
+func helpProvider(c *plugins.Configuration, org, repo string) plugins.PluginHelp {
+	return plugins.PluginHelp{
+		Description: "Assigns people to issues and PRs and requests reviews from them.",
+		Commands: []plugins.Command{
+			{
+				Usage:       "/[un]assign [@user ...]",
+				Description: "Assigns or unassigns the given people, or yourself if no one is given. Assignees must be org members.",
+				WhoCanUse:   "Anyone",
+				Regex:       assignRe.String(),
+				Examples:    []string{"/assign", "/unassign @alice"},
+			},
+			{
+				Usage:       "/[un]cc @user [@user ...]",
+				Description: "Requests or dismisses a review from the given people. Reviewers must be org members.",
+				WhoCanUse:   "Anyone",
+				Regex:       ccRe.String(),
+				Examples:    []string{"/cc @alice @bob"},
+			},
+		},
+	}
 }
 
 type githubClient interface {
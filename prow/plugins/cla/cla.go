@@ -29,9 +29,6 @@ import (
 
 const (
 	pluginName             = "cla"
-	claContextName         = "cla/linuxfoundation"
-	claYesLabel            = "cncf-cla: yes"
-	claNoLabel             = "cncf-cla: no"
 	cncfclaNotFoundMessage = `Thanks for your pull request. Before we can look at your pull request, you'll need to sign a Contributor License Agreement (CLA).
 
 :memo: **Please follow instructions at <https://github.com/kubernetes/kubernetes/wiki/CLA-FAQ> to sign the CLA.**
@@ -55,6 +52,14 @@ It may take a couple minutes for the CLA signature to be fully registered; after
 
 func init() {
 	plugins.RegisterStatusEventHandler(pluginName, handleStatusEvent)
+	plugins.RegisterHelpProvider(pluginName, helpProvider)
+}
+
+func helpProvider(c *plugins.Configuration, org, repo string) plugins.PluginHelp {
+	cla := c.CLAFor(org, repo)
+	return plugins.PluginHelp{
+		Description: fmt.Sprintf("Labels PRs with %q or %q according to the %s status.", cla.YesLabel, cla.NoLabel, cla.Context),
+	}
 }
 
 type gitHubClient interface {
@@ -66,7 +71,7 @@ type gitHubClient interface {
 }
 
 func handleStatusEvent(pc plugins.PluginClient, se github.StatusEvent) error {
-	return handle(pc.GitHubClient, pc.Logger, se)
+	return handle(pc.GitHubClient, pc.Logger, pc.PluginConfig.CLAFor(se.Repo.Owner.Login, se.Repo.Name), se)
 }
 
 // 1. Check that the status event received from the webhook is for the CNCF-CLA.
@@ -74,12 +79,12 @@ func handleStatusEvent(pc plugins.PluginClient, se github.StatusEvent) error {
 // 3. For each issue that matches, check that the PR's HEAD commit hash against the commit hash for which the status
 //    was received. This is because we only care about the status associated with the last (latest) commit in a PR.
 // 4. Set the corresponding CLA label if needed.
-func handle(gc gitHubClient, log *logrus.Entry, se github.StatusEvent) error {
+func handle(gc gitHubClient, log *logrus.Entry, cfg plugins.CLA, se github.StatusEvent) error {
 	if se.State == "" || se.Context == "" {
 		return fmt.Errorf("invalid status event delivered with empty state/context")
 	}
 
-	if se.Context != claContextName {
+	if se.Context != cfg.Context {
 		// Not the CNCF CLA context, do not process this.
 		return nil
 	}
@@ -109,17 +114,17 @@ func handle(gc gitHubClient, log *logrus.Entry, se github.StatusEvent) error {
 
 	for _, issue := range issues {
 		l := log.WithField("pr", issue.Number)
-		hasCncfYes := issue.HasLabel(claYesLabel)
-		hasCncfNo := issue.HasLabel(claNoLabel)
+		hasCncfYes := issue.HasLabel(cfg.YesLabel)
+		hasCncfNo := issue.HasLabel(cfg.NoLabel)
 		if hasCncfYes && se.State == github.StatusSuccess {
 			// Nothing to update.
-			l.Infof("PR has up-to-date %s label.", claYesLabel)
+			l.Infof("PR has up-to-date %s label.", cfg.YesLabel)
 			continue
 		}
 
 		if hasCncfNo && (se.State == github.StatusFailure || se.State == github.StatusError) {
 			// Nothing to update.
-			l.Infof("PR has up-to-date %s label.", claNoLabel)
+			l.Infof("PR has up-to-date %s label.", cfg.NoLabel)
 			continue
 		}
 
@@ -139,27 +144,27 @@ func handle(gc gitHubClient, log *logrus.Entry, se github.StatusEvent) error {
 		number := pr.Number
 		if se.State == github.StatusSuccess {
 			if hasCncfNo {
-				if err := gc.RemoveLabel(org, repo, number, claNoLabel); err != nil {
-					l.WithError(err).Warningf("Could not remove %s label.", claNoLabel)
+				if err := gc.RemoveLabel(org, repo, number, cfg.NoLabel); err != nil {
+					l.WithError(err).Warningf("Could not remove %s label.", cfg.NoLabel)
 				}
 			}
-			if err := gc.AddLabel(org, repo, number, claYesLabel); err != nil {
-				l.WithError(err).Warningf("Could not add %s label.", claYesLabel)
+			if err := gc.AddLabel(org, repo, number, cfg.YesLabel); err != nil {
+				l.WithError(err).Warningf("Could not add %s label.", cfg.YesLabel)
 			}
 			continue
 		}
 
 		// If we end up here, the status is a failure/error.
 		if hasCncfYes {
-			if err := gc.RemoveLabel(org, repo, number, claYesLabel); err != nil {
-				l.WithError(err).Warningf("Could not remove %s label.", claYesLabel)
+			if err := gc.RemoveLabel(org, repo, number, cfg.YesLabel); err != nil {
+				l.WithError(err).Warningf("Could not remove %s label.", cfg.YesLabel)
 			}
 		}
 		if err := gc.CreateComment(org, repo, number, fmt.Sprintf(cncfclaNotFoundMessage, plugins.AboutThisBot)); err != nil {
 			l.WithError(err).Warning("Could not create CLA not found comment.")
 		}
-		if err := gc.AddLabel(org, repo, number, claNoLabel); err != nil {
-			l.WithError(err).Warningf("Could not add %s label.", claNoLabel)
+		if err := gc.AddLabel(org, repo, number, cfg.NoLabel); err != nil {
+			l.WithError(err).Warningf("Could not add %s label.", cfg.NoLabel)
 		}
 	}
 	return nil
@@ -31,6 +31,19 @@ var closeRe = regexp.MustCompile(`(?mi)^\/close\r?$`)
 
 func init() {
 	plugins.RegisterIssueCommentHandler(pluginName, handleIssueComment)
+	plugins.RegisterHelpProvider(pluginName, helpProvider)
+}
+
+func helpProvider(c *plugins.Configuration, org, repo string) plugins.PluginHelp {
+	return plugins.PluginHelp{
+		Description: "Closes issues.",
+		Commands: []plugins.Command{{
+			Usage:       "/close",
+			Description: "Closes the issue.",
+			WhoCanUse:   "Authors and assignees",
+			Regex:       closeRe.String(),
+		}},
+	}
 }
 
 type githubClient interface {
@@ -0,0 +1,413 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugins
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/ghodss/yaml"
+)
+
+// Configuration is the contents of plugins.yaml. Besides which plugins are
+// enabled where, it holds settings for individual plugins. Each setting block
+// applies to the orgs and org/repos in its Repos, and a block for a repo wins
+// over one for its org. Repos without a block get the defaults.
+type Configuration struct {
+	// Plugins maps an org or an org/repo to the plugins enabled on it.
+	Plugins map[string][]string `json:"plugins,omitempty"`
+	// ExternalPlugins maps an org or an org/repo to the external plugins
+	// that hook forwards its events to.
+	ExternalPlugins map[string][]ExternalPlugin `json:"external_plugins,omitempty"`
+
+	Triggers []Trigger `json:"triggers,omitempty"`
+	Lgtm     []Lgtm    `json:"lgtm,omitempty"`
+	Label    []Label   `json:"label,omitempty"`
+	CLA      []CLA     `json:"cla,omitempty"`
+}
+
+// ExternalPlugin is an HTTP service that hook forwards webhooks to. Hook
+// signs each one with the plugin's own HMAC secret and sets the same headers
+// as GitHub, so the service can validate it like hook does. A service that
+// doesn't return 2xx is retried like any other plugin.
+type ExternalPlugin struct {
+	// Name identifies the plugin in logs and in hook's queue. It may not
+	// clash with a built-in plugin.
+	Name string `json:"name"`
+	// Endpoint is the http or https URL to POST webhooks to.
+	Endpoint string `json:"endpoint"`
+	// HMACSecretFile is the path to the secret that hook signs the webhooks
+	// with. It must not be hook's own GitHub secret, or the plugin could
+	// forge webhooks to hook.
+	HMACSecretFile string `json:"hmac_secret_file"`
+	// Events are the event types to forward, such as pull_request. If empty,
+	// every event type that hook handles is forwarded.
+	Events []string `json:"events,omitempty"`
+}
+
+// EventTypes are the GitHub event types that hook dispatches to plugins.
+var EventTypes = []string{
+	"issues",
+	"issue_comment",
+	"pull_request",
+	"pull_request_review",
+	"pull_request_review_comment",
+	"push",
+	"status",
+	"create",
+	"delete",
+	"release",
+	"check_run",
+}
+
+// Trigger configures the trigger plugin.
+type Trigger struct {
+	// Repos are the orgs and org/repos this applies to.
+	Repos []string `json:"repos"`
+	// TrustedOrg is the org whose members' PRs are tested automatically and
+	// who may say "ok to test". Defaults to the repo's org.
+	TrustedOrg string `json:"trusted_org,omitempty"`
+}
+
+// Lgtm configures the lgtm plugin, and trigger's handling of LGTMd PRs.
+type Lgtm struct {
+	Repos []string `json:"repos"`
+	// Label is the label that /lgtm applies. Defaults to "lgtm".
+	Label string `json:"label,omitempty"`
+}
+
+// Label configures the label plugin.
+type Label struct {
+	Repos []string `json:"repos"`
+	// Prefixes are the label prefixes that may be added with /<prefix> and
+	// removed with /remove-<prefix>. Defaults to area, priority and kind.
+	Prefixes []string `json:"prefixes,omitempty"`
+}
+
+// CLA configures the cla plugin.
+type CLA struct {
+	Repos []string `json:"repos"`
+	// Context is the status context of the CLA check. Defaults to
+	// "cla/linuxfoundation".
+	Context string `json:"context,omitempty"`
+	// YesLabel and NoLabel mark PRs whose authors have and haven't signed.
+	// They default to "cncf-cla: yes" and "cncf-cla: no".
+	YesLabel string `json:"yes_label,omitempty"`
+	NoLabel  string `json:"no_label,omitempty"`
+}
+
+var labelPrefixRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+
+// parseConfiguration unmarshals and validates plugins.yaml.
+func parseConfiguration(b []byte) (*Configuration, error) {
+	// Catch old-style files that list plugins at the top level, since they
+	// would otherwise quietly disable every plugin. Files that mix the two
+	// formats are rejected.
+	var fields map[string]interface{}
+	if err := yaml.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	var unknown []string
+	for k := range fields {
+		switch k {
+		case "plugins", "external_plugins", "triggers", "lgtm", "label", "cla":
+		default:
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) > 0 && len(unknown) == len(fields) {
+		// Still accept the old format for one release so that hook keeps
+		// running with an unmigrated ConfigMap.
+		var plugins map[string][]string
+		if err := yaml.Unmarshal(b, &plugins); err == nil {
+			logrus.Warn("Plugins are listed at the top level of plugins.yaml. This is deprecated, move them under \"plugins\".")
+			c := &Configuration{Plugins: plugins}
+			if err := c.validate(); err != nil {
+				return nil, err
+			}
+			return c, nil
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown field %q, enabled plugins belong under \"plugins\"", unknown[0])
+	}
+	c := &Configuration{}
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Configuration) validate() error {
+	// Check that there are no plugins that we don't know about.
+	for _, v := range c.Plugins {
+		for _, p := range v {
+			if _, ok := allPlugins[p]; !ok {
+				return fmt.Errorf("unknown plugin: %s", p)
+			}
+		}
+	}
+	// Check that there are no duplicates.
+	for k, v := range c.Plugins {
+		if strings.Contains(k, "/") {
+			org := strings.Split(k, "/")[0]
+			for _, p1 := range v {
+				for _, p2 := range c.Plugins[org] {
+					if p1 == p2 {
+						return fmt.Errorf("plugin %s is duplicated for %s and %s", p1, k, org)
+					}
+				}
+			}
+		}
+	}
+	if err := validateExternalPlugins(c.ExternalPlugins); err != nil {
+		return err
+	}
+
+	var repos [][]string
+	for _, t := range c.Triggers {
+		repos = append(repos, t.Repos)
+	}
+	if err := validateRepos("triggers", repos); err != nil {
+		return err
+	}
+	repos = nil
+	for _, l := range c.Lgtm {
+		repos = append(repos, l.Repos)
+	}
+	if err := validateRepos("lgtm", repos); err != nil {
+		return err
+	}
+	repos = nil
+	for _, l := range c.Label {
+		repos = append(repos, l.Repos)
+		for _, p := range l.Prefixes {
+			if !labelPrefixRe.MatchString(p) {
+				return fmt.Errorf("label: invalid prefix %q for %s", p, strings.Join(l.Repos, ", "))
+			}
+		}
+	}
+	if err := validateRepos("label", repos); err != nil {
+		return err
+	}
+	repos = nil
+	for _, cla := range c.CLA {
+		repos = append(repos, cla.Repos)
+		cla = cla.withDefaults()
+		if cla.YesLabel == cla.NoLabel {
+			return fmt.Errorf("cla: yes and no labels are both %q for %s", cla.YesLabel, strings.Join(cla.Repos, ", "))
+		}
+	}
+	return validateRepos("cla", repos)
+}
+
+func validateExternalPlugins(eps map[string][]ExternalPlugin) error {
+	known := map[string]bool{}
+	for _, e := range EventTypes {
+		known[e] = true
+	}
+	for k, v := range eps {
+		names := map[string]bool{}
+		for _, ep := range v {
+			if ep.Name == "" {
+				return fmt.Errorf("external plugin for %s has no name", k)
+			}
+			if _, ok := allPlugins[ep.Name]; ok {
+				return fmt.Errorf("external plugin %s for %s has the same name as a built-in plugin", ep.Name, k)
+			}
+			if names[ep.Name] {
+				return fmt.Errorf("external plugin %s is configured more than once for %s", ep.Name, k)
+			}
+			names[ep.Name] = true
+			u, err := url.Parse(ep.Endpoint)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("external plugin %s for %s has invalid endpoint %q", ep.Name, k, ep.Endpoint)
+			}
+			if ep.HMACSecretFile == "" {
+				return fmt.Errorf("external plugin %s for %s has no hmac_secret_file", ep.Name, k)
+			}
+			for _, e := range ep.Events {
+				if !known[e] {
+					return fmt.Errorf("external plugin %s for %s wants unknown event type %s", ep.Name, k, e)
+				}
+			}
+		}
+	}
+	// Check that there are no duplicates between an org and its repos.
+	for k, v := range eps {
+		if strings.Contains(k, "/") {
+			org := strings.Split(k, "/")[0]
+			for _, ep1 := range v {
+				for _, ep2 := range eps[org] {
+					if ep1.Name == ep2.Name {
+						return fmt.Errorf("external plugin %s is duplicated for %s and %s", ep1.Name, k, org)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ExternalPluginsFor returns the external plugins that want events of the
+// given type from org/repo.
+func (c *Configuration) ExternalPluginsFor(org, repo, eventType string) []ExternalPlugin {
+	if c == nil {
+		return nil
+	}
+	var eps []ExternalPlugin
+	for _, ep := range append(c.ExternalPlugins[org], c.ExternalPlugins[org+"/"+repo]...) {
+		if len(ep.Events) == 0 {
+			eps = append(eps, ep)
+			continue
+		}
+		for _, e := range ep.Events {
+			if e == eventType {
+				eps = append(eps, ep)
+				break
+			}
+		}
+	}
+	return eps
+}
+
+// validateRepos checks that each block of a section lists some orgs or
+// org/repos and that none of them are listed twice.
+func validateRepos(section string, blocks [][]string) error {
+	seen := map[string]bool{}
+	for i, repos := range blocks {
+		if len(repos) == 0 {
+			return fmt.Errorf("%s: block %d has no repos", section, i)
+		}
+		for _, r := range repos {
+			parts := strings.Split(r, "/")
+			if len(parts) > 2 || parts[0] == "" || (len(parts) == 2 && parts[1] == "") {
+				return fmt.Errorf("%s: %q is not an org or org/repo", section, r)
+			}
+			if seen[r] {
+				return fmt.Errorf("%s: %s is configured more than once", section, r)
+			}
+			seen[r] = true
+		}
+	}
+	return nil
+}
+
+// bestMatch returns the index of the block that applies to org/repo, or -1 if
+// none does.
+func bestMatch(blocks [][]string, org, repo string) int {
+	fullName := org + "/" + repo
+	best := -1
+	for i, repos := range blocks {
+		for _, r := range repos {
+			if r == fullName {
+				return i
+			} else if r == org {
+				best = i
+			}
+		}
+	}
+	return best
+}
+
+// TriggerFor returns the trigger settings for org/repo.
+func (c *Configuration) TriggerFor(org, repo string) Trigger {
+	var t Trigger
+	if c != nil {
+		var blocks [][]string
+		for _, b := range c.Triggers {
+			blocks = append(blocks, b.Repos)
+		}
+		if i := bestMatch(blocks, org, repo); i >= 0 {
+			t = c.Triggers[i]
+		}
+	}
+	if t.TrustedOrg == "" {
+		t.TrustedOrg = org
+	}
+	return t
+}
+
+// LgtmFor returns the lgtm settings for org/repo.
+func (c *Configuration) LgtmFor(org, repo string) Lgtm {
+	var l Lgtm
+	if c != nil {
+		var blocks [][]string
+		for _, b := range c.Lgtm {
+			blocks = append(blocks, b.Repos)
+		}
+		if i := bestMatch(blocks, org, repo); i >= 0 {
+			l = c.Lgtm[i]
+		}
+	}
+	if l.Label == "" {
+		l.Label = "lgtm"
+	}
+	return l
+}
+
+// LabelFor returns the label settings for org/repo.
+func (c *Configuration) LabelFor(org, repo string) Label {
+	var l Label
+	if c != nil {
+		var blocks [][]string
+		for _, b := range c.Label {
+			blocks = append(blocks, b.Repos)
+		}
+		if i := bestMatch(blocks, org, repo); i >= 0 {
+			l = c.Label[i]
+		}
+	}
+	if len(l.Prefixes) == 0 {
+		l.Prefixes = []string{"area", "priority", "kind"}
+	}
+	return l
+}
+
+// CLAFor returns the cla settings for org/repo.
+func (c *Configuration) CLAFor(org, repo string) CLA {
+	var cla CLA
+	if c != nil {
+		var blocks [][]string
+		for _, b := range c.CLA {
+			blocks = append(blocks, b.Repos)
+		}
+		if i := bestMatch(blocks, org, repo); i >= 0 {
+			cla = c.CLA[i]
+		}
+	}
+	return cla.withDefaults()
+}
+
+func (cla CLA) withDefaults() CLA {
+	if cla.Context == "" {
+		cla.Context = "cla/linuxfoundation"
+	}
+	if cla.YesLabel == "" {
+		cla.YesLabel = "cncf-cla: yes"
+	}
+	if cla.NoLabel == "" {
+		cla.NoLabel = "cncf-cla: no"
+	}
+	return cla
+}
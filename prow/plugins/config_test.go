@@ -0,0 +1,169 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugins
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseConfiguration(t *testing.T) {
+	allPlugins["config-test"] = struct{}{}
+	var testcases = []struct {
+		name   string
+		config string
+		valid  bool
+	}{
+		{
+			name:   "plugins and settings",
+			config: "plugins:\n  org:\n  - config-test\ntriggers:\n- repos: [org, other/repo]\n  trusted_org: org\n",
+			valid:  true,
+		},
+		{
+			name:   "old style top level plugin list",
+			config: "org:\n- config-test\nother/repo:\n- config-test\n",
+			valid:  true,
+		},
+		{
+			name:   "old style list with an unknown plugin",
+			config: "org:\n- nope\n",
+		},
+		{
+			name:   "old style list mixed with settings",
+			config: "org:\n- config-test\ntriggers:\n- repos: [org]\n",
+		},
+		{
+			name:   "unknown plugin",
+			config: "plugins:\n  org:\n  - nope\n",
+		},
+		{
+			name:   "plugin enabled on org and repo",
+			config: "plugins:\n  org:\n  - config-test\n  org/repo:\n  - config-test\n",
+		},
+		{
+			name:   "settings without repos",
+			config: "lgtm:\n- label: approved\n",
+		},
+		{
+			name:   "bad repo",
+			config: "lgtm:\n- repos: [org/repo/extra]\n",
+		},
+		{
+			name:   "repo configured twice",
+			config: "label:\n- repos: [org]\n- repos: [org/repo, org]\n",
+		},
+		{
+			name:   "bad label prefix",
+			config: "label:\n- repos: [org]\n  prefixes: [\"area|kind\"]\n",
+		},
+		{
+			name:   "external plugin",
+			config: "external_plugins:\n  org:\n  - name: ext\n    endpoint: http://ext.default:8080/hook\n    hmac_secret_file: /etc/ext/hmac\n    events: [push]\n",
+			valid:  true,
+		},
+		{
+			name:   "external plugin with a built-in name",
+			config: "external_plugins:\n  org:\n  - name: config-test\n    endpoint: http://ext\n    hmac_secret_file: /etc/ext/hmac\n",
+		},
+		{
+			name:   "external plugin with a bad endpoint",
+			config: "external_plugins:\n  org:\n  - name: ext\n    endpoint: ext:8080\n    hmac_secret_file: /etc/ext/hmac\n",
+		},
+		{
+			name:   "external plugin without a secret",
+			config: "external_plugins:\n  org:\n  - name: ext\n    endpoint: http://ext\n",
+		},
+		{
+			name:   "external plugin with an unknown event",
+			config: "external_plugins:\n  org:\n  - name: ext\n    endpoint: http://ext\n    hmac_secret_file: /etc/ext/hmac\n    events: [fork]\n",
+		},
+		{
+			name:   "external plugin on org and repo",
+			config: "external_plugins:\n  org:\n  - name: ext\n    endpoint: http://ext\n    hmac_secret_file: /etc/ext/hmac\n  org/repo:\n  - name: ext\n    endpoint: http://ext\n    hmac_secret_file: /etc/ext/hmac\n",
+		},
+		{
+			name:   "same cla labels",
+			config: "cla:\n- repos: [org]\n  yes_label: cla\n  no_label: cla\n",
+		},
+	}
+	for _, tc := range testcases {
+		_, err := parseConfiguration([]byte(tc.config))
+		if tc.valid && err != nil {
+			t.Errorf("For case %s, didn't expect error: %v", tc.name, err)
+		} else if !tc.valid && err == nil {
+			t.Errorf("For case %s, expected an error.", tc.name)
+		}
+	}
+}
+
+func TestParseOldStyleConfiguration(t *testing.T) {
+	allPlugins["config-test"] = struct{}{}
+	c, err := parseConfiguration([]byte("org:\n- config-test\n"))
+	if err != nil {
+		t.Fatalf("Didn't expect error: %v", err)
+	}
+	if !reflect.DeepEqual(c.Plugins, map[string][]string{"org": {"config-test"}}) {
+		t.Errorf("Expected the top level list to be read as plugins, got %v.", c.Plugins)
+	}
+}
+
+func TestSettingsFor(t *testing.T) {
+	c, err := parseConfiguration([]byte(`
+triggers:
+- repos: [org]
+  trusted_org: trusted
+- repos: [org/special]
+  trusted_org: special
+lgtm:
+- repos: [org/repo]
+  label: looks-good
+label:
+- repos: [org]
+  prefixes: [triage]
+cla:
+- repos: [org]
+  context: cla/google
+`))
+	if err != nil {
+		t.Fatalf("Error parsing config: %v", err)
+	}
+	if got := c.TriggerFor("org", "repo").TrustedOrg; got != "trusted" {
+		t.Errorf("Expected org/repo to trust the org block's org, got %s.", got)
+	}
+	if got := c.TriggerFor("org", "special").TrustedOrg; got != "special" {
+		t.Errorf("Expected the repo block to win for org/special, got %s.", got)
+	}
+	if got := c.TriggerFor("other", "repo").TrustedOrg; got != "other" {
+		t.Errorf("Expected other/repo to trust its own org, got %s.", got)
+	}
+	if got := c.LgtmFor("org", "repo").Label; got != "looks-good" {
+		t.Errorf("Expected label looks-good for org/repo, got %s.", got)
+	}
+	if got := c.LgtmFor("org", "other").Label; got != "lgtm" {
+		t.Errorf("Expected the default label for org/other, got %s.", got)
+	}
+	if got := c.LabelFor("org", "repo").Prefixes; !reflect.DeepEqual(got, []string{"triage"}) {
+		t.Errorf("Expected prefixes [triage] for org/repo, got %v.", got)
+	}
+	if got := c.CLAFor("org", "repo"); got.Context != "cla/google" || got.YesLabel != "cncf-cla: yes" {
+		t.Errorf("Expected the cla/google context with default labels, got %+v.", got)
+	}
+	var empty *Configuration
+	if got := empty.LabelFor("org", "repo").Prefixes; !reflect.DeepEqual(got, []string{"area", "priority", "kind"}) {
+		t.Errorf("Expected the default prefixes without a config, got %v.", got)
+	}
+}
@@ -45,6 +45,13 @@ var reactions = []string{
 func init() {
 	plugins.RegisterIssueCommentHandler(pluginName, handleIssueComment)
 	plugins.RegisterPullRequestHandler(pluginName, handlePullRequest)
+	plugins.RegisterHelpProvider(pluginName, helpProvider)
+}
+
+func helpProvider(c *plugins.Configuration, org, repo string) plugins.PluginHelp {
+	return plugins.PluginHelp{
+		Description: "Reacts happily to the merge bot's merge comments and to new PRs that add to OWNERS files.",
+	}
 }
 
 type githubClient interface {
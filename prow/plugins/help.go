@@ -0,0 +1,135 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var helpProviders = map[string]HelpProvider{}
+
+// PluginHelp describes what a plugin does and the commands it understands.
+type PluginHelp struct {
+	Description string    `json:"description"`
+	Commands    []Command `json:"commands,omitempty"`
+}
+
+// Command is a command that a plugin understands.
+type Command struct {
+	// Usage shows how to write the command, such as "/close".
+	Usage       string `json:"usage"`
+	Description string `json:"description"`
+	// WhoCanUse says who the plugin accepts the command from, such as
+	// "Anyone" or "Members of the kubernetes org".
+	WhoCanUse string `json:"who_can_use"`
+	// Regex is the regular expression that the plugin matches against
+	// comments.
+	Regex    string   `json:"regex"`
+	Examples []string `json:"examples,omitempty"`
+}
+
+// HelpProvider returns the help for a plugin on org/repo. Repo is empty when
+// the help is for a whole org. The configuration may be nil.
+type HelpProvider func(c *Configuration, org, repo string) PluginHelp
+
+// RegisterHelpProvider sets the help for a plugin. Plugins that have commands
+// should register one in their init functions next to their handlers.
+func RegisterHelpProvider(name string, fn HelpProvider) {
+	helpProviders[name] = fn
+}
+
+// Help returns the help for each plugin enabled on org/repo, including
+// external plugins. If repo is empty, only the plugins enabled on the whole
+// org are included.
+func (c *Configuration) Help(org, repo string) map[string]PluginHelp {
+	help := map[string]PluginHelp{}
+	if c == nil {
+		return help
+	}
+	keys := []string{org}
+	if repo != "" {
+		keys = append(keys, org+"/"+repo)
+	}
+	for _, k := range keys {
+		for _, p := range c.Plugins[k] {
+			if fn, ok := helpProviders[p]; ok {
+				help[p] = fn(c, org, repo)
+			} else {
+				help[p] = PluginHelp{Description: "No help is available for this plugin."}
+			}
+		}
+		for _, ep := range c.ExternalPlugins[k] {
+			events := "every event"
+			if len(ep.Events) > 0 {
+				events = strings.Join(ep.Events, ", ")
+			}
+			help[ep.Name] = PluginHelp{Description: fmt.Sprintf("External plugin that receives %s.", events)}
+		}
+	}
+	return help
+}
+
+// AllHelp returns the help for every org and org/repo that has plugins
+// enabled, keyed by the org or org/repo.
+func (c *Configuration) AllHelp() map[string]map[string]PluginHelp {
+	all := map[string]map[string]PluginHelp{}
+	if c == nil {
+		return all
+	}
+	for k := range c.Plugins {
+		all[k] = nil
+	}
+	for k := range c.ExternalPlugins {
+		all[k] = nil
+	}
+	for k := range all {
+		parts := strings.SplitN(k, "/", 2)
+		if len(parts) == 2 {
+			all[k] = c.Help(parts[0], parts[1])
+		} else {
+			all[k] = c.Help(k, "")
+		}
+	}
+	return all
+}
+
+// ServeHelp serves the help of every plugin as JSON, keyed by org or org/repo.
+// With ?repo=org/repo it serves only the help that applies to that repo.
+func (pa *PluginAgent) ServeHelp(w http.ResponseWriter, r *http.Request) {
+	c := pa.Config()
+	var v interface{}
+	if fullName := r.URL.Query().Get("repo"); fullName != "" {
+		parts := strings.SplitN(fullName, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			http.Error(w, fmt.Sprintf("Invalid repo %q, expected org/repo.", fullName), http.StatusBadRequest)
+			return
+		}
+		v = c.Help(parts[0], parts[1])
+	} else {
+		v = c.AllHelp()
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error marshaling help: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package help replies to /help with the commands enabled on the repo.
+package help
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+const pluginName = "help"
+
+var helpRe = regexp.MustCompile(`(?mi)^/help\s*$`)
+
+func init() {
+	plugins.RegisterIssueCommentHandler(pluginName, handleIssueComment)
+	plugins.RegisterHelpProvider(pluginName, helpProvider)
+}
+
+func helpProvider(c *plugins.Configuration, org, repo string) plugins.PluginHelp {
+	return plugins.PluginHelp{
+		Description: "Lists the commands that are enabled on the repo.",
+		Commands: []plugins.Command{{
+			Usage:       "/help",
+			Description: "Replies with the commands that the bot understands here.",
+			WhoCanUse:   "Anyone",
+			Regex:       helpRe.String(),
+		}},
+	}
+}
+
+type githubClient interface {
+	CreateComment(owner, repo string, number int, comment string) error
+}
+
+func handleIssueComment(pc plugins.PluginClient, ic github.IssueCommentEvent) error {
+	return handle(pc.GitHubClient, pc.Logger, pc.PluginConfig, ic)
+}
+
+func handle(gc githubClient, log *logrus.Entry, c *plugins.Configuration, ic github.IssueCommentEvent) error {
+	if ic.Action != "created" || !helpRe.MatchString(ic.Comment.Body) {
+		return nil
+	}
+	org := ic.Repo.Owner.Login
+	repo := ic.Repo.Name
+	log.Info("Listing commands.")
+	resp := "these are the commands I understand here:\n\n" + commandTable(c.Help(org, repo))
+	return gc.CreateComment(org, repo, ic.Issue.Number, plugins.FormatICResponse(ic.Comment, resp))
+}
+
+// commandTable formats the commands of each plugin as a markdown table,
+// sorted by plugin.
+func commandTable(help map[string]plugins.PluginHelp) string {
+	var names []string
+	for name := range help {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b bytes.Buffer
+	b.WriteString("Command | Plugin | Who can use it | Description\n--- | --- | --- | ---\n")
+	for _, name := range names {
+		for _, c := range help[name].Commands {
+			fmt.Fprintf(&b, "`%s` | %s | %s | %s\n", c.Usage, name, c.WhoCanUse, strings.Replace(c.Description, "|", "\\|", -1))
+		}
+	}
+	return b.String()
+}
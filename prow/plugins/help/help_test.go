@@ -0,0 +1,115 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package help
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+func TestHelpComment(t *testing.T) {
+	var testcases = []struct {
+		name          string
+		action        string
+		body          string
+		shouldComment bool
+	}{
+		{
+			name:          "help",
+			action:        "created",
+			body:          "/help",
+			shouldComment: true,
+		},
+		{
+			name:          "help on its own line",
+			action:        "created",
+			body:          "I'm lost.\n/help\nThanks!",
+			shouldComment: true,
+		},
+		{
+			name:          "help in a sentence",
+			action:        "created",
+			body:          "can someone /help me",
+			shouldComment: false,
+		},
+		{
+			name:          "edited comment",
+			action:        "edited",
+			body:          "/help",
+			shouldComment: false,
+		},
+	}
+	c := &plugins.Configuration{
+		Plugins: map[string][]string{
+			"org":      {"help"},
+			"org/repo": {"mystery"},
+		},
+	}
+	for _, tc := range testcases {
+		fc := &fakegithub.FakeClient{IssueComments: map[int][]github.IssueComment{}}
+		ic := github.IssueCommentEvent{
+			Action: tc.action,
+			Repo: github.Repo{
+				Owner: github.User{Login: "org"},
+				Name:  "repo",
+			},
+			Issue: github.Issue{Number: 5},
+			Comment: github.IssueComment{
+				Body: tc.body,
+				User: github.User{Login: "u"},
+			},
+		}
+		if err := handle(fc, logrus.WithField("plugin", pluginName), c, ic); err != nil {
+			t.Errorf("For case %s, didn't expect error: %v", tc.name, err)
+			continue
+		}
+		comments := fc.IssueComments[5]
+		if !tc.shouldComment {
+			if len(comments) != 0 {
+				t.Errorf("For case %s, didn't expect a comment, got %q", tc.name, comments[0].Body)
+			}
+			continue
+		}
+		if len(comments) != 1 {
+			t.Errorf("For case %s, expected one comment, got %d", tc.name, len(comments))
+			continue
+		}
+		if !strings.Contains(comments[0].Body, "`/help` | help | Anyone |") {
+			t.Errorf("For case %s, expected /help in the table, got %q", tc.name, comments[0].Body)
+		}
+	}
+}
+
+func TestCommandTable(t *testing.T) {
+	help := map[string]plugins.PluginHelp{
+		"b": {Commands: []plugins.Command{{Usage: "/b", WhoCanUse: "Anyone", Description: "Does b|c."}}},
+		"a": {Commands: []plugins.Command{{Usage: "/a", WhoCanUse: "Members", Description: "Does a."}}},
+		"c": {Description: "No commands."},
+	}
+	expected := "Command | Plugin | Who can use it | Description\n--- | --- | --- | ---\n" +
+		"`/a` | a | Members | Does a.\n" +
+		"`/b` | b | Anyone | Does b\\|c.\n"
+	if got := commandTable(help); got != expected {
+		t.Errorf("Expected table:\n%s\ngot:\n%s", expected, got)
+	}
+}
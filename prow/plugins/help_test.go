@@ -0,0 +1,144 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugins
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestHelp(t *testing.T) {
+	RegisterHelpProvider("help-test", func(c *Configuration, org, repo string) PluginHelp {
+		return PluginHelp{Description: "Help for " + c.LgtmFor(org, repo).Label}
+	})
+	c := &Configuration{
+		Plugins: map[string][]string{
+			"org":      {"help-test"},
+			"org/repo": {"no-help"},
+		},
+		ExternalPlugins: map[string][]ExternalPlugin{
+			"org/repo": {
+				{Name: "ext", Endpoint: "http://ext"},
+				{Name: "ext-issues", Endpoint: "http://ext", Events: []string{"issues", "push"}},
+			},
+		},
+		Lgtm: []Lgtm{{Repos: []string{"org/repo"}, Label: "approved"}},
+	}
+	var testcases = []struct {
+		name     string
+		org      string
+		repo     string
+		expected map[string]PluginHelp
+	}{
+		{
+			name: "repo",
+			org:  "org",
+			repo: "repo",
+			expected: map[string]PluginHelp{
+				"help-test":  {Description: "Help for approved"},
+				"no-help":    {Description: "No help is available for this plugin."},
+				"ext":        {Description: "External plugin that receives every event."},
+				"ext-issues": {Description: "External plugin that receives issues, push."},
+			},
+		},
+		{
+			name: "org",
+			org:  "org",
+			expected: map[string]PluginHelp{
+				"help-test": {Description: "Help for lgtm"},
+			},
+		},
+		{
+			name:     "other org",
+			org:      "other",
+			repo:     "repo",
+			expected: map[string]PluginHelp{},
+		},
+	}
+	for _, tc := range testcases {
+		if got := c.Help(tc.org, tc.repo); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("For case %s, expected %+v, got %+v", tc.name, tc.expected, got)
+		}
+	}
+
+	all := c.AllHelp()
+	if len(all) != 2 {
+		t.Fatalf("Expected help for org and org/repo, got %+v", all)
+	}
+	if !reflect.DeepEqual(all["org/repo"], c.Help("org", "repo")) {
+		t.Errorf("Expected help for org/repo to match Help, got %+v", all["org/repo"])
+	}
+	if !reflect.DeepEqual(all["org"], c.Help("org", "")) {
+		t.Errorf("Expected help for org to match Help, got %+v", all["org"])
+	}
+
+	var nilConfig *Configuration
+	if len(nilConfig.Help("org", "repo")) != 0 || len(nilConfig.AllHelp()) != 0 {
+		t.Error("Expected no help from a nil configuration.")
+	}
+}
+
+func TestServeHelp(t *testing.T) {
+	pa := &PluginAgent{configuration: &Configuration{
+		Plugins: map[string][]string{"org/repo": {"no-help"}},
+	}}
+	var testcases = []struct {
+		name     string
+		query    string
+		code     int
+		expected interface{}
+	}{
+		{
+			name:     "all",
+			code:     http.StatusOK,
+			expected: map[string]interface{}{"org/repo": map[string]interface{}{"no-help": map[string]interface{}{"description": "No help is available for this plugin."}}},
+		},
+		{
+			name:     "one repo",
+			query:    "?repo=org/repo",
+			code:     http.StatusOK,
+			expected: map[string]interface{}{"no-help": map[string]interface{}{"description": "No help is available for this plugin."}},
+		},
+		{
+			name:  "bad repo",
+			query: "?repo=org",
+			code:  http.StatusBadRequest,
+		},
+	}
+	for _, tc := range testcases {
+		w := httptest.NewRecorder()
+		pa.ServeHelp(w, httptest.NewRequest(http.MethodGet, "/plugin-help"+tc.query, nil))
+		if w.Code != tc.code {
+			t.Errorf("For case %s, expected code %d, got %d", tc.name, tc.code, w.Code)
+			continue
+		}
+		if tc.code != http.StatusOK {
+			continue
+		}
+		var got interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Errorf("For case %s, couldn't unmarshal response: %v", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("For case %s, expected %+v, got %+v", tc.name, tc.expected, got)
+		}
+	}
+}
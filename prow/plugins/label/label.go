@@ -42,8 +42,6 @@ type assignEvent struct {
 }
 
 var (
-	labelRegex              = regexp.MustCompile(`(?m)^/(area|priority|kind)\s*(.*)$`)
-	removeLabelRegex        = regexp.MustCompile(`(?m)^/remove-(area|priority|kind)\s*(.*)$`)
 	sigMatcher              = regexp.MustCompile(`(?m)@kubernetes/sig-([\w-]*)-(?:misc|test-failures|bugs|feature-requests|proposals|pr-reviews|api-reviews)`)
 	nonExistentLabel        = "These labels do not exist in this repository: `%v`"
 	nonExistentLabelOnIssue = "Those labels are not set on the issue: `%v`"
@@ -53,6 +51,39 @@ func init() {
 	plugins.RegisterIssueCommentHandler(pluginName, handleIssueComment)
 	plugins.RegisterIssueHandler(pluginName, handleIssue)
 	plugins.RegisterPullRequestHandler(pluginName, handlePullRequest)
+	plugins.RegisterHelpProvider(pluginName, helpProvider)
+}
+
+func helpProvider(c *plugins.Configuration, org, repo string) plugins.PluginHelp {
+	prefixes := c.LabelFor(org, repo).Prefixes
+	add, remove := commandRegexes(prefixes)
+	alternatives := strings.Join(prefixes, "|")
+	return plugins.PluginHelp{
+		Description: "Adds and removes labels that already exist in the repo.",
+		Commands: []plugins.Command{
+			{
+				Usage:       "/[" + alternatives + "] label [label ...]",
+				Description: "Adds <prefix>/<label> for each label.",
+				WhoCanUse:   "Anyone",
+				Regex:       add.String(),
+				Examples:    []string{"/" + prefixes[0] + " foo"},
+			},
+			{
+				Usage:       "/remove-[" + alternatives + "] label [label ...]",
+				Description: "Removes <prefix>/<label> for each label.",
+				WhoCanUse:   "Anyone",
+				Regex:       remove.String(),
+				Examples:    []string{"/remove-" + prefixes[0] + " foo"},
+			},
+			{
+				Usage:       "@kubernetes/sig-<sig>-<team>",
+				Description: "Adds the sig/<sig> label.",
+				WhoCanUse:   "Anyone",
+				Regex:       sigMatcher.String(),
+				Examples:    []string{"@kubernetes/sig-testing-bugs"},
+			},
+		},
+	}
 }
 
 type githubClient interface {
@@ -64,6 +95,19 @@ type githubClient interface {
 	BotName() string
 }
 
+// commandRegexes returns the regexes for adding and removing labels with the
+// given prefixes.
+func commandRegexes(prefixes []string) (add, remove *regexp.Regexp) {
+	var quoted []string
+	for _, p := range prefixes {
+		quoted = append(quoted, regexp.QuoteMeta(p))
+	}
+	alternatives := strings.Join(quoted, "|")
+	add = regexp.MustCompile(`(?m)^/(` + alternatives + `)\s*(.*)$`)
+	remove = regexp.MustCompile(`(?m)^/remove-(` + alternatives + `)\s*(.*)$`)
+	return add, remove
+}
+
 func handleIssueComment(pc plugins.PluginClient, ic github.IssueCommentEvent) error {
 	ae := assignEvent{
 		action:  ic.Action,
@@ -76,7 +120,7 @@ func handleIssueComment(pc plugins.PluginClient, ic github.IssueCommentEvent) er
 		issue:   ic.Issue,
 		comment: ic.Comment,
 	}
-	return handle(pc.GitHubClient, pc.Logger, ae)
+	return handle(pc.GitHubClient, pc.Logger, pc.PluginConfig.LabelFor(ae.org, ae.repo).Prefixes, ae)
 }
 
 func handleIssue(pc plugins.PluginClient, i github.IssueEvent) error {
@@ -90,7 +134,7 @@ func handleIssue(pc plugins.PluginClient, i github.IssueEvent) error {
 		number: i.Issue.Number,
 		issue:  i.Issue,
 	}
-	return handle(pc.GitHubClient, pc.Logger, ae)
+	return handle(pc.GitHubClient, pc.Logger, pc.PluginConfig.LabelFor(ae.org, ae.repo).Prefixes, ae)
 }
 
 func handlePullRequest(pc plugins.PluginClient, pr github.PullRequestEvent) error {
@@ -103,7 +147,7 @@ func handlePullRequest(pc plugins.PluginClient, pr github.PullRequestEvent) erro
 		url:    pr.PullRequest.HTMLURL,
 		number: pr.Number,
 	}
-	return handle(pc.GitHubClient, pc.Logger, ae)
+	return handle(pc.GitHubClient, pc.Logger, pc.PluginConfig.LabelFor(ae.org, ae.repo).Prefixes, ae)
 }
 
 // Get Lables from Regexp matches
@@ -117,12 +161,13 @@ func getLabelsFromREMatches(matches [][]string) (labels []string) {
 	return
 }
 
-func handle(gc githubClient, log *logrus.Entry, ae assignEvent) error {
+func handle(gc githubClient, log *logrus.Entry, prefixes []string, ae assignEvent) error {
 	// only parse newly created comments and if non bot author
 	if ae.login == gc.BotName() || ae.action != "created" {
 		return nil
 	}
 
+	labelRegex, removeLabelRegex := commandRegexes(prefixes)
 	labelMatches := labelRegex.FindAllStringSubmatch(ae.body, -1)
 	removeLabelMatches := removeLabelRegex.FindAllStringSubmatch(ae.body, -1)
 	sigMatches := sigMatcher.FindAllStringSubmatch(ae.body, -1)
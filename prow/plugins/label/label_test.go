@@ -34,6 +34,8 @@ const (
 	prNumber     = 1
 )
 
+var defaultPrefixes = []string{"area", "priority", "kind"}
+
 type testCase struct {
 	name                  string
 	body                  string
@@ -520,7 +522,7 @@ func TestLabel(t *testing.T) {
 		for i := 0; i < len(fakeRepoFunctions); i++ {
 			fakeClient, ae := fakeRepoFunctions[i](tc.body, tc.commenter, tc.repoLabels, tc.issueLabels)
 
-			if err := handle(fakeClient, logrus.WithField("plugin", pluginName), ae); err != nil {
+			if err := handle(fakeClient, logrus.WithField("plugin", pluginName), defaultPrefixes, ae); err != nil {
 				t.Errorf("For case %s, didn't expect error from label test: %v", tc.name, err)
 				return
 			}
@@ -553,3 +555,17 @@ func TestLabel(t *testing.T) {
 		}
 	}
 }
+
+func TestLabelPrefixes(t *testing.T) {
+	fakeClient, ae := getFakeRepoIssueComment("/triage needs-info\n/area infra\n/remove-triage duplicate", orgMember,
+		[]string{"triage/needs-info", "triage/duplicate", "area/infra"}, []string{"triage/duplicate"})
+	if err := handle(fakeClient, logrus.WithField("plugin", pluginName), []string{"triage"}, ae); err != nil {
+		t.Fatalf("Didn't expect error: %v", err)
+	}
+	if added := formatLabels("triage/needs-info"); fmt.Sprint(fakeClient.LabelsAdded) != fmt.Sprint(added) {
+		t.Errorf("Expected only %v to be added, got %v.", added, fakeClient.LabelsAdded)
+	}
+	if removed := formatLabels("triage/duplicate"); fmt.Sprint(fakeClient.LabelsRemoved) != fmt.Sprint(removed) {
+		t.Errorf("Expected %v to be removed, got %v.", removed, fakeClient.LabelsRemoved)
+	}
+}
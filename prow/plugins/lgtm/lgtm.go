@@ -17,6 +17,7 @@ limitations under the License.
 package lgtm
 
 import (
+	"fmt"
 	"regexp"
 
 	"github.com/Sirupsen/logrus"
@@ -28,13 +29,35 @@ import (
 const pluginName = "lgtm"
 
 var (
-	lgtmLabel    = "lgtm"
 	lgtmRe       = regexp.MustCompile(`(?mi)^\/lgtm\r?$`)
 	lgtmCancelRe = regexp.MustCompile(`(?mi)^\/lgtm cancel\r?$`)
 )
 
 func init() {
 	plugins.RegisterIssueCommentHandler(pluginName, handleIssueComment)
+	plugins.RegisterReviewEventHandler(pluginName, handleReview)
+	plugins.RegisterHelpProvider(pluginName, helpProvider)
+}
+
+func helpProvider(c *plugins.Configuration, org, repo string) plugins.PluginHelp {
+	label := c.LgtmFor(org, repo).Label
+	return plugins.PluginHelp{
+		Description: fmt.Sprintf("Adds and removes the %s label on PRs, from comments and review bodies.", label),
+		Commands: []plugins.Command{
+			{
+				Usage:       "/lgtm",
+				Description: fmt.Sprintf("Adds the %s label. Commenters who aren't assigned are assigned first.", label),
+				WhoCanUse:   fmt.Sprintf("Members of the %s org, except the PR author", org),
+				Regex:       lgtmRe.String(),
+			},
+			{
+				Usage:       "/lgtm cancel",
+				Description: fmt.Sprintf("Removes the %s label.", label),
+				WhoCanUse:   fmt.Sprintf("The PR author and members of the %s org", org),
+				Regex:       lgtmCancelRe.String(),
+			},
+		},
+	}
 }
 
 type githubClient interface {
@@ -46,10 +69,43 @@ type githubClient interface {
 }
 
 func handleIssueComment(pc plugins.PluginClient, ic github.IssueCommentEvent) error {
-	return handle(pc.GitHubClient, pc.Logger, ic)
+	cfg := pc.PluginConfig.LgtmFor(ic.Repo.Owner.Login, ic.Repo.Name)
+	return handle(pc.GitHubClient, pc.Logger, cfg.Label, ic)
+}
+
+// handleReview treats the body of a submitted review like a comment, so that
+// reviewers can /lgtm without leaving a separate comment.
+func handleReview(pc plugins.PluginClient, re github.ReviewEvent) error {
+	if re.Action != "submitted" {
+		return nil
+	}
+	cfg := pc.PluginConfig.LgtmFor(re.Repo.Owner.Login, re.Repo.Name)
+	return handle(pc.GitHubClient, pc.Logger, cfg.Label, reviewAsComment(re))
+}
+
+func reviewAsComment(re github.ReviewEvent) github.IssueCommentEvent {
+	pr := re.PullRequest
+	return github.IssueCommentEvent{
+		Action: "created",
+		Issue: github.Issue{
+			User:        pr.User,
+			Number:      pr.Number,
+			State:       pr.State,
+			HTMLURL:     pr.HTMLURL,
+			Labels:      pr.Labels,
+			Assignees:   pr.Assignees,
+			PullRequest: &struct{}{},
+		},
+		Comment: github.IssueComment{
+			Body:    re.Review.Body,
+			User:    re.Review.User,
+			HTMLURL: re.Review.HTMLURL,
+		},
+		Repo: re.Repo,
+	}
 }
 
-func handle(gc githubClient, log *logrus.Entry, ic github.IssueCommentEvent) error {
+func handle(gc githubClient, log *logrus.Entry, lgtmLabel string, ic github.IssueCommentEvent) error {
 	// Only consider open PRs.
 	if !ic.Issue.IsPullRequest() || ic.Issue.State != "open" || ic.Action != "created" {
 		return nil
@@ -84,7 +140,7 @@ func handle(gc githubClient, log *logrus.Entry, ic github.IssueCommentEvent) err
 		if err := gc.AssignIssue(org, repo, number, []string{commentAuthor}); err != nil {
 			msg := "assigning you to the PR failed"
 			if ok, merr := gc.IsMember(org, commentAuthor); merr == nil && !ok {
-				msg = "only kubernetes org members may be assigned issues"
+				msg = fmt.Sprintf("only %s org members may be assigned issues", org)
 			} else if merr != nil {
 				log.WithError(merr).Errorf("Failed IsMember(%s, %s)", org, commentAuthor)
 			} else {
@@ -26,6 +26,8 @@ import (
 	"k8s.io/test-infra/prow/github/fakegithub"
 )
 
+const lgtmLabel = "lgtm"
+
 func TestLGTMComment(t *testing.T) {
 	// "a" is the author, "a", "r1", and "r2" are reviewers.
 	var testcases = []struct {
@@ -165,7 +167,7 @@ func TestLGTMComment(t *testing.T) {
 		if tc.hasLGTM {
 			ice.Issue.Labels = []github.Label{{Name: lgtmLabel}}
 		}
-		if err := handle(fc, logrus.WithField("plugin", pluginName), ice); err != nil {
+		if err := handle(fc, logrus.WithField("plugin", pluginName), lgtmLabel, ice); err != nil {
 			t.Errorf("For case %s, didn't expect error from lgtmComment: %v", tc.name, err)
 			continue
 		}
@@ -207,3 +209,67 @@ func TestLGTMComment(t *testing.T) {
 		}
 	}
 }
+
+func TestLGTMReview(t *testing.T) {
+	var testcases = []struct {
+		name         string
+		body         string
+		state        string
+		hasLGTM      bool
+		shouldToggle bool
+	}{
+		{
+			name:         "lgtm in review body",
+			body:         "Looks great.\n/lgtm",
+			state:        "open",
+			shouldToggle: true,
+		},
+		{
+			name:         "lgtm cancel in review body",
+			body:         "/lgtm cancel",
+			state:        "open",
+			hasLGTM:      true,
+			shouldToggle: true,
+		},
+		{
+			name:  "review without a command",
+			body:  "Looks great.",
+			state: "open",
+		},
+		{
+			name:  "lgtm on a closed PR",
+			body:  "/lgtm",
+			state: "closed",
+		},
+	}
+	for _, tc := range testcases {
+		fc := &fakegithub.FakeClient{
+			IssueComments: make(map[int][]github.IssueComment),
+		}
+		re := github.ReviewEvent{
+			Action: "submitted",
+			Review: github.Review{
+				Body:  tc.body,
+				User:  github.User{Login: "r1"},
+				State: github.ReviewStateApproved,
+			},
+			PullRequest: github.PullRequest{
+				User:      github.User{Login: "a"},
+				Number:    5,
+				State:     tc.state,
+				Assignees: []github.User{{Login: "r1"}},
+			},
+		}
+		if tc.hasLGTM {
+			re.PullRequest.Labels = []github.Label{{Name: lgtmLabel}}
+		}
+		if err := handle(fc, logrus.WithField("plugin", pluginName), lgtmLabel, reviewAsComment(re)); err != nil {
+			t.Errorf("For case %s, didn't expect error: %v", tc.name, err)
+			continue
+		}
+		toggled := len(fc.LabelsAdded) > 0 || len(fc.LabelsRemoved) > 0
+		if toggled != tc.shouldToggle {
+			t.Errorf("For case %s, expected toggle %t, added %v and removed %v.", tc.name, tc.shouldToggle, fc.LabelsAdded, fc.LabelsRemoved)
+		}
+	}
+}
@@ -17,14 +17,14 @@ limitations under the License.
 package plugins
 
 import (
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"strings"
+	"net/http"
 	"sync"
-	"time"
 
 	"github.com/Sirupsen/logrus"
-	"github.com/ghodss/yaml"
 
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/github"
@@ -38,6 +38,13 @@ var (
 	pullRequestHandlers  = map[string]PullRequestHandler{}
 	pushEventHandlers    = map[string]PushEventHandler{}
 	statusEventHandlers  = map[string]StatusEventHandler{}
+
+	reviewEventHandlers        = map[string]ReviewEventHandler{}
+	reviewCommentEventHandlers = map[string]ReviewCommentEventHandler{}
+	createEventHandlers        = map[string]CreateEventHandler{}
+	deleteEventHandlers        = map[string]DeleteEventHandler{}
+	releaseEventHandlers       = map[string]ReleaseEventHandler{}
+	checkRunEventHandlers      = map[string]CheckRunEventHandler{}
 )
 
 type IssueHandler func(PluginClient, github.IssueEvent) error
@@ -66,6 +73,7 @@ type PluginClient struct {
 	GitHubClient *github.Client
 	KubeClient   *kube.Client
 	Config       *config.Config
+	PluginConfig *Configuration
 	Logger       *logrus.Entry
 }
 
@@ -83,67 +91,117 @@ func RegisterPushEventHandler(name string, fn PushEventHandler) {
 	pushEventHandlers[name] = fn
 }
 
+type ReviewEventHandler func(PluginClient, github.ReviewEvent) error
+
+func RegisterReviewEventHandler(name string, fn ReviewEventHandler) {
+	allPlugins[name] = struct{}{}
+	reviewEventHandlers[name] = fn
+}
+
+type ReviewCommentEventHandler func(PluginClient, github.ReviewCommentEvent) error
+
+func RegisterReviewCommentEventHandler(name string, fn ReviewCommentEventHandler) {
+	allPlugins[name] = struct{}{}
+	reviewCommentEventHandlers[name] = fn
+}
+
+type CreateEventHandler func(PluginClient, github.CreateEvent) error
+
+func RegisterCreateEventHandler(name string, fn CreateEventHandler) {
+	allPlugins[name] = struct{}{}
+	createEventHandlers[name] = fn
+}
+
+type DeleteEventHandler func(PluginClient, github.DeleteEvent) error
+
+func RegisterDeleteEventHandler(name string, fn DeleteEventHandler) {
+	allPlugins[name] = struct{}{}
+	deleteEventHandlers[name] = fn
+}
+
+type ReleaseEventHandler func(PluginClient, github.ReleaseEvent) error
+
+func RegisterReleaseEventHandler(name string, fn ReleaseEventHandler) {
+	allPlugins[name] = struct{}{}
+	releaseEventHandlers[name] = fn
+}
+
+type CheckRunEventHandler func(PluginClient, github.CheckRunEvent) error
+
+func RegisterCheckRunEventHandler(name string, fn CheckRunEventHandler) {
+	allPlugins[name] = struct{}{}
+	checkRunEventHandlers[name] = fn
+}
+
 type PluginAgent struct {
 	PluginClient
 
-	mut sync.Mutex
-	// Repo (eg "k/k") -> list of handler names.
-	ps map[string][]string
+	mut           sync.Mutex
+	configuration *Configuration
+
+	reloader *config.Reloader
 }
 
-// Load attempts to load config from the path. It returns an error if either
-// the file can't be read or it contains an unknown plugin.
+// Load attempts to load config from the path. It returns an error if the file
+// can't be read, contains an unknown plugin or has invalid plugin settings.
 func (pa *PluginAgent) Load(path string) error {
-	pa.mut.Lock()
-	defer pa.mut.Unlock()
+	_, err := pa.load(path)
+	return err
+}
+
+// load is Load that also returns the version of the config, which is a hash
+// of its contents.
+func (pa *PluginAgent) load(path string) (string, error) {
 	b, err := ioutil.ReadFile(path)
 	if err != nil {
-		return err
-	}
-	np := map[string][]string{}
-	if err := yaml.Unmarshal(b, &np); err != nil {
-		return err
-	}
-	// Check that there are no plugins that we don't know about.
-	for _, v := range np {
-		for _, p := range v {
-			if _, ok := allPlugins[p]; !ok {
-				return fmt.Errorf("unknown plugin: %s", p)
-			}
-		}
+		return "", err
 	}
-	// Check that there are no duplicates.
-	for k, v := range np {
-		if strings.Contains(k, "/") {
-			org := strings.Split(k, "/")[0]
-			for _, p1 := range v {
-				for _, p2 := range np[org] {
-					if p1 == p2 {
-						return fmt.Errorf("plugin %s is duplicated for %s and %s", p1, k, org)
-					}
-				}
-			}
-		}
+	c, err := parseConfiguration(b)
+	if err != nil {
+		return "", err
 	}
-	pa.ps = np
-	return nil
+	pa.mut.Lock()
+	defer pa.mut.Unlock()
+	pa.configuration = c
+	return fmt.Sprintf("%x", sha256.Sum256(b))[:12], nil
+}
+
+// Config returns the current plugin configuration. Callers must not modify
+// it.
+func (pa *PluginAgent) Config() *Configuration {
+	pa.mut.Lock()
+	defer pa.mut.Unlock()
+	return pa.configuration
 }
 
-// Start starts polling path for plugin config. If the first attempt fails,
-// then start returns the error. Future errors will halt updates but not stop.
+// Start loads the plugin config at path and reloads it whenever it changes.
+// If the first attempt fails, then start returns the error. Future errors
+// will halt updates but not stop.
 func (pa *PluginAgent) Start(path string) error {
-	if err := pa.Load(path); err != nil {
-		return err
-	}
-	ticker := time.Tick(1 * time.Minute)
-	go func() {
-		for range ticker {
-			if err := pa.Load(path); err != nil {
-				logrus.WithField("path", path).WithError(err).Error("Error loading plugin config.")
-			}
-		}
-	}()
-	return nil
+	pa.reloader = config.NewReloader("plugins", []string{path}, func() (string, error) {
+		return pa.load(path)
+	})
+	return pa.reloader.Start()
+}
+
+// ServeHTTP serves the load status and the enabled plugins as JSON.
+func (pa *PluginAgent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var status config.LoadStatus
+	if pa.reloader != nil {
+		status = pa.reloader.Status()
+	}
+	pa.mut.Lock()
+	b, err := json.MarshalIndent(struct {
+		Status config.LoadStatus `json:"status"`
+		Config *Configuration    `json:"config"`
+	}{status, pa.configuration}, "", "  ")
+	pa.mut.Unlock()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error marshaling plugins: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
 }
 
 // IssueHandlers returns a map of plugin names to handlers for the repo.
@@ -220,13 +278,106 @@ func (pa *PluginAgent) PushEventHandlers(owner, repo string) map[string]PushEven
 	return hs
 }
 
+// ReviewEventHandlers returns a map of plugin names to handlers for the repo.
+func (pa *PluginAgent) ReviewEventHandlers(owner, repo string) map[string]ReviewEventHandler {
+	pa.mut.Lock()
+	defer pa.mut.Unlock()
+
+	hs := map[string]ReviewEventHandler{}
+	for _, p := range pa.getPlugins(owner, repo) {
+		if h, ok := reviewEventHandlers[p]; ok {
+			hs[p] = h
+		}
+	}
+
+	return hs
+}
+
+// ReviewCommentEventHandlers returns a map of plugin names to handlers for the repo.
+func (pa *PluginAgent) ReviewCommentEventHandlers(owner, repo string) map[string]ReviewCommentEventHandler {
+	pa.mut.Lock()
+	defer pa.mut.Unlock()
+
+	hs := map[string]ReviewCommentEventHandler{}
+	for _, p := range pa.getPlugins(owner, repo) {
+		if h, ok := reviewCommentEventHandlers[p]; ok {
+			hs[p] = h
+		}
+	}
+
+	return hs
+}
+
+// CreateEventHandlers returns a map of plugin names to handlers for the repo.
+func (pa *PluginAgent) CreateEventHandlers(owner, repo string) map[string]CreateEventHandler {
+	pa.mut.Lock()
+	defer pa.mut.Unlock()
+
+	hs := map[string]CreateEventHandler{}
+	for _, p := range pa.getPlugins(owner, repo) {
+		if h, ok := createEventHandlers[p]; ok {
+			hs[p] = h
+		}
+	}
+
+	return hs
+}
+
+// DeleteEventHandlers returns a map of plugin names to handlers for the repo.
+func (pa *PluginAgent) DeleteEventHandlers(owner, repo string) map[string]DeleteEventHandler {
+	pa.mut.Lock()
+	defer pa.mut.Unlock()
+
+	hs := map[string]DeleteEventHandler{}
+	for _, p := range pa.getPlugins(owner, repo) {
+		if h, ok := deleteEventHandlers[p]; ok {
+			hs[p] = h
+		}
+	}
+
+	return hs
+}
+
+// ReleaseEventHandlers returns a map of plugin names to handlers for the repo.
+func (pa *PluginAgent) ReleaseEventHandlers(owner, repo string) map[string]ReleaseEventHandler {
+	pa.mut.Lock()
+	defer pa.mut.Unlock()
+
+	hs := map[string]ReleaseEventHandler{}
+	for _, p := range pa.getPlugins(owner, repo) {
+		if h, ok := releaseEventHandlers[p]; ok {
+			hs[p] = h
+		}
+	}
+
+	return hs
+}
+
+// CheckRunEventHandlers returns a map of plugin names to handlers for the repo.
+func (pa *PluginAgent) CheckRunEventHandlers(owner, repo string) map[string]CheckRunEventHandler {
+	pa.mut.Lock()
+	defer pa.mut.Unlock()
+
+	hs := map[string]CheckRunEventHandler{}
+	for _, p := range pa.getPlugins(owner, repo) {
+		if h, ok := checkRunEventHandlers[p]; ok {
+			hs[p] = h
+		}
+	}
+
+	return hs
+}
+
 // getPlugins returns a list of plugins that are enabled on a given (org, repository).
 func (pa *PluginAgent) getPlugins(owner, repo string) []string {
 	var plugins []string
 
+	if pa.configuration == nil {
+		return nil
+	}
 	fullName := fmt.Sprintf("%s/%s", owner, repo)
-	plugins = append(plugins, pa.ps[owner]...)
-	plugins = append(plugins, pa.ps[fullName]...)
+	plugins = append(plugins, pa.configuration.Plugins[owner]...)
+	plugins = append(plugins, pa.configuration.Plugins[fullName]...)
 
 	return plugins
 }
@@ -70,7 +70,7 @@ func TestGetPlugins(t *testing.T) {
 	}
 	for _, tc := range testcases {
 		pa := PluginAgent{}
-		pa.ps = tc.pluginMap
+		pa.configuration = &Configuration{Plugins: tc.pluginMap}
 
 		plugins := pa.getPlugins(tc.owner, tc.repo)
 		if len(plugins) != len(tc.expectedPlugins) {
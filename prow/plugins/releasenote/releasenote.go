@@ -49,6 +49,27 @@ var (
 
 func init() {
 	plugins.RegisterIssueCommentHandler(pluginName, handleIssueComment)
+	plugins.RegisterHelpProvider(pluginName, helpProvider)
+}
+
+func helpProvider(c *plugins.Configuration, org, repo string) plugins.PluginHelp {
+	return plugins.PluginHelp{
+		Description: "Labels PRs according to whether they need a release note.",
+		Commands: []plugins.Command{
+			{
+				Usage:       "/release-note",
+				Description: "Adds the " + releaseNote + " label.",
+				WhoCanUse:   "The PR author and org members",
+				Regex:       releaseNoteRe.String(),
+			},
+			{
+				Usage:       "/release-note-none",
+				Description: "Adds the " + releaseNoteNone + " label.",
+				WhoCanUse:   "The PR author and org members",
+				Regex:       releaseNoteNoneRe.String(),
+			},
+		},
+	}
 }
 
 type githubClient interface {
@@ -31,6 +31,19 @@ var reopenRe = regexp.MustCompile(`(?mi)^\/reopen\r?$`)
 
 func init() {
 	plugins.RegisterIssueCommentHandler(pluginName, handleIssueComment)
+	plugins.RegisterHelpProvider(pluginName, helpProvider)
+}
+
+func helpProvider(c *plugins.Configuration, org, repo string) plugins.PluginHelp {
+	return plugins.PluginHelp{
+		Description: "Reopens closed issues.",
+		Commands: []plugins.Command{{
+			Usage:       "/reopen",
+			Description: "Reopens the issue.",
+			WhoCanUse:   "Authors and assignees",
+			Regex:       reopenRe.String(),
+		}},
+	}
 }
 
 type githubClient interface {
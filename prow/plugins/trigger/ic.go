@@ -26,7 +26,15 @@ import (
 	"k8s.io/test-infra/prow/plugins"
 )
 
-var okToTest = regexp.MustCompile(`(?m)^(@k8s-bot )?ok to test\r?$`)
+// legacyBotName is the name that "ok to test" has always been addressed to.
+// Comments addressed to it still count whatever the bot is called.
+const legacyBotName = "k8s-bot"
+
+// okToTestRe matches "ok to test" on its own line, optionally addressed to
+// the bot by name or as @k8s-bot.
+func okToTestRe(botName string) *regexp.Regexp {
+	return regexp.MustCompile(`(?m)^(@(` + legacyBotName + `|` + regexp.QuoteMeta(botName) + `) )?ok to test\r?$`)
+}
 
 func handleIC(c client, ic github.IssueCommentEvent) error {
 	org := ic.Repo.Owner.Login
@@ -45,9 +53,11 @@ func handleIC(c client, ic github.IssueCommentEvent) error {
 		return nil
 	}
 	// Skip bot comments.
-	if commentAuthor == c.GitHubClient.BotName() {
+	botName := c.GitHubClient.BotName()
+	if commentAuthor == botName {
 		return nil
 	}
+	okToTest := okToTestRe(botName)
 
 	if okToTest.MatchString(ic.Comment.Body) && ic.Issue.HasLabel(needsOkToTest) {
 		if err := c.GitHubClient.RemoveLabel(ic.Repo.Owner.Login, ic.Repo.Name, ic.Issue.Number, needsOkToTest); err != nil {
@@ -66,11 +76,12 @@ func handleIC(c client, ic github.IssueCommentEvent) error {
 	}
 
 	// Skip untrusted users.
+	trustedOrg := c.PluginConfig.TriggerFor(org, repo).TrustedOrg
 	orgMember, err := c.GitHubClient.IsMember(trustedOrg, commentAuthor)
 	if err != nil {
 		return err
 	} else if !orgMember {
-		trusted, err := trustedPullRequest(c.GitHubClient, *pr)
+		trusted, err := trustedPullRequest(c.GitHubClient, *pr, trustedOrg)
 		if err != nil {
 			return err
 		}
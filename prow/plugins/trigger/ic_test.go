@@ -95,6 +95,22 @@ func TestHandleIssueComment(t *testing.T) {
 			IsPR:        true,
 			ShouldBuild: true,
 		},
+		// Trusted member's ok to test addressed to the bot by name.
+		{
+			Author:      "t",
+			Body:        "@k8s-ci-robot ok to test",
+			State:       "open",
+			IsPR:        true,
+			ShouldBuild: true,
+		},
+		// Trusted member's legacy ok to test.
+		{
+			Author:      "t",
+			Body:        "@k8s-bot ok to test",
+			State:       "open",
+			IsPR:        true,
+			ShouldBuild: true,
+		},
 		// Trusted member's not ok to test.
 		{
 			Author:      "t",
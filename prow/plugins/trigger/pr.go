@@ -30,6 +30,9 @@ const (
 )
 
 func handlePR(c client, pr github.PullRequestEvent) error {
+	org := pr.PullRequest.Base.Repo.Owner.Login
+	repo := pr.PullRequest.Base.Repo.Name
+	trustedOrg := c.PluginConfig.TriggerFor(org, repo).TrustedOrg
 	switch pr.Action {
 	case "opened":
 		// When a PR is opened, if the author is in the org then build it.
@@ -43,7 +46,7 @@ func handlePR(c client, pr github.PullRequestEvent) error {
 			return buildAll(c, pr.PullRequest)
 		} else {
 			c.Logger.Info("Asking PR author to join the org.")
-			if err := askToJoin(c.GitHubClient, pr.PullRequest); err != nil {
+			if err := askToJoin(c.GitHubClient, pr.PullRequest, trustedOrg); err != nil {
 				return fmt.Errorf("could not ask to join: %s", err)
 			}
 		}
@@ -51,7 +54,7 @@ func handlePR(c client, pr github.PullRequestEvent) error {
 		// When a PR is updated, check that the user is in the org or that an org
 		// member has said "ok to test" before building. There's no need to ask
 		// for "ok to test" because we do that once when the PR is created.
-		trusted, err := trustedPullRequest(c.GitHubClient, pr.PullRequest)
+		trusted, err := trustedPullRequest(c.GitHubClient, pr.PullRequest, trustedOrg)
 		if err != nil {
 			return fmt.Errorf("could not validate PR: %s", err)
 		} else if trusted {
@@ -60,8 +63,8 @@ func handlePR(c client, pr github.PullRequestEvent) error {
 		}
 	case "labeled":
 		// When a PR is LGTMd, if it is untrusted then build it once.
-		if pr.Label.Name == lgtmLabel {
-			trusted, err := trustedPullRequest(c.GitHubClient, pr.PullRequest)
+		if pr.Label.Name == c.PluginConfig.LgtmFor(org, repo).Label {
+			trusted, err := trustedPullRequest(c.GitHubClient, pr.PullRequest, trustedOrg)
 			if err != nil {
 				return fmt.Errorf("could not validate PR: %s", err)
 			} else if !trusted {
@@ -73,17 +76,17 @@ func handlePR(c client, pr github.PullRequestEvent) error {
 	return nil
 }
 
-func askToJoin(ghc githubClient, pr github.PullRequest) error {
+func askToJoin(ghc githubClient, pr github.PullRequest, trustedOrg string) error {
 	commentTemplate := `Hi @%s. Thanks for your PR.
 
-I'm waiting for a [%s](https://github.com/orgs/%s/people) member to verify that this patch is reasonable to test. If it is, they should reply with ` + "`@k8s-bot ok to test`" + ` on its own line. Until that is done, I will not automatically test new commits in this PR, but the usual testing commands by org members will still work. Regular contributors should join the org to skip this step.
+I'm waiting for a [%s](https://github.com/orgs/%s/people) member to verify that this patch is reasonable to test. If it is, they should reply with ` + "`@%s ok to test`" + ` on its own line. Until that is done, I will not automatically test new commits in this PR, but the usual testing commands by org members will still work. Regular contributors should join the org to skip this step.
 
 <details>
 
 %s
 </details>
 `
-	comment := fmt.Sprintf(commentTemplate, pr.User.Login, trustedOrg, trustedOrg, plugins.AboutThisBot)
+	comment := fmt.Sprintf(commentTemplate, pr.User.Login, trustedOrg, trustedOrg, ghc.BotName(), plugins.AboutThisBot)
 
 	owner := pr.Base.Repo.Owner.Login
 	name := pr.Base.Repo.Name
@@ -96,9 +99,9 @@ I'm waiting for a [%s](https://github.com/orgs/%s/people) member to verify that
 }
 
 // trustedPullRequest returns whether or not the given PR should be tested.
-// It first checks if the author is in the org, then looks for "ok to test
-// comments by org members.
-func trustedPullRequest(ghc githubClient, pr github.PullRequest) (bool, error) {
+// It first checks if the author is in the trusted org, then looks for "ok to
+// test" comments by its members.
+func trustedPullRequest(ghc githubClient, pr github.PullRequest, trustedOrg string) (bool, error) {
 	author := pr.User.Login
 	// First check if the author is a member of the org.
 	orgMember, err := ghc.IsMember(trustedOrg, author)
@@ -108,6 +111,8 @@ func trustedPullRequest(ghc githubClient, pr github.PullRequest) (bool, error) {
 		return true, nil
 	}
 	// Next look for "ok to test" comments on the PR.
+	botName := ghc.BotName()
+	okToTest := okToTestRe(botName)
 	comments, err := ghc.ListIssueComments(pr.Base.Repo.Owner.Login, pr.Base.Repo.Name, pr.Number)
 	if err != nil {
 		return false, err
@@ -119,7 +124,7 @@ func trustedPullRequest(ghc githubClient, pr github.PullRequest) (bool, error) {
 			continue
 		}
 		// Skip bot comments.
-		if commentAuthor == ghc.BotName() {
+		if commentAuthor == botName {
 			continue
 		}
 		// Look for "ok to test"
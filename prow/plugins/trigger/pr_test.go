@@ -17,6 +17,7 @@ limitations under the License.
 package trigger
 
 import (
+	"strings"
 	"testing"
 
 	"k8s.io/test-infra/prow/github"
@@ -82,6 +83,19 @@ func TestTrusted(t *testing.T) {
 			},
 			Trusted: true,
 		},
+		// Non org member, ok to test comment to the bot by name by org member.
+		{
+			PR: github.PullRequest{
+				User: github.User{Login: "u"},
+			},
+			Comments: []github.IssueComment{
+				{
+					Body: "@k8s-ci-robot ok to test",
+					User: github.User{Login: "t1"},
+				},
+			},
+			Trusted: true,
+		},
 		// Non org member, multiline ok to test comment by org member.
 		{
 			PR: github.PullRequest{
@@ -142,7 +156,7 @@ func TestTrusted(t *testing.T) {
 				0: tc.Comments,
 			},
 		}
-		trusted, err := trustedPullRequest(g, tc.PR)
+		trusted, err := trustedPullRequest(g, tc.PR, "kubernetes")
 		if err != nil {
 			t.Fatalf("Didn't expect error: %s", err)
 		}
@@ -151,3 +165,26 @@ func TestTrusted(t *testing.T) {
 		}
 	}
 }
+
+func TestAskToJoin(t *testing.T) {
+	g := &fakegithub.FakeClient{IssueComments: map[int][]github.IssueComment{}}
+	pr := github.PullRequest{
+		Number: 5,
+		User:   github.User{Login: "u"},
+		Base: github.PullRequestBranch{
+			Repo: github.Repo{
+				Owner: github.User{Login: "org"},
+				Name:  "repo",
+			},
+		},
+	}
+	if err := askToJoin(g, pr, "org"); err != nil {
+		t.Fatalf("Didn't expect error: %s", err)
+	}
+	if len(g.IssueComments[5]) != 1 {
+		t.Fatalf("Expected one comment, got %+v", g.IssueComments[5])
+	}
+	if body := g.IssueComments[5][0].Body; !strings.Contains(body, "`@k8s-ci-robot ok to test`") {
+		t.Errorf("Expected the comment to ask for ok to test from k8s-ci-robot, got %q", body)
+	}
+}
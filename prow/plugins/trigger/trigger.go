@@ -17,6 +17,8 @@ limitations under the License.
 package trigger
 
 import (
+	"fmt"
+
 	"github.com/Sirupsen/logrus"
 
 	"k8s.io/test-infra/prow/config"
@@ -25,16 +27,35 @@ import (
 	"k8s.io/test-infra/prow/plugins"
 )
 
-const (
-	pluginName = "trigger"
-	lgtmLabel  = "lgtm"
-	trustedOrg = "kubernetes"
-)
+const pluginName = "trigger"
 
 func init() {
 	plugins.RegisterIssueCommentHandler(pluginName, handleIssueComment)
 	plugins.RegisterPullRequestHandler(pluginName, handlePullRequest)
 	plugins.RegisterPushEventHandler(pluginName, handlePush)
+	plugins.RegisterHelpProvider(pluginName, helpProvider)
+}
+
+func helpProvider(c *plugins.Configuration, org, repo string) plugins.PluginHelp {
+	trustedOrg := c.TriggerFor(org, repo).TrustedOrg
+	return plugins.PluginHelp{
+		Description: fmt.Sprintf("Starts presubmit jobs for PRs from members of the %s org, and for other PRs once a member says they are ok to test. Each job's trigger is configured in config.yaml.", trustedOrg),
+		Commands: []plugins.Command{
+			{
+				Usage:       "@k8s-bot [job] test this",
+				Description: "Runs the jobs whose trigger matches the comment.",
+				WhoCanUse:   fmt.Sprintf("Members of the %s org, and anyone on a PR that is ok to test", trustedOrg),
+				Regex:       "Set by each job's trigger in config.yaml.",
+				Examples:    []string{"@k8s-bot test this", "@k8s-bot unit test this"},
+			},
+			{
+				Usage:       "@k8s-bot ok to test",
+				Description: "Marks a PR from outside the org as safe to test, and runs its jobs. The comment may also be addressed to the bot by name.",
+				WhoCanUse:   fmt.Sprintf("Members of the %s org", trustedOrg),
+				Regex:       okToTestRe(legacyBotName).String(),
+			},
+		},
+	}
 }
 
 type githubClient interface {
@@ -58,6 +79,7 @@ type client struct {
 	GitHubClient githubClient
 	KubeClient   kubeClient
 	Config       *config.Config
+	PluginConfig *plugins.Configuration
 	Logger       *logrus.Entry
 }
 
@@ -65,6 +87,7 @@ func getClient(pc plugins.PluginClient) client {
 	return client{
 		GitHubClient: pc.GitHubClient,
 		Config:       pc.Config,
+		PluginConfig: pc.PluginConfig,
 		KubeClient:   pc.KubeClient,
 		Logger:       pc.Logger,
 	}
@@ -40,6 +40,19 @@ const (
 
 func init() {
 	plugins.RegisterIssueCommentHandler(pluginName, handleIssueComment)
+	plugins.RegisterHelpProvider(pluginName, helpProvider)
+}
+
+func helpProvider(c *plugins.Configuration, org, repo string) plugins.PluginHelp {
+	return plugins.PluginHelp{
+		Description: "Tells jokes.",
+		Commands: []plugins.Command{{
+			Usage:       "@k8s-bot tell me a joke",
+			Description: "Replies with a joke, sometimes a bad one.",
+			WhoCanUse:   "Anyone",
+			Regex:       match.String(),
+		}},
+	}
 }
 
 type githubClient interface {
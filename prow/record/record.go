@@ -0,0 +1,247 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package record archives GitHub webhooks so that they can be replayed
+// later, for instance by phony against a local hook.
+package record
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Headers that are worth keeping. The signature is left out since replays
+// are signed again with the replaying hook's secret.
+var recordedHeaders = []string{
+	"X-GitHub-Event",
+	"X-GitHub-Delivery",
+	"User-Agent",
+}
+
+// Record is one webhook.
+type Record struct {
+	Time    time.Time         `json:"time"`
+	Event   string            `json:"event"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Payload json.RawMessage   `json:"payload"`
+}
+
+// Recorder appends webhooks to files of JSON records in a directory. Once a
+// file reaches MaxBytes it starts a new one, and once there are more than
+// MaxFiles it deletes the oldest. Payloads can come from private repos, so
+// only the user hook runs as can read the files.
+type Recorder struct {
+	Dir      string
+	MaxBytes int64
+	MaxFiles int
+
+	mut  sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// Archive files are named after the time they were started so that they
+// sort in order.
+const (
+	filePrefix = "webhooks-"
+	fileSuffix = ".json"
+	timeFormat = "20060102T150405.000000000"
+)
+
+// Record archives the webhook.
+func (r *Recorder) Record(eventType string, header http.Header, payload []byte) error {
+	rec := Record{
+		Time:    time.Now().UTC(),
+		Event:   eventType,
+		Headers: map[string]string{},
+		Payload: payload,
+	}
+	for _, h := range recordedHeaders {
+		if v := header.Get(h); v != "" {
+			rec.Headers[h] = v
+		}
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("error marshaling record: %v", err)
+	}
+	b = append(b, '\n')
+
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if r.f == nil || (r.MaxBytes > 0 && r.size+int64(len(b)) > r.MaxBytes && r.size > 0) {
+		if err := r.rotate(rec.Time); err != nil {
+			return err
+		}
+	}
+	n, err := r.f.Write(b)
+	r.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("error writing record: %v", err)
+	}
+	return nil
+}
+
+// rotate starts a new file and deletes the oldest ones if there are too
+// many.
+func (r *Recorder) rotate(now time.Time) error {
+	if r.f != nil {
+		r.f.Close()
+		r.f = nil
+	}
+	if err := os.MkdirAll(r.Dir, 0700); err != nil {
+		return fmt.Errorf("error making %s: %v", r.Dir, err)
+	}
+	path := filepath.Join(r.Dir, filePrefix+now.Format(timeFormat)+fileSuffix)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %v", path, err)
+	}
+	r.f = f
+	r.size = 0
+	if r.MaxFiles <= 0 {
+		return nil
+	}
+	files, err := archiveFiles(r.Dir)
+	if err != nil {
+		return err
+	}
+	for len(files) > r.MaxFiles {
+		if err := os.Remove(files[0]); err != nil {
+			return fmt.Errorf("error removing %s: %v", files[0], err)
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// Close closes the current file.
+func (r *Recorder) Close() error {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+func archiveFiles(dir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", dir, err)
+	}
+	var files []string
+	for _, info := range infos {
+		if !info.IsDir() && strings.HasPrefix(info.Name(), filePrefix) && strings.HasSuffix(info.Name(), fileSuffix) {
+			files = append(files, filepath.Join(dir, info.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Load reads the records in path, which is either a single archive file or a
+// directory of them, in the order they were recorded.
+func Load(path string) ([]Record, error) {
+	files := []string{path}
+	if fi, err := os.Stat(path); err != nil {
+		return nil, err
+	} else if fi.IsDir() {
+		if files, err = archiveFiles(path); err != nil {
+			return nil, err
+		}
+	}
+	var recs []Record
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		dec := json.NewDecoder(f)
+		for {
+			var rec Record
+			if err := dec.Decode(&rec); err == io.EOF {
+				break
+			} else if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("error reading %s: %v", file, err)
+			}
+			recs = append(recs, rec)
+		}
+		f.Close()
+	}
+	sort.SliceStable(recs, func(i, j int) bool { return recs[i].Time.Before(recs[j].Time) })
+	return recs, nil
+}
+
+// Filter selects records. Zero fields match everything.
+type Filter struct {
+	// Repo is the full name of a repo, such as "kubernetes/test-infra".
+	Repo string
+	// PR is a pull request or issue number.
+	PR    int
+	Since time.Time
+	Until time.Time
+}
+
+// The parts of any webhook payload that filters look at.
+type payloadSummary struct {
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Number      int `json:"number"`
+	PullRequest struct {
+		Number int `json:"number"`
+	} `json:"pull_request"`
+	Issue struct {
+		Number int `json:"number"`
+	} `json:"issue"`
+}
+
+// Match returns true if the filter selects the record.
+func (f Filter) Match(rec Record) bool {
+	if !f.Since.IsZero() && rec.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && rec.Time.After(f.Until) {
+		return false
+	}
+	if f.Repo == "" && f.PR == 0 {
+		return true
+	}
+	var s payloadSummary
+	if err := json.Unmarshal(rec.Payload, &s); err != nil {
+		return false
+	}
+	if f.Repo != "" && !strings.EqualFold(s.Repository.FullName, f.Repo) {
+		return false
+	}
+	if f.PR != 0 && s.Number != f.PR && s.PullRequest.Number != f.PR && s.Issue.Number != f.PR {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package record
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRecorderRotates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "record")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := &Recorder{Dir: dir, MaxBytes: 200, MaxFiles: 3}
+	for i := 0; i < 20; i++ {
+		h := http.Header{}
+		h.Set("X-GitHub-Delivery", fmt.Sprintf("delivery-%d", i))
+		h.Set("X-Hub-Signature", "sha1=secret")
+		if err := r.Record("issues", h, []byte(fmt.Sprintf(`{"number":%d}`, i))); err != nil {
+			t.Fatalf("Error recording webhook %d: %v", i, err)
+		}
+	}
+	r.Close()
+
+	files, err := archiveFiles(dir)
+	if err != nil {
+		t.Fatalf("Error listing files: %v", err)
+	}
+	if len(files) != 3 {
+		t.Errorf("Expected 3 files, got %v.", files)
+	}
+	for _, f := range files {
+		if fi, err := os.Stat(f); err != nil {
+			t.Errorf("Error statting %s: %v", f, err)
+		} else if fi.Mode().Perm() != 0600 {
+			t.Errorf("Expected %s to be private, got mode %v.", f, fi.Mode())
+		}
+	}
+	recs, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Error loading records: %v", err)
+	}
+	if len(recs) == 0 || len(recs) == 20 {
+		t.Fatalf("Expected some but not all records to be kept, got %d.", len(recs))
+	}
+	// The newest records are kept, in order.
+	first := 20 - len(recs)
+	for i, rec := range recs {
+		if want := fmt.Sprintf("delivery-%d", first+i); rec.Headers["X-GitHub-Delivery"] != want {
+			t.Errorf("Expected record %d to be %s, got %v.", i, want, rec.Headers)
+		}
+		if _, ok := rec.Headers["X-Hub-Signature"]; ok {
+			t.Errorf("Expected the signature not to be recorded, got %v.", rec.Headers)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	now := time.Now()
+	rec := Record{
+		Time:    now,
+		Event:   "issue_comment",
+		Payload: []byte(`{"repository":{"full_name":"Org/Repo"},"issue":{"number":5}}`),
+	}
+	var testcases = []struct {
+		name   string
+		filter Filter
+		match  bool
+	}{
+		{
+			name:  "empty filter",
+			match: true,
+		},
+		{
+			name:   "repo",
+			filter: Filter{Repo: "org/repo"},
+			match:  true,
+		},
+		{
+			name:   "other repo",
+			filter: Filter{Repo: "org/other"},
+		},
+		{
+			name:   "issue number",
+			filter: Filter{Repo: "org/repo", PR: 5},
+			match:  true,
+		},
+		{
+			name:   "other number",
+			filter: Filter{PR: 6},
+		},
+		{
+			name:   "in range",
+			filter: Filter{Since: now.Add(-time.Minute), Until: now.Add(time.Minute)},
+			match:  true,
+		},
+		{
+			name:   "too early",
+			filter: Filter{Until: now.Add(-time.Minute)},
+		},
+		{
+			name:   "too late",
+			filter: Filter{Since: now.Add(time.Minute)},
+		},
+	}
+	for _, tc := range testcases {
+		if got := tc.filter.Match(rec); got != tc.match {
+			t.Errorf("For case %s, expected match %t, got %t.", tc.name, tc.match, got)
+		}
+	}
+}
@@ -30,14 +30,16 @@ import (
 func main() {
 	args := os.Args[1:]
 
-	if len(args) != 3 {
-		fmt.Println("Missing args - usage: go run jenkins_validate.go <path/to/job_collection> <path/to/prow> <path/to/testgrid_config>")
+	if len(args) < 3 {
+		fmt.Println("Missing args - usage: go run jenkins_validate.go <path/to/job_collection> <path/to/prow> <path/to/testgrid_config> [path/to/prow_job_configs...]")
 		os.Exit(1)
 	}
 
 	jobPath := args[0]
 	prowPath := args[1]
 	configPath := args[2]
+	// Any further args are directories or globs of prow job config files.
+	jobConfigs := args[3:]
 
 	jenkinsjobs := make(map[string]bool)
 	files, err := filepath.Glob(jobPath + "/*")
@@ -69,7 +71,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	prowConfig, err := prow_config.Load(prowPath + "/config.yaml")
+	prowConfig, err := prow_config.Load(prowPath+"/config.yaml", jobConfigs...)
 	if err != nil {
 		fmt.Printf("Could not load prow configs: %v\n", err)
 		os.Exit(1)